@@ -1,4 +1,4 @@
 // Package api contains subpackages for our API endpoints.
 //
-// The endpoints are '/bookings' and '/classes'.
+// The endpoints are '/v1/bookings', '/v1/classes' (also without the deprecated prefix), '/passes', '/promos', '/users', '/login', '/logout', and '/openapi.json'.
 package api
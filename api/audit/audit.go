@@ -0,0 +1,71 @@
+// Package audit implements the API point '/v1/audit', where admins look into who changed which courses and bookings of their studio (see package audit).
+package audit
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// DefaultLimit is how many entries are returned if no 'limit' is given, MaxLimit how many at most.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// API holds the response functions to requests to '/v1/audit', which look at the entries of its studio.
+type API struct {
+	Studio string // the ID of the studio, empty for the default studio
+}
+
+// List is the response function to a GET request to '/v1/audit'.
+//
+// It returns the entries of the studio, oldest first, optionally only those of the 'actor', of the 'action', e.g. 'class-booked', or of the 'target', e.g. 'course/3' for a course, its classes and bookings.
+// The optional 'since' and 'until' select the days.
+// If there are more entries than the 'limit', the 'Link' header points to the next page, which starts 'after' the last entry.
+func (a API) List(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	filter := audit.Filter{
+		Studio: a.Studio,
+		Actor:  req.FormValue("actor"),
+		Action: audit.Action(req.FormValue("action")),
+		Target: req.FormValue("target"),
+		Limit:  DefaultLimit,
+	}
+	if req.FormValue("since") != "" {
+		filter.Since = f.Date("since")
+	}
+	if req.FormValue("until") != "" {
+		filter.Until = f.Date("until")
+	}
+	if req.FormValue("after") != "" {
+		filter.After = f.Uint64("after")
+	}
+	if req.FormValue("limit") != "" {
+		if filter.Limit = f.Int("limit"); (filter.Limit < 1 || filter.Limit > MaxLimit) && !f.Errors.Has("limit") {
+			f.Add("limit", validation.Invalid, "limit value (%d) must be between 1 and %d", filter.Limit, MaxLimit)
+		}
+	}
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	es, more := audit.Find(filter)
+	resp := api.NewSuccessNow(http.StatusOK, es, "number of entries: %d", len(es))
+	if !more {
+		return resp
+	}
+
+	next := req.URL.Query()
+	next.Set("after", fmt.Sprint(es[len(es)-1].ID))
+	return endpoint.WithHeader{
+		Header:   http.Header{"Link": {fmt.Sprintf(`<%s?%s>; rel="next"`, req.URL.Path, next.Encode())}},
+		Response: resp,
+	}
+}
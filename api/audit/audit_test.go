@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+func TestList(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/classes", nil)
+	req = req.WithContext(tenants.NewContext(req.Context(), tenants.Studio{ID: "api-test"}))
+	for i := 0; i < 3; i++ {
+		audit.Record(req, audit.CourseCreated, audit.Course(uint64(100+i)), nil, nil)
+	}
+	a := API{Studio: "api-test"}
+
+	resp, ok := a.List(httptest.NewRequest(http.MethodGet, "/v1/audit?action=course-created", nil)).(api.Success)
+	if es := resp.Object.([]audit.Entry); !ok || len(es) != 3 || es[0].Target != "course/100" {
+		t.Errorf("wrong entries: %v", resp)
+	}
+	if other := (API{Studio: "other"}).List(httptest.NewRequest(http.MethodGet, "/v1/audit", nil)).(api.Success); len(other.Object.([]audit.Entry)) != 0 {
+		t.Errorf("entries of another studio are listed: %v", other)
+	}
+
+	// pages
+	h, ok := a.List(httptest.NewRequest(http.MethodGet, "/v1/audit?limit=2", nil)).(endpoint.WithHeader)
+	if !ok || len(h.Response.(api.Success).Object.([]audit.Entry)) != 2 {
+		t.Fatalf("expected a page of 2 entries, got: %v", h)
+	}
+	link := h.Header.Get("Link")
+	after := h.Response.(api.Success).Object.([]audit.Entry)[1].ID
+	if !strings.Contains(link, fmt.Sprintf("after=%d", after)) || !strings.HasSuffix(link, `>; rel="next"`) {
+		t.Fatalf("wrong link: %s", link)
+	}
+	next := strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+	resp, ok = a.List(httptest.NewRequest(http.MethodGet, next, nil)).(api.Success)
+	if es := resp.Object.([]audit.Entry); !ok || len(es) != 1 || es[0].ID <= after {
+		t.Errorf("wrong next page: %v", resp)
+	}
+
+	for _, q := range []string{"limit=0", "limit=1001", "limit=x", "since=yesterday", "after=-1"} {
+		if resp, ok := a.List(httptest.NewRequest(http.MethodGet, "/v1/audit?"+q, nil)).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+			t.Errorf("%s: expected a bad request, got: %v", q, resp)
+		}
+	}
+}
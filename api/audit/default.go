@@ -0,0 +1,8 @@
+package audit
+
+import "net/http"
+
+// List is the response function to a GET request for the entries of the default studio (see API.List).
+func List(req *http.Request) interface{} {
+	return API{}.List(req)
+}
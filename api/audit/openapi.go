@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/audit"
+)
+
+// Schema is the schema of the query parameters to list entries (see API.List).
+var Schema = input.Schema{
+	{Name: "actor", Type: input.String, Description: "only the changes of the user with this name"},
+	{Name: "action", Type: input.String, Description: "only changes like this, e.g. 'course-created', 'course-updated', 'class-cancelled', 'class-booked' or 'booking-cancelled'"},
+	{Name: "target", Type: input.String, Description: "only the changes of this, e.g. 'course/3' for a course, its classes and bookings, or 'course/3/2021-01-04/Arnold' for a booking"},
+	{Name: "since", Type: input.Date, Description: "only the changes from this day on"},
+	{Name: "until", Type: input.Date, Description: "only the changes until this day"},
+	{Name: "after", Type: input.Int, Description: "the ID of the last entry of the previous page"},
+	{Name: "limit", Type: input.Int, Description: "how many entries at most, 100 by default, 1000 at most"},
+}
+
+// Doc documents the endpoint '/v1/audit' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/v1/audit",
+	Operations: []openapi.Operation{
+		{
+			Method:  http.MethodGet,
+			Summary: "List the changes to courses and bookings",
+			Description: "Admins only. Who changed what and when, with the values before and after the change and the ID of the request (see the header 'X-Request-ID'), oldest first. " +
+				"If there are more entries than the limit, the 'Link' header points to the next page.",
+			Params:   Schema,
+			Response: []audit.Entry{},
+			Respond:  List,
+		},
+	},
+}
@@ -0,0 +1,198 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/plans"
+	"github.com/MarkRosemaker/booking-system/promos"
+	"github.com/MarkRosemaker/booking-system/refunds"
+	"github.com/MarkRosemaker/booking-system/rules"
+)
+
+// how long we give the payment gateway to undo a charge if the booking didn't go through
+const rollbackTimeout = 10 * time.Second
+
+// An order is a customer's request to book a class.
+type order struct {
+	course *course.Course
+	studio string // whose passes and promo codes are used
+	name   string
+	date   civil.Date
+	token  string        // the payment token, only needed for paid classes
+	promo  string        // an optional promo code
+	req    *http.Request // that made the order, for the audit log
+}
+
+// attendance is what the audit log records about a booking before and after a change.
+type attendance struct {
+	Attending bool
+	Paid      *money.Amount   `json:",omitempty"`
+	Refund    *refunds.Result `json:",omitempty"`
+}
+
+// booking returns the booking as identified in the ledger.
+func (o order) booking() ledger.Booking {
+	return ledger.Booking{Course: o.course.ID(), Member: o.name, Date: o.date}
+}
+
+// bookClass checks the rules of the course and the customer's entitlement, takes the payment if needed, then books the class.
+// The payment or used credit is recorded in the ledger, the booking in the audit log. It returns the amount paid.
+// Rules that look at other courses, e.g. the weekly limit, look at those of the registry.
+// The member's bookings are locked until the class is booked, so that bookings at the same time can't break the rules together.
+func bookClass(ctx context.Context, o order, reg *courses.Registry) (money.Amount, error) {
+	c := o.course
+
+	unlock := rules.Lock(o.name)
+	defer unlock()
+
+	if err := rules.Check(rules.Booking{
+		Course:  c,
+		Member:  o.name,
+		Date:    o.date,
+		Now:     time.Now(),
+		Courses: reg,
+	}); err != nil {
+		return money.Amount{}, api.ErrBadRequest(err)
+	}
+
+	var (
+		price   = c.PriceOn(o.date)
+		pass    plans.Pass
+		receipt *payments.Receipt
+		// undo everything if the booking doesn't go through
+		rollback []func() error
+	)
+
+	undo := func(err error) (money.Amount, error) {
+		for i := len(rollback) - 1; i >= 0; i-- {
+			if rErr := rollback[i](); rErr != nil {
+				err = fmt.Errorf("%w (and %s)", err, rErr)
+			}
+		}
+		return money.Amount{}, err
+	}
+
+	if c.MembersOnly() || !price.IsZero() {
+		var err error
+		pass, err = plans.Consume(o.studio, o.name, c.ID(), o.date)
+		switch {
+		case err == nil:
+			rollback = append(rollback, func() error {
+				_, err := plans.Release(o.name, c.ID(), o.date)
+				return err
+			})
+		case !errors.Is(err, plans.ErrNoEntitlement) || c.MembersOnly():
+			return money.Amount{}, api.ErrBadRequest(err)
+		default:
+			// customers without a pass can pay for classes that are open to everyone
+			if receipt, err = pay(ctx, o, price, &rollback); err != nil {
+				return undo(err)
+			}
+		}
+	}
+
+	if err := c.BookClass(o.name, o.date); err != nil {
+		return undo(err)
+	}
+	promos.Booked(o.studio, o.name)
+
+	paid := money.Amount{}
+	if receipt != nil {
+		paid = receipt.Charge.Amount
+	}
+	audit.Record(o.req, audit.ClassBooked, audit.Booking(c.ID(), o.date, o.name), attendance{}, attendance{Attending: true, Paid: &paid})
+
+	switch {
+	case receipt != nil:
+		ledger.Record(ledger.Entry{Booking: o.booking(), Kind: ledger.KindCharge, Amount: receipt.Charge.Amount, Receipt: receipt})
+		return receipt.Charge.Amount, nil
+	case pass.ID != 0 && !pass.Plan.Unlimited():
+		ledger.Record(ledger.Entry{Booking: o.booking(), Kind: ledger.KindCreditUsed, Pass: pass.ID})
+	}
+
+	return money.Amount{}, nil
+}
+
+// pay redeems the promo code, if any, and charges the customer for the class.
+// The steps to undo the payment are added to the rollback.
+// If the class is free after the discount, no receipt is returned.
+func pay(ctx context.Context, o order, price money.Amount, rollback *[]func() error) (*payments.Receipt, error) {
+	var err error
+
+	if o.promo != "" {
+		if price, err = promos.Redeem(o.studio, o.promo, o.name, o.course.ID(), civil.DateOf(time.Now()), price); err != nil {
+			return nil, api.ErrBadRequest(err)
+		}
+		*rollback = append(*rollback, func() error {
+			return promos.Unredeem(o.studio, o.promo, o.name)
+		})
+	}
+
+	if price.IsZero() {
+		return nil, nil
+	}
+
+	if o.token == "" {
+		return nil, api.ErrBadRequest(fmt.Errorf("the class costs %s, please provide a payment token", price))
+	}
+
+	r, err := payments.Pay(ctx, payments.Charge{
+		Member:      o.name,
+		Amount:      price,
+		Token:       o.token,
+		Description: fmt.Sprintf("%s class on %s", o.course.Name(), o.date)})
+	if err != nil {
+		if errors.Is(err, payments.ErrDeclined) {
+			return nil, api.ErrBadRequest(err)
+		}
+		return nil, err
+	}
+
+	*rollback = append(*rollback, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+		defer cancel()
+		if err := payments.Refund(ctx, r, r.Charge.Amount); err != nil {
+			return fmt.Errorf("the payment %s could not be refunded: %w", r.ID, err)
+		}
+		return nil
+	})
+
+	return &r, nil
+}
+
+// cancelClass settles the payment or credit according to the refund policy, then cancels the booking.
+// If the payment can't be refunded, the booking is not cancelled, so that the customer can try again.
+// The cancellation is recorded in the audit log with what the customer got back.
+func cancelClass(ctx context.Context, o order) (refunds.Result, error) {
+	// the member can't book the class again while it is settled
+	unlock := rules.Lock(o.name)
+	defer unlock()
+
+	if err := o.course.CanCancelBooking(o.name, o.date); err != nil {
+		return refunds.Result{}, err
+	}
+
+	res, err := refunds.Process(ctx, o.booking(), o.course.ClassStart(o.date), false)
+	if err != nil {
+		return res, err
+	}
+
+	if err := o.course.CancelBooking(o.name, o.date); err != nil {
+		return res, err
+	}
+	audit.Record(o.req, audit.BookingCancelled, audit.Booking(o.course.ID(), o.date, o.name), attendance{Attending: true}, attendance{Refund: &res})
+	return res, nil
+}
@@ -5,7 +5,13 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/MarkRosemaker/booking-system/api/input"
 	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/notify"
+	"github.com/MarkRosemaker/booking-system/refunds"
+	"github.com/MarkRosemaker/booking-system/validation"
 
 	"cloud.google.com/go/civil"
 	"github.com/MarkRosemaker/booking-system/course"
@@ -14,51 +20,164 @@ import (
 	"github.com/MarkRosemaker/go-server/server/form"
 )
 
-// Respond is the response function to an API request to '/bookings'.
+// CreateSchema is the schema of a JSON body to book a class (see Create).
+var CreateSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "date", Type: input.Date, Required: true},
+	{Name: "id", Type: input.Int, Required: true},
+	{Name: "token", Type: input.String, Description: "payment token, only needed for paid classes without a pass"},
+	{Name: "promo", Type: input.String, Description: "promo code for a discount"},
+	{Name: "email", Type: input.String, Description: "where to send the confirmation and later emails about the booking"},
+	{Name: "timeout", Type: input.String, Description: "how long to wait at most, e.g. '1s'"},
+}
+
+// CancelSchema is the schema of a JSON body to cancel a booking (see Cancel).
+var CancelSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "date", Type: input.Date, Required: true},
+	{Name: "id", Type: input.Int, Required: true},
+	{Name: "timeout", Type: input.String, Description: "how long to wait at most, e.g. '1s'"},
+}
+
+// a booking as listed by the API
+type booking struct {
+	ID     uint64 // of the course
+	Course string
+	Date   civil.Date
+}
+
+// API holds the response functions to requests to '/bookings', which act on the courses of its registry and the passes and promo codes of its studio.
+// The package-level functions, e.g. Respond, act on the default registry of the default studio.
+type API struct {
+	Studio  string // the ID of the studio, empty for the default studio
+	Courses *courses.Registry
+}
+
+// New returns the response functions to requests to '/bookings' for the courses of the registry in the default studio.
+func New(r *courses.Registry) API {
+	return API{Courses: r}
+}
+
+// Respond is the legacy response function to an API request to '/bookings', which ignores the HTTP method.
+//
+// If the 'cancel' flag is set, it cancels a booking (see Cancel), otherwise it books a class (see Create).
+func (a API) Respond(req *http.Request) interface{} {
+	cancels, err := form.GetBoolE(req, "cancel")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+	return a.respond(req, cancels)
+}
+
+// List is the response function to a GET request to '/bookings'.
+//
+// It returns the classes the person with the given 'name' has booked, sorted by the start date of the course.
+func (a API) List(req *http.Request) interface{} {
+	name, err := form.GetStringE(req, "name")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	bs := make([]booking, 0)
+	for _, c := range a.Courses.All() {
+		for _, date := range c.BookedDates(name) {
+			bs = append(bs, booking{c.ID(), c.Name(), date})
+		}
+	}
+
+	return api.NewSuccessNow(http.StatusOK, bs, "number of bookings: %d", len(bs))
+}
+
+// Create is the response function to a POST request to '/bookings'.
 //
 // It parses the form input for a person's 'name', the 'date' of a class, and the 'id' of the course.
 // Optionally, a 'timeout' parameter can be given.
 //
-// If any input does not make sense, an error is returned. Otherwise, the name is added to the attendees of the class on that date.
+// If any input does not make sense or the booking breaks one of the rules of the course, an error is returned. Otherwise, the name is added to the attendees of the class on that date.
+// If the course requires a membership, a credit of the member's pass is used.
+// If the class has a price and the member has no valid pass, the customer pays with the payment 'token'. An optional 'promo' code gives a discount.
+// If an 'email' address is given, the customer gets a confirmation there, as well as later emails about their bookings (see package notify).
 //
 // Note: A member can book a class only once. For now, this check occurs via the name but obviously two people can have the same name. In the future, this check needs to be done via a member id.
-func Respond(req *http.Request) interface{} {
+func (a API) Create(req *http.Request) interface{} {
+	return a.respond(req, false)
+}
+
+// Cancel is the response function to a DELETE request to '/bookings'.
+//
+// It cancels the booking of the person with the given 'name' for the class on the given 'date' of the course with the given 'id'.
+// Depending on how close to the class that happens, the payment is refunded or the credit is given back (see package refunds).
+// If the customer's email address is known, they get an email about it.
+func (a API) Cancel(req *http.Request) interface{} {
+	return a.respond(req, true)
+}
+
+// respond books or cancels a class.
+func (a API) respond(req *http.Request, cancels bool) interface{} {
 	ctx, cancel := context.WithUserTimeout(req)
 	defer cancel()
 
 	var (
-		name string
-		date civil.Date
-		id   uint64
-		c    *course.Course
-		err  error
+		refund refunds.Result
+		paid   money.Amount
+		c      *course.Course
+		err    error
 	)
 
-	// get all the user input
+	// get all the user input, collecting all problems
 
-	if name, err = form.GetStringE(req, "name"); err != nil {
-		return api.ErrBadRequest(err)
+	f := input.NewForm(req)
+	name := f.String("name")
+	date := f.Date("date")
+	id := f.Uint64("id")
+	email := req.FormValue("email")
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			f.Add("email", validation.Invalid, "%s", err)
+		}
 	}
-
-	if date, err = form.GetDateE(req, "date"); err != nil {
+	if err = f.Err(); err != nil {
 		return api.ErrBadRequest(err)
 	}
 
-	if id, err = form.GetUint64E(req, "id"); err != nil {
-		return api.ErrBadRequest(err)
-	}
+	// only needed for paid classes
+	token := req.FormValue("token")
+	promo := req.FormValue("promo")
 
-	errChan := make(chan error)
+	// buffered, so that the goroutine can finish even if we stopped waiting
+	errChan := make(chan error, 1)
 	go func() <-chan error {
 		// for now: just get from map (quick)
 		// later: get from database (potentially slow)
 
-		if c, err = courses.Get(id); err != nil {
+		if c, err = a.Courses.Get(id); err != nil {
 			errChan <- api.ErrBadRequest(err)
 			return errChan
 		}
 
-		errChan <- c.BookClass(name, date)
+		// the customer gets an email even if we stopped waiting, since the booking went through
+		if cancels {
+			refund, err = cancelClass(ctx, order{course: c, name: name, date: date, req: req})
+			if err == nil {
+				d := notify.DataOf(c, name, date)
+				d.Refunded, d.Fee, d.CreditReturned = refund.Refunded, refund.Fee, refund.CreditReturned
+				notify.Notify(notify.BookingCancelled, d)
+			}
+			errChan <- err
+			return errChan
+		}
+
+		paid, err = bookClass(ctx, order{c, a.Studio, name, date, token, promo, req}, a.Courses)
+		if err == nil {
+			if email != "" {
+				// checked above
+				_ = notify.SetAddress(name, email)
+			}
+			d := notify.DataOf(c, name, date)
+			d.Paid = paid
+			notify.Notify(notify.BookingConfirmed, d)
+		}
+		errChan <- err
 		return errChan
 	}()
 
@@ -68,10 +187,32 @@ func Respond(req *http.Request) interface{} {
 		if err != nil {
 			return api.ErrWrap(err)
 		}
+		if cancels {
+			msg := "Your booking for the %s class on %s has been cancelled."
+			if refund.CreditReturned {
+				msg += " The credit has been returned to your pass."
+			}
+			if !refund.Refunded.IsZero() {
+				msg += " You will get " + refund.Refunded.String() + " back."
+			}
+			if !refund.Fee.IsZero() {
+				msg += " A cancellation fee of " + refund.Fee.String() + " applies."
+			}
+			return api.NewSuccessNow(
+				http.StatusOK,
+				nil,
+				msg,
+				c.Name(),
+				date.In(time.Local).Format("Monday, 2. January 2006"))
+		}
+		msg := "Congratulations, %s! You are now registered for the %s class on %s."
+		if !paid.IsZero() {
+			msg += " You paid " + paid.String() + "."
+		}
 		return api.NewSuccessNow(
 			http.StatusCreated,
 			nil,
-			"Congratulations, %s! You are now registered for the %s class on %s.",
+			msg,
 			name,
 			c.Name(),
 			date.In(time.Local).Format("Monday, 2. January 2006"))
@@ -2,13 +2,30 @@ package bookings
 
 import (
 	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/auth"
 	"github.com/MarkRosemaker/booking-system/course"
 	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/mail/outbox"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/notify"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/payments/fake"
+	"github.com/MarkRosemaker/booking-system/plans"
+	"github.com/MarkRosemaker/booking-system/promos"
+	"github.com/MarkRosemaker/booking-system/rules"
 	"github.com/MarkRosemaker/go-server/server/api"
 )
 
@@ -17,10 +34,12 @@ func TestRespond(t *testing.T) {
 	// populate course list with test courses
 
 	var (
-		cPast, cTest *course.Course
-		err          error
+		cPast, cTest, cMembers, cPaid *course.Course
+		err                           error
 	)
 
+	payments.Use(fake.New(0))
+
 	today := civil.DateOf(time.Now())
 	cPast, err = course.NewHistoric("Yoga", today.AddDays(-20), today.AddDays(-10), 10)
 	if err != nil {
@@ -31,6 +50,18 @@ func TestRespond(t *testing.T) {
 		t.Fatalf("couldn't create test course")
 	}
 
+	cMembers, err = course.New("Crossfit", today, today.AddDays(3), 10, course.RequireMembership())
+	if err != nil {
+		t.Fatalf("couldn't create members-only course")
+	}
+
+	cPaid, err = course.New("Spinning", today, today.AddDays(3), 10,
+		course.Priced(money.New(1500, "EUR")),
+		course.PricedOn(today.AddDays(3), money.New(2000, "EUR")))
+	if err != nil {
+		t.Fatalf("couldn't create paid course")
+	}
+
 	if err = courses.Add(cPast); err != nil {
 		t.Fatalf("couldn't add past course: %s", err)
 	}
@@ -38,10 +69,35 @@ func TestRespond(t *testing.T) {
 		t.Fatalf("couldn't add test course: %s", err)
 	}
 
+	if err = courses.Add(cMembers); err != nil {
+		t.Fatalf("couldn't add members-only course: %s", err)
+	}
+
+	if err = courses.Add(cPaid); err != nil {
+		t.Fatalf("couldn't add paid course: %s", err)
+	}
+
+	// Bruce has a single credit
+	if _, err = plans.Issue("", "Bruce", plans.Plan{Name: "single", Credits: 1, Days: 10}, today); err != nil {
+		t.Fatalf("couldn't issue pass: %s", err)
+	}
+
+	if _, err = promos.Create(promos.Code{Code: "HALF", Kind: promos.KindPercent, Percent: 50, Course: cPaid.ID()}); err != nil {
+		t.Fatalf("couldn't create promo code: %s", err)
+	}
+	if _, err = promos.Create(promos.Code{Code: "FREE", Kind: promos.KindFirstClass}); err != nil {
+		t.Fatalf("couldn't create promo code: %s", err)
+	}
+
+	rules.Configure(cTest.ID(), rules.List{rules.Blocked{Members: []string{"Chuck"}}})
+
 	// the output on successful bookings
 	successOn := func(d civil.Date) string {
 		return fmt.Sprintf("Congratulations, Arnold! You are now registered for the Pilates class on %s.", d.In(time.Local).Format("Monday, 2. January 2006"))
 	}
+	format := func(d civil.Date) string {
+		return d.In(time.Local).Format("Monday, 2. January 2006")
+	}
 
 	// create table
 
@@ -51,13 +107,13 @@ func TestRespond(t *testing.T) {
 	}{
 		// test all errors
 		{"",
-			"400 Bad Request: name value not provided"},
+			"400 Bad Request: name value not provided; date value not provided; id value not provided"},
 		{"?name=Arnold",
-			"400 Bad Request: date value not provided"},
+			"400 Bad Request: date value not provided; id value not provided"},
 		{"?name=Arnold&date=now",
-			"400 Bad Request: date value 'now' could not be parsed to date"},
+			"400 Bad Request: date value 'now' could not be parsed to date; id value not provided"},
 		{"?name=Arnold&date=2010-02-30",
-			"400 Bad Request: date value '2010-02-30' could not be parsed to date"},
+			"400 Bad Request: date value '2010-02-30' could not be parsed to date; id value not provided"},
 		{"?name=Arnold&date=2010-02-01",
 			"400 Bad Request: id value not provided"},
 		{"?name=Arnold&date=2010-02-01&id=fake_ID",
@@ -76,6 +132,48 @@ func TestRespond(t *testing.T) {
 			"400 Bad Request: you are already attending this class"},
 		{fmt.Sprintf("?name=Arnold&date=%s&id=%d", today.AddDays(2), cTest.ID()),
 			successOn(today.AddDays(2))},
+		{fmt.Sprintf("?name=Chuck&date=%s&id=%d", today.AddDays(2), cTest.ID()),
+			"400 Bad Request: member_blocked: you are not allowed to book this course"},
+
+		// cancellations
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d&cancel=true", today.AddDays(1), cTest.ID()),
+			"400 Bad Request: you are not attending this class"},
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d&cancel=true", today.AddDays(2), cTest.ID()),
+			fmt.Sprintf("Your booking for the Pilates class on %s has been cancelled.", format(today.AddDays(2)))},
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d", today.AddDays(2), cTest.ID()),
+			successOn(today.AddDays(2))},
+
+		// members-only course
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d", today.AddDays(2), cMembers.ID()),
+			"400 Bad Request: you don't have a valid pass or membership for this class"},
+		{fmt.Sprintf("?name=Bruce&date=%s&id=%d", today.AddDays(2), cMembers.ID()),
+			fmt.Sprintf("Congratulations, Bruce! You are now registered for the Crossfit class on %s.", format(today.AddDays(2)))},
+		{fmt.Sprintf("?name=Bruce&date=%s&id=%d", today.AddDays(3), cMembers.ID()),
+			"400 Bad Request: you don't have a valid pass or membership for this class"},
+		{fmt.Sprintf("?name=Bruce&date=%s&id=%d&cancel=true", today.AddDays(2), cMembers.ID()),
+			fmt.Sprintf("Your booking for the Crossfit class on %s has been cancelled. The credit has been returned to your pass.", format(today.AddDays(2)))},
+		{fmt.Sprintf("?name=Bruce&date=%s&id=%d", today.AddDays(3), cMembers.ID()),
+			fmt.Sprintf("Congratulations, Bruce! You are now registered for the Crossfit class on %s.", format(today.AddDays(3)))},
+
+		// paid course
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d", today.AddDays(2), cPaid.ID()),
+			"400 Bad Request: the class costs 15.00 EUR, please provide a payment token"},
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d&token=decline", today.AddDays(2), cPaid.ID()),
+			"400 Bad Request: your payment was declined"},
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d&token=tok", today.AddDays(2), cPaid.ID()),
+			fmt.Sprintf("Congratulations, Arnold! You are now registered for the Spinning class on %s. You paid 15.00 EUR.", format(today.AddDays(2)))},
+		{fmt.Sprintf("?name=Arnold&date=%s&id=%d&token=tok", today.AddDays(3), cPaid.ID()),
+			fmt.Sprintf("Congratulations, Arnold! You are now registered for the Spinning class on %s. You paid 20.00 EUR.", format(today.AddDays(3)))},
+
+		// promo codes
+		{fmt.Sprintf("?name=Frank&date=%s&id=%d&token=tok&promo=bogus", today.AddDays(1), cPaid.ID()),
+			"400 Bad Request: the code BOGUS is not valid"},
+		{fmt.Sprintf("?name=Frank&date=%s&id=%d&token=tok&promo=half", today.AddDays(1), cPaid.ID()),
+			fmt.Sprintf("Congratulations, Frank! You are now registered for the Spinning class on %s. You paid 7.50 EUR.", format(today.AddDays(1)))},
+		{fmt.Sprintf("?name=Gina&date=%s&id=%d&promo=free", today.AddDays(1), cPaid.ID()),
+			fmt.Sprintf("Congratulations, Gina! You are now registered for the Spinning class on %s.", format(today.AddDays(1)))},
+		{fmt.Sprintf("?name=Gina&date=%s&id=%d&promo=free", today.AddDays(2), cPaid.ID()),
+			"400 Bad Request: the code FREE is only valid for your first class"},
 	}
 
 	// test
@@ -100,3 +198,284 @@ func TestRespond(t *testing.T) {
 		}
 	}
 }
+
+func TestRespondTimeout(t *testing.T) {
+	payments.Use(fake.New(0))
+
+	today := civil.DateOf(time.Now())
+	c, err := course.New("Rowing", today, today.AddDays(3), 10, course.Priced(money.New(1000, "EUR")))
+	if err != nil {
+		t.Fatalf("couldn't create paid course")
+	}
+	if err = courses.Add(c); err != nil {
+		t.Fatalf("couldn't add paid course: %s", err)
+	}
+
+	// the payment gateway doesn't answer in time
+	url := fmt.Sprintf("/bookings?name=Arnold&date=%s&id=%d&token=timeout&timeout=20ms", today.AddDays(1), c.ID())
+	resp := Respond(httptest.NewRequest("GET", url, nil))
+	if _, ok := resp.(api.Error); !ok {
+		t.Fatalf("Result of %s has wrong type, expected: api.Error, got: %T", url, resp)
+	}
+
+	if dates := c.BookedDates("Arnold"); len(dates) != 0 {
+		t.Errorf("class was booked even though the payment timed out")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	format := func(d civil.Date) string {
+		return d.In(time.Local).Format("Monday, 2. January 2006")
+	}
+
+	c, err := course.New("Zumba", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = courses.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+
+	tables := []struct {
+		method string
+		params string
+		res    string
+	}{
+		{"GET", "",
+			"400 Bad Request: name value not provided"},
+		{"GET", "?name=Carla",
+			"number of bookings: 0"},
+		{"POST", fmt.Sprintf("?name=Carla&date=%s&id=%d", today.AddDays(1), c.ID()),
+			fmt.Sprintf("Congratulations, Carla! You are now registered for the Zumba class on %s.", format(today.AddDays(1)))},
+		{"GET", "?name=Carla",
+			"number of bookings: 1"},
+		// the 'cancel' flag only matters for legacy requests
+		{"POST", fmt.Sprintf("?name=Carla&date=%s&id=%d&cancel=true", today.AddDays(1), c.ID()),
+			"400 Bad Request: you are already attending this class"},
+		{"DELETE", fmt.Sprintf("?name=Carla&date=%s&id=%d", today.AddDays(1), c.ID()),
+			fmt.Sprintf("Your booking for the Zumba class on %s has been cancelled.", format(today.AddDays(1)))},
+		{"GET", "?name=Carla",
+			"number of bookings: 0"},
+	}
+
+	handlers := map[string]func(*http.Request) interface{}{
+		"GET":    List,
+		"POST":   Create,
+		"DELETE": Cancel,
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/bookings%s", table.params)
+		resp := handlers[table.method](httptest.NewRequest(table.method, url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s %s was incorrect, got: %q, want: %q.",
+					table.method, url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s %s was incorrect, got: '%s', want: '%s'.",
+					table.method, url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s %s has wrong type, expected: api.Error or api.Success, got: %T", table.method, url, resp)
+		}
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	reg := courses.NewRegistry()
+
+	c, err := course.New("Kendo", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = reg.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+
+	url := fmt.Sprintf("/bookings?name=Dana&date=%s&id=%d", today.AddDays(1), c.ID())
+	if _, ok := Create(httptest.NewRequest("POST", url, nil)).(api.Error); !ok {
+		t.Errorf("could book course that isn't in the default registry")
+	}
+	if resp, ok := New(reg).Create(httptest.NewRequest("POST", url, nil)).(api.Success); !ok {
+		t.Fatalf("couldn't book course of the registry: %v", resp)
+	}
+
+	for _, table := range []struct {
+		api  API
+		name string
+		n    int
+	}{
+		{New(reg), "registry", 1},
+		{New(courses.Default), "default registry", 0},
+	} {
+		resp, ok := table.api.List(httptest.NewRequest("GET", "/bookings?name=Dana", nil)).(api.Success)
+		if !ok {
+			t.Fatalf("couldn't list bookings of %s: %v", table.name, resp)
+		}
+		if n := reflect.ValueOf(resp.Object).Len(); n != table.n {
+			t.Errorf("wrong number of bookings in %s, got: %d, want: %d", table.name, n, table.n)
+		}
+	}
+}
+
+func TestWeeklyLimit(t *testing.T) {
+	// a whole week from next Monday on
+	today := civil.DateOf(time.Now())
+	monday := today.AddDays(7 - (int(today.In(time.UTC).Weekday())+6)%7)
+
+	// the payment takes a while, so that the bookings happen at the same time
+	payments.Use(fake.New(20 * time.Millisecond))
+	defer payments.Use(fake.New(0))
+
+	reg := courses.NewRegistry()
+	c, err := course.New("Judo", monday, monday.AddDays(6), 10, course.Priced(money.New(1000, "EUR")))
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = reg.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+	rules.Configure(c.ID(), rules.List{rules.WeeklyLimit{Max: 1}})
+
+	// Emma tries to book every class of the week at once
+	var wg sync.WaitGroup
+	for i := 0; i < 7; i++ {
+		wg.Add(1)
+		go func(date civil.Date) {
+			defer wg.Done()
+			New(reg).Create(httptest.NewRequest("POST", fmt.Sprintf("/bookings?name=Emma&date=%s&id=%d&token=tok", date, c.ID()), nil))
+		}(monday.AddDays(i))
+	}
+	wg.Wait()
+
+	if dates := c.BookedDates("Emma"); len(dates) != 1 {
+		t.Errorf("Emma booked %d classes in a week, the limit is 1", len(dates))
+	}
+}
+
+func TestEmails(t *testing.T) {
+	notify.Dir = filepath.Join("..", "..", "site", "emails")
+	o := outbox.New("")
+	mail.Use(o)
+	defer mail.Use(nil)
+
+	today := civil.DateOf(time.Now())
+	reg := courses.NewRegistry()
+
+	c, err := course.New("Zumba", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = reg.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+	a := New(reg)
+
+	url := fmt.Sprintf("/bookings?name=Emil&date=%s&id=%d", today.AddDays(1), c.ID())
+	if resp, ok := a.Create(httptest.NewRequest("POST", url+"&email=emil", nil)).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+		t.Errorf("expected an invalid email address, got: %v", resp)
+	}
+	if resp, ok := a.Create(httptest.NewRequest("POST", url+"&email=emil@example.com", nil)).(api.Success); !ok {
+		t.Fatalf("couldn't book class: %v", resp)
+	}
+	if resp, ok := a.Cancel(httptest.NewRequest("DELETE", url, nil)).(api.Success); !ok {
+		t.Fatalf("couldn't cancel booking: %v", resp)
+	}
+
+	ms, err := o.Wait(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"You are booked: Zumba", "Booking cancelled: Zumba"} {
+		if ms[i].To != "emil@example.com" || !strings.HasPrefix(ms[i].Subject, want) {
+			t.Errorf("email %d: got %q to %s, want: %q", i, ms[i].Subject, ms[i].To, want)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	reg := courses.NewRegistry()
+
+	c, err := course.New("Karate", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = reg.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+	a := New(reg)
+
+	url := fmt.Sprintf("/bookings?name=Frida&date=%s&id=%d", today.AddDays(1), c.ID())
+	req := httptest.NewRequest("POST", url, nil)
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Name: "Frida", Role: auth.RoleMember}))
+	if resp, ok := a.Create(req).(api.Success); !ok {
+		t.Fatalf("couldn't book class: %v", resp)
+	}
+	if resp, ok := a.Cancel(httptest.NewRequest("DELETE", url, nil)).(api.Success); !ok {
+		t.Fatalf("couldn't cancel booking: %v", resp)
+	}
+
+	es, _ := audit.Find(audit.Filter{Target: audit.Booking(c.ID(), today.AddDays(1), "Frida")})
+	if len(es) != 2 || es[0].Action != audit.ClassBooked || es[0].Actor.Name != "Frida" || es[1].Action != audit.BookingCancelled {
+		t.Fatalf("wrong entries: %+v", es)
+	}
+	if before, after := es[1].Before.(attendance), es[1].After.(attendance); !before.Attending || after.Attending || after.Refund == nil {
+		t.Errorf("wrong values of the cancellation: %+v, %+v", before, after)
+	}
+}
+
+func TestCancelRefundFails(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	reg := courses.NewRegistry()
+
+	c, err := course.New("Rowing", today, today.AddDays(10), 10, course.Priced(money.New(1000, "EUR")))
+	if err != nil {
+		t.Fatalf("couldn't create test course")
+	}
+	if err = reg.Add(c); err != nil {
+		t.Fatalf("couldn't add test course: %s", err)
+	}
+	a := New(reg)
+
+	g := fake.New(0)
+	payments.Use(g)
+	defer payments.Use(fake.New(0))
+
+	date := today.AddDays(5)
+	url := fmt.Sprintf("/bookings?name=Greta&date=%s&id=%d", date, c.ID())
+	if resp, ok := a.Create(httptest.NewRequest("POST", url+"&token=tok", nil)).(api.Success); !ok {
+		t.Fatalf("couldn't book class: %v", resp)
+	}
+	b := ledger.Booking{Course: c.ID(), Member: "Greta", Date: date}
+
+	// a gateway that doesn't know the payment can't refund it
+	payments.Use(fake.New(0))
+	if resp, ok := a.Cancel(httptest.NewRequest("DELETE", url, nil)).(api.Error); !ok {
+		t.Fatalf("cancelled booking even though the refund failed: %v", resp)
+	}
+	if dates := c.BookedDates("Greta"); len(dates) != 1 {
+		t.Errorf("booking was cancelled even though the refund failed")
+	}
+	if _, ok := ledger.OpenCharge(b); !ok {
+		t.Errorf("charge was settled even though the refund failed")
+	}
+
+	// cancelling again refunds the payment
+	payments.Use(g)
+	if resp, ok := a.Cancel(httptest.NewRequest("DELETE", url, nil)).(api.Success); !ok {
+		t.Fatalf("couldn't cancel booking: %v", resp)
+	}
+	if dates := c.BookedDates("Greta"); len(dates) != 0 {
+		t.Errorf("booking wasn't cancelled")
+	}
+	if _, ok := ledger.OpenCharge(b); ok {
+		t.Errorf("charge wasn't settled")
+	}
+}
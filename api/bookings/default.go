@@ -0,0 +1,27 @@
+package bookings
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// Respond is the legacy response function for the default registry (see API.Respond).
+func Respond(req *http.Request) interface{} {
+	return New(courses.Default).Respond(req)
+}
+
+// List is the response function to a GET request for the default registry (see API.List).
+func List(req *http.Request) interface{} {
+	return New(courses.Default).List(req)
+}
+
+// Create is the response function to a POST request for the default registry (see API.Create).
+func Create(req *http.Request) interface{} {
+	return New(courses.Default).Create(req)
+}
+
+// Cancel is the response function to a DELETE request for the default registry (see API.Cancel).
+func Cancel(req *http.Request) interface{} {
+	return New(courses.Default).Cancel(req)
+}
@@ -0,0 +1,52 @@
+package bookings
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// ListSchema is the schema of the query parameters to list bookings (see List).
+var ListSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+}
+
+// Doc documents the endpoint '/v1/bookings' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/v1/bookings",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodGet,
+			Summary:     "List the bookings of a member",
+			Description: "Members can only list their own bookings.",
+			Params:      ListSchema,
+			Response:    []booking{},
+			Respond:     checked(API.List),
+		},
+		{
+			Method:      http.MethodPost,
+			Summary:     "Book a class",
+			Description: "Members can only book for themselves. The booking must follow the rules of the course, and paid classes need a pass or a payment 'token'.",
+			Params:      CreateSchema,
+			Status:      http.StatusCreated,
+			Respond:     checked(API.Create),
+		},
+		{
+			Method:      http.MethodDelete,
+			Summary:     "Cancel a booking",
+			Description: "Members can only cancel their own bookings. The payment or credit is given back according to the refund policy.",
+			Params:      CancelSchema,
+			Respond:     checked(API.Cancel),
+		},
+	},
+}
+
+// checked returns the response function of an API on an empty registry, used by openapi.Check,
+// so that checking the documentation doesn't touch the courses of any studio.
+func checked(respond func(API, *http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		return respond(New(courses.NewRegistry()), req)
+	}
+}
@@ -0,0 +1,197 @@
+// Package calendars implements the implementation of the API points '/v1/calendars/...', which serve classes as iCalendar feeds to subscribe to in calendar apps.
+package calendars
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+	"github.com/MarkRosemaker/go-server/server/form"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ical"
+)
+
+// DefaultDuration is how long a class lasts in a feed if its course doesn't say.
+const DefaultDuration = time.Hour
+
+// History is how many days of past classes the schedule and the feeds of members contain.
+const History = 30
+
+// API holds the response functions to requests for feeds, which contain the courses of its registry.
+// The package-level functions, e.g. Course, use the default registry.
+type API struct {
+	Courses  *courses.Registry
+	Studio   string // the name of the studio, for the name of the schedule
+	StudioID string // the ID of the studio, the tokens of the feeds of members only work for it
+}
+
+// New returns the response functions to requests for feeds of the courses of the registry.
+func New(r *courses.Registry) API {
+	return API{Courses: r}
+}
+
+// Course is the response function to a GET request to '/v1/calendars/course'.
+//
+// It returns the feed of all classes of the course with the given 'id'.
+func (a API) Course(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	id := f.Uint64("id")
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	c, err := a.Courses.Get(id)
+	if err != nil {
+		return api.NewError(http.StatusNotFound, err)
+	}
+
+	cal := ical.Calendar{Name: c.Name()}
+	for i := 0; i < c.NumClasses(); i++ {
+		date := c.Start().AddDays(i)
+		cal.Events = append(cal.Events, event(c, date, c.Cancelled(date)))
+	}
+	return content(cal, fmt.Sprintf("course-%d.ics", id))
+}
+
+// Schedule is the response function to a GET request to '/v1/calendars/schedule'.
+//
+// It returns the feed of the classes of all courses, from History days ago on.
+func (a API) Schedule(req *http.Request) interface{} {
+	from := civil.DateOf(time.Now()).AddDays(-History)
+
+	cal := ical.Calendar{Name: strings.TrimSpace(a.Studio + " Schedule")}
+	for _, c := range a.Courses.Between(from, civil.Date{}) {
+		for i := 0; i < c.NumClasses(); i++ {
+			if date := c.Start().AddDays(i); !date.Before(from) {
+				cal.Events = append(cal.Events, event(c, date, c.Cancelled(date)))
+			}
+		}
+	}
+	return content(cal, "schedule.ics")
+}
+
+// Member is the response function to a GET request to '/v1/calendars/member'.
+//
+// It returns the feed of the classes the member with the secret 'token' booked, from History days ago on (see Token).
+// Classes the studio cancelled and bookings the member cancelled are part of it as cancelled events.
+func (a API) Member(req *http.Request) interface{} {
+	token, err := form.GetStringE(req, "token")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	name, ok := memberOf(a.StudioID, token)
+	if !ok {
+		return api.NewError(http.StatusNotFound, fmt.Errorf("there is no feed with this token"))
+	}
+
+	from := civil.DateOf(time.Now()).AddDays(-History)
+
+	cal := ical.Calendar{Name: "Classes of " + name}
+	for _, c := range a.Courses.Between(from, civil.Date{}) {
+		for _, date := range c.BookedDates(name) {
+			if !date.Before(from) {
+				cal.Events = append(cal.Events, event(c, date, false))
+			}
+		}
+		for _, date := range c.CancelledDates(name) {
+			if !date.Before(from) {
+				cal.Events = append(cal.Events, event(c, date, true))
+			}
+		}
+		// so that calendar apps remove the classes the member doesn't attend anymore
+		for _, date := range c.CancelledBookings(name) {
+			if !date.Before(from) {
+				cal.Events = append(cal.Events, event(c, date, true))
+			}
+		}
+	}
+	return content(cal, "classes.ics")
+}
+
+// feed is what the API returns about the feed of a member.
+type feed struct {
+	Token string
+	URL   string // to subscribe to
+}
+
+// Token is the response function to a GET request to '/v1/calendars/token'.
+//
+// It returns the secret token of the feed of the member with the given 'name' and the URL of the feed. The token only works for the studio.
+func (a API) Token(req *http.Request) interface{} {
+	name, err := form.GetStringE(req, "name")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	token, err := tokenOf(a.StudioID, name)
+	if err != nil {
+		return api.ErrWrap(err)
+	}
+
+	return api.NewSuccessNow(http.StatusOK, feed{token, feedURL(req, token)}, "feed of %s, keep the URL secret", name)
+}
+
+// Revoke is the response function to a DELETE request to '/v1/calendars/token'.
+//
+// It revokes the token of the feed of the member with the given 'name', e.g. because the URL was shared by accident. The next GET request returns a new one.
+func (a API) Revoke(req *http.Request) interface{} {
+	name, err := form.GetStringE(req, "name")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	if err := revoke(a.StudioID, name); err != nil {
+		return api.ErrWrap(err)
+	}
+	return api.NewSuccessNow(http.StatusOK, nil, "the feed of %s has been revoked", name)
+}
+
+// feedURL returns the URL of the feed of a member with the token, next to the path of the request.
+func feedURL(req *http.Request, token string) string {
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/token") + "/member"
+	return fmt.Sprintf("%s://%s%s?token=%s", scheme, req.Host, path, token)
+}
+
+// event returns the event of the class of the course on the date.
+func event(c *course.Course, date civil.Date, cancelled bool) ical.Event {
+	e := ical.Event{
+		UID:       fmt.Sprintf("%d-%s@booking-system", c.ID(), date),
+		Summary:   c.Name(),
+		Location:  c.Location(),
+		Cancelled: cancelled,
+	}
+	if c.Instructor() != "" {
+		e.Description = "Instructor: " + c.Instructor()
+	}
+
+	// classes without a time of day last the whole day
+	if c.At() == (civil.Time{}) && c.Duration() == 0 {
+		e.Date = date
+		return e
+	}
+
+	d := c.Duration()
+	if d == 0 {
+		d = DefaultDuration
+	}
+	e.Start = c.ClassStart(date)
+	e.End = e.Start.Add(d)
+	return e
+}
+
+// content returns the calendar as a file with the name.
+func content(cal ical.Calendar, name string) endpoint.Content {
+	return endpoint.Content{Type: ical.ContentType, Name: name, Body: cal.Marshal(time.Now())}
+}
@@ -0,0 +1,208 @@
+package calendars
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// body returns the calendar of the response or fails.
+func body(t *testing.T, resp interface{}) string {
+	t.Helper()
+	c, ok := resp.(endpoint.Content)
+	if !ok {
+		t.Fatalf("expected a calendar, got: %v", resp)
+	}
+	return string(c.Body)
+}
+
+// count returns how often the line occurs in the calendar.
+func count(cal, line string) int {
+	return strings.Count(cal, "\r\n"+line+"\r\n")
+}
+
+func TestCourse(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+
+	timed, err := course.New("Pilates", today, today.AddDays(2), 10,
+		course.StartingAt(civil.Time{Hour: 18, Minute: 30}), course.Lasting(45*time.Minute), course.HeldAt("Hall"), course.TaughtBy("Ann"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	allDay, err := course.New("Retreat", today, today.AddDays(1), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*course.Course{timed, allDay} {
+		if err := a.Courses.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := timed.CancelClass(today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	cal := body(t, a.Course(httptest.NewRequest("GET", fmt.Sprintf("/v1/calendars/course?id=%d", timed.ID()), nil)))
+
+	start := timed.ClassStart(today)
+	tables := []struct {
+		line string
+		n    int
+	}{
+		{"BEGIN:VEVENT", 3},
+		{"SUMMARY:Pilates", 3},
+		{"LOCATION:Hall", 3},
+		{"DESCRIPTION:Instructor: Ann", 3},
+		{"STATUS:CANCELLED", 1},
+		{"STATUS:CONFIRMED", 2},
+		{fmt.Sprintf("UID:%d-%s@booking-system", timed.ID(), today.AddDays(1)), 1},
+		{"DTSTART:" + start.UTC().Format("20060102T150405Z"), 1},
+		{"DTEND:" + start.Add(45*time.Minute).UTC().Format("20060102T150405Z"), 1},
+	}
+	for _, table := range tables {
+		if n := count(cal, table.line); n != table.n {
+			t.Errorf("%q occurs %d times, want: %d", table.line, n, table.n)
+		}
+	}
+
+	// classes without a time last the whole day
+	cal = body(t, a.Course(httptest.NewRequest("GET", fmt.Sprintf("/v1/calendars/course?id=%d", allDay.ID()), nil)))
+	if n := count(cal, "DTSTART;VALUE=DATE:"+strings.ReplaceAll(today.String(), "-", "")); n != 1 {
+		t.Errorf("first class doesn't last the whole day:\n%s", cal)
+	}
+
+	// only courses of the registry
+	if resp, ok := New(courses.NewRegistry()).Course(httptest.NewRequest("GET", fmt.Sprintf("/v1/calendars/course?id=%d", timed.ID()), nil)).(api.Error); !ok {
+		t.Errorf("got feed of a course of another registry: %v", resp)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := API{Courses: courses.NewRegistry(), Studio: "Downtown"}
+
+	for _, dates := range [][2]civil.Date{
+		{today.AddDays(-History - 10), today.AddDays(-History - 1)}, // too long ago
+		{today.AddDays(-History - 1), today.AddDays(-History + 1)},  // only the last two classes
+		{today, today.AddDays(2)},
+	} {
+		c, err := course.NewHistoric("Yoga", dates[0], dates[1], 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Courses.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cal := body(t, a.Schedule(httptest.NewRequest("GET", "/v1/calendars/schedule", nil)))
+	if n := count(cal, "BEGIN:VEVENT"); n != 5 {
+		t.Errorf("schedule has %d classes, want: 5", n)
+	}
+	if n := count(cal, "X-WR-CALNAME:Downtown Schedule"); n != 1 {
+		t.Errorf("schedule has the wrong name:\n%s", cal)
+	}
+}
+
+func TestMember(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+	a.StudioID = "north"
+
+	c, err := course.New("Zumba", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Courses.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, date := range []civil.Date{today.AddDays(1), today.AddDays(2)} {
+		if err := c.BookClass("Carla", date); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.BookClass("Dana", today.AddDays(3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CancelClass(today.AddDays(2)); err != nil {
+		t.Fatal(err)
+	}
+	// Carla cancels a booking
+	if err := c.BookClass("Carla", today.AddDays(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelBooking("Carla", today.AddDays(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token of the feed is signed with the JWT key
+	if _, ok := a.Token(httptest.NewRequest("GET", "/v1/calendars/token?name=Carla", nil)).(api.Error); !ok {
+		t.Errorf("got token without a JWT key")
+	}
+	if err := auth.SetJWTKey([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := a.Token(httptest.NewRequest("GET", "/v1/calendars/token", nil)).(api.Error); !ok {
+		t.Errorf("got token without a name")
+	}
+	resp, ok := a.Token(httptest.NewRequest("GET", "http://north.example.com/studios/north/v1/calendars/token?name=Carla", nil)).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't get token: %v", resp)
+	}
+	token := reflect.ValueOf(resp.Object).FieldByName("Token").String()
+	feedURL := reflect.ValueOf(resp.Object).FieldByName("URL").String()
+	if want := "http://north.example.com/studios/north/v1/calendars/member?token=" + token; feedURL != want {
+		t.Errorf("wrong URL of the feed, got: %s, want: %s", feedURL, want)
+	}
+
+	// the same token every time
+	again := a.Token(httptest.NewRequest("GET", "/v1/calendars/token?name=Carla", nil)).(api.Success)
+	if reflect.ValueOf(again.Object).FieldByName("Token").String() != token {
+		t.Errorf("got another token the second time")
+	}
+
+	cal := body(t, a.Member(httptest.NewRequest("GET", "/v1/calendars/member?token="+url.QueryEscape(token), nil)))
+	if n := count(cal, "BEGIN:VEVENT"); n != 3 {
+		t.Errorf("feed has %d classes, want: 3\n%s", n, cal)
+	}
+	if n := count(cal, "STATUS:CANCELLED"); n != 2 || count(cal, "SEQUENCE:1") != 2 {
+		t.Errorf("feed has %d cancelled classes, want: 2", n)
+	}
+	// the cancelled booking keeps the UID of the booked class
+	if uid := fmt.Sprintf("UID:%d-%s@booking-system", c.ID(), today.AddDays(3)); count(cal, uid) != 1 {
+		t.Errorf("feed doesn't have the cancelled booking %s\n%s", uid, cal)
+	}
+
+	// tokens only work for their studio
+	south := a
+	south.StudioID = "south"
+	if _, ok := south.Member(httptest.NewRequest("GET", "/v1/calendars/member?token="+url.QueryEscape(token), nil)).(api.Error); !ok {
+		t.Errorf("got feed with the token of another studio")
+	}
+
+	// revoked tokens don't work anymore
+	if _, ok := a.Revoke(httptest.NewRequest("DELETE", "/v1/calendars/token?name=Carla", nil)).(api.Success); !ok {
+		t.Fatalf("couldn't revoke token")
+	}
+	if _, ok := a.Member(httptest.NewRequest("GET", "/v1/calendars/member?token="+url.QueryEscape(token), nil)).(api.Error); !ok {
+		t.Errorf("got feed with a revoked token")
+	}
+	renewed := a.Token(httptest.NewRequest("GET", "/v1/calendars/token?name=Carla", nil)).(api.Success)
+	if reflect.ValueOf(renewed.Object).FieldByName("Token").String() == token {
+		t.Errorf("got the revoked token again")
+	}
+}
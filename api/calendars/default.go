@@ -0,0 +1,32 @@
+package calendars
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// Course is the response function to a GET request for a course of the default registry (see API.Course).
+func Course(req *http.Request) interface{} {
+	return New(courses.Default).Course(req)
+}
+
+// Schedule is the response function to a GET request for the schedule of the default registry (see API.Schedule).
+func Schedule(req *http.Request) interface{} {
+	return New(courses.Default).Schedule(req)
+}
+
+// Member is the response function to a GET request for the classes of a member in the default registry (see API.Member).
+func Member(req *http.Request) interface{} {
+	return New(courses.Default).Member(req)
+}
+
+// Token is the response function to a GET request for the token of a member (see API.Token).
+func Token(req *http.Request) interface{} {
+	return New(courses.Default).Token(req)
+}
+
+// Revoke is the response function to a DELETE request for the token of a member (see API.Revoke).
+func Revoke(req *http.Request) interface{} {
+	return New(courses.Default).Revoke(req)
+}
@@ -0,0 +1,92 @@
+package calendars
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ical"
+)
+
+// CourseDoc documents the endpoint '/v1/calendars/course' for the OpenAPI specification.
+var CourseDoc = openapi.Path{
+	URL: "/v1/calendars/course",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Get the feed of a course",
+			Description:  "All classes of the course as an iCalendar feed. Cancelled classes have the status CANCELLED.",
+			Params:       input.Schema{{Name: "id", Type: input.Int, Required: true}},
+			ContentTypes: []string{ical.ContentType},
+			Public:       true,
+			Respond:      checked(API.Course),
+		},
+	},
+}
+
+// ScheduleDoc documents the endpoint '/v1/calendars/schedule' for the OpenAPI specification.
+var ScheduleDoc = openapi.Path{
+	URL: "/v1/calendars/schedule",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Get the feed of the schedule",
+			Description:  "The classes of all courses of the studio as an iCalendar feed, from 30 days ago on. Cancelled classes have the status CANCELLED.",
+			ContentTypes: []string{ical.ContentType},
+			Public:       true,
+			Respond:      checked(API.Schedule),
+		},
+	},
+}
+
+// MemberDoc documents the endpoint '/v1/calendars/member' for the OpenAPI specification.
+var MemberDoc = openapi.Path{
+	URL: "/v1/calendars/member",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Get the feed of a member",
+			Description:  "The booked classes of the member with the secret token as an iCalendar feed, from 30 days ago on. Classes the studio cancelled have the status CANCELLED.",
+			Params:       input.Schema{{Name: "token", Type: input.String, Required: true, Description: "see '/v1/calendars/token'"}},
+			ContentTypes: []string{ical.ContentType},
+			Public:       true,
+			Respond:      checked(API.Member),
+		},
+	},
+}
+
+// TokenSchema is the schema of the parameters to get or revoke the token of a member's feed (see Token and Revoke).
+var TokenSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+}
+
+// TokenDoc documents the endpoint '/v1/calendars/token' for the OpenAPI specification.
+var TokenDoc = openapi.Path{
+	URL: "/v1/calendars/token",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodGet,
+			Summary:     "Get the feed of a member",
+			Description: "Members only for themselves. Returns the secret token and URL of the feed, which only work for the studio.",
+			Params:      TokenSchema,
+			Response:    feed{},
+			Respond:     checked(API.Token),
+		},
+		{
+			Method:      http.MethodDelete,
+			Summary:     "Revoke the feed of a member",
+			Description: "Members only for themselves. The URL stops working, the next GET request returns a new one.",
+			Params:      TokenSchema,
+			Respond:     checked(API.Revoke),
+		},
+	},
+}
+
+// checked returns the response function of an API on an empty registry, used by openapi.Check,
+// so that checking the documentation doesn't touch the courses of any studio.
+func checked(respond func(API, *http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		return respond(New(courses.NewRegistry()), req)
+	}
+}
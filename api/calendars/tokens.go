@@ -0,0 +1,106 @@
+package calendars
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+// a feed of a member of a studio
+type feedOf struct {
+	studio, member string
+}
+
+var (
+	// how often the feeds were revoked, which is part of their tokens
+	revoked map[feedOf]uint64 = make(map[feedOf]uint64)
+
+	// protect map with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// tokenOf returns the token of the feed of the member of the studio.
+//
+// The token is the member's name with a signature of the studio, the member and how often their feed was revoked,
+// so it is the same every time and doesn't have to be kept, but it only works for the studio and until it is revoked.
+func tokenOf(studio, member string) (string, error) {
+	mux.Lock()
+	n := revoked[feedOf{studio, member}]
+	mux.Unlock()
+
+	sig, err := auth.Sign(fmt.Sprintf("feed\n%s\n%s\n%d", studio, member, n))
+	if err != nil {
+		return "", fmt.Errorf("feeds of members are not available: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(member)) + "." + sig, nil
+}
+
+// memberOf returns the member of the studio whose feed has the token.
+func memberOf(studio, token string) (string, bool) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return "", false
+	}
+	name, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return "", false
+	}
+
+	want, err := tokenOf(studio, string(name))
+	if err != nil || !hmac.Equal([]byte(token), []byte(want)) {
+		return "", false
+	}
+	return string(name), true
+}
+
+// a revocation is how the journal keeps how often a feed was revoked
+type revocation struct {
+	Studio string
+	Member string
+	Count  uint64
+}
+
+// revoke changes the token of the feed of the member of the studio, so that the one before doesn't work anymore.
+// The revocation is kept in the journal, if one is open (see Restore).
+func revoke(studio, member string) error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	f := feedOf{studio, member}
+	b, err := json.Marshal(revocation{studio, member, revoked[f] + 1})
+	if err != nil {
+		return err
+	}
+	if err := journal.Append(journal.Event{Kind: journal.FeedRevoked, Key: studio + "/" + member, Record: b}); err != nil {
+		return err
+	}
+
+	revoked[f]++
+	return nil
+}
+
+// Restore sets how often the feeds were revoked as the journal, which has to be open, has it. They are not journaled again.
+func Restore() error {
+	rs := make([]revocation, 0)
+	for key, raw := range journal.Records(journal.FeedRevoked) {
+		var r revocation
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return fmt.Errorf("revocation %s: %w", key, err)
+		}
+		rs = append(rs, r)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	for _, r := range rs {
+		revoked[feedOf{r.Studio, r.Member}] = r.Count
+	}
+	return nil
+}
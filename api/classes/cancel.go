@@ -0,0 +1,129 @@
+package classes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/notify"
+	"github.com/MarkRosemaker/booking-system/refunds"
+)
+
+// classState is what the audit log records about a class before and after it was cancelled.
+type classState struct {
+	Cancelled bool
+	Attendees []string
+}
+
+// cancelled is what the API returns about a cancelled class.
+type cancelled struct {
+	ID        uint64 // of the course
+	Date      civil.Date
+	Attendees []string // who got their payment or credit back
+}
+
+// cancelClass cancels the class of the course with the given 'id' on the given 'date'.
+// All attendees get their payment or credit back and, if their email address is known, an email about it.
+//
+// The class is cancelled before the payments are settled, so that nobody is refunded for a class that still takes place.
+// If some of them can't be refunded, cancelling the class again retries them. Those that were settled are not settled twice.
+func (a API) cancelClass(ctx context.Context, req *http.Request) interface{} {
+	f := input.NewForm(req)
+	id := f.Uint64("id")
+	date := f.Date("date")
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	type result struct {
+		attendees []string
+		err       error
+	}
+
+	// buffered, so that the goroutine can finish even if we stopped waiting
+	resChan := make(chan result, 1)
+	go func() {
+		c, err := a.Courses.Get(id)
+		if err != nil {
+			resChan <- result{err: api.ErrBadRequest(err)}
+			return
+		}
+
+		var attendees []string
+		if c.Cancelled(date) {
+			// retry the refunds that failed when the class was cancelled
+			if attendees = outstanding(c, date); len(attendees) == 0 {
+				resChan <- result{err: c.CanCancelClass(date)}
+				return
+			}
+		} else {
+			if attendees, err = c.CancelClass(date); err != nil {
+				resChan <- result{err: err}
+				return
+			}
+			audit.Record(req, audit.ClassCancelled, audit.Class(id, date), classState{false, attendees}, classState{true, []string{}})
+		}
+
+		// settle the bookings even if the client stopped waiting
+		settleCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		refunded := make([]string, 0, len(attendees))
+		var errs []error
+		for _, name := range attendees {
+			b := ledger.Booking{Course: id, Member: name, Date: date}
+			res, err := refunds.Process(settleCtx, b, c.ClassStart(date), true)
+			if err != nil {
+				// they hear about it once the refund is retried
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			refunded = append(refunded, name)
+
+			d := notify.DataOf(c, name, date)
+			d.Refunded, d.CreditReturned = res.Refunded, res.CreditReturned
+			notify.Notify(notify.ClassCancelled, d)
+		}
+		if len(errs) > 0 {
+			resChan <- result{refunded, fmt.Errorf("class cancelled, but not all attendees could be refunded, please cancel it again to retry: %v", errs)}
+			return
+		}
+
+		resChan <- result{refunded, nil}
+	}()
+
+	// timout if necessary
+	select {
+	case res := <-resChan:
+		if res.err != nil {
+			return api.ErrWrap(res.err)
+		}
+		return api.NewSuccessNow(http.StatusOK, cancelled{id, date, res.attendees}, "class cancelled, number of attendees refunded: %d", len(res.attendees))
+	case <-ctx.Done():
+		return api.ErrWrap(ctx.Err())
+	}
+}
+
+// outstanding returns those that were attending the cancelled class on the given date and still have something to be given back.
+func outstanding(c *course.Course, date civil.Date) []string {
+	attendees, err := c.Attendees(date)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, name := range attendees {
+		if refunds.Outstanding(ledger.Booking{Course: c.ID(), Member: name, Date: date}) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
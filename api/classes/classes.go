@@ -2,11 +2,22 @@
 package classes
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/auth"
 	"github.com/MarkRosemaker/booking-system/course"
 	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/rules"
+	"github.com/MarkRosemaker/booking-system/validation"
 	"github.com/MarkRosemaker/go-server/server/form"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -17,91 +28,447 @@ import (
 
 var toTitleCase cases.Caser = cases.Title(language.English)
 
-// Respond is the response function to an API request to '/classes'.
-//
-// It parses the form input for the course 'name', the 'start' and 'end' dates of the course, and the 'capacity' of the course. (The 'name' parameter is transformed into title case.)
-// Optionally, a 'timeout' and 'historic' parameter can be given. The latter signifies whether or not we want to allow the course to be in the past.
-//
-// If any input does not make sense, an error is returned. Otherwise, the course is added to the list of courses.
-func Respond(req *http.Request) interface{} {
-	ctx, cancel := context.WithUserTimeout(req)
-	defer cancel()
+// CreateSchema is the schema of a JSON body to create a course (see Create).
+// The prices are strings, e.g. "12.50", the 'classprices' a list of strings of the form "2020-12-24:20.00".
+var CreateSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "start", Type: input.Date, Required: true},
+	{Name: "end", Type: input.Date, Required: true},
+	{Name: "capacity", Type: input.Int, Required: true},
+	{Name: "historic", Type: input.Bool, Description: "allow the course to be in the past"},
+	{Name: "members", Type: input.Bool, Description: "only bookable with a membership plan or pass"},
+	{Name: "time", Type: input.String, Description: "time of day the classes start, e.g. '18:30'"},
+	{Name: "duration", Type: input.String, Description: "how long the classes last, e.g. '1h'"},
+	{Name: "price", Type: input.String, Description: "price of a class, e.g. '12.50'"},
+	{Name: "currency", Type: input.String, Description: "currency of the prices, EUR by default"},
+	{Name: "classprices", Type: input.List, Description: "prices of single classes, e.g. '2020-12-24:20.00'"},
+	{Name: "advance", Type: input.Int, Description: "bookings open that many days in advance"},
+	{Name: "cutoff", Type: input.String, Description: "bookings close that long before a class starts, e.g. '2h'"},
+	{Name: "weekly", Type: input.Int, Description: "maximum active bookings per member and week"},
+	{Name: "blocked", Type: input.List, Description: "members that may not book"},
+	{Name: "instructor", Type: input.String, Description: "who teaches the course"},
+	{Name: "location", Type: input.String, Description: "where the classes take place"},
+	{Name: "timeout", Type: input.String, Description: "how long to wait at most, e.g. '1s'"},
+}
 
-	var (
-		name       string
-		start, end civil.Date
-		capacity   int
-		historic   bool
+// UpdateSchema is the schema of a JSON body to update a course (see Update).
+var UpdateSchema = input.Schema{
+	{Name: "id", Type: input.Int, Required: true},
+	{Name: "capacity", Type: input.Int, Required: true},
+}
 
-		c *course.Course
+// CancelSchema is the schema of a JSON body to cancel a class (see Cancel).
+var CancelSchema = input.Schema{
+	{Name: "id", Type: input.Int, Required: true},
+	{Name: "date", Type: input.Date, Required: true},
+	{Name: "timeout", Type: input.String, Description: "how long to wait at most, e.g. '1s'"},
+}
 
-		err error
-	)
+// info is what the API returns about a course.
+type info struct {
+	ID         uint64
+	Name       string
+	Start      civil.Date
+	End        civil.Date
+	Capacity   int
+	Classes    int
+	Price      money.Amount
+	Instructor string
+	Location   string
+	Free       int // see course.Course.FreePlaces
+}
 
-	// get all the user input
+// infoOf returns the information about the course.
+func infoOf(c *course.Course) info {
+	return info{c.ID(), c.Name(), c.Start(), c.End(), c.Capacity(), c.NumClasses(), c.Price(),
+		c.Instructor(), c.Location(), c.FreePlaces()}
+}
 
-	if name, err = form.GetStringE(req, "name"); err != nil {
-		return api.ErrBadRequest(err)
+// API holds the response functions to requests to '/classes', which act on the courses of its registry.
+// The package-level functions, e.g. Respond, act on the default registry.
+type API struct {
+	Courses *courses.Registry
+	Rooms   []string // where courses can be held, anywhere if empty
+}
+
+// New returns the response functions to requests to '/classes' for the courses of the registry.
+func New(r *courses.Registry) API {
+	return API{Courses: r}
+}
+
+// Authorize is the policy for legacy requests to '/classes' (see Respond): only admins can create courses, instructors can also cancel classes.
+func Authorize(p auth.Principal, req *http.Request) error {
+	if cancels, _ := form.GetBoolE(req, "cancel"); cancels {
+		return auth.Roles(auth.RoleAdmin, auth.RoleInstructor)(p, req)
 	}
-	name = toTitleCase.String(name)
+	return auth.Roles(auth.RoleAdmin)(p, req)
+}
 
-	if start, err = form.GetDateE(req, "start"); err != nil {
+// Respond is the legacy response function to an API request to '/classes', which ignores the HTTP method.
+//
+// If the 'cancel' flag is set, it cancels a class (see Cancel), otherwise it creates a course (see Create).
+func (a API) Respond(req *http.Request) interface{} {
+	if cancels, err := form.GetBoolE(req, "cancel"); err != nil {
 		return api.ErrBadRequest(err)
+	} else if cancels {
+		return a.Cancel(req)
 	}
+	return a.Create(req)
+}
 
-	if end, err = form.GetDateE(req, "end"); err != nil {
-		return api.ErrBadRequest(err)
+// List is the response function to a GET request to '/classes'.
+//
+// If an 'id' is given, it returns only the course with that ID.
+// Otherwise, it returns the courses selected by the other parameters (see ListQuery), by default all courses sorted by start date.
+// If there are more courses than the 'limit', the 'Link' header points to the next page.
+func (a API) List(req *http.Request) interface{} {
+	if req.FormValue("id") == "" {
+		q, err := ListQuery(req)
+		if err != nil {
+			return api.ErrBadRequest(err)
+		}
+
+		page, err := a.Courses.Find(q)
+		if err != nil {
+			return api.ErrBadRequest(err)
+		}
+
+		infos := make([]info, 0, len(page.Courses))
+		for _, c := range page.Courses {
+			infos = append(infos, infoOf(c))
+		}
+		resp := api.NewSuccessNow(http.StatusOK, infos, "number of courses: %d", len(infos))
+
+		if page.Next == "" {
+			return resp
+		}
+		next := req.URL.Query()
+		next.Set("cursor", page.Next)
+		return endpoint.WithHeader{
+			Header:   http.Header{"Link": {fmt.Sprintf(`<%s?%s>; rel="next"`, req.URL.Path, next.Encode())}},
+			Response: resp,
+		}
 	}
 
-	if capacity, err = form.GetIntE(req, "capacity"); err != nil {
+	f := input.NewForm(req)
+	id := f.Uint64("id")
+	if err := f.Err(); err != nil {
 		return api.ErrBadRequest(err)
 	}
 
-	if historic, err = form.GetBoolE(req, "historic"); err != nil {
+	c, err := a.Courses.Get(id)
+	if err != nil {
+		return api.NewError(http.StatusNotFound, err)
+	}
+	return api.NewSuccessNow(http.StatusOK, []info{infoOf(c)}, "course %d", id)
+}
+
+// ListQuery returns the query for courses given by the parameters of the request (see ListSchema), e.g. to list courses in a template.
+// If a parameter is invalid, the problems are returned together with a query of the valid parameters.
+func ListQuery(req *http.Request) (courses.Query, error) {
+	f := input.NewForm(req)
+
+	q := courses.Query{
+		Name:       req.FormValue("name"),
+		Instructor: req.FormValue("instructor"),
+		Location:   req.FormValue("location"),
+		Sort:       courses.SortKey(strings.ToLower(req.FormValue("sort"))),
+		Cursor:     req.FormValue("cursor"),
+	}
+
+	if req.FormValue("from") != "" {
+		q.From = f.Date("from")
+	}
+	if req.FormValue("to") != "" {
+		q.To = f.Date("to")
+	}
+	if req.FormValue("free") != "" {
+		q.Free = f.Int("free")
+	}
+	if req.FormValue("limit") != "" {
+		q.Limit = f.Int("limit")
+		if !f.Errors.Has("limit") && q.Limit < 1 {
+			f.Add("limit", validation.Invalid, "limit value (%d) must be positive", q.Limit)
+		}
+	}
+
+	switch order := strings.ToLower(req.FormValue("order")); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		f.Add("order", validation.Invalid, "order value '%s' must be asc or desc", order)
+	}
+
+	return q, f.Err()
+}
+
+// Update is the response function to a PATCH request to '/classes'.
+//
+// It sets the new 'capacity' of the course with the given 'id'.
+func (a API) Update(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	id := f.Uint64("id")
+	capacity := f.Int("capacity")
+	if err := f.Err(); err != nil {
 		return api.ErrBadRequest(err)
 	}
 
-	if historic {
-		c, err = course.NewHistoric(name, start, end, capacity)
-	} else {
-		c, err = course.New(name, start, end, capacity)
+	c, err := a.Courses.Get(id)
+	if err != nil {
+		return api.NewError(http.StatusNotFound, err)
 	}
+
+	before := infoOf(c)
+	f.Check(c.SetCapacity(capacity))
+	if err = f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+	audit.Record(req, audit.CourseUpdated, audit.Course(id), before, infoOf(c))
+	return api.NewSuccessNow(http.StatusOK, infoOf(c), "course updated")
+}
+
+// Cancel is the response function to a DELETE request to '/classes'.
+//
+// It cancels the class of the course with the given 'id' on the given 'date', and all attendees get their payment or credit back.
+// Optionally, a 'timeout' parameter can be given.
+func (a API) Cancel(req *http.Request) interface{} {
+	ctx, cancel := context.WithUserTimeout(req)
+	defer cancel()
+
+	return a.cancelClass(ctx, req)
+}
+
+// Create is the response function to a POST request to '/classes'.
+//
+// It parses the form input for the course 'name', the 'start' and 'end' dates of the course, and the 'capacity' of the course. (The 'name' parameter is transformed into title case.)
+// Optionally, a 'timeout' and 'historic' parameter can be given. The latter signifies whether or not we want to allow the course to be in the past.
+// The optional 'time' parameter (e.g. '18:30') is the time of day the classes start, the optional 'duration' (e.g. '1h') how long they last.
+// If the 'members' flag is set, the course can only be booked with a membership plan or pass.
+// The optional 'instructor' and 'location' tell who teaches the course and where. If the API has rooms, the location must be one of them.
+// The optional 'price' of a class (e.g. '12.50') is in the given 'currency' (EUR by default). Prices of single classes can be set with 'classprices', e.g. '2020-12-24:20.00,2020-12-31:20.00'.
+//
+// Booking rules can be set with the optional parameters 'advance' (bookings open that many days in advance), 'cutoff' (bookings close that long before a class starts, e.g. '2h'), 'weekly' (maximum active bookings per member and week), and 'blocked' (comma-separated names of members that may not book).
+//
+// If any input does not make sense, an error is returned. Otherwise, the course is added to the list of courses.
+func (a API) Create(req *http.Request) interface{} {
+	ctx, cancel := context.WithUserTimeout(req)
+	defer cancel()
+
+	c, rs, err := a.parse(req)
 	if err != nil {
 		return api.ErrBadRequest(err)
 	}
 
-	errChan := make(chan error)
+	// whoever comes first decides: either the course is added and reported as created, or we stopped waiting and it isn't added
+	const (
+		undecided int32 = iota
+		adding
+		abandoned
+	)
+	var state int32
+
+	// buffered, so that the goroutine can finish even if we stopped waiting
+	errChan := make(chan error, 1)
 	go func() <-chan error {
 		// for now: add to arrays and map, check for duplicates (quick)
 		// later: add to database (potentially slow)
 
-		errChan <- courses.Add(c)
+		if !atomic.CompareAndSwapInt32(&state, undecided, adding) {
+			errChan <- ctx.Err()
+			return errChan
+		}
+
+		// configure the rules first so that nobody can book the course without them
+		if err := rules.Configure(c.ID(), rs); err != nil {
+			errChan <- err
+			return errChan
+		}
+		if err := a.Courses.Add(c); err != nil {
+			rules.Configure(c.ID(), nil)
+			errChan <- err
+			return errChan
+		}
+		audit.Record(req, audit.CourseCreated, audit.Course(c.ID()), nil, infoOf(c))
+
+		errChan <- nil
 		return errChan
 	}()
 
-	// timout if necessary
+	// timout if necessary, unless the course is already being added
 	select {
 	case err = <-errChan:
-		if err != nil {
-			return api.ErrWrap(err)
-		}
-		// return new information about the course, such as ID and number of classes
-		return api.NewSuccessNow(http.StatusCreated, struct {
-			ID       uint64
-			Name     string
-			Start    civil.Date
-			End      civil.Date
-			Capacity int
-			Classes  int
-		}{
-			c.ID(),
-			c.Name(),
-			c.Start(),
-			c.End(),
-			c.Capacity(),
-			c.NumClasses(),
-		}, "course created")
 	case <-ctx.Done():
-		return api.ErrWrap(ctx.Err())
+		if atomic.CompareAndSwapInt32(&state, undecided, abandoned) {
+			return api.ErrWrap(ctx.Err())
+		}
+		err = <-errChan
+	}
+
+	if err != nil {
+		return api.ErrWrap(err)
+	}
+	// return new information about the course, such as ID and number of classes
+	return api.NewSuccessNow(http.StatusCreated, infoOf(c), "course created")
+}
+
+// parse returns the course described by the parameters of the request and its booking rules (see Create).
+// If any parameter is missing or invalid, validation.Errors are returned.
+func (a API) parse(req *http.Request) (*course.Course, rules.List, error) {
+	var (
+		opts []course.Option
+		c    *course.Course
+		err  error
+	)
+
+	// get all the user input, collecting all problems
+
+	f := input.NewForm(req)
+
+	name := toTitleCase.String(f.String("name"))
+	start := f.Date("start")
+	end := f.Date("end")
+	capacity := f.Int("capacity")
+	historic := f.Bool("historic")
+
+	if f.Bool("members") {
+		opts = append(opts, course.RequireMembership())
+	}
+	if instructor := req.FormValue("instructor"); instructor != "" {
+		opts = append(opts, course.TaughtBy(instructor))
+	}
+	if location := req.FormValue("location"); location != "" {
+		if len(a.Rooms) > 0 && !contains(a.Rooms, location) {
+			f.Add("location", validation.Invalid, "location value '%s' must be one of the rooms: %s", location, strings.Join(a.Rooms, ", "))
+		}
+		opts = append(opts, course.HeldAt(location))
+	}
+
+	opts = getPrices(f, req, opts)
+
+	if req.FormValue("time") != "" {
+		if at, err := time.Parse("15:04", req.FormValue("time")); err != nil {
+			f.Add("time", validation.Malformed, "time value '%s' could not be parsed to time of day", req.FormValue("time"))
+		} else {
+			opts = append(opts, course.StartingAt(civil.TimeOf(at)))
+		}
+	}
+
+	if req.FormValue("duration") != "" {
+		if d, err := time.ParseDuration(req.FormValue("duration")); err != nil {
+			f.Add("duration", validation.Malformed, "duration value '%s' could not be parsed to duration", req.FormValue("duration"))
+		} else {
+			opts = append(opts, course.Lasting(d))
+		}
+	}
+
+	rs := getRules(f, req)
+
+	// the course checks the values, too
+	if historic {
+		c, err = course.NewHistoric(name, start, end, capacity, opts...)
+	} else {
+		c, err = course.New(name, start, end, capacity, opts...)
+	}
+	f.Check(err)
+
+	if err = f.Err(); err != nil {
+		return nil, nil, err
+	}
+	return c, rs, nil
+}
+
+// getRules parses the optional booking rules of a course.
+func getRules(f *input.Form, req *http.Request) rules.List {
+	rs := rules.List{}
+
+	if req.FormValue("blocked") != "" {
+		var blocked []string
+		for _, name := range strings.Split(req.FormValue("blocked"), ",") {
+			// e.g. "Arnold, Bruce"
+			if name = strings.TrimSpace(name); name != "" {
+				blocked = append(blocked, name)
+			}
+		}
+		if len(blocked) > 0 {
+			rs = append(rs, rules.Blocked{Members: blocked})
+		}
+	}
+
+	if req.FormValue("advance") != "" {
+		if days := f.Int("advance"); days < 0 {
+			f.Add("advance", validation.Invalid, "advance value (%d) must not be negative", days)
+		} else {
+			rs = append(rs, rules.AdvanceWindow{Days: days})
+		}
+	}
+
+	if req.FormValue("cutoff") != "" {
+		if d, err := time.ParseDuration(req.FormValue("cutoff")); err != nil {
+			f.Add("cutoff", validation.Malformed, "cutoff value '%s' could not be parsed to duration", req.FormValue("cutoff"))
+		} else {
+			rs = append(rs, rules.Cutoff{Before: d})
+		}
+	}
+
+	if req.FormValue("weekly") != "" {
+		if max := f.Int("weekly"); max > 0 {
+			rs = append(rs, rules.WeeklyLimit{Max: max})
+		} else if !f.Errors.Has("weekly") {
+			f.Add("weekly", validation.Invalid, "weekly value (%d) must be positive", max)
+		}
+	}
+
+	return rs
+}
+
+// getPrices parses the optional prices of a course and adds them to the options.
+func getPrices(f *input.Form, req *http.Request, opts []course.Option) []course.Option {
+	currency := money.DefaultCurrency
+	if req.FormValue("currency") != "" {
+		currency = req.FormValue("currency")
+	}
+
+	if req.FormValue("price") != "" {
+		if a, err := money.Parse(req.FormValue("price"), currency); err != nil {
+			f.Add("price", validation.Malformed, "price value invalid: %s", err)
+		} else {
+			opts = append(opts, course.Priced(a))
+		}
+	}
+
+	if req.FormValue("classprices") == "" {
+		return opts
+	}
+
+	for _, cp := range strings.Split(req.FormValue("classprices"), ",") {
+		ds, as, ok := strings.Cut(cp, ":")
+		if !ok {
+			f.Add("classprices", validation.Malformed, "classprices value '%s' must be of the form 'date:price'", cp)
+			continue
+		}
+		date, err := civil.ParseDate(ds)
+		if err != nil {
+			f.Add("classprices", validation.Malformed, "classprices value '%s' could not be parsed to date", ds)
+			continue
+		}
+		a, err := money.Parse(as, currency)
+		if err != nil {
+			f.Add("classprices", validation.Malformed, "classprices value invalid: %s", err)
+			continue
+		}
+		opts = append(opts, course.PricedOn(date, a))
+	}
+
+	return opts
+}
+
+// contains returns whether the list contains the string.
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
 	}
+	return false
 }
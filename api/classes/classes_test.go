@@ -1,13 +1,30 @@
 package classes
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/payments/fake"
+	"github.com/MarkRosemaker/booking-system/rules"
+	"github.com/MarkRosemaker/booking-system/validation"
 	"github.com/MarkRosemaker/go-server/server/api"
 )
 
@@ -24,25 +41,37 @@ func TestRespond(t *testing.T) {
 	}{
 		// test all errors
 		{"",
-			"400 Bad Request: name value not provided"},
+			"400 Bad Request: name value not provided; start value not provided; end value not provided; capacity value not provided"},
 		{"?name=Karate",
-			"400 Bad Request: start value not provided"},
+			"400 Bad Request: start value not provided; end value not provided; capacity value not provided"},
 		{"?name=Karate&start=now",
-			"400 Bad Request: start value 'now' could not be parsed to date"},
+			"400 Bad Request: start value 'now' could not be parsed to date; end value not provided; capacity value not provided"},
 		{"?name=Karate&start=2010-30-02",
-			"400 Bad Request: start value '2010-30-02' could not be parsed to date"},
+			"400 Bad Request: start value '2010-30-02' could not be parsed to date; end value not provided; capacity value not provided"},
 		{"?name=Karate&start=2010-01-02",
-			"400 Bad Request: end value not provided"},
+			"400 Bad Request: end value not provided; capacity value not provided"},
 		{"?name=Karate&start=2010-01-01&end=2010-02-01",
-			"400 Bad Request: capacity value not provided"},
+			"400 Bad Request: capacity value not provided; invalid course parameters: course is in the past"},
 		{"?name=Mesoamerican_Ballgame&start=2010-01-01&end=2010-02-01&capacity=10",
 			"400 Bad Request: invalid course parameters: course is in the past"},
 		{"?name=Time_Travelling&start=2015-10-21&end=1985-10-26&capacity=10&historic=true",
 			"400 Bad Request: invalid course parameters: start date (2015-10-21) after end date (1985-10-26)"},
 		{fmt.Sprintf("?name=Karate&start=%s&end=%s&capacity=10", today.AddDays(100), today.AddDays(10)),
 			fmt.Sprintf("400 Bad Request: invalid course parameters: start date (%s) after end date (%s)", today.AddDays(100), today.AddDays(10))},
+		{"?name=&start=2015-10-21&end=1985-10-26&capacity=0&historic=true&weekly=0&price=free",
+			"400 Bad Request: name value not provided; price value invalid: amount 'free' could not be parsed; weekly value (0) must be positive; invalid course parameters: start date (2015-10-21) after end date (1985-10-26); invalid course parameters: capacity (0) must be positive"},
 		{fmt.Sprintf("?name=Negative&start=%s&end=%s&capacity=-10", today, today),
 			"400 Bad Request: invalid course parameters: capacity (-10) must be positive"},
+		{fmt.Sprintf("?name=Boxing&start=%s&end=%s&capacity=10&time=late", today, today),
+			"400 Bad Request: time value 'late' could not be parsed to time of day"},
+		{fmt.Sprintf("?name=Boxing&start=%s&end=%s&capacity=10&cutoff=soon", today, today),
+			"400 Bad Request: cutoff value 'soon' could not be parsed to duration"},
+		{fmt.Sprintf("?name=Boxing&start=%s&end=%s&capacity=10&weekly=0", today, today),
+			"400 Bad Request: weekly value (0) must be positive"},
+		{fmt.Sprintf("?name=Boxing&start=%s&end=%s&capacity=10&price=free", today, today),
+			"400 Bad Request: price value invalid: amount 'free' could not be parsed"},
+		{fmt.Sprintf("?name=Boxing&start=%s&end=%s&capacity=10&price=10.00&classprices=%s:5.00", today, today, today.AddDays(1)),
+			fmt.Sprintf("400 Bad Request: invalid course parameters: price date (%s) not within the timeframe of the course", today.AddDays(1))},
 
 		// successful course creation
 		{judoToday,
@@ -81,6 +110,8 @@ func TestRespond(t *testing.T) {
 		}
 	}
 
+	testCancel(t)
+
 	// "Ex: If a class by name pilates starts on 1st Dec and ends on 20th Dec, with capacity 10, that means Pilates has 20 classes and for each class the maximum capacity of attendance is 10."
 	resp := Respond(httptest.NewRequest("GET", "/classes?name=pilates&start=2019-12-01&end=2019-12-20&capacity=10&historic=true", nil))
 	if s, ok := resp.(api.Success); ok {
@@ -94,4 +125,598 @@ func TestRespond(t *testing.T) {
 	} else {
 		t.Fatalf("Didn't receive api.Success when testing the example in the challenge specification, got: %T", resp)
 	}
+
+	resp = Respond(httptest.NewRequest("GET", fmt.Sprintf("/classes?name=spinning&start=%s&end=%s&capacity=10&price=12.50&currency=usd", today, today), nil))
+	if s, ok := resp.(api.Success); ok {
+		if price := reflect.ValueOf(s.Object).FieldByName("Price").Interface(); price != money.New(1250, "USD") {
+			t.Fatalf("Spinning should cost 12.50 USD, costs: %s", price)
+		}
+	} else {
+		t.Fatalf("Didn't receive api.Success when creating a paid course, got: %T", resp)
+	}
+}
+
+func testCancel(t *testing.T) {
+	today := civil.DateOf(time.Now())
+
+	resp := Respond(httptest.NewRequest("GET", fmt.Sprintf("/classes?name=Aerobics&start=%s&end=%s&capacity=10", today, today.AddDays(3)), nil))
+	s, ok := resp.(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course to cancel: %v", resp)
+	}
+	id := reflect.ValueOf(s.Object).FieldByName("ID").Uint()
+
+	c, err := courses.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.BookClass("Arnold", today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		params string
+		res    string
+	}{
+		{"?cancel=true",
+			"400 Bad Request: id value not provided; date value not provided"},
+		{fmt.Sprintf("?cancel=true&id=%d", id),
+			"400 Bad Request: date value not provided"},
+		{fmt.Sprintf("?cancel=true&id=%d&date=%s", id, today.AddDays(4)),
+			"400 Bad Request: the chosen date is not within the timeframe of the course"},
+		{fmt.Sprintf("?cancel=true&id=%d&date=%s", id, today.AddDays(1)),
+			"class cancelled, number of attendees refunded: 1"},
+		{fmt.Sprintf("?cancel=true&id=%d&date=%s", id, today.AddDays(1)),
+			"400 Bad Request: the class has already been cancelled"},
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/classes%s", table.params)
+		resp := Respond(httptest.NewRequest("GET", url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.",
+					url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.",
+					url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", url, resp)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tables := []struct {
+		role auth.Role
+		url  string
+		ok   bool
+	}{
+		{auth.RoleAdmin, "/classes?name=Judo", true},
+		{auth.RoleInstructor, "/classes?name=Judo", false},
+		{auth.RoleMember, "/classes?name=Judo", false},
+		{auth.RoleAdmin, "/classes?cancel=true&id=1", true},
+		{auth.RoleInstructor, "/classes?cancel=true&id=1", true},
+		{auth.RoleMember, "/classes?cancel=true&id=1", false},
+	}
+
+	for _, table := range tables {
+		err := Authorize(auth.Principal{Name: "Test", Role: table.role}, httptest.NewRequest("GET", table.url, nil))
+		if table.ok != (err == nil) {
+			t.Errorf("authorization of %s for %s was incorrect, got: %v", table.role, table.url, err)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	today := civil.DateOf(time.Now())
+
+	resp := Create(httptest.NewRequest("POST", fmt.Sprintf("/classes?name=Tai_Chi&start=%s&end=%s&capacity=10", today, today.AddDays(3)), nil))
+	s, ok := resp.(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+	id := reflect.ValueOf(s.Object).FieldByName("ID").Uint()
+
+	tables := []struct {
+		method string
+		params string
+		res    string
+	}{
+		{"GET", fmt.Sprintf("?id=%d", id),
+			fmt.Sprintf("course %d", id)},
+		{"GET", "?id=0",
+			"404 Not Found: course with id 0 does not exist"},
+		{"PATCH", "",
+			"400 Bad Request: id value not provided; capacity value not provided"},
+		{"PATCH", fmt.Sprintf("?id=%d", id),
+			"400 Bad Request: capacity value not provided"},
+		{"PATCH", fmt.Sprintf("?id=%d&capacity=0", id),
+			"400 Bad Request: invalid course parameters: capacity (0) must be positive"},
+		{"PATCH", "?id=0&capacity=20",
+			"404 Not Found: course with id 0 does not exist"},
+		{"PATCH", fmt.Sprintf("?id=%d&capacity=20", id),
+			"course updated"},
+		{"DELETE", fmt.Sprintf("?id=%d&date=%s", id, today.AddDays(1)),
+			"class cancelled, number of attendees refunded: 0"},
+	}
+
+	handlers := map[string]func(*http.Request) interface{}{
+		"GET":    List,
+		"PATCH":  Update,
+		"DELETE": Cancel,
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/classes%s", table.params)
+		resp := handlers[table.method](httptest.NewRequest(table.method, url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s %s was incorrect, got: %q, want: %q.",
+					table.method, url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s %s was incorrect, got: '%s', want: '%s'.",
+					table.method, url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s %s has wrong type, expected: api.Error or api.Success, got: %T", table.method, url, resp)
+		}
+	}
+
+	c, err := courses.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Capacity() != 20 {
+		t.Errorf("capacity wasn't updated, got: %d", c.Capacity())
+	}
+
+	resp = List(httptest.NewRequest("GET", "/classes", nil))
+	if s, ok := resp.(api.Success); !ok || reflect.ValueOf(s.Object).Len() == 0 {
+		t.Errorf("listing all courses didn't return the course, got: %v", resp)
+	}
+}
+
+func TestList(t *testing.T) {
+	today := civil.DateOf(time.Now())
+
+	for _, params := range []string{
+		"name=Listed+Karate&instructor=Ann&location=Dojo",
+		"name=Listed+Judo&instructor=Bob&location=Dojo",
+		"name=Listed+Aikido&instructor=Ann",
+	} {
+		url := fmt.Sprintf("/classes?%s&start=%s&end=%s&capacity=10", params, today, today.AddDays(3))
+		if resp := Create(httptest.NewRequest("POST", url, nil)); reflect.TypeOf(resp) != reflect.TypeOf(api.Success{}) {
+			t.Fatalf("couldn't create course: %v", resp)
+		}
+	}
+
+	tables := []struct {
+		params string
+		names  string
+		next   bool
+		err    string
+	}{
+		{"name=listed", "Listed Karate,Listed Judo,Listed Aikido", false, ""},
+		{"name=listed&sort=name", "Listed Aikido,Listed Judo,Listed Karate", false, ""},
+		{"name=listed&sort=NAME&order=desc", "Listed Karate,Listed Judo,Listed Aikido", false, ""},
+		{"name=listed&instructor=ann&location=dojo", "Listed Karate", false, ""},
+		{"name=listed&free=10", "Listed Karate,Listed Judo,Listed Aikido", false, ""},
+		{"name=listed&free=11", "", false, ""},
+		{fmt.Sprintf("name=listed&from=%s", today.AddDays(4)), "", false, ""},
+		{"name=listed&sort=name&limit=2", "Listed Aikido,Listed Judo", true, ""},
+
+		// errors
+		{"sort=price&order=up&limit=0&free=x",
+			"", false, "400 Bad Request: free value 'x' could not be parsed to int; limit value (0) must be positive; order value 'up' must be asc or desc"},
+		{"sort=price", "", false, "400 Bad Request: sort value 'price' must be one of: start, end, name"},
+		{"cursor=abc", "", false, "400 Bad Request: cursor value is not a cursor"},
+	}
+
+	for _, table := range tables {
+		// the endpoint writes the headers, the response is kept to check it
+		var resp interface{}
+		e := endpoint.Methods{
+			BaseEndpoint: api.BaseEndpoint{URL: "/classes"},
+			Handlers: endpoint.Handlers{"GET": func(req *http.Request) interface{} {
+				resp = List(req)
+				return resp
+			}},
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", "/classes?"+table.params, nil))
+
+		if wh, ok := resp.(endpoint.WithHeader); ok {
+			resp = wh.Response
+		}
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.err {
+				t.Errorf("listing with %s failed, got: %q, want: %q", table.params, s, table.err)
+			}
+			continue
+		case api.Success:
+			if table.err != "" {
+				t.Errorf("listing with %s should have failed with %q", table.params, table.err)
+				continue
+			}
+
+			infos := v.Object.([]info)
+			names := make([]string, len(infos))
+			for i, info := range infos {
+				names[i] = info.Name
+			}
+			if got := strings.Join(names, ","); got != table.names {
+				t.Errorf("listing with %s returned the wrong courses, got: %s, want: %s", table.params, got, table.names)
+			}
+		default:
+			t.Errorf("listing with %s has wrong type, expected: api.Error or api.Success, got: %T", table.params, resp)
+			continue
+		}
+
+		link := w.Header().Get("Link")
+		if (link != "") != table.next {
+			t.Errorf("listing with %s had the wrong 'Link' header: %q", table.params, link)
+		}
+		if table.next && (!strings.Contains(link, "cursor=") || !strings.Contains(link, "limit=2") || !strings.HasSuffix(link, `rel="next"`)) {
+			t.Errorf("listing with %s had a wrong link to the next page: %q", table.params, link)
+		}
+	}
+}
+
+// TestDoc checks that the responses are of the documented types.
+func TestDoc(t *testing.T) {
+	today := civil.DateOf(time.Now())
+
+	requests := map[string]string{
+		"POST": fmt.Sprintf("/classes?name=Yin_Yoga&start=%s&end=%s&capacity=10", today, today.AddDays(3)),
+		"GET":  "/classes",
+	}
+
+	for _, method := range []string{"POST", "GET"} {
+		op, ok := Doc.Operation(method)
+		if !ok {
+			t.Fatalf("%s is not documented", method)
+		}
+
+		resp := op.Respond(httptest.NewRequest(method, requests[method], nil))
+		s, ok := resp.(api.Success)
+		if !ok {
+			t.Fatalf("%s %s failed: %v", method, requests[method], resp)
+		}
+		if got, want := reflect.TypeOf(s.Object), reflect.TypeOf(op.Response); got != want {
+			t.Errorf("%s returns %s, but %s is documented", method, got, want)
+		}
+		if op.Status != 0 && s.Status != op.Status {
+			t.Errorf("%s returns the status %d, but %d is documented", method, s.Status, op.Status)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	create := input.JSON(CreateSchema, Create)
+
+	tables := []struct {
+		body string
+		res  string
+	}{
+		{fmt.Sprintf(`{"name": "qigong", "start": "%s", "end": "%s", "capacity": 10, "blocked": ["Arnold"], "classprices": ["%s:20.00"]}`, today, today.AddDays(2), today),
+			"course created"},
+		{`{"name": "qigong", "start": "today", "capacity": "10", "cancel": true}`,
+			"400 Bad Request: start value 'today' could not be parsed to date; end value not provided; capacity value must be an integer; cancel is not a known field"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("POST", "/classes", strings.NewReader(table.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		switch v := create(req).(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.body, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.", table.body, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", table.body, v)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	studio := New(courses.NewRegistry())
+
+	url := fmt.Sprintf("/classes?name=Aikido&start=%s&end=%s&capacity=10&blocked=Arnold,%%20Bruce,", today, today.AddDays(3))
+	resp, ok := studio.Create(httptest.NewRequest("POST", url, nil)).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+	id := reflect.ValueOf(resp.Object).FieldByName("ID").Uint()
+
+	want := rules.List{rules.Blocked{Members: []string{"Arnold", "Bruce"}}}
+	if got := rules.Of(id); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong rules, got: %v, want: %v", got, want)
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	studio, other := New(courses.NewRegistry()), New(courses.NewRegistry())
+
+	url := fmt.Sprintf("/classes?name=Capoeira&start=%s&end=%s&capacity=10", today, today.AddDays(3))
+	resp, ok := studio.Create(httptest.NewRequest("POST", url, nil)).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+	id := reflect.ValueOf(resp.Object).FieldByName("ID").Uint()
+
+	tables := []struct {
+		api  API
+		name string
+		n    int
+	}{
+		{studio, "studio", 1},
+		{other, "other registry", 0},
+		{New(courses.Default), "default registry", 0},
+	}
+
+	for _, table := range tables {
+		resp, ok := table.api.List(httptest.NewRequest("GET", "/classes?name=capoeira", nil)).(api.Success)
+		if !ok {
+			t.Fatalf("couldn't list courses of %s: %v", table.name, resp)
+		}
+		if n := reflect.ValueOf(resp.Object).Len(); n != table.n {
+			t.Errorf("wrong number of courses in %s, got: %d, want: %d", table.name, n, table.n)
+		}
+	}
+
+	if _, ok := other.Update(httptest.NewRequest("PATCH", fmt.Sprintf("/classes?id=%d&capacity=20", id), nil)).(api.Error); !ok {
+		t.Errorf("could update course of another registry")
+	}
+}
+
+func TestImport(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+
+	existing := fmt.Sprintf("/classes?name=Tai+Chi&start=%s&end=%s&capacity=10", today, today.AddDays(3))
+	if resp, ok := a.Create(httptest.NewRequest("POST", existing, nil)).(api.Success); !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+
+	csvFile := func(rows ...string) string {
+		return strings.Join(append([]string{"name,start,end,capacity,time,blocked"}, rows...), "\n")
+	}
+	row := func(name string, start, end civil.Date) string {
+		return fmt.Sprintf("%s,%s,%s,10,18:30,\"Arnold,Bruce\"", name, start, end)
+	}
+
+	tables := []struct {
+		contentType string
+		body        string
+		dryRun      bool
+		status      int
+		fields      []string // of the problems
+		added       int
+	}{
+		// a dry run only checks the courses
+		{"text/csv", csvFile(row("Yoga", today, today.AddDays(6)), row("Pilates", today, today.AddDays(6))), true, http.StatusOK, nil, 0},
+		{"text/csv", csvFile(row("Yoga", today, today.AddDays(6)), row("Pilates", today, today.AddDays(6))), false, http.StatusCreated, nil, 2},
+		{"text/csv; charset=utf-8", "\ufeffName;Start;End;Capacity\nZumba;" + today.String() + ";" + today.String() + ";5\n;;;\n", false, http.StatusCreated, nil, 1},
+		{"application/json", fmt.Sprintf(`[{"name": "Spinning", "start": "%s", "end": "%s", "capacity": 8, "blocked": ["Arnold"]}]`, today, today), false, http.StatusCreated, nil, 1},
+		// all problems at once, by row
+		{"text/csv", csvFile(row("Boxing", today, today), "Boxing,tomorrow,,10,,", row("Karate", today.AddDays(2), today)), false, http.StatusBadRequest, []string{"3.start", "3.end", "4.end"}, 0},
+		{"text/csv", csvFile(row("Boxing", today, today), "Boxing,tomorrow,,10,,", row("Karate", today.AddDays(2), today)), true, http.StatusBadRequest, []string{"3.start", "3.end", "4.end"}, 0},
+		{"application/json", fmt.Sprintf(`[{"name": "Boxing", "start": "%s", "end": "%s", "capacity": 8}, {"name": "Karate", "capacity": "8", "belt": "black"}, 7]`, today, today), false, http.StatusBadRequest, []string{"2.start", "2.end", "2.capacity", "2.belt", "3"}, 0},
+		// duplicates of existing courses and within the import
+		{"text/csv", csvFile(row("Boxing", today, today), row("Tai Chi", today, today.AddDays(3))), false, http.StatusBadRequest, []string{"3"}, 0},
+		{"text/csv", csvFile(row("Boxing", today, today), row("Boxing", today, today)), true, http.StatusBadRequest, []string{"3"}, 0},
+		// the file itself is wrong
+		{"text/csv", "name,start,end,capacity,colour\nBoxing,2020-01-01,2020-01-01,10,red", false, http.StatusBadRequest, []string{"colour"}, 0},
+		{"text/csv", "name,start,end,capacity", false, http.StatusBadRequest, nil, 0},
+		{"application/json", `{"name": "Boxing"}`, false, http.StatusBadRequest, nil, 0},
+		{"text/plain", csvFile(row("Boxing", today, today)), false, http.StatusUnsupportedMediaType, nil, 0},
+	}
+
+	for i, table := range tables {
+		before := len(a.Courses.All())
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/v1/classes/import?dryrun=%t", table.dryRun), strings.NewReader(table.body))
+		req.Header.Set("Content-Type", table.contentType)
+
+		status := 0
+		switch resp := a.Import(req).(type) {
+		case api.Success:
+			status = resp.Status
+			if n := reflect.ValueOf(resp.Object).FieldByName("Courses").Len(); n != table.added && !table.dryRun {
+				t.Errorf("%d: %d courses returned, want: %d", i, n, table.added)
+			}
+		case api.Error:
+			status = resp.Status
+			var errs validation.Errors
+			errors.As(resp, &errs)
+			fields := make([]string, len(errs))
+			for j, e := range errs {
+				fields[j] = e.Field
+			}
+			if strings.Join(fields, " ") != strings.Join(table.fields, " ") {
+				t.Errorf("%d: problems with the wrong fields, got: %v, want: %v\n%s", i, fields, table.fields, resp)
+			}
+		}
+		if status != table.status {
+			t.Errorf("%d: wrong status, got: %d, want: %d", i, status, table.status)
+		}
+		if added := len(a.Courses.All()) - before; added != table.added {
+			t.Errorf("%d: %d courses added, want: %d", i, added, table.added)
+		}
+	}
+
+	// the rules of the courses are configured
+	page, err := a.Courses.Find(courses.Query{Name: "yoga"})
+	if err != nil || len(page.Courses) != 1 {
+		t.Fatalf("imported course not found: %v", err)
+	}
+	if rs := rules.Of(page.Courses[0].ID()); len(rs) != 1 {
+		t.Errorf("imported course has %d rules, want: 1", len(rs))
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	f, err := w.CreateFormFile("file", "season.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "name,start,end,capacity\nYoga,%s,%s,10\n", today, today.AddDays(6))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/v1/classes/import", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	if resp, ok := a.Import(req).(api.Success); !ok || resp.Status != http.StatusCreated {
+		t.Fatalf("couldn't import uploaded file: %v", resp)
+	}
+	if n := len(a.Courses.All()); n != 1 {
+		t.Errorf("%d courses imported, want: 1", n)
+	}
+}
+
+func TestCreateTimeout(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+
+	// whether the timeout or the course comes first, the response tells what happened
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("Kendo %d", i)
+		url := fmt.Sprintf("/classes?name=%s&start=%s&end=%s&capacity=10&timeout=1ns", strings.ReplaceAll(name, " ", "+"), today, today.AddDays(3))
+		_, created := a.Create(httptest.NewRequest("POST", url, nil)).(api.Success)
+
+		page, err := a.Courses.Find(courses.Query{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added := len(page.Courses) == 1; added != created {
+			t.Fatalf("course %q added: %t, but reported as created: %t", name, added, created)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	a := New(courses.NewRegistry())
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/classes?name=Aikido&start=%s&end=%s&capacity=10", today, today.AddDays(3)), nil)
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Name: "admin", Role: auth.RoleAdmin}))
+	resp, ok := a.Create(req).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+	id := resp.Object.(info).ID
+
+	if resp, ok := a.Update(httptest.NewRequest("PATCH", fmt.Sprintf("/classes?id=%d&capacity=20", id), nil)).(api.Success); !ok {
+		t.Fatalf("couldn't update course: %v", resp)
+	}
+	if resp, ok := a.Cancel(httptest.NewRequest("DELETE", fmt.Sprintf("/classes?id=%d&date=%s", id, today.AddDays(1)), nil)).(api.Success); !ok {
+		t.Fatalf("couldn't cancel class: %v", resp)
+	}
+
+	es, _ := audit.Find(audit.Filter{Target: audit.Course(id)})
+	if len(es) != 3 || es[0].Action != audit.CourseCreated || es[0].Actor.Name != "admin" || es[1].Action != audit.CourseUpdated || es[2].Action != audit.ClassCancelled {
+		t.Fatalf("wrong entries: %+v", es)
+	}
+	if before, after := es[1].Before.(info), es[1].After.(info); before.Capacity != 10 || after.Capacity != 20 {
+		t.Errorf("wrong values of the update: %+v, %+v", before, after)
+	}
+	if es[2].Target != audit.Class(id, today.AddDays(1)) || !es[2].After.(classState).Cancelled {
+		t.Errorf("wrong entry of the cancellation: %+v", es[2])
+	}
+}
+
+func TestCancelRefundFails(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	studio := New(courses.NewRegistry())
+
+	url := fmt.Sprintf("/classes?name=Sailing&start=%s&end=%s&capacity=10&price=10.00", today, today.AddDays(3))
+	resp, ok := studio.Create(httptest.NewRequest("POST", url, nil)).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create course: %v", resp)
+	}
+	id := reflect.ValueOf(resp.Object).FieldByName("ID").Uint()
+	c, err := studio.Courses.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hanna and Bruce paid for the class, but Bruce with another gateway
+	date := today.AddDays(1)
+	g, other := fake.New(0), fake.New(0)
+	defer payments.Use(fake.New(0))
+	pay := func(g *fake.Gateway, name string) payments.Receipt {
+		r, err := g.Charge(context.Background(), payments.Charge{Member: name, Amount: money.New(1000, "EUR"), Token: "tok"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.BookClass(name, date); err != nil {
+			t.Fatal(err)
+		}
+		b := ledger.Booking{Course: id, Member: name, Date: date}
+		ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindCharge, Amount: r.Charge.Amount, Receipt: &r})
+		return r
+	}
+	hanna, bruce := pay(g, "Hanna"), pay(other, "Bruce")
+
+	// the gateway doesn't know Bruce's payment, so it can't refund it
+	payments.Use(g)
+	cancelURL := fmt.Sprintf("/classes?id=%d&date=%s", id, date)
+	if resp, ok := studio.Cancel(httptest.NewRequest("DELETE", cancelURL, nil)).(api.Error); !ok {
+		t.Fatalf("no error even though a refund failed: %v", resp)
+	}
+	if !c.Cancelled(date) {
+		t.Errorf("class wasn't cancelled")
+	}
+	if a := g.Charged(hanna.ID); !a.IsZero() {
+		t.Errorf("%s of Hanna weren't refunded", a)
+	}
+	if _, open := ledger.OpenCharge(ledger.Booking{Course: id, Member: "Hanna", Date: date}); open {
+		t.Errorf("charge of Hanna still open")
+	}
+	if _, open := ledger.OpenCharge(ledger.Booking{Course: id, Member: "Bruce", Date: date}); !open {
+		t.Errorf("charge of Bruce closed even though it wasn't refunded")
+	}
+
+	// cancelling again refunds only Bruce
+	payments.Use(other)
+	resp, ok = studio.Cancel(httptest.NewRequest("DELETE", cancelURL, nil)).(api.Success)
+	if !ok {
+		t.Fatalf("couldn't retry the refunds: %v", resp)
+	}
+	if got := resp.Object.(cancelled).Attendees; !reflect.DeepEqual(got, []string{"Bruce"}) {
+		t.Errorf("wrong attendees refunded, got: %v", got)
+	}
+	if a := other.Charged(bruce.ID); !a.IsZero() {
+		t.Errorf("%s of Bruce weren't refunded", a)
+	}
+
+	// with everything refunded, the class can't be cancelled again
+	if resp, ok := studio.Cancel(httptest.NewRequest("DELETE", cancelURL, nil)).(api.Error); !ok {
+		t.Errorf("cancelled class twice: %v", resp)
+	}
 }
@@ -0,0 +1,37 @@
+package classes
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// Respond is the legacy response function for the default registry (see API.Respond).
+func Respond(req *http.Request) interface{} {
+	return New(courses.Default).Respond(req)
+}
+
+// List is the response function to a GET request for the default registry (see API.List).
+func List(req *http.Request) interface{} {
+	return New(courses.Default).List(req)
+}
+
+// Update is the response function to a PATCH request for the default registry (see API.Update).
+func Update(req *http.Request) interface{} {
+	return New(courses.Default).Update(req)
+}
+
+// Cancel is the response function to a DELETE request for the default registry (see API.Cancel).
+func Cancel(req *http.Request) interface{} {
+	return New(courses.Default).Cancel(req)
+}
+
+// Create is the response function to a POST request for the default registry (see API.Create).
+func Create(req *http.Request) interface{} {
+	return New(courses.Default).Create(req)
+}
+
+// Import is the response function to a POST request to '/v1/classes/import' for the default registry (see API.Import).
+func Import(req *http.Request) interface{} {
+	return New(courses.Default).Import(req)
+}
@@ -0,0 +1,320 @@
+package classes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+	"github.com/MarkRosemaker/go-server/server/form"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/rules"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// MaxRows is the maximum number of courses of an import.
+const MaxRows = 1000
+
+// ImportSchema is the schema of the courses of an import (see Import): the parameters to create a course, except for 'timeout'.
+var ImportSchema = func() input.Schema {
+	s := input.Schema{}
+	for _, f := range CreateSchema {
+		if f.Name != "timeout" {
+			s = append(s, f)
+		}
+	}
+	return s
+}()
+
+// ImportParams are the parameters of an import besides the courses.
+var ImportParams = input.Schema{
+	{Name: "dryrun", Type: input.Bool, Description: "only check the courses, don't create them"},
+}
+
+// imported is what the API returns about an import.
+type imported struct {
+	DryRun  bool
+	Courses []info // in the order of the rows, without IDs in a dry run
+}
+
+// a row describes a course to import
+type row struct {
+	n      int // the number of the row, e.g. the line of a CSV file
+	values url.Values
+	err    error // a problem found while reading the row
+}
+
+// Import is the response function to a POST request to '/v1/classes/import'.
+//
+// It creates many courses at once, e.g. the schedule of a season kept in a spreadsheet.
+// The body is a CSV file with a header row naming the columns or a JSON array of objects, both with the fields of ImportSchema.
+// It can also be uploaded as the 'file' of a multipart form.
+// Every course is checked like one created by Create, including the checks for duplicates, which also compare the courses of the import with each other.
+//
+// Either all courses are created or none. If any course has a problem, all problems are returned at once.
+// Their fields start with the number of the row, e.g. '3.start', which is the line for a CSV file and the position in the array for JSON.
+// If the flag 'dryrun' is set, the courses are only checked.
+func (a API) Import(req *http.Request) interface{} {
+	dryRun, err := form.GetBoolE(req, "dryrun")
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	rows, err := readRows(req)
+	if err != nil {
+		return api.ErrWrap(err)
+	}
+
+	var (
+		cs   = make(courses.Courses, 0, len(rows))
+		rls  = make([]rules.List, 0, len(rows))
+		errs validation.Errors
+	)
+
+	for _, r := range rows {
+		if r.err != nil {
+			errs = appendRow(errs, r.n, r.err)
+			continue
+		}
+
+		c, rs, err := a.parse(&http.Request{Method: http.MethodPost, URL: &url.URL{}, Form: r.values, PostForm: url.Values{}})
+		if err != nil {
+			errs = appendRow(errs, r.n, err)
+			continue
+		}
+		cs = append(cs, c)
+		rls = append(rls, rs)
+	}
+
+	// duplicates are only checked once all courses could be created
+	if len(errs) > 0 {
+		return api.ErrBadRequest(errs)
+	}
+
+	if dryRun {
+		errs = appendRows(errs, rows, a.Courses.Check(cs))
+		if len(errs) > 0 {
+			return api.ErrBadRequest(errs)
+		}
+
+		infos := make([]info, len(cs))
+		for i, c := range cs {
+			infos[i] = infoOf(c)
+			infos[i].ID = 0 // the course doesn't exist
+		}
+		return api.NewSuccessNow(http.StatusOK, imported{true, infos}, "%d courses can be created", len(cs))
+	}
+
+	// configure the rules first so that nobody can book the courses without them
+	for i, c := range cs {
+		if err := rules.Configure(c.ID(), rls[i]); err != nil {
+			for _, c := range cs[:i] {
+				rules.Configure(c.ID(), nil)
+			}
+			return api.ErrWrap(err)
+		}
+	}
+	if errs = appendRows(errs, rows, a.Courses.AddAll(cs)); len(errs) > 0 {
+		for _, c := range cs {
+			rules.Configure(c.ID(), nil)
+		}
+		return api.ErrBadRequest(errs)
+	}
+
+	infos := make([]info, len(cs))
+	for i, c := range cs {
+		infos[i] = infoOf(c)
+		audit.Record(req, audit.CourseCreated, audit.Course(c.ID()), nil, infos[i])
+	}
+	return api.NewSuccessNow(http.StatusCreated, imported{false, infos}, "%d courses created", len(cs))
+}
+
+// appendRow adds the problems with the row with number n to the errors, their fields prefixed with the number.
+func appendRow(errs validation.Errors, n int, err error) validation.Errors {
+	var found validation.Errors
+	found.Append("", err)
+
+	for _, e := range found {
+		field := fmt.Sprint(n)
+		if e.Field != "" {
+			field += "." + e.Field
+		}
+		errs = append(errs, validation.Error{Field: field, Code: e.Code, Message: fmt.Sprintf("row %d: %s", n, e.Message)})
+	}
+	return errs
+}
+
+// appendRows adds the problems with the courses, one error or nil per row (see courses.Registry.Check).
+func appendRows(errs validation.Errors, rows []row, found []error) validation.Errors {
+	for i, err := range found {
+		if err != nil {
+			var e api.Error
+			if errors.As(err, &e) {
+				err = e.Err
+			}
+			errs = appendRow(errs, rows[i].n, err)
+		}
+	}
+	return errs
+}
+
+// the media types of uploaded files by extension
+var types = map[string]string{".csv": "text/csv", ".json": "application/json"}
+
+// readRows reads the courses to import from the body of the request or the uploaded file.
+func readRows(req *http.Request) ([]row, error) {
+	var (
+		r    io.Reader = req.Body
+		kind           = req.Header.Get("Content-Type")
+	)
+
+	t, _, _ := mime.ParseMediaType(kind)
+	if t == "multipart/form-data" {
+		f, h, err := req.FormFile("file")
+		if err != nil {
+			return nil, api.ErrBadRequest(fmt.Errorf("file could not be read: %w", err))
+		}
+		defer f.Close()
+
+		r, kind = f, h.Header.Get("Content-Type")
+		if t, _, _ = mime.ParseMediaType(kind); t != "text/csv" && t != "application/json" {
+			// browsers don't always know the type
+			kind = types[strings.ToLower(path.Ext(h.Filename))]
+		}
+	}
+
+	read := readCSV
+	switch t, _, _ = mime.ParseMediaType(kind); t {
+	case "text/csv":
+	case "application/json":
+		read = readJSON
+	default:
+		return nil, api.NewError(http.StatusUnsupportedMediaType,
+			fmt.Errorf("courses must be sent as 'text/csv' or 'application/json', not '%s'", kind))
+	}
+
+	if r == nil {
+		return nil, api.ErrBadRequest(fmt.Errorf("there are no courses to import"))
+	}
+	body, err := io.ReadAll(io.LimitReader(r, input.MaxBodySize+1))
+	if err != nil {
+		return nil, api.ErrBadRequest(fmt.Errorf("courses could not be read: %w", err))
+	}
+	if len(body) > input.MaxBodySize {
+		return nil, api.ErrBadRequest(fmt.Errorf("courses must not be larger than %d bytes", input.MaxBodySize))
+	}
+
+	rows, err := read(body)
+	if err != nil {
+		return nil, api.ErrBadRequest(err)
+	}
+
+	if len(rows) == 0 {
+		return nil, api.ErrBadRequest(fmt.Errorf("there are no courses to import"))
+	}
+	if len(rows) > MaxRows {
+		return nil, api.ErrBadRequest(fmt.Errorf("at most %d courses can be imported at once, got: %d", MaxRows, len(rows)))
+	}
+	return rows, nil
+}
+
+// readCSV reads the courses from a CSV file. The first row names the columns, empty cells are left out.
+// The values are separated by commas or, as spreadsheets do in some languages, by semicolons.
+// If a column is unknown, validation.Errors are returned.
+func readCSV(body []byte) ([]row, error) {
+	// spreadsheets may start the file with a byte order mark
+	text := strings.TrimPrefix(string(body), "\ufeff")
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.TrimLeadingSpace = true
+	if first, _, _ := strings.Cut(text, "\n"); !strings.Contains(first, ",") && strings.Contains(first, ";") {
+		r.Comma = ';'
+	}
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV file could not be read: %w", err)
+	}
+
+	var errs validation.Errors
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case !known(header[i]):
+			errs.Add(header[i], validation.Unknown, "column %s is not a known field", header[i])
+		case contains(header[:i], header[i]):
+			errs.Add(header[i], validation.Invalid, "column %s must not be given twice", header[i])
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var rows []row
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV file could not be read: %w", err)
+		}
+
+		values := url.Values{}
+		for i, cell := range record {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				values.Set(header[i], cell)
+			}
+		}
+		// skip empty rows, e.g. at the end of a spreadsheet
+		if len(values) == 0 {
+			continue
+		}
+
+		line, _ := r.FieldPos(0)
+		rows = append(rows, row{n: line, values: values})
+	}
+}
+
+// readJSON reads the courses from a JSON array of objects.
+func readJSON(body []byte) ([]row, error) {
+	var objs []json.RawMessage
+	if err := json.Unmarshal(body, &objs); err != nil {
+		return nil, fmt.Errorf("courses must be a JSON array of objects")
+	}
+
+	rows := make([]row, len(objs))
+	for i, obj := range objs {
+		rows[i].n = i + 1
+		if rows[i].values, rows[i].err = input.DecodeObject(obj, ImportSchema); rows[i].err != nil {
+			var errs validation.Errors
+			if !errors.As(rows[i].err, &errs) {
+				rows[i].err = fmt.Errorf("course must be a JSON object")
+			}
+		}
+	}
+	return rows, nil
+}
+
+// known returns whether the field is part of ImportSchema.
+func known(name string) bool {
+	for _, f := range ImportSchema {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package classes
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// ListSchema is the schema of the query parameters to list courses (see List).
+var ListSchema = input.Schema{
+	{Name: "id", Type: input.Int, Description: "only return the course with this ID, the other parameters are ignored"},
+	{Name: "name", Type: input.String, Description: "only courses whose name contains this, not case-sensitive"},
+	{Name: "from", Type: input.Date, Description: "only courses that end on or after this date"},
+	{Name: "to", Type: input.Date, Description: "only courses that start on or before this date"},
+	{Name: "instructor", Type: input.String, Description: "only courses taught by this instructor"},
+	{Name: "location", Type: input.String, Description: "only courses held at this location"},
+	{Name: "free", Type: input.Int, Description: "only courses with a future class that has at least this many free places"},
+	{Name: "sort", Type: input.String, Description: "sort by 'start' (default), 'end' or 'name'"},
+	{Name: "order", Type: input.String, Description: "'asc' (default) or 'desc'"},
+	{Name: "limit", Type: input.Int, Description: "the number of courses per page, at most 500, all if not set"},
+	{Name: "cursor", Type: input.String, Description: "where the page starts, taken from the 'Link' header of the previous page"},
+}
+
+// Doc documents the endpoint '/v1/classes' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/v1/classes",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodGet,
+			Summary:     "List courses or get one course",
+			Description: "If there are more courses than the 'limit', the 'Link' header points to the next page.",
+			Params:      ListSchema,
+			Response:    []info{},
+			Respond:     checked(API.List),
+		},
+		{
+			Method:      http.MethodPost,
+			Summary:     "Create a course",
+			Description: "Admins only. The course has one class on every day from 'start' to 'end'.",
+			Params:      CreateSchema,
+			Status:      http.StatusCreated,
+			Response:    info{},
+			Respond:     checked(API.Create),
+		},
+		{
+			Method:      http.MethodPatch,
+			Summary:     "Change the capacity of a course",
+			Description: "Admins only.",
+			Params:      UpdateSchema,
+			Response:    info{},
+			Respond:     checked(API.Update),
+		},
+		{
+			Method:      http.MethodDelete,
+			Summary:     "Cancel a class",
+			Description: "Admins and instructors only. All attendees get their payment or credit back.",
+			Params:      CancelSchema,
+			Response:    cancelled{},
+			Respond:     checked(API.Cancel),
+		},
+	},
+}
+
+// ImportDoc documents the endpoint '/v1/classes/import' for the OpenAPI specification.
+var ImportDoc = openapi.Path{
+	URL: "/v1/classes/import",
+	Operations: []openapi.Operation{
+		{
+			Method:  http.MethodPost,
+			Summary: "Create many courses at once",
+			Description: "Admins only. The courses are sent as CSV with a header row, as JSON array or as uploaded 'file'. " +
+				"Either all courses are created or none, the problems of all rows are returned at once, e.g. as '3.start' for row 3.",
+			Params:   ImportParams,
+			Rows:     ImportSchema,
+			Status:   http.StatusCreated,
+			Response: imported{},
+			Respond:  checked(API.Import),
+		},
+	},
+}
+
+// checked returns the response function of an API on an empty registry, used by openapi.Check,
+// so that checking the documentation doesn't touch the courses of any studio.
+func checked(respond func(API, *http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		return respond(New(courses.NewRegistry()), req)
+	}
+}
@@ -0,0 +1,35 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// Deprecated serves a path that is kept for old clients, e.g. an unversioned path whose behavior is frozen in a version.
+// The responses are those of the handler, but they carry headers that tell clients to move to the successor.
+type Deprecated struct {
+	api.BaseEndpoint
+	Handler http.Handler
+
+	// the path that replaces this one, e.g. '/v1/classes'
+	Successor string
+	// when the path will be removed, zero if that isn't decided yet
+	Sunset time.Time
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e Deprecated) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// see https://datatracker.ietf.org/doc/html/draft-ietf-httpapi-deprecation-header and RFC 8594
+	w.Header().Set("Deprecation", "true")
+	if e.Successor != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, e.Successor))
+	}
+	if !e.Sunset.IsZero() {
+		w.Header().Set("Sunset", e.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	e.Handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,54 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestDeprecated(t *testing.T) {
+	var called bool
+	successor := api.BaseEndpoint{URL: "/v1/test", ResponseFunc: func(*http.Request) interface{} {
+		called = true
+		return api.NewSuccessNow(http.StatusOK, nil, "test")
+	}}
+
+	tables := []struct {
+		sunset time.Time
+		header map[string]string
+	}{
+		{time.Time{}, map[string]string{
+			"Deprecation": "true",
+			"Link":        `</v1/test>; rel="successor-version"`,
+			"Sunset":      ""}},
+		{time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC), map[string]string{
+			"Deprecation": "true",
+			"Sunset":      "Wed, 30 Jun 2021 00:00:00 GMT"}},
+	}
+
+	for _, table := range tables {
+		called = false
+
+		e := Deprecated{
+			BaseEndpoint: api.BaseEndpoint{URL: "/test"},
+			Handler:      successor,
+			Successor:    successor.URL,
+			Sunset:       table.sunset,
+		}
+
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		if !called {
+			t.Errorf("the successor wasn't called")
+		}
+		for k, v := range table.header {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("header %s was incorrect, got: %q, want: %q", k, got, v)
+			}
+		}
+	}
+}
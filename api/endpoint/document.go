@@ -0,0 +1,42 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// Document is an endpoint that serves a fixed object encoded as JSON, e.g. the OpenAPI specification.
+// Unlike the response of a base endpoint, the object is not wrapped in an api.Success.
+type Document struct {
+	api.BaseEndpoint
+	Object interface{}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e Document) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+
+		base := e.BaseEndpoint
+		base.ResponseFunc = func(*http.Request) interface{} {
+			return api.NewError(http.StatusMethodNotAllowed,
+				fmt.Errorf("method %s is not allowed, use one of: GET, HEAD", req.Method))
+		}
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	b, err := json.MarshalIndent(e.Object, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		w.Write(b)
+	}
+}
@@ -0,0 +1,50 @@
+// Package endpoint contains API endpoints that extend the base endpoint of go-server.
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// WithWriter is like api.BaseEndpoint, but its response function also gets the response writer, e.g. to set cookies or other headers.
+// The returned object is written to the client just like the one of a base endpoint.
+type WithWriter struct {
+	api.BaseEndpoint
+	RespondFunc func(w http.ResponseWriter, req *http.Request) interface{}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e WithWriter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	resp := e.RespondFunc(w, req)
+
+	base := e.BaseEndpoint
+	base.ResponseFunc = func(*http.Request) interface{} {
+		return resp
+	}
+	base.ServeHTTP(w, req)
+}
+
+// WithHeader is a response object together with headers, e.g. a link to the next page.
+// Response functions of a Methods endpoint can return it to set headers without getting the response writer.
+type WithHeader struct {
+	Header   http.Header
+	Response interface{}
+}
+
+// writeHeader returns a response function that writes the headers of a WithHeader response and passes on its response object.
+// Other responses are passed on as they are.
+func writeHeader(w http.ResponseWriter, respond func(*http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		resp := respond(req)
+		if r, ok := resp.(WithHeader); ok {
+			for k, vs := range r.Header {
+				for _, v := range vs {
+					w.Header().Add(k, v)
+				}
+			}
+			return r.Response
+		}
+		return resp
+	}
+}
@@ -0,0 +1,29 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestWithWriter(t *testing.T) {
+	e := WithWriter{
+		BaseEndpoint: api.BaseEndpoint{URL: "/test"},
+		RespondFunc: func(w http.ResponseWriter, req *http.Request) interface{} {
+			w.Header().Set("X-Test", "yes")
+			return api.NewSuccessNow(http.StatusOK, nil, "done")
+		},
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+
+	if h := w.Result().Header.Get("X-Test"); h != "yes" {
+		t.Errorf("header set by response function is missing")
+	}
+	if w.Body.Len() == 0 {
+		t.Errorf("response wasn't written")
+	}
+}
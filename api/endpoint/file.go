@@ -0,0 +1,62 @@
+package endpoint
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// Content is a response that is written to the client as it is instead of being encoded as JSON, e.g. a calendar.
+type Content struct {
+	Type string // the media type, e.g. 'text/calendar; charset=utf-8'
+	Name string // the file name suggested to clients, empty if none
+	Body []byte
+
+	// whether clients should save the file instead of showing it, e.g. a spreadsheet
+	Attachment bool
+}
+
+// File is an endpoint that serves files for GET and HEAD requests, e.g. feeds to subscribe to or exports.
+// Its response function returns Content. Anything else, e.g. an error, is written like the response of a base endpoint.
+type File struct {
+	api.BaseEndpoint
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e File) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	base := e.BaseEndpoint
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		base.ResponseFunc = func(*http.Request) interface{} {
+			return api.NewError(http.StatusMethodNotAllowed,
+				fmt.Errorf("method %s is not allowed, use one of: GET, HEAD", req.Method))
+		}
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	resp := e.ResponseFunc(req)
+	c, ok := resp.(Content)
+	if !ok {
+		base.ResponseFunc = func(*http.Request) interface{} {
+			return resp
+		}
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.Type)
+	if c.Name != "" {
+		disposition := "inline"
+		if c.Attachment {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": c.Name}))
+	}
+	if req.Method == http.MethodGet {
+		w.Write(c.Body)
+	}
+}
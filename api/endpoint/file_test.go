@@ -0,0 +1,60 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestFile(t *testing.T) {
+	e := File{api.BaseEndpoint{URL: "/test.ics", ResponseFunc: func(req *http.Request) interface{} {
+		if req.FormValue("fail") != "" {
+			return api.ErrBadRequest(errors.New("failed"))
+		}
+		return Content{Type: "text/calendar", Name: "test.ics", Body: []byte("BEGIN:VCALENDAR"), Attachment: req.FormValue("save") != ""}
+	}}}
+
+	tables := []struct {
+		method, url string
+		typ, body   string
+	}{
+		{http.MethodGet, "/test.ics", "text/calendar", "BEGIN:VCALENDAR"},
+		{http.MethodHead, "/test.ics", "text/calendar", ""},
+		// written like the response of a base endpoint
+		{http.MethodGet, "/test.ics?fail=true", "", ""},
+		{http.MethodPost, "/test.ics", "", ""},
+	}
+
+	for _, table := range tables {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(table.method, table.url, nil))
+
+		if typ := w.Header().Get("Content-Type"); table.typ != "" && typ != table.typ {
+			t.Errorf("%s %s: wrong content type, got: %q, want: %q", table.method, table.url, typ, table.typ)
+		}
+		if table.typ == "" {
+			if w.Body.String() == "BEGIN:VCALENDAR" || w.Body.Len() == 0 {
+				t.Errorf("%s %s: the error wasn't written, got: %q", table.method, table.url, w.Body.String())
+			}
+			continue
+		}
+		if w.Body.String() != table.body {
+			t.Errorf("%s %s: wrong body, got: %q, want: %q", table.method, table.url, w.Body.String(), table.body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test.ics", nil))
+	if d := w.Header().Get("Content-Disposition"); d != `inline; filename=test.ics` {
+		t.Errorf("wrong content disposition: %q", d)
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test.ics?save=true", nil))
+	if d := w.Header().Get("Content-Disposition"); d != `attachment; filename=test.ics` {
+		t.Errorf("wrong content disposition of an attachment: %q", d)
+	}
+}
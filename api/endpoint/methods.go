@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/audit"
+)
+
+// MethodOverride is the form value with which HTML forms, which only know GET and POST, can send a POST request as another method, e.g. DELETE.
+const MethodOverride = "_method"
+
+// Handlers maps HTTP methods to response functions.
+type Handlers map[string]func(*http.Request) interface{}
+
+// Methods is an endpoint that calls a different response function depending on the HTTP method.
+//
+// Requests with another method are answered with '405 Method Not Allowed' and the allowed methods in the 'Allow' header.
+// If UseLegacy is set, all requests are handled by the Legacy response function instead, as before we distinguished methods.
+// The response functions can return WithHeader to set headers.
+// Every request gets an ID, which the response functions find with audit.RequestID and which is sent back in the header 'X-Request-ID'.
+type Methods struct {
+	api.BaseEndpoint
+	Handlers Handlers
+
+	Legacy    func(*http.Request) interface{}
+	UseLegacy bool
+}
+
+// method returns the method of the request, taking into account the method override of HTML forms.
+func method(req *http.Request) string {
+	if req.Method != http.MethodPost {
+		return req.Method
+	}
+
+	switch m := strings.ToUpper(req.FormValue(MethodOverride)); m {
+	case http.MethodPatch, http.MethodDelete:
+		return m
+	}
+	return req.Method
+}
+
+// Allow returns the allowed methods, sorted.
+func (e Methods) Allow() []string {
+	ms := make([]string, 0, len(e.Handlers)+2)
+	for m := range e.Handlers {
+		ms = append(ms, m)
+	}
+	if _, ok := e.Handlers[http.MethodGet]; ok {
+		ms = append(ms, http.MethodHead)
+	}
+	ms = append(ms, http.MethodOptions)
+
+	sort.Strings(ms)
+	return ms
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e Methods) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	base := e.BaseEndpoint
+
+	id := audit.NewRequestID(req)
+	w.Header().Set(audit.RequestIDHeader, id)
+	req = req.WithContext(audit.NewContext(req.Context(), id))
+
+	if e.UseLegacy && e.Legacy != nil {
+		base.ResponseFunc = writeHeader(w, e.Legacy)
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	m := method(req)
+	if m == http.MethodHead {
+		m = http.MethodGet
+	}
+
+	if respond, ok := e.Handlers[m]; ok {
+		base.ResponseFunc = writeHeader(w, respond)
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	allow := strings.Join(e.Allow(), ", ")
+	w.Header().Set("Allow", allow)
+
+	if m == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	base.ResponseFunc = func(*http.Request) interface{} {
+		return api.NewError(http.StatusMethodNotAllowed,
+			fmt.Errorf("method %s is not allowed, use one of: %s", m, allow))
+	}
+	base.ServeHTTP(w, req)
+}
@@ -0,0 +1,137 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/audit"
+)
+
+func TestMethods(t *testing.T) {
+	var called string
+	handler := func(name string) func(*http.Request) interface{} {
+		return func(*http.Request) interface{} {
+			called = name
+			return api.NewSuccessNow(http.StatusOK, nil, "%s", name)
+		}
+	}
+
+	e := Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: "/test"},
+		Handlers: Handlers{
+			http.MethodGet:    handler("list"),
+			http.MethodPost:   handler("create"),
+			http.MethodDelete: handler("cancel"),
+		},
+		Legacy: handler("legacy"),
+	}
+
+	if allow := strings.Join(e.Allow(), ", "); allow != "DELETE, GET, HEAD, OPTIONS, POST" {
+		t.Fatalf("allowed methods were incorrect, got: %s", allow)
+	}
+
+	tables := []struct {
+		method string
+		form   url.Values
+		called string
+		allow  bool // whether the 'Allow' header is expected
+	}{
+		{http.MethodGet, nil, "list", false},
+		{http.MethodHead, nil, "list", false},
+		{http.MethodPost, nil, "create", false},
+		{http.MethodDelete, nil, "cancel", false},
+		{http.MethodPost, url.Values{MethodOverride: {"delete"}}, "cancel", false},
+		{http.MethodPost, url.Values{MethodOverride: {"PATCH"}}, "", true},
+		{http.MethodPost, url.Values{MethodOverride: {"GET"}}, "create", false},
+		{http.MethodPut, nil, "", true},
+		{http.MethodOptions, nil, "", true},
+	}
+
+	for _, table := range tables {
+		called = ""
+
+		req := httptest.NewRequest(table.method, "/test", strings.NewReader(table.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		if called != table.called {
+			t.Errorf("%s request with %v called the wrong handler, got: %q, want: %q", table.method, table.form, called, table.called)
+		}
+		if allow := w.Result().Header.Get("Allow"); (allow != "") != table.allow {
+			t.Errorf("%s request with %v had an unexpected 'Allow' header: %q", table.method, table.form, allow)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/test", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS request returned status %d, want: %d", w.Code, http.StatusNoContent)
+	}
+
+	// in legacy mode, the method doesn't matter
+	e.UseLegacy = true
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut} {
+		called = ""
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/test", nil))
+		if called != "legacy" {
+			t.Errorf("%s request in legacy mode called the wrong handler, got: %q", method, called)
+		}
+	}
+}
+
+func TestMethodsWithHeader(t *testing.T) {
+	e := Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: "/test"},
+		Handlers: Handlers{
+			http.MethodGet: func(*http.Request) interface{} {
+				return WithHeader{
+					Header:   http.Header{"Link": {`</test?cursor=abc>; rel="next"`}},
+					Response: api.NewSuccessNow(http.StatusOK, nil, "page"),
+				}
+			},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if link := w.Result().Header.Get("Link"); link != `</test?cursor=abc>; rel="next"` {
+		t.Errorf("header set by response function is missing, got: %q", link)
+	}
+	if !strings.Contains(w.Body.String(), "page") || strings.Contains(w.Body.String(), "cursor") {
+		t.Errorf("the response object wasn't written alone, got: %s", w.Body.String())
+	}
+}
+
+func TestMethodsRequestID(t *testing.T) {
+	var got string
+	e := Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: "/test"},
+		Handlers: Handlers{
+			http.MethodPost: func(req *http.Request) interface{} {
+				got = audit.RequestID(req)
+				return api.NewSuccessNow(http.StatusOK, nil, "created")
+			},
+		},
+	}
+
+	for _, sent := range []string{"", "proxy-42"} {
+		req := httptest.NewRequest(http.MethodPost, "/test", nil)
+		if sent != "" {
+			req.Header.Set(audit.RequestIDHeader, sent)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		id := w.Result().Header.Get(audit.RequestIDHeader)
+		if id == "" || id != got || sent != "" && id != sent {
+			t.Errorf("sent request ID %q, got %q in the header and %q in the response function", sent, id, got)
+		}
+	}
+}
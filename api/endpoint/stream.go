@@ -0,0 +1,116 @@
+package endpoint
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// DefaultHeartbeat is how often a stream sends a comment to keep the connection open while there are no events, e.g. through proxies.
+const DefaultHeartbeat = 15 * time.Second
+
+// A Message is a server-sent event.
+type Message struct {
+	ID    string // lets clients resume after it with the header 'Last-Event-ID', empty if none
+	Event string // the type of the event, empty for the default type 'message'
+	Data  []byte
+}
+
+// Events is a response that is written to the client as server-sent events, one for each message, until the client goes away.
+type Events struct {
+	Messages <-chan Message // when it is closed, the stream ends and the client reconnects
+	Close    func()         // called when the stream ends, e.g. to unsubscribe; may be nil
+}
+
+// Stream is an endpoint that streams server-sent events (text/event-stream) for GET requests, e.g. to update pages live.
+// Its response function returns Events. Anything else, e.g. an error, is written like the response of a base endpoint.
+type Stream struct {
+	api.BaseEndpoint
+	Heartbeat time.Duration // DefaultHeartbeat if zero
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e Stream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	base := e.BaseEndpoint
+
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		base.ResponseFunc = func(*http.Request) interface{} {
+			return api.NewError(http.StatusMethodNotAllowed,
+				fmt.Errorf("method %s is not allowed, use: GET", req.Method))
+		}
+		base.ServeHTTP(w, req)
+		return
+	}
+
+	resp := e.ResponseFunc(req)
+	ev, ok := resp.(Events)
+	if !ok {
+		base.ResponseFunc = func(*http.Request) interface{} {
+			return resp
+		}
+		base.ServeHTTP(w, req)
+		return
+	}
+	if ev.Close != nil {
+		defer ev.Close()
+	}
+
+	heartbeat := e.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // don't let nginx hold back the events
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		case m, ok := <-ev.Messages:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(m.encode()); err != nil {
+				return
+			}
+		}
+		flush(w)
+	}
+}
+
+// encode returns the message in the format of an event stream. Each line of the data gets a field of its own.
+func (m Message) encode() []byte {
+	var b bytes.Buffer
+	if m.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", m.ID)
+	}
+	if m.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", m.Event)
+	}
+	for _, line := range bytes.Split(m.Data, []byte("\n")) {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// flush sends what was written to the client right away, if the response writer supports it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
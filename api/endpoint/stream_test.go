@@ -0,0 +1,72 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestStream(t *testing.T) {
+	var (
+		msgs   chan Message
+		closed bool
+	)
+	e := Stream{BaseEndpoint: api.BaseEndpoint{URL: "/events", ResponseFunc: func(req *http.Request) interface{} {
+		if req.FormValue("fail") != "" {
+			return api.ErrBadRequest(errors.New("failed"))
+		}
+		return Events{Messages: msgs, Close: func() { closed = true }}
+	}}, Heartbeat: time.Millisecond}
+
+	// the stream ends when the channel is closed
+	msgs = make(chan Message, 2)
+	msgs <- Message{ID: "1", Event: "booked", Data: []byte(`{"free":3}`)}
+	msgs <- Message{Data: []byte("two\nlines")}
+	close(msgs)
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if typ := w.Header().Get("Content-Type"); typ != "text/event-stream" {
+		t.Errorf("wrong content type: %q", typ)
+	}
+	if want := "id: 1\nevent: booked\ndata: {\"free\":3}\n\ndata: two\ndata: lines\n\n"; w.Body.String() != want {
+		t.Errorf("wrong body, got: %q, want: %q", w.Body.String(), want)
+	}
+	if !closed {
+		t.Errorf("the stream wasn't closed")
+	}
+
+	// the stream ends when the client goes away, meanwhile it sends heartbeats
+	msgs, closed = make(chan Message), false
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w = httptest.NewRecorder()
+	go func() {
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	if !closed || !strings.HasPrefix(w.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("expected heartbeats and the stream to be closed, got: %q", w.Body.String())
+	}
+
+	// written like the response of a base endpoint
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/events?fail=true", nil),
+		httptest.NewRequest(http.MethodPost, "/events", nil),
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Header().Get("Content-Type") == "text/event-stream" || w.Body.Len() == 0 {
+			t.Errorf("%s %s: the error wasn't written, got: %q", req.Method, req.URL, w.Body.String())
+		}
+	}
+}
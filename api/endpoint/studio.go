@@ -0,0 +1,22 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+// ForStudio serves the requests to a path of a studio, e.g. '/studios/downtown/v1/classes', with a handler that is shared by all studios.
+// The handler finds the studio with tenants.Of.
+type ForStudio struct {
+	api.BaseEndpoint
+	Handler http.Handler
+	Studio  tenants.Studio
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (e ForStudio) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	e.Handler.ServeHTTP(w, req.WithContext(tenants.NewContext(req.Context(), e.Studio)))
+}
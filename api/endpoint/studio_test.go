@@ -0,0 +1,38 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+func TestForStudio(t *testing.T) {
+	var got tenants.Studio
+	shared := api.BaseEndpoint{URL: "/v1/test", ResponseFunc: func(req *http.Request) interface{} {
+		got = tenants.Of(req)
+		return api.NewSuccessNow(http.StatusOK, nil, "test")
+	}}
+
+	tables := []struct {
+		url    string
+		handle http.Handler
+		studio string
+	}{
+		{"/v1/test", shared, ""},
+		{"/studios/downtown/v1/test", ForStudio{
+			BaseEndpoint: api.BaseEndpoint{URL: "/studios/downtown/v1/test"},
+			Handler:      shared,
+			Studio:       tenants.Studio{ID: "downtown"}}, "downtown"},
+	}
+
+	for _, table := range tables {
+		table.handle.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, table.url, nil))
+		if got.ID != table.studio {
+			t.Errorf("%s: wrong studio, got: %q, want: %q", table.url, got.ID, table.studio)
+		}
+	}
+}
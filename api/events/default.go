@@ -0,0 +1,12 @@
+package events
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// Stream is the response function to a GET request for the events about the default registry (see API.Stream).
+func Stream(req *http.Request) interface{} {
+	return New(courses.Default).Stream(req)
+}
@@ -0,0 +1,150 @@
+// Package events implements the API point '/v1/events', which streams changes to the courses and their free places as server-sent events, e.g. to update pages and kiosk displays live.
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// Reset is the type of event that tells clients they missed events, e.g. because they were gone too long, and should reload everything.
+const Reset = "reset"
+
+// Schema is the schema of the query parameters of the stream.
+var Schema = input.Schema{
+	{Name: "id", Type: input.Int, Description: "only events of the course with this ID"},
+}
+
+// API holds the response function to requests for events, which are about the courses of its registry.
+// The package-level function Stream uses the default registry.
+type API struct {
+	Courses *courses.Registry
+}
+
+// New returns the response function to requests for events about the courses of the registry.
+func New(r *courses.Registry) API {
+	return API{Courses: r}
+}
+
+// data is what clients get about an event, as JSON.
+type data struct {
+	Course     uint64
+	Name       string
+	Date       string `json:",omitempty"` // of the class, empty for events of the whole course
+	Capacity   int
+	ClassFree  int // the free places of the class, zero for events of the whole course
+	CourseFree int // see course.Course.FreePlaces
+	Time       time.Time
+}
+
+// Stream is the response function to a GET request to '/v1/events'.
+//
+// It streams the events about the courses of the studio, optionally only those of the course with the given 'id'.
+// The type of each server-sent event is the kind of event, e.g. 'class-booked'. Its ID lets clients that reconnect catch up.
+func (a API) Stream(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	var (
+		id   uint64
+		byID bool
+	)
+	if req.FormValue("id") != "" {
+		id, byID = f.Uint64("id"), true
+	}
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	// subscribe before catching up, so that no event is missed in between
+	sub, unsubscribe := bus.Subscribe()
+	var (
+		missed   []bus.Event
+		complete = true
+		last     uint64
+	)
+	if h := req.Header.Get("Last-Event-ID"); h != "" {
+		if last, _ = strconv.ParseUint(h, 10, 64); last != 0 {
+			missed, complete = bus.Since(last)
+		}
+	}
+	if !complete {
+		// the client reloads everything, so only new events matter
+		missed, last = nil, 0
+	}
+
+	msgs, done := make(chan endpoint.Message), make(chan struct{})
+	go func() {
+		defer close(msgs)
+
+		send := func(e bus.Event) bool {
+			if e.ID <= last {
+				return true // caught up with it already
+			}
+			last = e.ID
+			m, ok := a.message(e, id, byID)
+			if !ok {
+				return true
+			}
+			select {
+			case msgs <- m:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		if !complete {
+			select {
+			case msgs <- endpoint.Message{Event: Reset, Data: []byte("{}")}:
+			case <-done:
+				return
+			}
+		}
+		for _, e := range missed {
+			if !send(e) {
+				return
+			}
+		}
+		// until unsubscribed or too far behind, then the client reconnects and catches up
+		for e := range sub {
+			if !send(e) {
+				return
+			}
+		}
+	}()
+
+	return endpoint.Events{Messages: msgs, Close: func() {
+		unsubscribe()
+		close(done)
+	}}
+}
+
+// message returns the event as a server-sent event, or false if it isn't about a course of the registry (with the ID, if byID is set).
+// Reminders don't change the availability and are about a member, so they aren't streamed either.
+func (a API) message(e bus.Event, id uint64, byID bool) (endpoint.Message, bool) {
+	if e.Kind == bus.ClassReminder || byID && e.Course != id {
+		return endpoint.Message{}, false
+	}
+	c, err := a.Courses.Get(e.Course)
+	if err != nil {
+		return endpoint.Message{}, false
+	}
+
+	d := data{Course: e.Course, Name: c.Name(), Capacity: c.Capacity(), ClassFree: e.ClassFree, CourseFree: e.CourseFree, Time: e.Time}
+	if e.Date.IsValid() {
+		d.Date = e.Date.String()
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return endpoint.Message{}, false
+	}
+
+	return endpoint.Message{ID: strconv.FormatUint(e.ID, 10), Event: string(e.Kind), Data: b}, true
+}
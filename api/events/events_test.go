@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// stream returns the events of the response or fails.
+func stream(t *testing.T, resp interface{}) endpoint.Events {
+	t.Helper()
+	ev, ok := resp.(endpoint.Events)
+	if !ok {
+		t.Fatalf("expected events, got: %v", resp)
+	}
+	return ev
+}
+
+// next returns the next message of the events or fails.
+func next(t *testing.T, ev endpoint.Events) endpoint.Message {
+	t.Helper()
+	select {
+	case m := <-ev.Messages:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+	}
+	return endpoint.Message{}
+}
+
+// add returns a new course in the registry, with classes from today on.
+func add(t *testing.T, r *courses.Registry, name string, capacity int) *course.Course {
+	today := civil.DateOf(time.Now())
+	c, err := course.New(name, today, today.AddDays(2), capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestStream(t *testing.T) {
+	a, other := New(courses.NewRegistry()), courses.NewRegistry()
+	tomorrow := civil.DateOf(time.Now()).AddDays(1)
+
+	ev := stream(t, a.Stream(httptest.NewRequest("GET", "/v1/events", nil)))
+
+	pilates := add(t, a.Courses, "Pilates", 10)
+	add(t, other, "Yoga", 5) // of another studio
+	if err := pilates.BookClass("Ann", tomorrow); err != nil {
+		t.Fatal(err)
+	}
+
+	m := next(t, ev)
+	if m.Event != string(bus.CourseAdded) {
+		t.Errorf("wrong event, got: %s, want: %s", m.Event, bus.CourseAdded)
+	}
+
+	m = next(t, ev)
+	if m.Event != string(bus.ClassBooked) {
+		t.Fatalf("wrong event, got: %s, want: %s", m.Event, bus.ClassBooked)
+	}
+	var d data
+	if err := json.Unmarshal(m.Data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Course != pilates.ID() || d.Name != "Pilates" || d.Date != tomorrow.String() || d.Capacity != 10 || d.ClassFree != 9 || d.CourseFree != 10 {
+		t.Errorf("wrong data: %s", m.Data)
+	}
+	if _, err := pilates.CancelClass(tomorrow); err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, ev); m.Event != string(bus.ClassCancelled) {
+		t.Errorf("wrong event, got: %s, want: %s", m.Event, bus.ClassCancelled)
+	}
+
+	// closing ends the stream
+	ev.Close()
+	for range ev.Messages {
+	}
+}
+
+func TestStreamCourse(t *testing.T) {
+	a := New(courses.NewRegistry())
+	pilates, yoga := add(t, a.Courses, "Pilates", 10), add(t, a.Courses, "Yoga", 5)
+	tomorrow := civil.DateOf(time.Now()).AddDays(1)
+
+	ev := stream(t, a.Stream(httptest.NewRequest("GET", fmt.Sprintf("/v1/events?id=%d", yoga.ID()), nil)))
+	defer ev.Close()
+
+	for _, c := range []*course.Course{pilates, yoga} {
+		if err := c.BookClass("Ann", tomorrow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var d data
+	if err := json.Unmarshal(next(t, ev).Data, &d); err != nil || d.Course != yoga.ID() {
+		t.Errorf("expected an event of the course only, got: %v, %v", d, err)
+	}
+
+	if resp, ok := a.Stream(httptest.NewRequest("GET", "/v1/events?id=x", nil)).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+		t.Errorf("expected a bad request, got: %v", resp)
+	}
+}
+
+func TestStreamCatchUp(t *testing.T) {
+	a := New(courses.NewRegistry())
+	pilates := add(t, a.Courses, "Pilates", 10)
+	tomorrow := civil.DateOf(time.Now()).AddDays(1)
+
+	ev := stream(t, a.Stream(httptest.NewRequest("GET", "/v1/events", nil)))
+	if err := pilates.BookClass("Ann", tomorrow); err != nil {
+		t.Fatal(err)
+	}
+	seen := next(t, ev)
+	ev.Close()
+
+	// missed while gone
+	for _, name := range []string{"Bea", "Cleo"} {
+		if err := pilates.BookClass(name, tomorrow); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Last-Event-ID", seen.ID)
+	ev = stream(t, a.Stream(req))
+	defer ev.Close()
+	for _, free := range []int{8, 7} {
+		var d data
+		if err := json.Unmarshal(next(t, ev).Data, &d); err != nil || d.ClassFree != free {
+			t.Errorf("expected the missed event with %d free places, got: %v, %v", free, d, err)
+		}
+	}
+
+	// an ID from before a restart
+	req = httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Last-Event-ID", "999999999")
+	ev2 := stream(t, a.Stream(req))
+	defer ev2.Close()
+	if m := next(t, ev2); m.Event != Reset {
+		t.Errorf("expected a reset, got: %v", m)
+	}
+}
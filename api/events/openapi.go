@@ -0,0 +1,25 @@
+package events
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+)
+
+// Doc documents the endpoint '/v1/events' for the OpenAPI specification.
+// It has no response function to check, since that would subscribe to the events.
+var Doc = openapi.Path{
+	URL: "/v1/events",
+	Operations: []openapi.Operation{
+		{
+			Method:  http.MethodGet,
+			Summary: "Stream live availability",
+			Description: "Server-sent events when courses are added, classes booked or cancelled and capacities change. " +
+				"The type of each event is its kind, e.g. 'class-booked', its data the course, the date of the class and their free places as JSON. " +
+				"Clients that reconnect with the header 'Last-Event-ID' catch up; if they missed too much, they get an event of the type 'reset' and should reload everything.",
+			Params:       Schema,
+			ContentTypes: []string{"text/event-stream"},
+			Public:       true,
+		},
+	},
+}
@@ -0,0 +1,22 @@
+package exports
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// CourseList is the response function to a GET request to '/v1/exports/courses' for the default registry (see API.CourseList).
+func CourseList(req *http.Request) interface{} {
+	return New(courses.Default).CourseList(req)
+}
+
+// Rosters is the response function to a GET request to '/v1/exports/rosters' for the default registry (see API.Rosters).
+func Rosters(req *http.Request) interface{} {
+	return New(courses.Default).Rosters(req)
+}
+
+// Bookings is the response function to a GET request to '/v1/exports/bookings' for the default registry (see API.Bookings).
+func Bookings(req *http.Request) interface{} {
+	return New(courses.Default).Bookings(req)
+}
@@ -0,0 +1,261 @@
+// Package exports implements the API points '/v1/exports/...', which export courses, the attendees of classes and bookings as files for spreadsheets (see package export).
+package exports
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/export"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// Schema is the schema of the query parameters of all exports.
+var Schema = input.Schema{
+	{Name: "format", Type: input.String, Description: "'csv' (default), 'jsonl' or 'xlsx'"},
+	{Name: "from", Type: input.Date, Description: "only classes on or after this date"},
+	{Name: "to", Type: input.Date, Description: "only classes on or before this date"},
+	{Name: "id", Type: input.Int, Description: "only the course with this ID"},
+}
+
+// API holds the response functions to requests for exports, which contain the courses of its registry.
+// The package-level functions, e.g. Rosters, use the default registry.
+type API struct {
+	Courses *courses.Registry
+	Studio  string // the name of the studio, for the names of the sheets
+}
+
+// New returns the response functions to requests for exports of the courses of the registry.
+func New(r *courses.Registry) API {
+	return API{Courses: r}
+}
+
+// a filter selects what is exported (see Schema)
+type filter struct {
+	format   export.Format
+	from, to civil.Date // zero if open
+	id       uint64     // of the only course, if byID is set
+	byID     bool
+}
+
+// parse returns the filter given by the parameters of the request.
+func parse(req *http.Request) (filter, error) {
+	f := input.NewForm(req)
+
+	var (
+		fl  filter
+		err error
+	)
+	if fl.format, err = export.ParseFormat(req.FormValue("format")); err != nil {
+		f.Add("format", validation.Invalid, "%s", err)
+	}
+	if req.FormValue("from") != "" {
+		fl.from = f.Date("from")
+	}
+	if req.FormValue("to") != "" {
+		fl.to = f.Date("to")
+	}
+	if req.FormValue("id") != "" {
+		fl.id, fl.byID = f.Uint64("id"), true
+	}
+	if fl.from.IsValid() && fl.to.IsValid() && fl.to.Before(fl.from) {
+		f.Add("to", validation.Invalid, "to value (%s) must not be before from value (%s)", fl.to, fl.from)
+	}
+
+	return fl, f.Err()
+}
+
+// selected returns the courses with classes in the time frame of the filter, sorted by start date.
+func (a API) selected(fl filter) (courses.Courses, error) {
+	if !fl.byID {
+		return a.Courses.Between(fl.from, fl.to), nil
+	}
+	c, err := a.Courses.Get(fl.id)
+	if err != nil {
+		return nil, api.NewError(http.StatusNotFound, err)
+	}
+	return courses.Courses{c}, nil
+}
+
+// dates returns the dates of the classes of the course in the time frame of the filter.
+func (fl filter) dates(c *course.Course) []civil.Date {
+	dates := make([]civil.Date, 0, c.NumClasses())
+	for i := 0; i < c.NumClasses(); i++ {
+		date := c.Start().AddDays(i)
+		if fl.from.IsValid() && date.Before(fl.from) || fl.to.IsValid() && date.After(fl.to) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// CourseList is the response function to a GET request to '/v1/exports/courses'.
+//
+// It exports the courses with classes in the time frame, one row per course.
+func (a API) CourseList(req *http.Request) interface{} {
+	return a.respond(req, "Courses", func(fl filter, cs courses.Courses) export.Table {
+		t := export.Table{Columns: []string{"id", "name", "start", "end", "time", "duration", "classes", "capacity", "free",
+			"price", "currency", "members_only", "instructor", "location"}}
+		for _, c := range cs {
+			t.Add(c.ID(), c.Name(), c.Start(), c.End(), timeOf(c), durationOf(c), c.NumClasses(), c.Capacity(), c.FreePlaces(),
+				decimal(c.Price()), c.Price().Currency, c.MembersOnly(), c.Instructor(), c.Location())
+		}
+		return t
+	})
+}
+
+// Rosters is the response function to a GET request to '/v1/exports/rosters'.
+//
+// It exports the attendees of the classes in the time frame, one row per attendee and class, e.g. to print a list of attendees.
+// The attendees of classes the studio cancelled are listed with the status 'cancelled'.
+func (a API) Rosters(req *http.Request) interface{} {
+	return a.respond(req, "Rosters", func(fl filter, cs courses.Courses) export.Table {
+		t := export.Table{Columns: []string{"date", "time", "course_id", "course", "instructor", "location", "capacity", "member", "status"}}
+		eachAttendee(fl, cs, func(date civil.Date, c *course.Course, name, status string) {
+			t.Add(date, timeOf(c), c.ID(), c.Name(), c.Instructor(), c.Location(), c.Capacity(), name, status)
+		})
+		return t
+	})
+}
+
+// Bookings is the response function to a GET request to '/v1/exports/bookings'.
+//
+// It exports the bookings of the classes in the time frame, one row per booking, with what was paid according to the ledger, e.g. for accounting.
+// Amounts are decimal numbers in the currency, e.g. '12.50'.
+func (a API) Bookings(req *http.Request) interface{} {
+	return a.respond(req, "Bookings", func(fl filter, cs courses.Courses) export.Table {
+		t := export.Table{Columns: []string{"date", "course_id", "course", "member", "status",
+			"price", "charged", "refunded", "fee", "currency", "pass"}}
+		eachAttendee(fl, cs, func(date civil.Date, c *course.Course, name, status string) {
+			s := summarize(ledger.Of(ledger.Booking{Course: c.ID(), Member: name, Date: date}))
+			price := c.PriceOn(date)
+
+			var pass interface{}
+			if s.pass != 0 {
+				pass = s.pass
+			}
+			t.Add(date, c.ID(), c.Name(), name, status,
+				decimal(price), decimal(s.charged), decimal(s.refunded), decimal(s.fee), price.Currency, pass)
+		})
+		return t
+	})
+}
+
+// respond parses the filter, creates the table of the selected courses and returns it as a file with the name, e.g. 'Rosters'.
+func (a API) respond(req *http.Request, name string, table func(filter, courses.Courses) export.Table) interface{} {
+	fl, err := parse(req)
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	cs, err := a.selected(fl)
+	if err != nil {
+		return api.ErrWrap(err)
+	}
+
+	t := table(fl, cs)
+	t.Name = strings.TrimSpace(a.Studio + " " + name)
+	b, err := t.Marshal(fl.format)
+	if err != nil {
+		return api.ErrWrap(err)
+	}
+
+	return endpoint.Content{Type: fl.format.ContentType(), Name: strings.ToLower(name) + "." + string(fl.format), Body: b, Attachment: true}
+}
+
+// eachAttendee calls the function for each attendee of the classes of the courses in the time frame of the filter, ordered by date.
+// The status is 'booked' or, if the studio cancelled the class, 'cancelled'.
+func eachAttendee(fl filter, cs courses.Courses, f func(date civil.Date, c *course.Course, name, status string)) {
+	type class struct {
+		date civil.Date
+		c    *course.Course
+	}
+
+	classes := make([]class, 0)
+	for _, c := range cs {
+		for _, date := range fl.dates(c) {
+			classes = append(classes, class{date, c})
+		}
+	}
+	// the courses are sorted by start date, keep that order on each date
+	sort.SliceStable(classes, func(i, j int) bool { return classes[i].date.Before(classes[j].date) })
+
+	for _, cl := range classes {
+		// a copy read under the lock of the course, so that bookings can go on meanwhile
+		attendees, err := cl.c.Attendees(cl.date)
+		if err != nil {
+			continue
+		}
+		status := "booked"
+		if cl.c.Cancelled(cl.date) {
+			status = "cancelled"
+		}
+		for _, name := range attendees {
+			f(cl.date, cl.c, name, status)
+		}
+	}
+}
+
+// what the ledger recorded about a booking
+type summary struct {
+	charged, refunded, fee money.Amount
+	pass                   uint64 // whose credit was used, zero if none
+}
+
+// summarize adds up the entries of a booking.
+func summarize(es []ledger.Entry) summary {
+	var s summary
+	for _, e := range es {
+		switch e.Kind {
+		case ledger.KindCharge:
+			s.charged = add(s.charged, e.Amount)
+		case ledger.KindRefund:
+			s.refunded = add(s.refunded, e.Amount)
+		case ledger.KindFee:
+			s.fee = add(s.fee, e.Amount)
+		case ledger.KindCreditUsed:
+			s.pass = e.Pass
+		}
+	}
+	return s
+}
+
+// add returns the sum of the amounts, in the currency of b if a is zero.
+func add(a, b money.Amount) money.Amount {
+	if a.Currency == "" {
+		a.Currency = b.Currency
+	}
+	return money.New(a.Minor+b.Minor, a.Currency)
+}
+
+// decimal returns the amount as a decimal number without the currency, e.g. '12.50'.
+func decimal(a money.Amount) string {
+	return fmt.Sprintf("%d.%02d", a.Minor/100, a.Minor%100)
+}
+
+// timeOf returns the time of day the classes of the course start, e.g. '18:30', or nil if it isn't known.
+func timeOf(c *course.Course) interface{} {
+	if c.At() == (civil.Time{}) {
+		return nil
+	}
+	return fmt.Sprintf("%02d:%02d", c.At().Hour, c.At().Minute)
+}
+
+// durationOf returns how long the classes of the course last, e.g. '1h30m0s', or nil if it isn't known.
+func durationOf(c *course.Course) interface{} {
+	if c.Duration() == 0 {
+		return nil
+	}
+	return c.Duration().String()
+}
@@ -0,0 +1,169 @@
+package exports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+// records returns the rows of the CSV file of the response, without the header, or fails.
+func records(t *testing.T, resp interface{}) [][]string {
+	t.Helper()
+	c, ok := resp.(endpoint.Content)
+	if !ok {
+		t.Fatalf("expected a file, got: %v", resp)
+	}
+	rs, err := csv.NewReader(strings.NewReader(string(c.Body))).ReadAll()
+	if err != nil {
+		t.Fatalf("file is not CSV: %s", err)
+	}
+	return rs[1:]
+}
+
+// setup returns an API with two courses: Pilates with three classes from today on, and Yoga the week after.
+func setup(t *testing.T) (API, *course.Course, *course.Course) {
+	today := civil.DateOf(time.Now())
+	a := API{Courses: courses.NewRegistry(), Studio: "Downtown"}
+
+	pilates, err := course.New("Pilates", today, today.AddDays(2), 10,
+		course.StartingAt(civil.Time{Hour: 18, Minute: 30}), course.Priced(money.New(1250, "EUR")), course.TaughtBy("Ann"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yoga, err := course.New("Yoga", today.AddDays(7), today.AddDays(7), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*course.Course{pilates, yoga} {
+		if err := a.Courses.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, pilates, yoga
+}
+
+func TestCourseList(t *testing.T) {
+	a, pilates, yoga := setup(t)
+	today := civil.DateOf(time.Now())
+
+	tables := []struct {
+		query string
+		ids   []uint64
+	}{
+		{"", []uint64{pilates.ID(), yoga.ID()}},
+		{fmt.Sprintf("?id=%d", yoga.ID()), []uint64{yoga.ID()}},
+		{fmt.Sprintf("?from=%s", today.AddDays(3)), []uint64{yoga.ID()}},
+		{fmt.Sprintf("?to=%s", today.AddDays(3)), []uint64{pilates.ID()}},
+	}
+
+	for _, table := range tables {
+		rs := records(t, a.CourseList(httptest.NewRequest("GET", "/v1/exports/courses"+table.query, nil)))
+		if len(rs) != len(table.ids) {
+			t.Errorf("%q: got %d courses, want: %d", table.query, len(rs), len(table.ids))
+			continue
+		}
+		for i, id := range table.ids {
+			if rs[i][0] != fmt.Sprint(id) {
+				t.Errorf("%q: row %d is course %s, want: %d", table.query, i, rs[i][0], id)
+			}
+		}
+	}
+
+	rs := records(t, a.CourseList(httptest.NewRequest("GET", "/v1/exports/courses", nil)))
+	if want := fmt.Sprintf("%d,Pilates,%s,%s,18:30,,3,10,10,12.50,EUR,false,Ann,", pilates.ID(), today, today.AddDays(2)); strings.Join(rs[0], ",") != want {
+		t.Errorf("wrong row, got: %s, want: %s", strings.Join(rs[0], ","), want)
+	}
+
+	for _, query := range []string{"?format=pdf", "?from=2020-12-02&to=2020-12-01", "?id=x"} {
+		if resp, ok := a.CourseList(httptest.NewRequest("GET", "/v1/exports/courses"+query, nil)).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+			t.Errorf("%q: expected a bad request, got: %v", query, resp)
+		}
+	}
+	if resp, ok := a.CourseList(httptest.NewRequest("GET", "/v1/exports/courses?id=0", nil)).(api.Error); !ok || resp.Status != http.StatusNotFound {
+		t.Errorf("expected an unknown course not to be found, got: %v", resp)
+	}
+}
+
+func TestRosters(t *testing.T) {
+	a, pilates, yoga := setup(t)
+	today := civil.DateOf(time.Now())
+
+	for _, b := range []struct {
+		c    *course.Course
+		name string
+		date civil.Date
+	}{
+		{pilates, "Carla", today.AddDays(1)},
+		{pilates, "Dana", today.AddDays(1)},
+		{pilates, "Carla", today.AddDays(2)},
+		{yoga, "Dana", today.AddDays(7)},
+	} {
+		if err := b.c.BookClass(b.name, b.date); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pilates.CancelClass(today.AddDays(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := records(t, a.Rosters(httptest.NewRequest("GET", "/v1/exports/rosters", nil)))
+	want := []string{
+		fmt.Sprintf("%s,18:30,%d,Pilates,Ann,,10,Carla,booked", today.AddDays(1), pilates.ID()),
+		fmt.Sprintf("%s,18:30,%d,Pilates,Ann,,10,Dana,booked", today.AddDays(1), pilates.ID()),
+		fmt.Sprintf("%s,18:30,%d,Pilates,Ann,,10,Carla,cancelled", today.AddDays(2), pilates.ID()),
+		fmt.Sprintf("%s,,%d,Yoga,,,5,Dana,booked", today.AddDays(7), yoga.ID()),
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("got %d rows, want: %d\n%v", len(rs), len(want), rs)
+	}
+	for i, w := range want {
+		if got := strings.Join(rs[i], ","); got != w {
+			t.Errorf("row %d: got %s, want: %s", i, got, w)
+		}
+	}
+
+	// only the classes in the time frame
+	rs = records(t, a.Rosters(httptest.NewRequest("GET", fmt.Sprintf("/v1/exports/rosters?from=%s&to=%s", today.AddDays(2), today.AddDays(2)), nil)))
+	if len(rs) != 1 || rs[0][7] != "Carla" {
+		t.Errorf("wrong rows in time frame: %v", rs)
+	}
+
+	// the other formats
+	for _, format := range []string{"jsonl", "xlsx"} {
+		c, ok := a.Rosters(httptest.NewRequest("GET", "/v1/exports/rosters?format="+format, nil)).(endpoint.Content)
+		if !ok || c.Name != "rosters."+format || !c.Attachment || len(c.Body) == 0 {
+			t.Errorf("wrong %s file: %v", format, c)
+		}
+	}
+}
+
+func TestBookings(t *testing.T) {
+	a, pilates, _ := setup(t)
+	today := civil.DateOf(time.Now())
+
+	if err := pilates.BookClass("Erin", today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	b := ledger.Booking{Course: pilates.ID(), Member: "Erin", Date: today.AddDays(1)}
+	ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindCharge, Amount: money.New(1250, "EUR")})
+	ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindRefund, Amount: money.New(250, "EUR")})
+
+	rs := records(t, a.Bookings(httptest.NewRequest("GET", fmt.Sprintf("/v1/exports/bookings?id=%d", pilates.ID()), nil)))
+	want := fmt.Sprintf("%s,%d,Pilates,Erin,booked,12.50,12.50,2.50,0.00,EUR,", today.AddDays(1), pilates.ID())
+	if len(rs) != 1 || strings.Join(rs[0], ",") != want {
+		t.Errorf("wrong bookings, got: %v, want: %s", rs, want)
+	}
+}
@@ -0,0 +1,71 @@
+package exports
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/export"
+)
+
+// the media types of all formats
+var contentTypes = func() []string {
+	ts := make([]string, len(export.Formats))
+	for i, f := range export.Formats {
+		ts[i] = f.ContentType()
+	}
+	return ts
+}()
+
+// CoursesDoc documents the endpoint '/v1/exports/courses' for the OpenAPI specification.
+var CoursesDoc = openapi.Path{
+	URL: "/v1/exports/courses",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Export courses",
+			Description:  "Admins and instructors only. One row per course with classes in the time frame.",
+			Params:       Schema,
+			ContentTypes: contentTypes,
+			Respond:      checked(API.CourseList),
+		},
+	},
+}
+
+// RostersDoc documents the endpoint '/v1/exports/rosters' for the OpenAPI specification.
+var RostersDoc = openapi.Path{
+	URL: "/v1/exports/rosters",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Export the attendees of classes",
+			Description:  "Admins and instructors only. One row per attendee of a class in the time frame, e.g. to print a list of attendees.",
+			Params:       Schema,
+			ContentTypes: contentTypes,
+			Respond:      checked(API.Rosters),
+		},
+	},
+}
+
+// BookingsDoc documents the endpoint '/v1/exports/bookings' for the OpenAPI specification.
+var BookingsDoc = openapi.Path{
+	URL: "/v1/exports/bookings",
+	Operations: []openapi.Operation{
+		{
+			Method:       http.MethodGet,
+			Summary:      "Export bookings",
+			Description:  "Admins only. One row per booking of a class in the time frame, with what was charged, refunded and kept as fee.",
+			Params:       Schema,
+			ContentTypes: contentTypes,
+			Respond:      checked(API.Bookings),
+		},
+	},
+}
+
+// checked returns the response function of an API on an empty registry, used by openapi.Check,
+// so that checking the documentation doesn't touch the courses of any studio.
+func checked(respond func(API, *http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		return respond(New(courses.NewRegistry()), req)
+	}
+}
@@ -0,0 +1,101 @@
+package input
+
+import (
+	"net/http"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/form"
+
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// Form reads the form parameters of a request.
+// Instead of stopping at the first problem, all problems are collected in Errors.
+type Form struct {
+	req    *http.Request
+	Errors validation.Errors
+}
+
+// NewForm returns a form to read the parameters of the request.
+func NewForm(req *http.Request) *Form {
+	return &Form{req: req}
+}
+
+// add adds the problem with the parameter.
+func (f *Form) add(key string, err error) {
+	code := validation.Malformed
+	if f.req.FormValue(key) == "" {
+		code = validation.Required
+	}
+	f.Errors = append(f.Errors, validation.Error{Field: key, Code: code, Message: err.Error()})
+}
+
+// String returns the parameter as string.
+func (f *Form) String(key string) string {
+	s, err := form.GetStringE(f.req, key)
+	if err != nil {
+		f.add(key, err)
+	}
+	return s
+}
+
+// Date returns the parameter as date.
+func (f *Form) Date(key string) civil.Date {
+	d, err := form.GetDateE(f.req, key)
+	if err != nil {
+		f.add(key, err)
+	}
+	return d
+}
+
+// Int returns the parameter as int.
+func (f *Form) Int(key string) int {
+	i, err := form.GetIntE(f.req, key)
+	if err != nil {
+		f.add(key, err)
+	}
+	return i
+}
+
+// Uint64 returns the parameter as uint64.
+func (f *Form) Uint64(key string) uint64 {
+	u, err := form.GetUint64E(f.req, key)
+	if err != nil {
+		f.add(key, err)
+	}
+	return u
+}
+
+// Bool returns the parameter as bool.
+func (f *Form) Bool(key string) bool {
+	b, err := form.GetBoolE(f.req, key)
+	if err != nil {
+		f.add(key, err)
+	}
+	return b
+}
+
+// Add adds a problem with the parameter that was found while checking its value.
+func (f *Form) Add(key string, code validation.Code, format string, a ...interface{}) {
+	f.Errors.Add(key, code, format, a...)
+}
+
+// Check adds the problems found while checking the values, e.g. by course.New, unless the parameter already has a problem.
+func (f *Form) Check(err error) {
+	if err == nil {
+		return
+	}
+
+	var found validation.Errors
+	found.Append("", err)
+	for _, e := range found {
+		if e.Field == "" || !f.Errors.Has(e.Field) {
+			f.Errors = append(f.Errors, e)
+		}
+	}
+}
+
+// Err returns the problems as an error or nil, if there are none.
+func (f *Form) Err() error {
+	return f.Errors.Err()
+}
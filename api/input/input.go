@@ -0,0 +1,221 @@
+// Package input reads the parameters of API requests, which are sent as form parameters or as a JSON body.
+//
+// A JSON body is checked against a schema and its values are stored in the form of the request, so that the response functions can read them just like form parameters.
+// All problems with the input are collected as validation.Errors, so that they can be fixed at once.
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// MaxBodySize is the maximum size of a JSON body in bytes.
+const MaxBodySize = 1 << 20
+
+// A Type is the JSON type a field must have.
+type Type int
+
+// the types of fields
+const (
+	String Type = iota // a string
+	Int                // an integer number
+	Bool               // true or false
+	Date               // a string with a date, e.g. "2020-12-24"
+	List               // an array of strings, e.g. ["Arnold", "Bruce"]
+)
+
+// String returns how the type is described in error messages.
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "a string"
+	case Int:
+		return "an integer"
+	case Bool:
+		return "true or false"
+	case Date:
+		return "a date string"
+	case List:
+		return "an array of strings"
+	}
+	return "unknown"
+}
+
+// A Field is a field of a JSON body.
+type Field struct {
+	Name        string
+	Type        Type
+	Required    bool
+	Description string // for the documentation of the API
+}
+
+// A Schema lists all fields a JSON body may have. Other fields are rejected.
+type Schema []Field
+
+// IsJSON returns whether the request has a JSON body.
+func IsJSON(req *http.Request) bool {
+	t, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && t == "application/json"
+}
+
+// JSON wraps a response function so that the request can also have a JSON body of the schema.
+// If the body doesn't match the schema, all problems are returned at once.
+func JSON(s Schema, respond func(*http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		if IsJSON(req) {
+			if err := Decode(req, s); err != nil {
+				return api.ErrBadRequest(err)
+			}
+		}
+		return respond(req)
+	}
+}
+
+// Decode reads the JSON body of the request and checks it against the schema.
+// The values are stored in the form of the request, together with the parameters of the URL query.
+// Lists are stored comma-separated.
+//
+// If the body is not a JSON object, an error is returned. If fields are unknown, missing or of the wrong type, validation.Errors are returned.
+func Decode(req *http.Request, s Schema) error {
+	body, err := io.ReadAll(io.LimitReader(req.Body, MaxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("request body could not be read: %w", err)
+	}
+	if len(body) > MaxBodySize {
+		return fmt.Errorf("request body must not be larger than %d bytes", MaxBodySize)
+	}
+
+	var raw json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err = dec.Decode(&raw); err != nil {
+		return fmt.Errorf("request body must be a JSON object")
+	}
+	if dec.More() {
+		return fmt.Errorf("request body must contain a single JSON object")
+	}
+
+	values, err := DecodeObject(raw, s)
+	if err != nil {
+		return err
+	}
+
+	// parameters of the URL query, e.g. 'timeout', are still allowed
+	for k, vs := range req.URL.Query() {
+		if _, ok := values[k]; !ok {
+			values[k] = vs
+		}
+	}
+	req.Form = values
+	req.PostForm = url.Values{}
+	return nil
+}
+
+// DecodeObject checks the JSON object against the schema and returns its values as form values.
+// Lists are stored comma-separated.
+//
+// If it is not a JSON object, an error is returned. If fields are unknown, missing or of the wrong type, validation.Errors are returned.
+func DecodeObject(raw json.RawMessage, s Schema) (url.Values, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+		return nil, fmt.Errorf("request body must be a JSON object")
+	}
+
+	values := url.Values{}
+	var errs validation.Errors
+
+	for _, f := range s {
+		raw, ok := obj[f.Name]
+		delete(obj, f.Name)
+
+		if !ok || string(raw) == "null" {
+			if f.Required {
+				errs.Add(f.Name, validation.Required, "%s value not provided", f.Name)
+			}
+			continue
+		}
+
+		v, err := f.value(raw)
+		if err != nil {
+			errs.Append(f.Name, err)
+			continue
+		}
+		values.Set(f.Name, v)
+	}
+
+	// all remaining fields are unknown
+	unknown := make([]string, 0, len(obj))
+	for name := range obj {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs.Add(name, validation.Unknown, "%s is not a known field", name)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return values, nil
+}
+
+// value returns the JSON value as a form value.
+func (f Field) value(raw json.RawMessage) (string, error) {
+	wrongType := f.error(validation.Malformed, "%s value must be %s", f.Name, f.Type)
+
+	switch f.Type {
+	case String, Date:
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return "", wrongType
+		}
+		if f.Type == Date {
+			if _, err := civil.ParseDate(s); err != nil {
+				return "", f.error(validation.Malformed, "%s value '%s' could not be parsed to date", f.Name, s)
+			}
+		}
+		return s, nil
+	case Int:
+		var n int64
+		if json.Unmarshal(raw, &n) != nil {
+			return "", wrongType
+		}
+		return strconv.FormatInt(n, 10), nil
+	case Bool:
+		var b bool
+		if json.Unmarshal(raw, &b) != nil {
+			return "", wrongType
+		}
+		return strconv.FormatBool(b), nil
+	case List:
+		var l []string
+		if json.Unmarshal(raw, &l) != nil {
+			return "", wrongType
+		}
+		for _, s := range l {
+			if strings.Contains(s, ",") {
+				return "", f.error(validation.Invalid, "%s value '%s' must not contain a comma", f.Name, s)
+			}
+		}
+		return strings.Join(l, ","), nil
+	}
+
+	return "", fmt.Errorf("%s has an unknown type", f.Name)
+}
+
+// error returns a problem with the field.
+func (f Field) error(code validation.Code, format string, a ...interface{}) validation.Error {
+	return validation.Error{Field: f.Name, Code: code, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,137 @@
+package input
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+var testSchema = Schema{
+	{Name: "name", Type: String, Required: true},
+	{Name: "date", Type: Date, Required: true},
+	{Name: "capacity", Type: Int},
+	{Name: "historic", Type: Bool},
+	{Name: "blocked", Type: List},
+}
+
+func TestDecode(t *testing.T) {
+	tables := []struct {
+		url  string
+		body string
+		form map[string]string
+		err  string
+	}{
+		{"/test", `{"name": "Judo", "date": "2020-12-24", "capacity": 10, "historic": true, "blocked": ["Arnold", "Bruce"]}`,
+			map[string]string{"name": "Judo", "date": "2020-12-24", "capacity": "10", "historic": "true", "blocked": "Arnold,Bruce"}, ""},
+		{"/test?timeout=1s", `{"name": "Judo", "date": "2020-12-24", "capacity": null}`,
+			map[string]string{"name": "Judo", "date": "2020-12-24", "capacity": "", "timeout": "1s"}, ""},
+		{"/test?name=Karate", `{"name": "Judo", "date": "2020-12-24"}`,
+			map[string]string{"name": "Judo"}, ""},
+
+		// errors
+		{"/test", ``,
+			nil, "request body must be a JSON object"},
+		{"/test", `["Judo"]`,
+			nil, "request body must be a JSON object"},
+		{"/test", `{"name": "Judo", "date": "2020-12-24"} {}`,
+			nil, "request body must contain a single JSON object"},
+		{"/test", `{}`,
+			nil, "name value not provided; date value not provided"},
+		{"/test", `{"name": 1, "date": "tomorrow", "capacity": 1.5, "historic": "yes", "blocked": "Arnold", "cancel": true, "Name": "Judo"}`,
+			nil, "name value must be a string; date value 'tomorrow' could not be parsed to date; capacity value must be an integer; historic value must be true or false; blocked value must be an array of strings; Name is not a known field; cancel is not a known field"},
+		{"/test", `{"name": "Judo", "date": "2020-12-24", "blocked": ["Arnold,Bruce"]}`,
+			nil, "blocked value 'Arnold,Bruce' must not contain a comma"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("POST", table.url, strings.NewReader(table.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		err := Decode(req, testSchema)
+		if table.err != "" {
+			if err == nil || err.Error() != table.err {
+				t.Errorf("decoding %s was supposed to fail, got: %v, want: %q", table.body, err, table.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("decoding %s failed: %s", table.body, err)
+			continue
+		}
+
+		for k, v := range table.form {
+			if got := req.FormValue(k); got != v {
+				t.Errorf("decoding %s gave wrong %s, got: %q, want: %q", table.body, k, got, v)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"date": "2020-12-24", "cancel": true}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var errs validation.Errors
+	if err := Decode(req, testSchema); !errors.As(err, &errs) {
+		t.Fatalf("expected a list of errors, got: %v", err)
+	}
+
+	want := validation.Errors{
+		{Field: "name", Code: validation.Required, Message: "name value not provided"},
+		{Field: "cancel", Code: validation.Unknown, Message: "cancel is not a known field"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("wrong errors, got: %v, want: %v", errs, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	respond := JSON(testSchema, func(req *http.Request) interface{} {
+		return api.NewSuccessNow(http.StatusOK, nil, "hello %s", req.FormValue("name"))
+	})
+
+	tables := []struct {
+		contentType string
+		url         string
+		body        string
+		res         string
+	}{
+		{"application/json", "/test", `{"name": "Judo", "date": "2020-12-24"}`,
+			"hello Judo"},
+		{"application/json; charset=utf-8", "/test", `{"name": "Judo", "date": "2020-12-24"}`,
+			"hello Judo"},
+		{"application/json", "/test", `{"name": "Judo"}`,
+			"400 Bad Request: date value not provided"},
+		{"application/x-www-form-urlencoded", "/test", `name=Judo`,
+			"hello Judo"},
+		{"", "/test?name=Judo", ``,
+			"hello Judo"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("POST", table.url, strings.NewReader(table.body))
+		if table.contentType != "" {
+			req.Header.Set("Content-Type", table.contentType)
+		}
+
+		switch v := respond(req).(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.body, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.", table.body, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", table.body, v)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+)
+
+// how a missing parameter is reported, e.g. "name value not provided"
+var notProvided = regexp.MustCompile(`(\w+) value not provided`)
+
+// example values by type, which are read without problems but don't cause side effects on their own
+var examples = map[input.Type]string{
+	input.String: "x",
+	input.Int:    "1",
+	input.Bool:   "false",
+	input.Date:   civil.DateOf(time.Now()).String(),
+	input.List:   "x",
+}
+
+// invalid values by type, which can't be read, nil for types of which any value can be read
+var invalid = map[input.Type]string{
+	input.Int:  "x",
+	input.Bool: "x",
+	input.Date: "x",
+}
+
+// Check returns an error if the response functions of the path and its documentation drifted apart.
+//
+// For every required parameter, the response function is called without it, but with all other required parameters.
+// It must report the parameter as missing, and it must not report any parameter as missing that isn't documented as required.
+// For every optional parameter that can be invalid, e.g. a date, the response function is called with an invalid value. It must report the parameter.
+// If a read succeeds, the object it returns must be of the type of the documented response.
+//
+// Operations that change something are never called with all required parameters, since the request would be carried out.
+// They are called without the first one instead, and must report invalid optional parameters along with it, as problems are reported all at once.
+// Operations that don't document required parameters are only called if they read, i.e. with GET.
+// The response functions should act on throwaway state, e.g. an empty registry of courses, in case a request is carried out anyway.
+func Check(p Path) error {
+	var problems []string
+
+	for _, op := range p.Operations {
+		if op.Respond == nil {
+			continue
+		}
+
+		documented := map[string]bool{}
+		var required []string
+		for _, f := range op.Params {
+			if f.Required {
+				documented[f.Name] = true
+				required = append(required, f.Name)
+			}
+		}
+
+		missing := append([]string{}, required...)
+		sort.Strings(missing)
+
+		reads := op.Method == http.MethodGet
+
+		// if nothing is documented as required, nothing must be reported as missing
+		if len(missing) == 0 {
+			if !reads {
+				continue
+			}
+			missing = append(missing, "")
+		}
+
+		// call calls the response function with the required parameters except the missing one and the values, and checks what is reported missing
+		call := func(m string, values url.Values) (interface{}, error) {
+			for _, f := range op.Params {
+				if f.Required && f.Name != m {
+					values.Set(f.Name, examples[f.Type])
+				}
+			}
+
+			req, err := http.NewRequest(op.Method, p.URL+"?"+values.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			resp := op.Respond(req)
+			msg := fmt.Sprint(resp)
+
+			reported := map[string]bool{}
+			for _, match := range notProvided.FindAllStringSubmatch(msg, -1) {
+				reported[match[1]] = true
+				if !documented[match[1]] {
+					problems = append(problems, fmt.Sprintf("%s %s: '%s' is required but not documented as required", op.Method, p.URL, match[1]))
+				}
+			}
+
+			if m != "" && !reported[m] {
+				problems = append(problems, fmt.Sprintf("%s %s: '%s' is documented as required, but the request without it got: %s", op.Method, p.URL, m, msg))
+			}
+			return resp, nil
+		}
+
+		for _, m := range missing {
+			if _, err := call(m, url.Values{}); err != nil {
+				return err
+			}
+		}
+
+		// a read with all required parameters returns the documented object
+		if reads {
+			resp, err := call("", url.Values{})
+			if err != nil {
+				return err
+			}
+			if s, ok := resp.(api.Success); ok && reflect.TypeOf(s.Object) != reflect.TypeOf(op.Response) {
+				problems = append(problems, fmt.Sprintf("%s %s: the response is documented as %v, but is %T", op.Method, p.URL, reflect.TypeOf(op.Response), s.Object))
+			}
+		}
+
+		for _, f := range op.Params {
+			v, ok := invalid[f.Type]
+			if f.Required || !ok {
+				continue
+			}
+
+			// without the first required parameter, so that a change isn't carried out
+			m := ""
+			if !reads {
+				m = missing[0]
+			}
+			resp, err := call(m, url.Values{f.Name: {v}})
+			if err != nil {
+				return err
+			}
+			if msg := fmt.Sprint(resp); !regexp.MustCompile(`\b` + regexp.QuoteMeta(f.Name) + ` value\b`).MatchString(msg) {
+				problems = append(problems, fmt.Sprintf("%s %s: '%s' is documented, but the request with the invalid value '%s' got: %s", op.Method, p.URL, f.Name, v, msg))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the documentation drifted from the code:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
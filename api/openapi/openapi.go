@@ -0,0 +1,271 @@
+// Package openapi generates an OpenAPI 3 specification from the documentation of our API endpoints.
+//
+// Every API package documents its endpoint with a Path next to its response functions.
+// The parameters are the same schemas that are used to read JSON bodies (see package input), and the schemas of the responses are derived from example objects via reflection, so the specification can't drift from the code.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// Version is the version of the OpenAPI specification we generate.
+const Version = "3.0.3"
+
+// An Operation documents what an endpoint does for one HTTP method.
+type Operation struct {
+	Method      string
+	Summary     string
+	Description string
+
+	// the parameters, in the URL query for GET, otherwise also as form or JSON body
+	Params input.Schema
+
+	// the fields of the objects of a body that is a list, e.g. courses to import, sent as CSV, as JSON array or as uploaded file
+	// If set, the parameters are always in the URL query.
+	Rows input.Schema
+
+	// the HTTP status of a success and an example of the returned object, nil if there is none
+	Status   int
+	Response interface{}
+
+	// the media types of a success that is not an object encoded as JSON, e.g. a calendar (see endpoint.Content)
+	ContentTypes []string
+
+	// whether the operation can be used without authentication
+	Public bool
+
+	// whether clients should use another path instead (see Path.DeprecatedAt)
+	Deprecated bool
+
+	// the response function, used to check that it requires the documented parameters (see Check), on throwaway state
+	Respond func(*http.Request) interface{}
+}
+
+// A Path documents an endpoint.
+type Path struct {
+	URL        string
+	Operations []Operation
+}
+
+// Operation returns the operation of the method.
+func (p Path) Operation(method string) (Operation, bool) {
+	for _, op := range p.Operations {
+		if op.Method == method {
+			return op, true
+		}
+	}
+	return Operation{}, false
+}
+
+// DeprecatedAt returns the documentation of an old path that still serves the same operations, e.g. an unversioned path.
+func (p Path) DeprecatedAt(url string) Path {
+	ops := make([]Operation, len(p.Operations))
+	for i, op := range p.Operations {
+		op.Deprecated = true
+		op.Description = strings.TrimSpace(fmt.Sprintf("Deprecated, use '%s' instead. %s", p.URL, op.Description))
+		ops[i] = op
+	}
+	return Path{URL: url, Operations: ops}
+}
+
+// At returns the documentation of another path that serves the same operations, e.g. the path of a studio.
+func (p Path) At(url string) Path {
+	return Path{URL: url, Operations: p.Operations}
+}
+
+// Spec is an OpenAPI specification, ready to be encoded as JSON.
+type Spec map[string]interface{}
+
+// Paths returns the documented operations by URL and HTTP method (in lower case).
+func (s Spec) Paths() map[string]map[string]interface{} {
+	return s["paths"].(map[string]map[string]interface{})
+}
+
+// the security schemes with which requests can be authenticated (see package auth)
+var securitySchemes = object{
+	"apiKey":  object{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+	"bearer":  object{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+	"session": object{"type": "apiKey", "in": "cookie", "name": "session"},
+}
+
+// object is a JSON object of the specification.
+type object = map[string]interface{}
+
+// Build returns the specification of the documented endpoints.
+func Build(title, version string, paths ...Path) Spec {
+	ps := make(map[string]map[string]interface{}, len(paths))
+	for _, p := range paths {
+		ops := make(map[string]interface{}, len(p.Operations))
+		for _, op := range p.Operations {
+			ops[strings.ToLower(op.Method)] = op.spec()
+		}
+		ps[p.URL] = ops
+	}
+
+	return Spec{
+		"openapi": Version,
+		"info":    object{"title": title, "version": version},
+		"paths":   ps,
+		"components": object{
+			"securitySchemes": securitySchemes,
+			"schemas": object{
+				"Error":           schemaOf(reflect.TypeOf(api.Error{})),
+				"ValidationError": schemaOf(reflect.TypeOf(validation.Error{})),
+			},
+		},
+		// any of the schemes is enough
+		"security": []object{{"apiKey": []string{}}, {"bearer": []string{}}, {"session": []string{}}},
+	}
+}
+
+// spec returns the specification of the operation.
+func (op Operation) spec() object {
+	o := object{"summary": op.Summary}
+	if op.Description != "" {
+		o["description"] = op.Description
+	}
+	if op.Public {
+		o["security"] = []object{}
+	}
+	if op.Deprecated {
+		o["deprecated"] = true
+	}
+
+	if op.Rows != nil {
+		o["parameters"] = parameters(op.Params)
+		o["requestBody"] = object{
+			"required": true,
+			"content": object{
+				"application/json": object{"schema": object{"type": "array", "items": bodySchema(op.Rows)}},
+				"text/csv":         object{"schema": object{"type": "string", "description": "the first row names the columns"}},
+				"multipart/form-data": object{"schema": object{"type": "object", "properties": object{
+					"file": object{"type": "string", "format": "binary", "description": "a CSV or JSON file"}}}},
+			},
+		}
+	} else if len(op.Params) > 0 {
+		if op.Method == http.MethodGet {
+			o["parameters"] = parameters(op.Params)
+		} else {
+			body := bodySchema(op.Params)
+			o["requestBody"] = object{
+				"required": true,
+				"content": object{
+					"application/json":                  object{"schema": body},
+					"application/x-www-form-urlencoded": object{"schema": body},
+				},
+			}
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// the object of a success is described by the example
+	success := schemaOf(reflect.TypeOf(api.Success{}))
+	if props, ok := success["properties"].(object); ok {
+		if op.Response == nil {
+			props["Object"] = object{"nullable": true}
+		} else {
+			props["Object"] = schemaOf(reflect.TypeOf(op.Response))
+		}
+	}
+
+	content := object{"application/json": object{"schema": success}}
+	if len(op.ContentTypes) > 0 {
+		content = object{}
+		for _, t := range op.ContentTypes {
+			content[t] = object{"schema": object{"type": "string"}}
+		}
+	}
+
+	errorRef := object{"$ref": "#/components/schemas/Error"}
+	responses := object{
+		fmt.Sprint(status): object{
+			"description": http.StatusText(status),
+			"content":     content,
+		},
+		"400": object{
+			"description": "The input is invalid. If parameters are invalid, the error lists all of them as ValidationError.",
+			"content":     object{"application/json": object{"schema": errorRef}},
+		},
+	}
+	if !op.Public {
+		for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+			responses[fmt.Sprint(code)] = object{
+				"description": http.StatusText(code),
+				"content":     object{"application/json": object{"schema": errorRef}},
+			}
+		}
+	}
+	o["responses"] = responses
+
+	return o
+}
+
+// the schemas of the types of fields
+var fieldSchemas = map[input.Type]object{
+	input.String: {"type": "string"},
+	input.Int:    {"type": "integer"},
+	input.Bool:   {"type": "boolean"},
+	input.Date:   {"type": "string", "format": "date"},
+	input.List:   {"type": "array", "items": object{"type": "string"}, "description": "comma-separated in a form"},
+}
+
+// parameters returns the query parameters of the schema.
+func parameters(s input.Schema) []object {
+	ps := make([]object, 0, len(s))
+	for _, f := range s {
+		p := object{"name": f.Name, "in": "query", "required": f.Required, "schema": fieldSchema(f)}
+		if f.Description != "" {
+			p["description"] = f.Description
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// bodySchema returns the schema of a request body with the fields of the schema.
+func bodySchema(s input.Schema) object {
+	props := make(object, len(s))
+	required := make([]string, 0, len(s))
+	for _, f := range s {
+		props[f.Name] = fieldSchema(f)
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	sort.Strings(required)
+
+	o := object{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+// fieldSchema returns the schema of the field.
+func fieldSchema(f input.Field) object {
+	o := object{}
+	for k, v := range fieldSchemas[f.Type] {
+		o[k] = v
+	}
+	if f.Description != "" {
+		if d, ok := o["description"]; ok {
+			o["description"] = fmt.Sprintf("%s (%s)", f.Description, d)
+		} else {
+			o["description"] = f.Description
+		}
+	}
+	return o
+}
@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+)
+
+type inner struct {
+	Name string
+}
+
+type testObject struct {
+	inner
+	ID      uint64
+	Date    civil.Date
+	Tags    []string `json:"tags,omitempty"`
+	Skipped string   `json:"-"`
+	Next    *testObject
+	private int
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := schemaOf(reflect.TypeOf(testObject{}))
+
+	props, ok := s["properties"].(object)
+	if !ok {
+		t.Fatalf("struct has no properties: %v", s)
+	}
+
+	want := map[string]string{
+		"Name": "string",
+		"ID":   "integer",
+		"Date": "string",
+		"tags": "array",
+		"Next": "object",
+	}
+	if len(props) != len(want) {
+		t.Errorf("wrong properties, got: %v", props)
+	}
+	for name, typ := range want {
+		p, ok := props[name].(object)
+		if !ok || p["type"] != typ {
+			t.Errorf("property %s has wrong schema, got: %v, want type: %s", name, props[name], typ)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	p := Path{
+		URL: "/test",
+		Operations: []Operation{
+			{Method: http.MethodGet, Summary: "list", Params: input.Schema{{Name: "id", Type: input.Int}}, Response: []testObject{}},
+			{Method: http.MethodPost, Summary: "create", Params: input.Schema{{Name: "name", Type: input.String, Required: true}}, Status: http.StatusCreated, Public: true},
+		},
+	}
+	upload := Path{
+		URL: "/test/import",
+		Operations: []Operation{
+			{Method: http.MethodPost, Summary: "import", Params: input.Schema{{Name: "dryrun", Type: input.Bool}}, Rows: input.Schema{{Name: "name", Type: input.String, Required: true}}},
+		},
+	}
+
+	spec := Build("Test", "1.0.0", p)
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("specification can't be encoded: %s", err)
+	}
+
+	ops := spec.Paths()["/test"]
+	if len(ops) != 2 {
+		t.Fatalf("wrong operations, got: %v", ops)
+	}
+
+	get := ops["get"].(object)
+	if params := get["parameters"].([]object); len(params) != 1 || params[0]["in"] != "query" {
+		t.Errorf("GET should have query parameters, got: %v", get["parameters"])
+	}
+	if _, ok := get["responses"].(object)["401"]; !ok {
+		t.Errorf("GET should document that authentication is needed")
+	}
+
+	post := ops["post"].(object)
+	if _, ok := post["requestBody"]; !ok {
+		t.Errorf("POST should have a request body")
+	}
+	if _, ok := post["responses"].(object)["201"]; !ok {
+		t.Errorf("POST should document the status 201")
+	}
+	if _, ok := post["responses"].(object)["401"]; ok {
+		t.Errorf("public POST shouldn't need authentication")
+	}
+	if _, ok := post["deprecated"]; ok {
+		t.Errorf("POST shouldn't be deprecated")
+	}
+
+	spec = Build("Test", "1.0.0", upload)
+	imp := spec.Paths()["/test/import"]["post"].(object)
+	if params := imp["parameters"].([]object); len(params) != 1 || params[0]["in"] != "query" {
+		t.Errorf("POST with rows should have query parameters, got: %v", imp["parameters"])
+	}
+	content := imp["requestBody"].(object)["content"].(object)
+	for _, media := range []string{"application/json", "text/csv", "multipart/form-data"} {
+		if _, ok := content[media]; !ok {
+			t.Errorf("POST with rows should accept %s", media)
+		}
+	}
+	if items := content["application/json"].(object)["schema"].(object)["items"].(object); items["required"].([]string)[0] != "name" {
+		t.Errorf("rows should be described by their schema, got: %v", items)
+	}
+
+	spec = Build("Test", "1.0.0", p, p.DeprecatedAt("/old"))
+	old, ok := spec.Paths()["/old"]
+	if !ok || len(old) != 2 {
+		t.Fatalf("wrong operations of the deprecated path, got: %v", old)
+	}
+	for m, op := range old {
+		if op.(object)["deprecated"] != true {
+			t.Errorf("%s of the deprecated path should be deprecated", m)
+		}
+	}
+	if _, ok := spec.Paths()["/test"]["get"].(object)["deprecated"]; ok {
+		t.Errorf("deprecating another path shouldn't deprecate the original")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	respond := func(req *http.Request) interface{} {
+		f := input.NewForm(req)
+		f.String("name")
+		f.String("date")
+		if req.FormValue("limit") != "" {
+			f.Int("limit")
+		}
+		if err := f.Err(); err != nil {
+			return api.ErrBadRequest(err)
+		}
+		return api.NewSuccessNow(http.StatusOK, []string{}, "ok")
+	}
+
+	tables := []struct {
+		params input.Schema
+		drift  []string
+	}{
+		{input.Schema{{Name: "name", Required: true}, {Name: "date", Type: input.Date, Required: true}},
+			nil},
+		{input.Schema{{Name: "name"}, {Name: "date", Type: input.Date, Required: true}},
+			[]string{"'name' is required but not documented as required"}},
+		{input.Schema{{Name: "name", Required: true}, {Name: "date", Type: input.Date, Required: true}, {Name: "id", Type: input.Int, Required: true}},
+			[]string{"'id' is documented as required"}},
+		{input.Schema{{Name: "name", Required: true}, {Name: "date", Type: input.Date, Required: true}, {Name: "limit", Type: input.Int}},
+			nil},
+		{input.Schema{{Name: "name", Required: true}, {Name: "date", Type: input.Date, Required: true}, {Name: "after", Type: input.Date}},
+			[]string{"'after' is documented, but the request with the invalid value 'x' got"}},
+	}
+
+	for i, table := range tables {
+		err := Check(Path{URL: "/test", Operations: []Operation{{Method: http.MethodPost, Params: table.params, Respond: respond}}})
+		if table.drift == nil {
+			if err != nil {
+				t.Errorf("%d: unexpected drift: %s", i, err)
+			}
+			continue
+		}
+		for _, d := range table.drift {
+			if err == nil || !strings.Contains(err.Error(), d) {
+				t.Errorf("%d: expected drift %q, got: %v", i, d, err)
+			}
+		}
+	}
+
+	// the response of a read must be the documented one
+	read := Operation{Method: http.MethodGet, Params: input.Schema{{Name: "name", Required: true}, {Name: "date", Type: input.Date, Required: true}}, Respond: respond}
+	read.Response = []string{}
+	if err := Check(Path{URL: "/test", Operations: []Operation{read}}); err != nil {
+		t.Errorf("unexpected drift: %s", err)
+	}
+	read.Response = []int{}
+	if err := Check(Path{URL: "/test", Operations: []Operation{read}}); err == nil || !strings.Contains(err.Error(), "the response is documented as []int, but is []string") {
+		t.Errorf("expected drift of the response, got: %v", err)
+	}
+
+	carryOut := func(req *http.Request) interface{} {
+		t.Errorf("%s without required parameters shouldn't be called", req.Method)
+		return nil
+	}
+	if err := Check(Path{URL: "/test", Operations: []Operation{{Method: http.MethodDelete, Respond: carryOut}}}); err != nil {
+		t.Errorf("unexpected drift: %s", err)
+	}
+}
@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+var (
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// schemaOf returns the schema of the JSON encoding of the type.
+func schemaOf(t reflect.Type) object {
+	return schemaFor(t, map[reflect.Type]bool{})
+}
+
+// schemaFor returns the schema of the type. Structs that are being described are seen, so that recursive types end.
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) object {
+	// types with a special encoding
+	switch t {
+	case reflect.TypeOf(civil.Date{}):
+		return object{"type": "string", "format": "date"}
+	case reflect.TypeOf(civil.Time{}):
+		return object{"type": "string", "example": "18:30:00"}
+	case reflect.TypeOf(time.Time{}):
+		return object{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(time.Duration(0)):
+		return object{"type": "integer", "description": "nanoseconds"}
+	}
+	if t.Implements(textMarshaler) {
+		return object{"type": "string"}
+	}
+	if t.Implements(errorType) && t.Kind() == reflect.Interface {
+		// the error of an api.Error can be any error, e.g. validation.Errors
+		return object{"oneOf": []object{
+			{"type": "object"},
+			{"type": "array", "items": object{"$ref": "#/components/schemas/ValidationError"}},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := schemaFor(t.Elem(), seen)
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return object{"type": "string", "format": "byte"}
+		}
+		return object{"type": "array", "items": schemaFor(t.Elem(), seen)}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": schemaFor(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return object{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		props := object{}
+		addFields(props, t, seen)
+		return object{"type": "object", "properties": props}
+	}
+
+	// interfaces can be anything
+	return object{}
+}
+
+// addFields adds the properties of the exported fields of the struct, including those of embedded structs.
+func addFields(props object, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// fields of embedded structs are encoded as if they were fields of the outer struct
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(props, f.Type, seen)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaFor(f.Type, seen)
+	}
+}
@@ -0,0 +1,8 @@
+package passes
+
+import "net/http"
+
+// Respond is the response function to an API request to issue a pass of the default studio (see API.Respond).
+func Respond(req *http.Request) interface{} {
+	return API{}.Respond(req)
+}
@@ -0,0 +1,32 @@
+package passes
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/plans"
+)
+
+// Schema is the schema of the parameters to issue a pass (see Respond).
+var Schema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "plan", Type: input.String, Required: true, Description: "e.g. '10-class' or 'monthly-unlimited'"},
+	{Name: "start", Type: input.Date, Description: "first day the pass is valid, today by default"},
+}
+
+// Doc documents the endpoint '/passes' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/passes",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodPost,
+			Summary:     "Issue a pass to a member",
+			Description: "Admins only.",
+			Params:      Schema,
+			Status:      http.StatusCreated,
+			Response:    plans.Pass{},
+			Respond:     Respond,
+		},
+	},
+}
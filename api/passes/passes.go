@@ -0,0 +1,55 @@
+// Package passes implements the implementation of the API point '/passes'.
+//
+// Only admins may issue passes, so the endpoint must not be served without authentication.
+package passes
+
+import (
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/plans"
+	"github.com/MarkRosemaker/booking-system/validation"
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// API holds the response function to requests to '/passes', which issues passes of its studio.
+type API struct {
+	Studio string // the ID of the studio, empty for the default studio
+}
+
+// Respond is the response function to an API request to '/passes'.
+//
+// It parses the form input for a member's 'name' and the name of the 'plan' (see plans.Catalogue).
+// Optionally, a 'start' date can be given. If not, the pass is valid from today.
+//
+// If any input does not make sense, an error is returned. Otherwise, a pass of the studio is issued to the member.
+func (a API) Respond(req *http.Request) interface{} {
+	// get all the user input, collecting all problems
+
+	f := input.NewForm(req)
+	name := f.String("name")
+	plan := f.String("plan")
+	p, ok := plans.Catalogue[plan]
+	if plan != "" && !ok {
+		f.Add("plan", validation.Invalid, "plan '%s' does not exist", plan)
+	}
+
+	start := civil.DateOf(time.Now())
+	if req.FormValue("start") != "" {
+		start = f.Date("start")
+	}
+
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	pass, err := plans.Issue(a.Studio, name, p, start)
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	return api.NewSuccessNow(http.StatusCreated, pass,
+		"%s pass issued to %s, valid until %s", pass.Plan.Name, pass.Member, pass.Until)
+}
@@ -0,0 +1,58 @@
+package passes
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestRespond(t *testing.T) {
+	today := civil.DateOf(time.Now())
+
+	tables := []struct {
+		params string
+		res    string
+	}{
+		// test all errors
+		{"",
+			"400 Bad Request: name value not provided; plan value not provided"},
+		{"?name=Arnold",
+			"400 Bad Request: plan value not provided"},
+		{"?name=Arnold&plan=lifetime",
+			"400 Bad Request: plan 'lifetime' does not exist"},
+		{"?name=Arnold&plan=10-class&start=now",
+			"400 Bad Request: start value 'now' could not be parsed to date"},
+		{"?plan=lifetime&start=now",
+			"400 Bad Request: name value not provided; plan 'lifetime' does not exist; start value 'now' could not be parsed to date"},
+
+		// successful
+		{"?name=Arnold&plan=10-class",
+			fmt.Sprintf("10-class pass issued to Arnold, valid until %s", today.AddDays(364))},
+		{fmt.Sprintf("?name=Arnold&plan=monthly-unlimited&start=%s", today.AddDays(10)),
+			fmt.Sprintf("monthly-unlimited pass issued to Arnold, valid until %s", today.AddDays(39))},
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/passes%s", table.params)
+		resp := Respond(httptest.NewRequest("GET", url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.",
+					url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.",
+					url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", url, resp)
+		}
+	}
+}
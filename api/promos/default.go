@@ -0,0 +1,8 @@
+package promos
+
+import "net/http"
+
+// Respond is the response function to an API request for the promo codes of the default studio (see API.Respond).
+func Respond(req *http.Request) interface{} {
+	return API{}.Respond(req)
+}
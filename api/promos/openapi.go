@@ -0,0 +1,36 @@
+package promos
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/promos"
+)
+
+// Schema is the schema of the parameters to create or deactivate a promo code (see Respond).
+var Schema = input.Schema{
+	{Name: "code", Type: input.String, Required: true},
+	{Name: "deactivate", Type: input.Bool, Description: "deactivate the code instead"},
+	{Name: "kind", Type: input.String, Description: "'percent' or 'first-class', needed to create a code"},
+	{Name: "percent", Type: input.Int, Description: "taken off the price, needed for codes of the kind 'percent'"},
+	{Name: "course", Type: input.Int, Description: "ID of the only course the code is valid for"},
+	{Name: "limit", Type: input.Int, Description: "how often the code can be redeemed in total"},
+	{Name: "expires", Type: input.Date, Description: "last day the code can be redeemed"},
+}
+
+// Doc documents the endpoint '/promos' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/promos",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodPost,
+			Summary:     "Create or deactivate a promo code",
+			Description: "Admins only.",
+			Params:      Schema,
+			Status:      http.StatusCreated,
+			Response:    promos.Code{},
+			Respond:     Respond,
+		},
+	},
+}
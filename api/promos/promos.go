@@ -0,0 +1,72 @@
+// Package promos implements the implementation of the API point '/promos'.
+package promos
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/promos"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// API holds the response function to requests to '/promos', which acts on the promo codes of its studio.
+type API struct {
+	Studio string // the ID of the studio, empty for the default studio
+}
+
+// Respond is the response function to an API request to '/promos'.
+//
+// It parses the form input for the 'code' and its 'kind' ('percent' or 'first-class'). Codes of the kind 'percent' need the 'percent' that is taken off the price.
+// Optionally, the code can be restricted to the course with the given 'course' ID, be limited to 'limit' redemptions, and expire after the date 'expires'.
+//
+// If the 'deactivate' flag is set, the code is deactivated instead.
+//
+// If any input does not make sense, an error is returned. Otherwise, the code is created in the studio.
+func (a API) Respond(req *http.Request) interface{} {
+	// get all the user input, collecting all problems
+
+	f := input.NewForm(req)
+	c := promos.Code{Studio: a.Studio, Code: f.String("code")}
+
+	if f.Bool("deactivate") {
+		if err := f.Err(); err != nil {
+			return api.ErrBadRequest(err)
+		}
+		c, err := promos.Deactivate(a.Studio, c.Code)
+		if err != nil {
+			return api.ErrBadRequest(err)
+		}
+		return api.NewSuccessNow(http.StatusOK, c, "code %s deactivated", c.Code)
+	}
+
+	// only needed to create a code, so it isn't reported like a required parameter
+	if c.Kind = promos.Kind(req.FormValue("kind")); c.Kind == "" {
+		f.Add("kind", validation.Required, "please provide the kind of the code: %s or %s", promos.KindPercent, promos.KindFirstClass)
+	}
+
+	if c.Kind == promos.KindPercent || req.FormValue("percent") != "" {
+		c.Percent = f.Int("percent")
+	}
+	if req.FormValue("course") != "" {
+		c.Course = f.Uint64("course")
+	}
+	if req.FormValue("limit") != "" {
+		c.Limit = f.Int("limit")
+	}
+	if req.FormValue("expires") != "" {
+		c.Expires = f.Date("expires")
+	}
+
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	c, err := promos.Create(c)
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	return api.NewSuccessNow(http.StatusCreated, c, "code %s created", c.Code)
+}
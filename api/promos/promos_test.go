@@ -0,0 +1,66 @@
+package promos
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+func TestRespond(t *testing.T) {
+	tables := []struct {
+		params string
+		res    string
+	}{
+		// test all errors
+		{"",
+			"400 Bad Request: code value not provided; please provide the kind of the code: percent or first-class"},
+		{"?code=WINTER",
+			"400 Bad Request: please provide the kind of the code: percent or first-class"},
+		{"?code=WINTER&kind=percent",
+			"400 Bad Request: percent value not provided"},
+		{"?code=WINTER&kind=percent&percent=0",
+			"400 Bad Request: invalid code parameters: percent (0) must be between 1 and 100"},
+		{"?code=WINTER&kind=free",
+			"400 Bad Request: invalid code parameters: kind 'free' does not exist"},
+		{"?code=WINTER&kind=percent&percent=10&expires=never",
+			"400 Bad Request: expires value 'never' could not be parsed to date"},
+		{"?code=WINTER&deactivate=true",
+			"400 Bad Request: the code WINTER does not exist"},
+		{"?kind=percent&percent=ten&limit=often",
+			"400 Bad Request: code value not provided; percent value 'ten' could not be parsed to int; limit value 'often' could not be parsed to int"},
+
+		// successful
+		{"?code=winter&kind=percent&percent=10&limit=100&expires=2030-03-01",
+			"code WINTER created"},
+		{"?code=WELCOME&kind=first-class",
+			"code WELCOME created"},
+		{"?code=winter&deactivate=true",
+			"code WINTER deactivated"},
+
+		// duplicate not accepted
+		{"?code=WELCOME&kind=first-class",
+			"400 Bad Request: the code WELCOME already exists"},
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/promos%s", table.params)
+		resp := Respond(httptest.NewRequest("GET", url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.",
+					url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.",
+					url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", url, resp)
+		}
+	}
+}
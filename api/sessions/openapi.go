@@ -0,0 +1,43 @@
+package sessions
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/auth"
+)
+
+// LoginSchema is the schema of the parameters to log in (see Login).
+var LoginSchema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "password", Type: input.String, Required: true},
+}
+
+// LoginDoc documents the endpoint '/login' for the OpenAPI specification.
+var LoginDoc = openapi.Path{
+	URL: "/login",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodPost,
+			Summary:     "Log in",
+			Description: "Starts a session and sets the session cookie.",
+			Params:      LoginSchema,
+			Response:    auth.Principal{},
+			Public:      true,
+		},
+	},
+}
+
+// LogoutDoc documents the endpoint '/logout' for the OpenAPI specification.
+var LogoutDoc = openapi.Path{
+	URL: "/logout",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodPost,
+			Summary:     "Log out",
+			Description: "Ends the session and removes the session cookie.",
+			Public:      true,
+		},
+	},
+}
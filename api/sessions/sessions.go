@@ -0,0 +1,73 @@
+// Package sessions implements the implementation of the API points '/login' and '/logout'.
+package sessions
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+	"github.com/MarkRosemaker/go-server/server/form"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+)
+
+// Login is the response function to an API request to '/login'.
+//
+// It parses the form input for the user 'name' and 'password'. If they are correct, a session is started and its token is stored in a cookie.
+// Since the password should not end up in a URL, only the POST method is allowed.
+func Login(w http.ResponseWriter, req *http.Request) interface{} {
+	if req.Method != http.MethodPost {
+		return api.NewError(http.StatusMethodNotAllowed, fmt.Errorf("please log in with the POST method"))
+	}
+
+	var (
+		name, password string
+		token          string
+		p              auth.Principal
+		err            error
+	)
+
+	if name, err = form.GetStringE(req, "name"); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	if password, err = form.GetStringE(req, "password"); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	if token, p, err = auth.Login(name, password); err != nil {
+		return api.NewError(http.StatusUnauthorized, err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(auth.SessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		// other sites can't make requests with it, not even by a link (cross-site request forgery)
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return api.NewSuccessNow(http.StatusOK, p, "Welcome back, %s!", p.Name)
+}
+
+// Logout is the response function to an API request to '/logout'.
+//
+// It ends the session and removes the cookie.
+func Logout(w http.ResponseWriter, req *http.Request) interface{} {
+	if c, err := req.Cookie(auth.SessionCookie); err == nil {
+		auth.Logout(c.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	return api.NewSuccessNow(http.StatusOK, nil, "You are now logged out.")
+}
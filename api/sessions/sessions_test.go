@@ -0,0 +1,74 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+)
+
+func TestLogin(t *testing.T) {
+	if err := auth.AddUser("Arnold", "correct horse", auth.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) *http.Request {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tables := []struct {
+		req *http.Request
+		res string
+	}{
+		{httptest.NewRequest("GET", "/login?name=Arnold&password=correct+horse", nil),
+			"405 Method Not Allowed: please log in with the POST method"},
+		{post(""),
+			"400 Bad Request: name value not provided"},
+		{post("name=Arnold&password=wrong+horse"),
+			"401 Unauthorized: wrong user name or password"},
+		{post("name=Arnold&password=correct+horse"),
+			"Welcome back, Arnold!"},
+	}
+
+	for _, table := range tables {
+		w := httptest.NewRecorder()
+
+		switch v := Login(w, table.req).(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of login was incorrect, got: %q, want: %q.", s, table.res)
+			}
+			if len(w.Result().Cookies()) != 0 {
+				t.Errorf("cookie set even though login failed")
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of login was incorrect, got: %q, want: %q.", v.Message, table.res)
+			}
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != auth.SessionCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+				t.Fatalf("session cookie not set correctly: %v", cookies)
+			}
+
+			// the cookie authenticates the user
+			req := httptest.NewRequest("GET", "/bookings", nil)
+			req.AddCookie(cookies[0])
+			if p, err := auth.Authenticate(req); err != nil || p.Name != "Arnold" {
+				t.Errorf("couldn't authenticate with session cookie: %v", err)
+			}
+
+			// logging out ends the session
+			Logout(httptest.NewRecorder(), req)
+			if _, err := auth.Authenticate(req); err == nil {
+				t.Errorf("session still valid after logout")
+			}
+		}
+	}
+}
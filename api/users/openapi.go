@@ -0,0 +1,33 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+)
+
+// Schema is the schema of the parameters to add a user (see Respond).
+var Schema = input.Schema{
+	{Name: "name", Type: input.String, Required: true},
+	{Name: "role", Type: input.String, Required: true, Description: "'admin', 'instructor' or 'member'"},
+	{Name: "password", Type: input.String, Description: "needed unless 'key' is set"},
+	{Name: "key", Type: input.Bool, Description: "create an API key, which is only shown once"},
+	{Name: "studio", Type: input.String, Description: "the studio the user belongs to, all studios if empty"},
+}
+
+// Doc documents the endpoint '/users' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/users",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodPost,
+			Summary:     "Add a user",
+			Description: "Admins only. The API key is only part of the response if the 'key' flag is set.",
+			Params:      Schema,
+			Status:      http.StatusCreated,
+			Response:    withKey{},
+			Respond:     Respond,
+		},
+	},
+}
@@ -0,0 +1,84 @@
+// Package users implements the implementation of the API point '/users'.
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/tenants"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// withKey is what the API returns about a user with a new API key.
+type withKey struct {
+	auth.Principal
+	Key string
+}
+
+// Respond is the response function to an API request to '/users'.
+//
+// It parses the form input for the user 'name' and 'role' ('admin', 'instructor' or 'member').
+// If a 'password' is given, the user can log in with it. If the 'key' flag is set, an API key is created for the user, e.g. for an integration. The key is only shown once.
+// The optional 'studio' is the ID of the studio the user belongs to (see package tenants). Admins of a studio can only add users to their studio, which is the default for them,
+// and can't replace users of other studios or of all studios. Admins of all studios can also move users to another studio.
+func Respond(req *http.Request) interface{} {
+	// get all the user input, collecting all problems
+
+	f := input.NewForm(req)
+	name := f.String("name")
+	role := f.String("role")
+	key := f.Bool("key")
+
+	password := req.FormValue("password")
+	if password == "" && !key && !f.Errors.Has("key") {
+		f.Add("password", validation.Required, "please provide a password or set the key flag")
+	}
+
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	// admins of a studio can't replace users of other studios or of all studios
+	add := auth.ReplaceUser
+
+	studio := req.FormValue("studio")
+	if admin, ok := auth.FromRequest(req); ok && admin.Studio != "" {
+		add = auth.AddStudioUser
+		if studio == "" {
+			studio = admin.Studio
+		} else if studio != admin.Studio {
+			return api.NewError(http.StatusForbidden, fmt.Errorf("you can only add users to your studio"))
+		}
+	}
+	if studio != "" {
+		if _, err := tenants.Get(studio); err != nil {
+			return api.ErrBadRequest(err)
+		}
+	}
+
+	p := auth.Principal{Name: name, Role: auth.Role(role), Studio: studio}
+
+	if password != "" {
+		if err := add(studio, name, password, p.Role); errors.Is(err, auth.ErrOtherStudio) {
+			return api.NewError(http.StatusForbidden, fmt.Errorf("user %s belongs to another studio", name))
+		} else if err != nil {
+			return api.ErrBadRequest(err)
+		}
+	}
+
+	if !key {
+		return api.NewSuccessNow(http.StatusCreated, p, "user %s can now log in as %s", p.Name, p.Role)
+	}
+
+	k, err := auth.NewKey(p)
+	if err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	return api.NewSuccessNow(http.StatusCreated, withKey{p, k}, "API key created for %s as %s, it won't be shown again", p.Name, p.Role)
+}
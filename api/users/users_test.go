@@ -0,0 +1,109 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+func TestRespond(t *testing.T) {
+	tables := []struct {
+		params string
+		res    string
+	}{
+		// test all errors
+		{"",
+			"400 Bad Request: name value not provided; role value not provided; please provide a password or set the key flag"},
+		{"?name=Arnold&key=true",
+			"400 Bad Request: role value not provided"},
+		{"?name=Arnold&role=member&key=maybe",
+			"400 Bad Request: key value 'maybe' could not be parsed to bool"},
+		{"?name=Arnold&role=member",
+			"400 Bad Request: please provide a password or set the key flag"},
+		{"?name=Arnold&role=boss&password=correct+horse",
+			"400 Bad Request: role 'boss' does not exist"},
+		{"?name=Arnold&role=member&password=short",
+			"400 Bad Request: the password must have at least 8 characters"},
+		{"?name=Arnold&role=member&password=correct+horse&studio=nowhere",
+			"400 Bad Request: studio 'nowhere' does not exist"},
+
+		// successful
+		{"?name=Arnold&role=member&password=correct+horse",
+			"user Arnold can now log in as member"},
+		{"?name=CRM&role=admin&key=true",
+			"API key created for CRM as admin, it won't be shown again"},
+	}
+
+	for _, table := range tables {
+		url := fmt.Sprintf("/users%s", table.params)
+		resp := Respond(httptest.NewRequest("GET", url, nil))
+
+		switch v := resp.(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.",
+					url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: '%s', want: '%s'.",
+					url, v.Message, table.res)
+			}
+		default:
+			t.Errorf("Result of %s has wrong type, expected: api.Error or api.Success, got: %T", url, resp)
+		}
+	}
+
+	// the created key works
+	resp := Respond(httptest.NewRequest("GET", "/users?name=Kiosk&role=member&key=true", nil))
+	s, ok := resp.(api.Success)
+	if !ok {
+		t.Fatalf("couldn't create API key: %v", resp)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", reflect.ValueOf(s.Object).FieldByName("Key").String())
+	if p, err := auth.Authenticate(req); err != nil || p.Name != "Kiosk" {
+		t.Errorf("couldn't authenticate with created key: %v", err)
+	}
+
+	// admins of a studio add users to their studio
+	if err := tenants.Add(tenants.Studio{ID: "users-test"}); err != nil {
+		t.Fatal(err)
+	}
+	admin := auth.Principal{Name: "Boss", Role: auth.RoleAdmin, Studio: "users-test"}
+
+	req = httptest.NewRequest("GET", "/users?name=Dana&role=member&password=correct+horse", nil)
+	resp = Respond(req.WithContext(auth.NewContext(req.Context(), admin)))
+	if s, ok := resp.(api.Success); !ok || s.Object.(auth.Principal).Studio != "users-test" {
+		t.Errorf("user wasn't added to the studio of the admin: %v", resp)
+	}
+
+	req = httptest.NewRequest("GET", "/users?name=Eve&role=admin&password=correct+horse&studio=other", nil)
+	resp = Respond(req.WithContext(auth.NewContext(req.Context(), admin)))
+	if e, ok := resp.(api.Error); !ok || e.Status != http.StatusForbidden {
+		t.Errorf("admin of a studio could add a user to another studio: %v", resp)
+	}
+
+	// admins of a studio can't take over the accounts of other studios or of all studios
+	if err := auth.AddUser("Root", "correct horse", auth.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "/users?name=Root&role=admin&password=stolen+password", nil)
+	resp = Respond(req.WithContext(auth.NewContext(req.Context(), admin)))
+	if e, ok := resp.(api.Error); !ok || e.Status != http.StatusForbidden {
+		t.Errorf("admin of a studio could replace a user of all studios: %v", resp)
+	}
+	if _, p, err := auth.Login("Root", "stolen password"); err == nil {
+		t.Errorf("admin of a studio took over %+v", p)
+	}
+	if _, p, err := auth.Login("Root", "correct horse"); err != nil || p.Studio != "" {
+		t.Errorf("user of all studios changed: %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,23 @@
+package webhooks
+
+import "net/http"
+
+// List is the response function to a GET request for the webhooks of the default studio (see API.List).
+func List(req *http.Request) interface{} {
+	return API{}.List(req)
+}
+
+// Subscribe is the response function to a POST request to subscribe to the events of the default studio (see API.Subscribe).
+func Subscribe(req *http.Request) interface{} {
+	return API{}.Subscribe(req)
+}
+
+// Unsubscribe is the response function to a DELETE request for a webhook of the default studio (see API.Unsubscribe).
+func Unsubscribe(req *http.Request) interface{} {
+	return API{}.Unsubscribe(req)
+}
+
+// DeadLetters is the response function to a GET request for the dead letters of the default studio (see API.DeadLetters).
+func DeadLetters(req *http.Request) interface{} {
+	return API{}.DeadLetters(req)
+}
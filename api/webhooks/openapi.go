@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/webhooks"
+)
+
+// SubscribeSchema is the schema of the parameters to subscribe to events (see API.Subscribe).
+var SubscribeSchema = input.Schema{
+	{Name: "url", Type: input.String, Required: true, Description: "gets the events as signed JSON POST requests"},
+	{Name: "events", Type: input.String, Description: "comma-separated, e.g. 'class-booked,booking-cancelled', all if empty"},
+	{Name: "secret", Type: input.String, Description: "signs the payloads, a new one if empty"},
+}
+
+// UnsubscribeSchema is the schema of the parameters to remove a subscription (see API.Unsubscribe).
+var UnsubscribeSchema = input.Schema{
+	{Name: "id", Type: input.Int, Required: true},
+}
+
+// Doc documents the endpoint '/v1/webhooks' for the OpenAPI specification.
+var Doc = openapi.Path{
+	URL: "/v1/webhooks",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodGet,
+			Summary:     "List the webhooks",
+			Description: "Admins only. The subscriptions of the studio, without their secrets.",
+			Response:    []webhooks.Subscription{},
+			Respond:     List,
+		},
+		{
+			Method:  http.MethodPost,
+			Summary: "Subscribe to events",
+			Description: "Admins only. The URL gets a POST request with a JSON payload for every event of the studio, e.g. 'class-booked'. " +
+				"The header 'X-Webhook-Signature' holds the HMAC-SHA256 of the time and payload, signed with the secret, which is only returned here. " +
+				"Failed deliveries are retried with exponential backoff, up to 6 attempts, before they become dead letters.",
+			Params:   SubscribeSchema,
+			Status:   http.StatusCreated,
+			Response: subscribed{},
+			Respond:  Subscribe,
+		},
+		{
+			Method:      http.MethodDelete,
+			Summary:     "Unsubscribe",
+			Description: "Admins only.",
+			Params:      UnsubscribeSchema,
+			Respond:     Unsubscribe,
+		},
+	},
+}
+
+// DeadLettersDoc documents the endpoint '/v1/webhooks/dead-letters' for the OpenAPI specification.
+var DeadLettersDoc = openapi.Path{
+	URL: "/v1/webhooks/dead-letters",
+	Operations: []openapi.Operation{
+		{
+			Method:      http.MethodGet,
+			Summary:     "List the dead letters",
+			Description: "Admins only. The deliveries of the studio that failed every attempt, with the error of the last one, oldest first.",
+			Response:    []webhooks.DeadLetter{},
+			Respond:     DeadLetters,
+		},
+	},
+}
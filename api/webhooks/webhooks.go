@@ -0,0 +1,79 @@
+// Package webhooks implements the API points '/v1/webhooks/...', where admins subscribe other systems, e.g. a CRM, to the events of their studio (see package webhooks).
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/validation"
+	"github.com/MarkRosemaker/booking-system/webhooks"
+)
+
+// API holds the response functions to requests for webhooks, which act on the subscriptions of its studio.
+type API struct {
+	Studio string // the ID of the studio, empty for the default studio
+}
+
+// subscribed is what the API returns about a new subscription: the only time the secret is shown.
+type subscribed struct {
+	webhooks.Subscription
+	Secret string // to verify the signatures of the deliveries
+}
+
+// List is the response function to a GET request to '/v1/webhooks'.
+//
+// It returns the subscriptions of the studio, without their secrets.
+func (a API) List(req *http.Request) interface{} {
+	ss := webhooks.Of(a.Studio)
+	return api.NewSuccessNow(http.StatusOK, ss, "%d subscriptions", len(ss))
+}
+
+// Subscribe is the response function to a POST request to '/v1/webhooks'.
+//
+// It subscribes the 'url' to the comma-separated 'events', e.g. 'class-booked,booking-cancelled', or to all events if none are given.
+// The payloads are signed with the 'secret', or with a new one if none is given, which is returned.
+func (a API) Subscribe(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	s := webhooks.Subscription{URL: f.String("url"), Studio: a.Studio, Secret: req.FormValue("secret")}
+
+	kinds, err := webhooks.ParseKinds(req.FormValue("events"))
+	if err != nil {
+		f.Add("events", validation.Invalid, "%s", err)
+	}
+	s.Kinds = kinds
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	if s, err = webhooks.Subscribe(s); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	return api.NewSuccessNow(http.StatusCreated, subscribed{s, s.Secret}, "%s subscribed, keep the secret safe", s.URL)
+}
+
+// Unsubscribe is the response function to a DELETE request to '/v1/webhooks'.
+//
+// It removes the subscription with the given 'id'.
+func (a API) Unsubscribe(req *http.Request) interface{} {
+	f := input.NewForm(req)
+	id := f.Uint64("id")
+	if err := f.Err(); err != nil {
+		return api.ErrBadRequest(err)
+	}
+
+	if err := webhooks.Unsubscribe(a.Studio, id); err != nil {
+		return api.NewError(http.StatusNotFound, err)
+	}
+	return api.NewSuccessNow(http.StatusOK, nil, "subscription %d removed", id)
+}
+
+// DeadLetters is the response function to a GET request to '/v1/webhooks/dead-letters'.
+//
+// It returns the deliveries of the studio that failed every attempt, oldest first.
+func (a API) DeadLetters(req *http.Request) interface{} {
+	ds := webhooks.DeadLetters(a.Studio)
+	return api.NewSuccessNow(http.StatusOK, ds, "%d dead letters", len(ds))
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/webhooks"
+)
+
+// post returns a request with the form values, in the body for POST and in the query otherwise, e.g. for DELETE.
+func post(method string, values url.Values) *http.Request {
+	if method != http.MethodPost {
+		return httptest.NewRequest(method, "/v1/webhooks?"+values.Encode(), nil)
+	}
+	req := httptest.NewRequest(method, "/v1/webhooks", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestSubscribe(t *testing.T) {
+	a := API{Studio: "api-test"}
+
+	resp, ok := a.Subscribe(post(http.MethodPost, url.Values{"url": {"https://crm.example.com/hooks"}, "events": {"class-booked,booking-cancelled"}})).(api.Success)
+	if !ok || resp.Status != http.StatusCreated {
+		t.Fatalf("expected the subscription to be created, got: %v", resp)
+	}
+	s := resp.Object.(subscribed)
+	if s.Secret == "" || s.Studio != "api-test" || len(s.Kinds) != 2 || s.Kinds[0] != bus.ClassBooked {
+		t.Errorf("wrong subscription: %v", s)
+	}
+
+	for _, values := range []url.Values{
+		{},
+		{"url": {"crm"}},
+		{"url": {"https://crm.example.com/hooks"}, "events": {"class-sold"}},
+	} {
+		if resp, ok := a.Subscribe(post(http.MethodPost, values)).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+			t.Errorf("%v: expected a bad request, got: %v", values, resp)
+		}
+	}
+
+	// listed without the secret
+	resp, ok = a.List(httptest.NewRequest(http.MethodGet, "/v1/webhooks", nil)).(api.Success)
+	if ss := resp.Object.([]webhooks.Subscription); !ok || len(ss) != 1 || ss[0].ID != s.ID {
+		t.Errorf("wrong subscriptions: %v", resp)
+	}
+	if other := (API{Studio: "other"}).List(httptest.NewRequest(http.MethodGet, "/v1/webhooks", nil)).(api.Success); len(other.Object.([]webhooks.Subscription)) != 0 {
+		t.Errorf("subscriptions of another studio are listed: %v", other)
+	}
+
+	// only from its studio
+	if resp, ok := (API{Studio: "other"}).Unsubscribe(post(http.MethodDelete, url.Values{"id": {fmt.Sprint(s.ID)}})).(api.Error); !ok || resp.Status != http.StatusNotFound {
+		t.Errorf("expected the subscription not to be found in another studio, got: %v", resp)
+	}
+	if resp, ok := a.Unsubscribe(post(http.MethodDelete, url.Values{"id": {fmt.Sprint(s.ID)}})).(api.Success); !ok || resp.Status != http.StatusOK {
+		t.Errorf("expected the subscription to be removed, got: %v", resp)
+	}
+	if resp, ok := a.Unsubscribe(post(http.MethodDelete, url.Values{"id": {"x"}})).(api.Error); !ok || resp.Status != http.StatusBadRequest {
+		t.Errorf("expected a bad request, got: %v", resp)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	resp, ok := API{Studio: "api-test"}.DeadLetters(httptest.NewRequest(http.MethodGet, "/v1/webhooks/dead-letters", nil)).(api.Success)
+	if !ok || len(resp.Object.([]webhooks.DeadLetter)) != 0 {
+		t.Errorf("expected no dead letters, got: %v", resp)
+	}
+}
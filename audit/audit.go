@@ -0,0 +1,189 @@
+// Package audit keeps a log of every change to the courses and bookings: who changed what, when, from which values to which, and in which request.
+//
+// The log is append-only: entries are never changed or removed. If a file is opened (see Open), every entry is also appended to it, so that the log survives restarts.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+// An Action describes what was changed.
+type Action string
+
+// the actions
+const (
+	CourseCreated    Action = "course-created"    // a course was created or imported
+	CourseUpdated    Action = "course-updated"    // a course was changed, e.g. its capacity
+	ClassCancelled   Action = "class-cancelled"   // the studio cancelled a class
+	ClassBooked      Action = "class-booked"      // a member booked a class
+	BookingCancelled Action = "booking-cancelled" // a member cancelled a booking
+)
+
+// An Entry records a single change.
+type Entry struct {
+	ID        uint64
+	Time      time.Time
+	Studio    string         `json:",omitempty"` // the ID, empty for the default studio
+	Actor     auth.Principal // who made the change, empty if not known
+	Action    Action
+	Target    string      // what was changed (see Course, Class and Booking)
+	Before    interface{} `json:",omitempty"` // the values before the change, nil if the target didn't exist
+	After     interface{} `json:",omitempty"` // the values after the change
+	RequestID string      `json:",omitempty"` // of the request that made the change (see RequestIDHeader)
+}
+
+// Course returns the target of a change to the course, e.g. 'course/3'.
+func Course(id uint64) string {
+	return fmt.Sprintf("course/%d", id)
+}
+
+// Class returns the target of a change to the class of the course on the date, e.g. 'course/3/2021-01-04'.
+func Class(id uint64, date civil.Date) string {
+	return fmt.Sprintf("%s/%s", Course(id), date)
+}
+
+// Booking returns the target of a change to the booking of the member for the class of the course on the date, e.g. 'course/3/2021-01-04/Arnold'.
+func Booking(id uint64, date civil.Date, member string) string {
+	return fmt.Sprintf("%s/%s", Class(id, date), member)
+}
+
+var (
+	// all entries, in the order they were recorded
+	entries []Entry = make([]Entry, 0)
+	// where they are appended, nil if nowhere
+	out *os.File
+
+	// protect list and file with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Record adds the change made by the request to the log and returns the entry.
+// The actor, studio and request ID are those of the request, if given.
+func Record(req *http.Request, action Action, target string, before, after interface{}) Entry {
+	e := Entry{Action: action, Target: target, Before: before, After: after}
+	if req != nil {
+		e.Actor, _ = auth.FromRequest(req)
+		e.Studio = tenants.Of(req).ID
+		e.RequestID = RequestID(req)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	e.ID = uint64(len(entries) + 1)
+	e.Time = time.Now()
+	entries = append(entries, e)
+
+	if out != nil {
+		// the change was made, so it can't fail because we couldn't write it down
+		if err := json.NewEncoder(out).Encode(e); err != nil {
+			log.Printf("audit: entry %d: %s", e.ID, err)
+		}
+	}
+
+	return e
+}
+
+// Open loads the entries of the file, if it exists, and appends every entry from now on to it.
+// The entries recorded before, if no file was open, are added to it, too.
+func Open(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	es := make([]Entry, 0)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for n := 1; sc.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			f.Close()
+			return fmt.Errorf("%s, line %d: %w", path, n, err)
+		}
+		es = append(es, e)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if out != nil {
+		// the entries recorded so far are in the file that was open
+		out.Close()
+	} else {
+		// the entries recorded so far come after those of the file
+		for _, e := range entries {
+			e.ID = uint64(len(es) + 1)
+			es = append(es, e)
+			if err := json.NewEncoder(f).Encode(e); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	entries, out = es, f
+	return nil
+}
+
+// A Filter selects entries of the log. Empty fields select all entries.
+type Filter struct {
+	Studio string // always applied, empty for the default studio
+	Actor  string // the name
+	Action Action
+	Target string     // e.g. 'course/3' for the entries of the course, its classes and bookings
+	Since  civil.Date // the first day
+	Until  civil.Date // the last day
+	After  uint64     // an ID, to continue after it
+	Limit  int        // how many entries at most, all if zero
+}
+
+// matches returns whether the filter selects the entry.
+func (f Filter) matches(e Entry) bool {
+	day := civil.DateOf(e.Time)
+	switch {
+	case e.Studio != f.Studio,
+		f.Actor != "" && e.Actor.Name != f.Actor,
+		f.Action != "" && e.Action != f.Action,
+		f.Target != "" && e.Target != f.Target && !strings.HasPrefix(e.Target, f.Target+"/"),
+		f.Since.IsValid() && day.Before(f.Since),
+		f.Until.IsValid() && day.After(f.Until),
+		e.ID <= f.After:
+		return false
+	}
+	return true
+}
+
+// Find returns the entries the filter selects, oldest first, and whether there are more than its limit.
+func Find(f Filter) ([]Entry, bool) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	es := make([]Entry, 0)
+	for _, e := range entries {
+		if !f.matches(e) {
+			continue
+		}
+		if f.Limit > 0 && len(es) == f.Limit {
+			return es, true
+		}
+		es = append(es, e)
+	}
+	return es, false
+}
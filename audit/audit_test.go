@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+func TestRecord(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/bookings", nil)
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Name: "Arnold", Role: auth.RoleMember}))
+	req = req.WithContext(tenants.NewContext(req.Context(), tenants.Studio{ID: "audit-test"}))
+	req = req.WithContext(NewContext(req.Context(), "req-1"))
+
+	today := civil.DateOf(time.Now())
+	e := Record(req, ClassBooked, Booking(3, today, "Arnold"), nil, map[string]bool{"Attending": true})
+	if e.ID == 0 || e.Actor.Name != "Arnold" || e.Studio != "audit-test" || e.RequestID != "req-1" || e.Target != "course/3/"+today.String()+"/Arnold" {
+		t.Errorf("wrong entry: %+v", e)
+	}
+
+	Record(req, CourseUpdated, Course(31), nil, nil)
+	Record(nil, CourseCreated, Course(3), nil, nil) // e.g. by a tool, for the default studio
+
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		n      int
+		more   bool
+	}{
+		{"studio", Filter{Studio: "audit-test"}, 2, false},
+		{"default studio", Filter{Action: CourseCreated}, 1, false},
+		{"target", Filter{Studio: "audit-test", Target: "course/3"}, 1, false},
+		{"actor", Filter{Studio: "audit-test", Actor: "Berta"}, 0, false},
+		{"action", Filter{Studio: "audit-test", Action: CourseUpdated}, 1, false},
+		{"limit", Filter{Studio: "audit-test", Limit: 1}, 1, true},
+		{"after", Filter{Studio: "audit-test", After: e.ID}, 1, false},
+		{"since", Filter{Studio: "audit-test", Since: today.AddDays(1)}, 0, false},
+		{"until", Filter{Studio: "audit-test", Until: today}, 2, false},
+	} {
+		es, more := Find(tc.filter)
+		if len(es) != tc.n || more != tc.more {
+			t.Errorf("%s: got %d entries (more: %t), want: %d (more: %t)", tc.name, len(es), more, tc.n, tc.more)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	Record(nil, CourseCreated, Course(7), nil, map[string]int{"Capacity": 10})
+	Record(nil, CourseUpdated, Course(7), map[string]int{"Capacity": 10}, map[string]int{"Capacity": 12})
+
+	// as after a restart
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	e := Record(nil, CourseUpdated, Course(7), nil, nil)
+
+	es, _ := Find(Filter{Target: "course/7"})
+	if len(es) != 3 || e.ID != es[2].ID || es[1].Before.(map[string]interface{})["Capacity"] != 10.0 {
+		t.Errorf("wrong entries: %+v", es)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		same   bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"forged\nline", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, tc.header)
+		id := NewRequestID(req)
+		if id == "" || (id == tc.header) != tc.same {
+			t.Errorf("%q: got ID %q", tc.header, id)
+		}
+	}
+}
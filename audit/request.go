@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header with the ID of a request, which a proxy may set and which is sent back to the client.
+// It ties the entries of the log to a request, e.g. all courses of an import, and to the logs of other systems.
+const RequestIDHeader = "X-Request-ID"
+
+// the key to store the request ID in the context of a request
+type contextKey struct{}
+
+// NewContext returns a copy of the context that carries the ID of the request.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// RequestID returns the ID of the request that was stored in its context, or an empty string.
+func RequestID(req *http.Request) string {
+	id, _ := req.Context().Value(contextKey{}).(string)
+	return id
+}
+
+// NewRequestID returns the ID of the request: the one in its header, if it is valid, otherwise a new one.
+func NewRequestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID returns whether the ID is non-empty, not too long and consists of printable ASCII characters, so that it can't forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+// Package auth implements authentication and role-based authorization for the API.
+//
+// A request is authenticated by one of the following, in this order:
+//
+//   - an API key in the header 'X-API-Key', for integrations.
+//   - a JSON Web Token signed with our local key in the header 'Authorization: Bearer <token>'.
+//   - a session cookie, for the pages in the folder 'site'.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// A Role determines what a user is allowed to do.
+type Role string
+
+// the roles
+const (
+	RoleAdmin      Role = "admin"      // can do everything
+	RoleInstructor Role = "instructor" // can manage classes and book for members
+	RoleMember     Role = "member"     // can book and cancel for themselves
+)
+
+// valid returns whether the role exists.
+func (r Role) valid() bool {
+	switch r {
+	case RoleAdmin, RoleInstructor, RoleMember:
+		return true
+	}
+	return false
+}
+
+// A Principal is an authenticated user.
+type Principal struct {
+	Name   string
+	Role   Role
+	Studio string `json:",omitempty"` // the studio the user belongs to, empty if the user belongs to all of them (see package tenants)
+}
+
+// Is returns whether the principal has one of the roles.
+func (p Principal) Is(roles ...Role) bool {
+	for _, r := range roles {
+		if p.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated is returned if a request carries no valid credentials.
+var ErrUnauthenticated = errors.New("please log in or provide valid credentials")
+
+// Authenticate returns the principal that made the request.
+func Authenticate(req *http.Request) (Principal, error) {
+	if key := req.Header.Get("X-API-Key"); key != "" {
+		return byKey(key)
+	}
+
+	if h := req.Header.Get("Authorization"); h != "" {
+		token := strings.TrimPrefix(h, "Bearer ")
+		if token == h {
+			return Principal{}, fmt.Errorf("authorization header must be of the form 'Bearer <token>'")
+		}
+		return verifyJWT(token)
+	}
+
+	if c, err := req.Cookie(SessionCookie); err == nil {
+		return bySession(c.Value)
+	}
+
+	return Principal{}, ErrUnauthenticated
+}
+
+// the key to store the principal in the context of a request
+type contextKey struct{}
+
+// NewContext returns a copy of the context that carries the principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromRequest returns the principal that was stored in the context of the request by Protect.
+func FromRequest(req *http.Request) (Principal, bool) {
+	p, ok := req.Context().Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// A Policy decides whether the principal may make the request. If not, it returns an error explaining why.
+type Policy func(p Principal, req *http.Request) error
+
+// Roles allows principals with one of the given roles.
+func Roles(roles ...Role) Policy {
+	return func(p Principal, req *http.Request) error {
+		if p.Is(roles...) {
+			return nil
+		}
+		return fmt.Errorf("as %s, you are not allowed to do this", p.Role)
+	}
+}
+
+// Self allows members to act only on their own behalf, i.e. the form value with the given key must be their name.
+// Admins and instructors may act on behalf of anybody.
+func Self(key string) Policy {
+	return func(p Principal, req *http.Request) error {
+		if p.Is(RoleAdmin, RoleInstructor) || req.FormValue(key) == p.Name {
+			return nil
+		}
+		return fmt.Errorf("you can only do this for yourself")
+	}
+}
+
+// Changes allows what the policy allows, except requests with the method GET or HEAD that are authenticated by the session cookie.
+// Response functions that change something, e.g. book a class, need it if they don't check the method themselves:
+// a link on another site makes the browser send such a request with the cookie of whoever follows it (cross-site request forgery).
+func Changes(policy Policy) Policy {
+	return func(p Principal, req *http.Request) error {
+		if bySessionCookie(req) && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+			return fmt.Errorf("please use the POST method to make changes when logged in")
+		}
+		return policy(p, req)
+	}
+}
+
+// bySessionCookie returns whether the request is authenticated by the session cookie, i.e. it has no other credentials (see Authenticate).
+func bySessionCookie(req *http.Request) bool {
+	if req.Header.Get("X-API-Key") != "" || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, err := req.Cookie(SessionCookie)
+	return err == nil
+}
+
+// Protect wraps the response function of an endpoint so that it is only called for authenticated requests allowed by the policy.
+// The principal can be retrieved in the response function with FromRequest.
+func Protect(respond func(*http.Request) interface{}, policy Policy) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		p, err := Authenticate(req)
+		if err != nil {
+			return api.NewError(http.StatusUnauthorized, err)
+		}
+
+		if err = policy(p, req); err != nil {
+			return api.NewError(http.StatusForbidden, err)
+		}
+
+		return respond(req.WithContext(NewContext(req.Context(), p)))
+	}
+}
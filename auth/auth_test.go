@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func TestKeys(t *testing.T) {
+	if err := AddKey("short", Principal{Name: "crm", Role: RoleAdmin}); err == nil {
+		t.Errorf("added API key that is too short")
+	}
+	if err := AddKey(testKey, Principal{Name: "crm", Role: "boss"}); err == nil {
+		t.Errorf("added API key with invalid role")
+	}
+
+	key, err := NewKey(Principal{Name: "crm", Role: RoleInstructor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", key)
+	if p, err := Authenticate(req); err != nil || p.Name != "crm" || p.Role != RoleInstructor {
+		t.Errorf("couldn't authenticate with API key: %+v, %v", p, err)
+	}
+
+	req.Header.Set("X-API-Key", "wrong")
+	if _, err := Authenticate(req); err == nil {
+		t.Errorf("authenticated with wrong API key")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	if err := AddUser("Arnold", "short", RoleMember); err == nil {
+		t.Errorf("added user with short password")
+	}
+	if err := AddUser("Arnold", "correct horse", RoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Login("Arnold", "wrong horse"); err == nil {
+		t.Errorf("logged in with wrong password")
+	}
+	if _, _, err := Login("Nobody", "correct horse"); err == nil {
+		t.Errorf("logged in as unknown user")
+	}
+
+	token, p, err := Login("Arnold", "correct horse")
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if p.Role != RoleMember {
+		t.Errorf("wrong role after login: %s", p.Role)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	if p, err = Authenticate(req); err != nil || p.Name != "Arnold" {
+		t.Errorf("couldn't authenticate with session: %+v, %v", p, err)
+	}
+
+	Logout(token)
+	if _, err = Authenticate(req); err == nil {
+		t.Errorf("authenticated after logout")
+	}
+
+	// users of a studio
+	if err := AddStudioUser("downtown", "Bruce", "correct horse", RoleInstructor); err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err = Login("Bruce", "correct horse"); err != nil || p.Studio != "downtown" {
+		t.Errorf("wrong studio after login: %+v, %v", p, err)
+	}
+
+	// only admins of all studios replace users of other studios
+	if err := AddStudioUser("uptown", "Bruce", "stolen password", RoleAdmin); !errors.Is(err, ErrOtherStudio) {
+		t.Errorf("replaced a user of another studio: %v", err)
+	}
+	if err := AddStudioUser("uptown", "Arnold", "stolen password", RoleAdmin); !errors.Is(err, ErrOtherStudio) {
+		t.Errorf("replaced a user of all studios: %v", err)
+	}
+	if _, _, err = Login("Bruce", "correct horse"); err != nil {
+		t.Errorf("refused replacement changed the password: %v", err)
+	}
+	if err := ReplaceUser("uptown", "Bruce", "correct horse", RoleInstructor); err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err = Login("Bruce", "correct horse"); err != nil || p.Studio != "uptown" {
+		t.Errorf("wrong studio after replacing: %+v, %v", p, err)
+	}
+
+	// a demoted user loses the rights of the sessions before
+	if err := AddUser("Erik", "correct horse", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if token, _, err = Login("Erik", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	respond := func(req *http.Request) interface{} {
+		return api.NewSuccessNow(http.StatusOK, nil, "admin")
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	if resp, ok := Protect(respond, Roles(RoleAdmin))(req).(api.Success); !ok {
+		t.Fatalf("admin couldn't act as admin: %v", resp)
+	}
+	if err := AddUser("Erik", "correct horse", RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if resp, ok := Protect(respond, Roles(RoleAdmin))(req).(api.Error); !ok {
+		t.Errorf("demoted user still acted as admin: %v", resp)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer a.b.c")
+	if _, err := Authenticate(req); err == nil {
+		t.Errorf("accepted token without a key")
+	}
+
+	if err := SetJWTKey([]byte("short")); err == nil {
+		t.Errorf("accepted short JWT key")
+	}
+	if err := SetJWTKey([]byte(testKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := SignJWT(Principal{Name: "Bruce", Role: RoleInstructor}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if p, err := Authenticate(req); err != nil || p.Name != "Bruce" || p.Role != RoleInstructor {
+		t.Errorf("couldn't authenticate with token: %+v, %v", p, err)
+	}
+
+	// the studio of the user is part of the token
+	studioToken, _ := SignJWT(Principal{Name: "Chuck", Role: RoleMember, Studio: "downtown"}, time.Hour)
+	req.Header.Set("Authorization", "Bearer "+studioToken)
+	if p, err := Authenticate(req); err != nil || p.Studio != "downtown" {
+		t.Errorf("lost the studio of the token: %+v, %v", p, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// tampering with the payload breaks the signature
+	parts := strings.Split(token, ".")
+	forged, _ := SignJWT(Principal{Name: "Bruce", Role: RoleAdmin}, time.Hour)
+	parts[1] = strings.Split(forged, ".")[1]
+	req.Header.Set("Authorization", "Bearer "+strings.Join(parts, "."))
+	if _, err := Authenticate(req); err == nil {
+		t.Errorf("accepted forged token")
+	}
+
+	expired, _ := SignJWT(Principal{Name: "Bruce", Role: RoleInstructor}, -time.Hour)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	if _, err := Authenticate(req); err == nil {
+		t.Errorf("accepted expired token")
+	}
+
+	req.Header.Set("Authorization", token)
+	if _, err := Authenticate(req); err == nil {
+		t.Errorf("accepted authorization header without 'Bearer'")
+	}
+}
+
+func TestProtect(t *testing.T) {
+	member, _ := NewKey(Principal{Name: "Chuck", Role: RoleMember})
+	admin, _ := NewKey(Principal{Name: "Boss", Role: RoleAdmin})
+
+	respond := func(req *http.Request) interface{} {
+		p, _ := FromRequest(req)
+		return api.NewSuccessNow(http.StatusOK, nil, "hello %s", p.Name)
+	}
+
+	tables := []struct {
+		policy Policy
+		key    string
+		url    string
+		res    string
+	}{
+		{Roles(RoleAdmin), "", "/",
+			"401 Unauthorized: please log in or provide valid credentials"},
+		{Roles(RoleAdmin), member, "/",
+			"403 Forbidden: as member, you are not allowed to do this"},
+		{Roles(RoleAdmin), admin, "/",
+			"hello Boss"},
+		{Self("name"), member, "/?name=Arnold",
+			"403 Forbidden: you can only do this for yourself"},
+		{Self("name"), member, "/?name=Chuck",
+			"hello Chuck"},
+		{Self("name"), admin, "/?name=Arnold",
+			"hello Boss"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("GET", table.url, nil)
+		if table.key != "" {
+			req.Header.Set("X-API-Key", table.key)
+		}
+
+		switch v := Protect(respond, table.policy)(req).(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.url, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.url, v.Message, table.res)
+			}
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	if err := AddUser("Diana", "correct horse", RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := Login("Diana", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := NewKey(Principal{Name: "Diana", Role: RoleMember})
+
+	respond := func(req *http.Request) interface{} {
+		return api.NewSuccessNow(http.StatusOK, nil, "changed")
+	}
+
+	tables := []struct {
+		method string
+		cookie bool
+		key    string
+		res    string
+	}{
+		{"GET", true, "",
+			"403 Forbidden: please use the POST method to make changes when logged in"},
+		{"HEAD", true, "",
+			"403 Forbidden: please use the POST method to make changes when logged in"},
+		{"POST", true, "",
+			"changed"},
+		// API keys can't be sent by another site
+		{"GET", false, key,
+			"changed"},
+		{"GET", true, key,
+			"changed"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest(table.method, "/?name=Diana", nil)
+		if table.cookie {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+		}
+		if table.key != "" {
+			req.Header.Set("X-API-Key", table.key)
+		}
+
+		switch v := Protect(respond, Changes(Self("name")))(req).(type) {
+		case api.Error:
+			if s := v.Error(); s != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.method, s, table.res)
+			}
+		case api.Success:
+			if v.Message != table.res {
+				t.Errorf("Result of %s was incorrect, got: %q, want: %q.", table.method, v.Message, table.res)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the claims of a JSON Web Token we understand
+type claims struct {
+	Subject string `json:"sub"`
+	Role    Role   `json:"role"`
+	Studio  string `json:"studio,omitempty"`
+	Expires int64  `json:"exp"` // in seconds since the Unix epoch
+}
+
+var (
+	// the key to verify JSON Web Tokens, if nil, tokens are not accepted
+	jwtKey []byte
+
+	// protect key with mutex
+	jwtMux *sync.Mutex = &sync.Mutex{}
+)
+
+// SetJWTKey sets the local key with which JSON Web Tokens are signed (HMAC with SHA-256).
+func SetJWTKey(key []byte) error {
+	if len(key) < 32 {
+		return fmt.Errorf("the JWT key must have at least 32 bytes")
+	}
+
+	jwtMux.Lock()
+	defer jwtMux.Unlock()
+
+	jwtKey = key
+	return nil
+}
+
+// getJWTKey returns the local key or nil, if none is set.
+func getJWTKey() []byte {
+	jwtMux.Lock()
+	defer jwtMux.Unlock()
+
+	return jwtKey
+}
+
+// the header of all tokens we sign
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignJWT returns a token for the principal that expires after the given duration.
+func SignJWT(p Principal, d time.Duration) (string, error) {
+	key := getJWTKey()
+	if key == nil {
+		return "", fmt.Errorf("no JWT key set")
+	}
+
+	payload, err := json.Marshal(claims{p.Name, p.Role, p.Studio, time.Now().Add(d).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(key, unsigned), nil
+}
+
+// Sign returns the encoded signature of the message with the local key of the JSON Web Tokens,
+// e.g. for secrets that can be checked later without keeping them.
+func Sign(msg string) (string, error) {
+	key := getJWTKey()
+	if key == nil {
+		return "", fmt.Errorf("no JWT key set")
+	}
+	return sign(key, msg), nil
+}
+
+// sign returns the encoded HMAC-SHA256 signature of the message.
+func sign(key []byte, msg string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyJWT checks the signature and expiry of the token and returns its principal.
+func verifyJWT(token string) (Principal, error) {
+	key := getJWTKey()
+	if key == nil {
+		return Principal{}, fmt.Errorf("tokens are not accepted")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("malformed token")
+	}
+
+	// only accept the algorithm we sign with
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(b, &header) != nil || header.Alg != "HS256" {
+		return Principal{}, fmt.Errorf("malformed token header")
+	}
+
+	if !hmac.Equal([]byte(sign(key, parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return Principal{}, fmt.Errorf("invalid token signature")
+	}
+
+	var c claims
+	if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(b, &c) != nil {
+		return Principal{}, fmt.Errorf("malformed token payload")
+	}
+
+	if c.Expires == 0 || time.Now().After(time.Unix(c.Expires, 0)) {
+		return Principal{}, fmt.Errorf("token expired")
+	}
+
+	if c.Subject == "" || !c.Role.valid() {
+		return Principal{}, fmt.Errorf("token has invalid subject or role")
+	}
+
+	return Principal{c.Subject, c.Role, c.Studio}, nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionCookie is the name of the cookie that holds the session token.
+const SessionCookie = "session"
+
+// SessionDuration is how long a session lasts after logging in.
+const SessionDuration = 24 * time.Hour
+
+// a user can log in with a password
+type user struct {
+	Principal
+	hash []byte
+}
+
+// a session is created when a user logs in
+type session struct {
+	Principal
+	expires time.Time
+}
+
+var (
+	// users by name
+	users map[string]user = make(map[string]user)
+	// principals by API key
+	keys map[string]Principal = make(map[string]Principal)
+	// sessions by token
+	sessions map[string]session = make(map[string]session)
+
+	// protect maps with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// ErrOtherStudio is returned when a user of another studio or of all studios would be replaced by one of a studio.
+var ErrOtherStudio = errors.New("the user belongs to another studio")
+
+// AddUser adds a user of all studios that can log in with the password.
+// If the user exists, even in a studio, the studio, password and role are replaced and the sessions of the user end.
+func AddUser(name, password string, role Role) error {
+	return ReplaceUser("", name, password, role)
+}
+
+// AddStudioUser adds a user of the studio that can log in with the password.
+// User names are unique across studios: if the user exists in the studio, the password and role are replaced and the sessions of the user end.
+// A user of another studio or of all studios is not replaced (see ErrOtherStudio), so that admins of a studio can't take over their accounts.
+func AddStudioUser(studio, name, password string, role Role) error {
+	return addUser(studio, name, password, role, false)
+}
+
+// ReplaceUser adds a user of the studio like AddStudioUser, but also replaces a user of another studio or of all studios.
+// It is meant for admins of all studios.
+func ReplaceUser(studio, name, password string, role Role) error {
+	return addUser(studio, name, password, role, true)
+}
+
+// addUser adds a user of the studio, replacing a user of another studio only if 'anyStudio' is set.
+func addUser(studio, name, password string, role Role, anyStudio bool) error {
+	if name == "" {
+		return fmt.Errorf("please provide a user name")
+	}
+
+	if !role.valid() {
+		return fmt.Errorf("role '%s' does not exist", role)
+	}
+
+	if len(password) < 8 {
+		return fmt.Errorf("the password must have at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	u, ok := users[name]
+	if ok && u.Studio != studio && !anyStudio {
+		return ErrOtherStudio
+	}
+
+	users[name] = user{Principal{name, role, studio}, hash}
+	if ok {
+		// the sessions have the principal of the replaced user, e.g. with a role it no longer has
+		endSessions(name)
+	}
+	return nil
+}
+
+// endSessions ends all sessions of the user. The caller holds the lock.
+func endSessions(name string) {
+	for token, s := range sessions {
+		if s.Name == name {
+			delete(sessions, token)
+		}
+	}
+}
+
+// AddKey allows integrations to authenticate as the principal with the API key.
+func AddKey(key string, p Principal) error {
+	if len(key) < 16 {
+		return fmt.Errorf("the API key must have at least 16 characters")
+	}
+
+	if !p.Role.valid() {
+		return fmt.Errorf("role '%s' does not exist", p.Role)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	keys[key] = p
+	return nil
+}
+
+// NewKey creates a random API key for the principal.
+func NewKey(p Principal) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	key := base64.RawURLEncoding.EncodeToString(b)
+	return key, AddKey(key, p)
+}
+
+// byKey returns the principal of the API key.
+func byKey(key string) (Principal, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if p, ok := keys[key]; ok {
+		return p, nil
+	}
+	return Principal{}, fmt.Errorf("invalid API key")
+}
+
+// a hash to compare against if a user doesn't exist, so the response time doesn't tell whether the user exists
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// Login checks the password of the user and starts a session.
+// It returns the session token, to be stored in the session cookie.
+func Login(name, password string) (string, Principal, error) {
+	mux.Lock()
+	u, ok := users[name]
+	mux.Unlock()
+
+	// hashing is slow on purpose, so we don't hold the lock
+	if !ok {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return "", Principal{}, fmt.Errorf("wrong user name or password")
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.hash, []byte(password)); err != nil {
+		return "", Principal{}, fmt.Errorf("wrong user name or password")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Principal{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	sessions[token] = session{u.Principal, time.Now().Add(SessionDuration)}
+	return token, u.Principal, nil
+}
+
+// Logout ends the session.
+func Logout(token string) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	delete(sessions, token)
+}
+
+// bySession returns the principal of the session.
+func bySession(token string) (Principal, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	s, ok := sessions[token]
+	if !ok {
+		return Principal{}, fmt.Errorf("invalid session, please log in again")
+	}
+
+	if time.Now().After(s.expires) {
+		delete(sessions, token)
+		return Principal{}, fmt.Errorf("your session expired, please log in again")
+	}
+
+	return s.Principal, nil
+}
@@ -0,0 +1,118 @@
+// Package bus passes events, e.g. that a class was booked, from where they happen to those who are interested, e.g. pages that show the free places live.
+//
+// Publishing never blocks: a subscriber that doesn't keep up is dropped, i.e. its channel is closed. It can subscribe again and catch up with the recent events (see Since).
+package bus
+
+import (
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// A Kind describes what happened.
+type Kind string
+
+// the kinds of events
+const (
+	CourseAdded      Kind = "course-added"      // a course was added to a registry
+	CapacityChanged  Kind = "capacity-changed"  // the capacity of a course changed
+	ClassBooked      Kind = "class-booked"      // a member booked a class
+	BookingCancelled Kind = "booking-cancelled" // a member cancelled a booking
+	ClassCancelled   Kind = "class-cancelled"   // the studio cancelled a class
+	ClassReminder    Kind = "class-reminder"    // a member was reminded of a class they booked
+)
+
+// An Event is something that happened to a course or one of its classes.
+type Event struct {
+	ID   uint64 // increasing, so that subscribers can catch up after it (see Since)
+	Time time.Time
+	Kind Kind
+
+	Course     uint64
+	Date       civil.Date // of the class, zero for events of the whole course
+	Member     string     // who booked or cancelled, empty for events of the whole course or class
+	ClassFree  int        // the free places of the class, zero for events of the whole course
+	CourseFree int        // the most free places in any upcoming class of the course (see course.Course.FreePlaces)
+}
+
+// History is how many of the recent events are kept for subscribers to catch up.
+const History = 1000
+
+// Buffer is how many events a subscriber can fall behind before it is dropped.
+const Buffer = 64
+
+var (
+	// the channels of the subscribers
+	subscribers map[chan Event]struct{} = make(map[chan Event]struct{})
+	// the recent events, oldest first
+	recent []Event = make([]Event, 0, History)
+	// the ID of the last event
+	lastID uint64
+
+	// protect map, list and ID with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Publish sends the event to all subscribers and returns it with its ID and time.
+func Publish(e Event) Event {
+	mux.Lock()
+	defer mux.Unlock()
+
+	lastID++
+	e.ID = lastID
+	e.Time = time.Now()
+
+	if len(recent) == History {
+		recent = append(recent[:0], recent[1:]...)
+	}
+	recent = append(recent, e)
+
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			// too far behind, it has to catch up with Since
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return e
+}
+
+// Subscribe returns a channel that gets all events published from now on and a function to unsubscribe.
+// The channel is closed when unsubscribing or when the subscriber falls too far behind.
+func Subscribe() (<-chan Event, func()) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	ch := make(chan Event, Buffer)
+	subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if _, ok := subscribers[ch]; ok {
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Since returns the recent events after the one with the ID, oldest first.
+// If events after it are no longer kept or the ID is unknown, e.g. from before a restart, it returns false and the events that are kept.
+func Since(id uint64) ([]Event, bool) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	complete := id == lastID || id < lastID && recent[0].ID <= id+1
+	es := make([]Event, 0)
+	for _, e := range recent {
+		if e.ID > id {
+			es = append(es, e)
+		}
+	}
+	return es, complete
+}
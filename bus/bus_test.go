@@ -0,0 +1,70 @@
+package bus
+
+import (
+	"testing"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestPublish(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	date := civil.Date{Year: 2020, Month: 12, Day: 1}
+
+	first := Publish(Event{Kind: ClassBooked, Course: 1, Date: date, ClassFree: 2, CourseFree: 3})
+	second := Publish(Event{Kind: CourseAdded, Course: 2, CourseFree: 5})
+	if second.ID != first.ID+1 || first.Time.IsZero() {
+		t.Errorf("wrong ID or time: %v, %v", first, second)
+	}
+
+	for _, want := range []Event{first, second} {
+		if e := <-ch; e != want {
+			t.Errorf("got %v, want: %v", e, want)
+		}
+	}
+
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Errorf("channel wasn't closed")
+	}
+	unsubscribe() // again, without panicking
+	Publish(Event{Kind: CourseAdded, Course: 3})
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= Buffer; i++ {
+		Publish(Event{Kind: ClassBooked, Course: 1})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != Buffer {
+		t.Errorf("got %d events before being dropped, want: %d", n, Buffer)
+	}
+}
+
+func TestSince(t *testing.T) {
+	first := Publish(Event{Kind: ClassBooked, Course: 1})
+	second := Publish(Event{Kind: BookingCancelled, Course: 1})
+
+	if es, ok := Since(first.ID); !ok || len(es) != 1 || es[0] != second {
+		t.Errorf("wrong events since %d: %v, %v", first.ID, es, ok)
+	}
+	if es, ok := Since(second.ID); !ok || len(es) != 0 {
+		t.Errorf("expected no events since the last one, got: %v, %v", es, ok)
+	}
+	if _, ok := Since(second.ID + 1); ok {
+		t.Errorf("an unknown ID is complete")
+	}
+
+	for i := 0; i < History; i++ {
+		Publish(Event{Kind: ClassBooked, Course: 1})
+	}
+	if es, ok := Since(first.ID); ok || len(es) != History {
+		t.Errorf("expected the kept events only, got %d, %v", len(es), ok)
+	}
+}
@@ -0,0 +1,124 @@
+// Command import creates many courses at once from a CSV or JSON file, e.g. the schedule of a season kept in a spreadsheet.
+//
+// It sends the file to '/v1/classes/import' of a running server, authenticated with the API key of an admin:
+//
+//	BOOKING_API_KEY=... go run ./cmd/import -server http://localhost:8080 -studio downtown -dry-run season.csv
+//
+// Either all courses are created or none. If any course has a problem, the problems of all rows are printed.
+// With -dry-run, the courses are only checked.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// the media types of the files by extension
+var types = map[string]string{".csv": "text/csv", ".json": "application/json"}
+
+// run imports the file given in the arguments and writes the result to out.
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	server := fs.String("server", "http://localhost:8080", "the URL of the server")
+	studio := fs.String("studio", "", "the ID of the studio to import to, the default studio if empty")
+	key := fs.String("key", os.Getenv("BOOKING_API_KEY"), "the API key of an admin, BOOKING_API_KEY by default")
+	dryRun := fs.Bool("dry-run", false, "only check the courses, don't create them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: import [flags] <file.csv|file.json>\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file, got %d", fs.NArg())
+	}
+
+	file := fs.Arg(0)
+	contentType, ok := types[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return fmt.Errorf("%s: only .csv and .json files can be imported", file)
+	}
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(*server, "/"))
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	if *studio != "" {
+		u.Path += "/studios/" + *studio
+	}
+	u.Path += "/v1/classes/import"
+	u.RawQuery = url.Values{"dryrun": {fmt.Sprint(*dryRun)}}.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-API-Key", *key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		// the error lists the problems of all rows
+		var indented bytes.Buffer
+		if json.Indent(&indented, respBody, "", "  ") == nil {
+			respBody = indented.Bytes()
+		}
+		return fmt.Errorf("%s: nothing was imported (%s):\n%s", file, resp.Status, respBody)
+	}
+
+	var result struct {
+		Message string
+		Object  struct {
+			Courses []struct {
+				ID         uint64
+				Name       string
+				Start, End string
+			}
+		}
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+
+	for _, c := range result.Object.Courses {
+		if *dryRun {
+			fmt.Fprintf(out, "%s (%s to %s)\n", c.Name, c.Start, c.End)
+		} else {
+			fmt.Fprintf(out, "%d: %s (%s to %s)\n", c.ID, c.Name, c.Start, c.End)
+		}
+	}
+	fmt.Fprintln(out, result.Message)
+	return nil
+}
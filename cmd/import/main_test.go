@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		got, gotBody = req, string(body)
+
+		if strings.Contains(gotBody, "Broken") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Status": 400, "Err": "row 2: start value not provided"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Message": "1 courses created",
+			"Object": map[string]interface{}{"Courses": []map[string]interface{}{
+				{"ID": 7, "Name": "Yoga", "Start": "2020-12-01", "End": "2020-12-07"}}},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	season := write("season.csv", "name,start,end,capacity\nYoga,2020-12-01,2020-12-07,10\n")
+
+	var out bytes.Buffer
+	if err := run([]string{"-server", srv.URL, "-studio", "north", "-key", "secret", "-dry-run", season}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/studios/north/v1/classes/import" || got.URL.Query().Get("dryrun") != "true" {
+		t.Errorf("wrong URL: %s", got.URL)
+	}
+	if got.Header.Get("X-API-Key") != "secret" || got.Header.Get("Content-Type") != "text/csv" {
+		t.Errorf("wrong headers: %v", got.Header)
+	}
+	if !strings.Contains(gotBody, "Yoga,2020-12-01") {
+		t.Errorf("file wasn't sent, got: %q", gotBody)
+	}
+	if !strings.Contains(out.String(), "Yoga (2020-12-01 to 2020-12-07)") {
+		t.Errorf("courses weren't printed, got: %q", out.String())
+	}
+
+	// the problems are returned
+	broken := write("broken.json", `[{"name": "Broken"}]`)
+	err := run([]string{"-server", srv.URL, broken}, &out)
+	if err == nil || !strings.Contains(err.Error(), "row 2: start value not provided") {
+		t.Errorf("problems weren't returned, got: %v", err)
+	}
+	if got.Header.Get("Content-Type") != "application/json" || got.URL.Path != "/v1/classes/import" {
+		t.Errorf("wrong request for JSON file: %s %v", got.URL, got.Header)
+	}
+
+	// only known files
+	if err := run([]string{write("season.xlsx", "")}, &out); err == nil {
+		t.Errorf("could import unknown file")
+	}
+}
@@ -4,11 +4,17 @@ package course
 import (
 	"fmt"
 	"log"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/civil"
 	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/validation"
 )
 
 // the current id, is incremented before creating a Course
@@ -23,7 +29,57 @@ type Course struct {
 	start    civil.Date
 	end      civil.Date
 	capacity int
+	at       civil.Time    // time of day at which each class starts
+	duration time.Duration // how long each class lasts, zero if not known
+	members  bool          // whether the course can only be booked with a membership plan or pass
+	price    money.Amount
+	teacher  string   // the instructor, empty if not known
+	location string   // where the classes take place, empty if not known
 	classes  []*class // len(classes) == end-start +1 == NumClasses()
+
+	// protects the capacity, which can change, and the classes, e.g. their attendees, while they are booked and read
+	// the methods that use them have pointer receivers, so that they don't read a copy that is out of date
+	mux *sync.Mutex
+}
+
+// An Option sets an optional property of a course when it is created.
+type Option func(*Course) error
+
+// StartingAt sets the time of day at which the classes of a course start.
+// If not set, classes start at midnight.
+func StartingAt(t civil.Time) Option {
+	return func(c *Course) error {
+		c.at = t
+		return nil
+	}
+}
+
+// Lasting sets how long the classes of a course last.
+func Lasting(d time.Duration) Option {
+	return func(c *Course) error {
+		if d <= 0 {
+			return validation.Error{Field: "duration", Code: validation.Invalid,
+				Message: fmt.Sprintf("invalid course parameters: duration (%s) must be positive", d)}
+		}
+		c.duration = d
+		return nil
+	}
+}
+
+// TaughtBy sets the instructor of a course.
+func TaughtBy(instructor string) Option {
+	return func(c *Course) error {
+		c.teacher = instructor
+		return nil
+	}
+}
+
+// HeldAt sets where the classes of a course take place, e.g. a studio or a room.
+func HeldAt(location string) Option {
+	return func(c *Course) error {
+		c.location = location
+		return nil
+	}
 }
 
 // A class represents one day of a course.
@@ -33,6 +89,54 @@ type class struct {
 	// list of the names of the attendees
 	// later, this could be a slice of a struct 'Member' with not just the name, but also member ID to avoid mix-ups
 	attendees []string
+
+	// the price of the class, if it differs from the price of the course
+	price *money.Amount
+
+	// whether the studio cancelled the class
+	cancelled bool
+
+	// the names of the attendees when the studio cancelled the class
+	cancelledFor []string
+
+	// the names of those that cancelled their booking and didn't book again
+	withdrawn []string
+}
+
+// RequireMembership restricts a course to customers holding a valid membership plan or pass.
+func RequireMembership() Option {
+	return func(c *Course) error {
+		c.members = true
+		return nil
+	}
+}
+
+// Priced sets the price of each class of a course. If not set, the classes are free.
+func Priced(a money.Amount) Option {
+	return func(c *Course) error {
+		if a.Minor < 0 {
+			return validation.Error{Field: "price", Code: validation.Invalid,
+				Message: fmt.Sprintf("invalid course parameters: price (%s) must not be negative", a)}
+		}
+		c.price = a
+		return nil
+	}
+}
+
+// PricedOn sets the price of the class on the given date, overriding the price of the course.
+func PricedOn(date civil.Date, a money.Amount) Option {
+	return func(c *Course) error {
+		if date.Before(c.start) || date.After(c.end) {
+			return validation.Error{Field: "classprices", Code: validation.Invalid,
+				Message: fmt.Sprintf("invalid course parameters: price date (%s) not within the timeframe of the course", date)}
+		}
+		if a.Minor < 0 {
+			return validation.Error{Field: "classprices", Code: validation.Invalid,
+				Message: fmt.Sprintf("invalid course parameters: price (%s) must not be negative", a)}
+		}
+		c.classes[date.DaysSince(c.start)].price = &a
+		return nil
+	}
 }
 
 // getter methods
@@ -58,34 +162,186 @@ func (c Course) End() civil.Date {
 }
 
 // Capacity returns the capacity of the course.
-func (c Course) Capacity() int {
+func (c *Course) Capacity() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
 	return c.capacity
 }
 
+// SetCapacity changes the capacity of the course.
+// Since it is possible to overbook, classes may afterwards have more attendees than the new capacity.
+func (c *Course) SetCapacity(capacity int) error {
+	if capacity < 1 {
+		return validation.Error{Field: "capacity", Code: validation.Invalid,
+			Message: fmt.Sprintf("invalid course parameters: capacity (%d) must be positive", capacity)}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := journal.Append(journal.Event{Kind: journal.CapacityChanged, Course: c.id, Capacity: capacity}); err != nil {
+		return err
+	}
+	c.capacity = capacity
+	c.publish(bus.CapacityChanged, civil.Date{}, nil, "")
+
+	return nil
+}
+
+// At returns the time of day at which the classes of the course start.
+func (c Course) At() civil.Time {
+	return c.at
+}
+
+// Duration returns how long the classes of the course last, or zero if not known.
+func (c Course) Duration() time.Duration {
+	return c.duration
+}
+
+// MembersOnly returns whether the course can only be booked with a membership plan or pass.
+func (c Course) MembersOnly() bool {
+	return c.members
+}
+
+// Price returns the price of a class of the course, unless it has its own price (see PriceOn).
+func (c Course) Price() money.Amount {
+	return c.price
+}
+
+// PriceOn returns the price of the class on the given date, or the price of the course if the date is not within the timeframe of the course.
+func (c Course) PriceOn(date civil.Date) money.Amount {
+	if class, err := c.getClassOn(date); err == nil && class.price != nil {
+		return *class.price
+	}
+	return c.price
+}
+
+// Instructor returns the instructor of the course, or an empty string if not known.
+func (c Course) Instructor() string {
+	return c.teacher
+}
+
+// Location returns where the classes of the course take place, or an empty string if not known.
+func (c Course) Location() string {
+	return c.location
+}
+
+// ClassStart returns the point in time at which the class on the given date starts.
+func (c Course) ClassStart(date civil.Date) time.Time {
+	return civil.DateTime{Date: date, Time: c.at}.In(time.Local)
+}
+
+// Definition returns how the course is defined, as it is kept in the journal when the course is created.
+func (c *Course) Definition() journal.Course {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	d := journal.Course{
+		ID:          c.id,
+		Name:        c.name,
+		Start:       c.start,
+		End:         c.end,
+		Capacity:    c.capacity,
+		At:          c.at,
+		Duration:    c.duration,
+		MembersOnly: c.members,
+		Price:       c.price,
+		Instructor:  c.teacher,
+		Location:    c.location,
+	}
+	for i, class := range c.classes {
+		if class.price != nil {
+			if d.ClassPrices == nil {
+				d.ClassPrices = make(map[civil.Date]money.Amount)
+			}
+			d.ClassPrices[c.start.AddDays(i)] = *class.price
+		}
+	}
+	return d
+}
+
 // initializers
 
 // NewHistoric creates a new course, if the input passes some checks or an error, if not.
 // The course may be in the past (i.e. be 'historic').
-func NewHistoric(name string, start, end civil.Date, capacity int) (*Course, error) {
+//
+// All problems with the input are returned at once as validation.Errors.
+func NewHistoric(name string, start, end civil.Date, capacity int, opts ...Option) (*Course, error) {
+	return create(atomic.AddUint64(&currID, 1), name, start, end, capacity, opts...)
+}
+
+// Restore creates the course as the journal has it, with its ID, its current capacity and its booked and cancelled classes.
+// Courses created afterwards get higher IDs than it.
+func Restore(s journal.State) (*Course, error) {
+	opts := []Option{StartingAt(s.At), Priced(s.Price), TaughtBy(s.Instructor), HeldAt(s.Location)}
+	if s.Duration > 0 {
+		opts = append(opts, Lasting(s.Duration))
+	}
+	if s.MembersOnly {
+		opts = append(opts, RequireMembership())
+	}
+	for date, a := range s.ClassPrices {
+		opts = append(opts, PricedOn(date, a))
+	}
+
+	c, err := create(s.ID, s.Name, s.Start, s.End, s.Capacity, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("course %d: %w", s.ID, err)
+	}
+	Reserve(s.ID)
+
+	for date, cl := range s.Classes {
+		class, err := c.getClassOn(date)
+		if err != nil {
+			return nil, fmt.Errorf("course %d: %s: %w", s.ID, date, err)
+		}
+		class.attendees = append(class.attendees, cl.Attendees...)
+		class.cancelled = cl.Cancelled
+		class.cancelledFor = cl.CancelledFor
+		class.withdrawn = append(class.withdrawn, cl.Withdrawn...)
+	}
+	return c, nil
+}
+
+// Reserve makes sure that courses created afterwards get higher IDs than the given one.
+func Reserve(id uint64) {
+	for {
+		curr := atomic.LoadUint64(&currID)
+		if curr >= id || atomic.CompareAndSwapUint64(&currID, curr, id) {
+			return
+		}
+	}
+}
+
+// create creates a course with the ID (see NewHistoric).
+func create(id uint64, name string, start, end civil.Date, capacity int, opts ...Option) (*Course, error) {
+	var errs validation.Errors
 
 	if name == "" {
-		return nil, fmt.Errorf("please provide a course name")
+		errs.Add("name", validation.Required, "please provide a course name")
 	}
 
 	if start.After(end) {
-		return nil, fmt.Errorf("invalid course parameters: start date (%s) after end date (%s)", start, end)
+		errs.Add("end", validation.Invalid, "invalid course parameters: start date (%s) after end date (%s)", start, end)
 	}
 
 	if capacity < 1 {
-		return nil, fmt.Errorf("invalid course parameters: capacity (%d) must be positive", capacity)
+		errs.Add("capacity", validation.Invalid, "invalid course parameters: capacity (%d) must be positive", capacity)
+	}
+
+	// without valid dates, there are no classes to apply the options to
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	c := &Course{
-		id:       atomic.AddUint64(&currID, 1),
+		id:       id,
 		name:     name,
 		start:    start,
 		end:      end,
-		capacity: capacity}
+		capacity: capacity,
+		mux:      &sync.Mutex{}}
 
 	k := c.NumClasses()
 	c.classes = make([]*class, k)
@@ -95,6 +351,15 @@ func NewHistoric(name string, start, end civil.Date, capacity int) (*Course, err
 			attendees: make([]string, 0)}
 	}
 
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			errs.Append("", err)
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -102,14 +367,24 @@ func NewHistoric(name string, start, end civil.Date, capacity int) (*Course, err
 //
 // The course may not be in the past, i.e. the end date is today or in the future.
 // Otherwise, we assume that we have faulty input data.
-func New(name string, start, end civil.Date, capacity int) (*Course, error) {
+func New(name string, start, end civil.Date, capacity int, opts ...Option) (*Course, error) {
+	var errs validation.Errors
+
 	// check if the course is in the past because then it might be a faulty input
 	today := civil.DateOf(time.Now())
 	if end.Before(today) {
-		return nil, fmt.Errorf("invalid course parameters: course is in the past")
+		errs.Add("end", validation.Invalid, "invalid course parameters: course is in the past")
+	}
+
+	c, err := NewHistoric(name, start, end, capacity, opts...)
+	if err != nil {
+		errs.Append("", err)
 	}
 
-	return NewHistoric(name, start, end, capacity)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 // getClassOn returns the class of the course that is happening on a certain day.
@@ -125,7 +400,7 @@ func (c Course) getClassOn(date civil.Date) (*class, error) {
 // BookClass registers a customer for a class on the given day.
 // That day must be during the course duration and be in the future.
 // A customer can only book a class once.
-func (c Course) BookClass(customer string, date civil.Date) error {
+func (c *Course) BookClass(customer string, date civil.Date) error {
 	today := civil.DateOf(time.Now())
 	if today.After(c.end) {
 		return api.ErrBadRequest(fmt.Errorf("the course is in the past"))
@@ -140,6 +415,13 @@ func (c Course) BookClass(customer string, date civil.Date) error {
 		return err
 	}
 
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if class.cancelled {
+		return api.ErrBadRequest(fmt.Errorf("unfortunately, this class has been cancelled"))
+	}
+
 	// obviously some people have the same names
 	// in the future, attendees can be a slice of a 'Member' struct that contains member id etc.
 	for _, att := range class.attendees {
@@ -148,16 +430,253 @@ func (c Course) BookClass(customer string, date civil.Date) error {
 		}
 	}
 
+	if err := journal.Append(journal.Event{Kind: journal.ClassBooked, Course: c.id, Date: &date, Member: customer}); err != nil {
+		return err
+	}
 	class.attendees = append(class.attendees, customer)
+	// booked again, so the booking is no longer cancelled
+	for i, name := range class.withdrawn {
+		if name == customer {
+			class.withdrawn = append(class.withdrawn[:i], class.withdrawn[i+1:]...)
+			break
+		}
+	}
 	if over := len(class.attendees) - c.capacity; over > 0 {
 		// per specification, it is possible to overbook
 		// so we simply log the overbooking
 		log.Printf("course %s (%d) over capacity by %d on %s", c.name, c.id, over, date)
 	}
+	c.publish(bus.ClassBooked, date, class, customer)
 
 	return nil
 }
 
+// CanCancelBooking returns the error CancelBooking would return if it was called now, nil if the booking can be cancelled.
+// Like this, the payment of a booking can be settled before it is cancelled.
+func (c *Course) CanCancelBooking(customer string, date civil.Date) error {
+	today := civil.DateOf(time.Now())
+	if date.Before(today) {
+		return api.ErrBadRequest(fmt.Errorf("classes in the past can't be cancelled"))
+	}
+
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, att := range class.attendees {
+		if att == customer {
+			return nil
+		}
+	}
+	return api.ErrBadRequest(fmt.Errorf("you are not attending this class"))
+}
+
+// CancelBooking removes a customer from the attendees of the class on the given day.
+// Only future classes can be cancelled.
+func (c *Course) CancelBooking(customer string, date civil.Date) error {
+	today := civil.DateOf(time.Now())
+	if date.Before(today) {
+		return api.ErrBadRequest(fmt.Errorf("classes in the past can't be cancelled"))
+	}
+
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for i, att := range class.attendees {
+		if att == customer {
+			if err := journal.Append(journal.Event{Kind: journal.BookingCancelled, Course: c.id, Date: &date, Member: customer}); err != nil {
+				return err
+			}
+			class.attendees = append(class.attendees[:i], class.attendees[i+1:]...)
+			class.withdrawn = append(class.withdrawn, customer)
+			c.publish(bus.BookingCancelled, date, class, customer)
+			return nil
+		}
+	}
+
+	return api.ErrBadRequest(fmt.Errorf("you are not attending this class"))
+}
+
+// CanCancelClass returns the error CancelClass would return if it was called now, nil if the class can be cancelled.
+// Like this, the payments of the attendees can be settled before the class is cancelled.
+func (c *Course) CanCancelClass(date civil.Date) error {
+	today := civil.DateOf(time.Now())
+	if date.Before(today) {
+		return api.ErrBadRequest(fmt.Errorf("classes in the past can't be cancelled"))
+	}
+
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if class.cancelled {
+		return api.ErrBadRequest(fmt.Errorf("the class has already been cancelled"))
+	}
+	return nil
+}
+
+// CancelClass cancels the class on the given day, e.g. because the instructor is sick.
+// It returns the customers that were attending the class. They are no longer attending.
+func (c *Course) CancelClass(date civil.Date) ([]string, error) {
+	today := civil.DateOf(time.Now())
+	if date.Before(today) {
+		return nil, api.ErrBadRequest(fmt.Errorf("classes in the past can't be cancelled"))
+	}
+
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if class.cancelled {
+		return nil, api.ErrBadRequest(fmt.Errorf("the class has already been cancelled"))
+	}
+
+	if err := journal.Append(journal.Event{Kind: journal.ClassCancelled, Course: c.id, Date: &date}); err != nil {
+		return nil, err
+	}
+	attendees := class.attendees
+	class.cancelled = true
+	class.cancelledFor = attendees
+	class.attendees = make([]string, 0)
+	c.publish(bus.ClassCancelled, date, class, "")
+
+	return attendees, nil
+}
+
+// Cancelled returns whether the class on the given day has been cancelled by the studio.
+func (c *Course) Cancelled(date civil.Date) bool {
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return false
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return class.cancelled
+}
+
+// Attendees returns the customers attending the class on the given day, in the order they booked.
+// For a class the studio cancelled, it returns those that were attending when it was cancelled.
+func (c *Course) Attendees(date civil.Date) ([]string, error) {
+	class, err := c.getClassOn(date)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// a copy, so that bookings don't change it while it is read
+	if class.cancelled {
+		return append([]string{}, class.cancelledFor...), nil
+	}
+	return append([]string{}, class.attendees...), nil
+}
+
+// BookedDates returns the dates of all classes of the course the customer is attending.
+func (c *Course) BookedDates(customer string) []civil.Date {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	dates := make([]civil.Date, 0)
+	for i, class := range c.classes {
+		for _, att := range class.attendees {
+			if att == customer {
+				dates = append(dates, c.start.AddDays(i))
+				break
+			}
+		}
+	}
+	return dates
+}
+
+// CancelledDates returns the dates of all classes of the course the customer was attending when the studio cancelled them.
+func (c *Course) CancelledDates(customer string) []civil.Date {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	dates := make([]civil.Date, 0)
+	for i, class := range c.classes {
+		for _, att := range class.cancelledFor {
+			if att == customer {
+				dates = append(dates, c.start.AddDays(i))
+				break
+			}
+		}
+	}
+	return dates
+}
+
+// CancelledBookings returns the dates of all classes of the course the customer booked, but cancelled the booking of.
+// Dates the customer booked again are not among them.
+func (c *Course) CancelledBookings(customer string) []civil.Date {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	dates := make([]civil.Date, 0)
+	for i, class := range c.classes {
+		for _, name := range class.withdrawn {
+			if name == customer {
+				dates = append(dates, c.start.AddDays(i))
+				break
+			}
+		}
+	}
+	return dates
+}
+
+// FreePlaces returns the most places that are free in any class from today on that hasn't been cancelled.
+// That is the remaining capacity for somebody who wants to book a class of the course.
+func (c *Course) FreePlaces() int {
+	today := civil.DateOf(time.Now())
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.freePlaces(today)
+}
+
+// freePlaces returns the free places (see FreePlaces) from the given day on. The caller holds the lock.
+func (c *Course) freePlaces(today civil.Date) int {
+	free := 0
+	for i, class := range c.classes {
+		if class.cancelled || c.start.AddDays(i).Before(today) {
+			continue
+		}
+		if f := c.capacity - len(class.attendees); f > free {
+			free = f
+		}
+	}
+	return free
+}
+
+// publish tells those interested that the course or, if given, its class on the date changed, e.g. because the member booked it. The caller holds the lock.
+func (c *Course) publish(kind bus.Kind, date civil.Date, class *class, member string) {
+	e := bus.Event{Kind: kind, Course: c.id, Date: date, Member: member, CourseFree: c.freePlaces(civil.DateOf(time.Now()))}
+	if class != nil && !class.cancelled && c.capacity > len(class.attendees) {
+		e.ClassFree = c.capacity - len(class.attendees)
+	}
+	bus.Publish(e)
+}
+
 // NumClasses returns the number of classes for the course.
 // For now, there is a class on every day of the duration of the course.
 func (c Course) NumClasses() int {
@@ -1,10 +1,17 @@
 package course
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/validation"
 )
 
 func getPastDate(t *testing.T) civil.Date {
@@ -125,6 +132,296 @@ func TestBookClass(t *testing.T) {
 	}
 }
 
+func TestCancelBooking(t *testing.T) {
+	c := getTestCourse(t)
+
+	if c.CancelBooking("Arnold", today.AddDays(-1)) == nil {
+		t.Errorf("could cancel class of yesterday")
+	}
+
+	if c.CancelBooking("Arnold", today.AddDays(1)) == nil {
+		t.Errorf("could cancel class that wasn't booked")
+	}
+
+	if err := c.BookClass("Arnold", today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelBooking("Arnold", today.AddDays(1)); err != nil {
+		t.Errorf("couldn't cancel class: %s", err)
+	}
+	if dates := c.BookedDates("Arnold"); len(dates) != 0 {
+		t.Errorf("customer still attends %d classes after cancellation", len(dates))
+	}
+	if dates := c.CancelledBookings("Arnold"); len(dates) != 1 || dates[0] != today.AddDays(1) {
+		t.Errorf("wrong cancelled bookings: %v", dates)
+	}
+
+	// can book again after cancelling
+	if err := c.BookClass("Arnold", today.AddDays(1)); err != nil {
+		t.Errorf("couldn't book class again after cancelling: %s", err)
+	}
+	if dates := c.CancelledBookings("Arnold"); len(dates) != 0 {
+		t.Errorf("booking again still counts as cancelled: %v", dates)
+	}
+}
+
+func TestCancelClass(t *testing.T) {
+	c := getTestCourse(t)
+
+	if _, err := c.CancelClass(today.AddDays(-1)); err == nil {
+		t.Errorf("could cancel class of yesterday")
+	}
+
+	for _, name := range []string{"Arnold", "Bruce"} {
+		if err := c.BookClass(name, today.AddDays(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attendees, err := c.CancelClass(today.AddDays(1))
+	if err != nil {
+		t.Fatalf("couldn't cancel class: %s", err)
+	}
+	if len(attendees) != 2 {
+		t.Errorf("cancelled class had %d attendees, want: 2", len(attendees))
+	}
+	if !c.Cancelled(today.AddDays(1)) || c.Cancelled(today.AddDays(2)) {
+		t.Errorf("wrong classes marked as cancelled")
+	}
+	if dates := c.CancelledDates("Arnold"); len(dates) != 1 || dates[0] != today.AddDays(1) {
+		t.Errorf("wrong cancelled dates of an attendee: %v", dates)
+	}
+	if dates := c.CancelledDates("Chuck"); len(dates) != 0 {
+		t.Errorf("wrong cancelled dates of somebody who wasn't attending: %v", dates)
+	}
+
+	if _, err = c.CancelClass(today.AddDays(1)); err == nil {
+		t.Errorf("could cancel class twice")
+	}
+	if err = c.BookClass("Chuck", today.AddDays(1)); err == nil {
+		t.Errorf("could book cancelled class")
+	}
+}
+
+func TestAttendees(t *testing.T) {
+	c, err := New("Karate", today, today.AddDays(2), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Attendees(today.AddDays(100)); err == nil {
+		t.Errorf("got attendees of a class outside of the course")
+	}
+
+	// attendees can be read while others book
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.BookClass(fmt.Sprintf("Member %d", i), today.AddDays(1)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Attendees(today.AddDays(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	attendees, err := c.Attendees(today.AddDays(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attendees) != 50 {
+		t.Errorf("class has %d attendees, want: 50", len(attendees))
+	}
+
+	// a copy that bookings don't change
+	attendees[0] = "Changed"
+	if again, _ := c.Attendees(today.AddDays(1)); again[0] == "Changed" {
+		t.Errorf("attendees are not a copy")
+	}
+
+	// the attendees of a cancelled class are those it was cancelled for
+	if _, err := c.CancelClass(today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled, _ := c.Attendees(today.AddDays(1)); len(cancelled) != 50 {
+		t.Errorf("cancelled class has %d attendees, want: 50", len(cancelled))
+	}
+}
+
+func TestSetCapacity(t *testing.T) {
+	c, err := New("Karate", today, today.AddDays(2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the capacity can be changed while others book and read it (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.SetCapacity(i + 1); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.BookClass(fmt.Sprintf("Member %d", i), today.AddDays(1)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if n := c.Capacity(); n < 1 || n > 20 {
+				t.Errorf("wrong capacity: %d", n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.SetCapacity(30); err != nil {
+		t.Fatal(err)
+	}
+	if n, free := c.Capacity(), c.FreePlaces(); n != 30 || free != 30 {
+		t.Errorf("wrong capacity after the change, got: %d with %d free places, want: 30 with 30", n, free)
+	}
+}
+
+func TestFreePlaces(t *testing.T) {
+	c, err := New("Karate", today.AddDays(-1), today.AddDays(1), 2, TaughtBy("Ann"), HeldAt("Dojo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Instructor() != "Ann" || c.Location() != "Dojo" {
+		t.Errorf("wrong instructor or location, got: %q, %q", c.Instructor(), c.Location())
+	}
+
+	// the class of yesterday doesn't count, not even after it has been booked
+	c.classes[0].attendees = append(c.classes[0].attendees, "Arnold", "Bruce")
+
+	tables := []struct {
+		book   []string // on the class of today
+		cancel bool     // the class of tomorrow
+		free   int
+	}{
+		{nil, false, 2},
+		{[]string{"Arnold"}, false, 2},
+		{nil, true, 1},
+		{[]string{"Bruce"}, false, 0},
+	}
+
+	for i, table := range tables {
+		for _, name := range table.book {
+			if err := c.BookClass(name, today); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if table.cancel {
+			if _, err := c.CancelClass(today.AddDays(1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if free := c.FreePlaces(); free != table.free {
+			t.Errorf("%d: wrong number of free places, got: %d, want: %d", i, free, table.free)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	if _, err := New("Spinning", today, today, 10, Lasting(0)); err == nil {
+		t.Errorf("created course whose classes don't last")
+	}
+
+	c, err := New("Spinning", today, today, 10, Lasting(45*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := c.Duration(); d != 45*time.Minute {
+		t.Errorf("wrong duration, got: %s", d)
+	}
+}
+
+func TestPriceOn(t *testing.T) {
+	price, special := money.New(1000, "EUR"), money.New(1500, "EUR")
+
+	_, err := New("Spinning", today, today.AddDays(3), 10, Priced(money.New(-1, "EUR")))
+	if err == nil {
+		t.Errorf("created course with negative price")
+	}
+
+	_, err = New("Spinning", today, today.AddDays(3), 10, PricedOn(today.AddDays(4), special))
+	if err == nil {
+		t.Errorf("set price of class outside of the course")
+	}
+
+	c, err := New("Spinning", today, today.AddDays(3), 10, Priced(price), PricedOn(today.AddDays(2), special))
+	if err != nil {
+		t.Fatalf("couldn't create course: %s", err)
+	}
+
+	if p := c.PriceOn(today.AddDays(1)); p != price {
+		t.Errorf("wrong price of regular class, got: %s, want: %s", p, price)
+	}
+	if p := c.PriceOn(today.AddDays(2)); p != special {
+		t.Errorf("wrong price of special class, got: %s, want: %s", p, special)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tomorrow := today.AddDays(1)
+	c, err := NewHistoric("Yoga", today, today.AddDays(3), 10,
+		StartingAt(civil.Time{Hour: 18, Minute: 30}), Lasting(time.Hour), RequireMembership(),
+		Priced(money.New(1200, "EUR")), PricedOn(tomorrow, money.New(1500, "EUR")), TaughtBy("Berta"), HeldAt("Hall"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// far ahead of the courses created so far
+	s := journal.State{Course: c.Definition()}
+	s.ID += 1000
+	s.Capacity = 12
+	s.Classes = map[civil.Date]*journal.Class{
+		tomorrow:         {Attendees: []string{"Arnold", "Bruce"}, Withdrawn: []string{"Chuck"}},
+		today.AddDays(2): {Cancelled: true, CancelledFor: []string{"Chuck"}},
+	}
+
+	r, err := Restore(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := r.Definition()
+	if d.ID != s.ID || d.Capacity != 12 || d.At != c.At() || d.Duration != time.Hour || !d.MembersOnly ||
+		d.Price != c.Price() || d.ClassPrices[tomorrow] != c.PriceOn(tomorrow) || d.Instructor != "Berta" || d.Location != "Hall" {
+		t.Errorf("wrong course restored: %+v", d)
+	}
+	if as, _ := r.Attendees(tomorrow); len(as) != 2 || as[1] != "Bruce" {
+		t.Errorf("wrong attendees: %v", as)
+	}
+	if dates := r.CancelledBookings("Chuck"); len(dates) != 1 || dates[0] != tomorrow {
+		t.Errorf("wrong cancelled bookings: %v", dates)
+	}
+	if as, _ := r.Attendees(today.AddDays(2)); !r.Cancelled(today.AddDays(2)) || len(as) != 1 || as[0] != "Chuck" {
+		t.Errorf("wrong attendees of cancelled class: %v", as)
+	}
+
+	if n := getTestCourse(t); n.ID() <= s.ID {
+		t.Errorf("new course got ID %d, which isn't higher than that of the restored course (%d)", n.ID(), s.ID)
+	}
+
+	// classes outside of the course
+	s.Classes[today.AddDays(5)] = &journal.Class{Attendees: []string{"Arnold"}}
+	if _, err := Restore(s); err == nil {
+		t.Error("no error for a class outside of the course")
+	}
+}
+
 func TestNumClasses(t *testing.T) {
 	var (
 		start, end civil.Date
@@ -150,3 +447,39 @@ func TestNumClasses(t *testing.T) {
 		t.Fatalf("Pilates does not have 20 classes but %d", num)
 	}
 }
+
+func TestNewHistoricErrors(t *testing.T) {
+	pastDate := getPastDate(t)
+
+	_, err := NewHistoric("", pastDate, pastDate.AddDays(-1), 0)
+
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, got: %v", err)
+	}
+
+	want := []struct {
+		field string
+		code  validation.Code
+	}{
+		{"name", validation.Required},
+		{"end", validation.Invalid},
+		{"capacity", validation.Invalid},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d problems, got: %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w.field || errs[i].Code != w.code {
+			t.Errorf("problem %d was incorrect, got: %s (%s), want: %s (%s)", i, errs[i].Field, errs[i].Code, w.field, w.code)
+		}
+	}
+
+	// problems of options are reported, too
+	_, err = New("Karate", today, today, 10,
+		Priced(money.New(-100, "EUR")),
+		PricedOn(today.AddDays(1), money.New(100, "EUR")))
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "price" || errs[1].Field != "classprices" {
+		t.Errorf("expected problems with price and classprices, got: %v", err)
+	}
+}
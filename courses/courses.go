@@ -1,6 +1,9 @@
 // Package courses implements adding and retrieval of courses.
 //
+// The courses are kept in a Registry, e.g. one per studio. The functions of this package use the Default registry.
 // The courses are stored in maps and sorted slices for efficient access.
+// The sorted slices are copied on write: adding a course replaces them instead of changing them,
+// so the lists of courses we return are snapshots that don't change while they are being read, e.g. by a template.
 package courses
 
 import (
@@ -12,157 +15,257 @@ import (
 	"cloud.google.com/go/civil"
 	"github.com/MarkRosemaker/go-server/server/api"
 
+	"github.com/MarkRosemaker/booking-system/bus"
 	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/journal"
 )
 
 // Courses is a slice of courses.
 type Courses []*course.Course
 
-var (
+// A Registry is a collection of courses. Use NewRegistry to create one.
+type Registry struct {
+	// identifies the registry in the journal (see Restore), e.g. the ID of its studio; empty for the default registry
+	Name string
+
 	// maps for quick access
-	byID   map[uint64]*course.Course = make(map[uint64]*course.Course)
-	byName map[string]Courses        = make(map[string]Courses)
+	byID   map[uint64]*course.Course
+	byName map[string]Courses
+
+	// sorted lists, never changed but replaced (see add)
+	byStart Courses
+	byEnd   Courses
 
-	// sorted lists
-	byStart Courses = make(Courses, 0)
-	byEnd   Courses = make(Courses, 0)
+	// index to find the courses in a time frame
+	byDates intervals
 
 	// protect maps and lists with mutex
-	mux *sync.Mutex = &sync.Mutex{}
-)
+	mux sync.Mutex
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byID:    make(map[uint64]*course.Course),
+		byName:  make(map[string]Courses),
+		byStart: make(Courses, 0),
+		byEnd:   make(Courses, 0),
+	}
+}
 
 // Get returns the course with the given id or an error, if no course with the ID exists.
-func Get(id uint64) (*course.Course, error) {
-	mux.Lock()
-	defer mux.Unlock()
+func (r *Registry) Get(id uint64) (*course.Course, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
-	if c, ok := byID[id]; ok {
+	if c, ok := r.byID[id]; ok {
 		return c, nil
 	}
 	return nil, fmt.Errorf("course with id %d does not exist", id)
 }
 
-// Add adds a course to the collection.
+// Add adds a course to the registry.
 //
 // If the course already exists, an error is returned. A course is considered a duplicate if the ID is the same or if there is another course with the same dates and the same name.
-func Add(c *course.Course) error {
+func (r *Registry) Add(c *course.Course) error {
 	// later:
 	// - consider passing context as input
 	// - save to DB
 
-	mux.Lock()
-	defer mux.Unlock()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.duplicate(c, nil); err != nil {
+		return err
+	}
+	if err := journal.Append(r.created(c)); err != nil {
+		return err
+	}
+	r.insert(c)
+	return nil
+}
+
+// Check returns for each of the courses why it can't be added, nil if it can (see Add).
+// The courses are also checked against the ones before them in the list, e.g. to validate an import.
+func (r *Registry) Check(cs Courses) []error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.check(cs)
+}
+
+// AddAll adds all courses or, if any of them can't be added, none of them.
+// In that case, it returns for each course why it can't be added, nil if it can (see Check).
+// If the journal can't write them down, that is why none of them can be added.
+func (r *Registry) AddAll(cs Courses) []error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if errs := r.check(cs); errs != nil {
+		return errs
+	}
+
+	es := make([]journal.Event, len(cs))
+	for i, c := range cs {
+		es[i] = r.created(c)
+	}
+	if err := journal.Append(es...); err != nil {
+		errs := make([]error, len(cs))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	for _, c := range cs {
+		r.insert(c)
+	}
+	return nil
+}
+
+// created returns the event that the course was added to the registry, for the journal.
+func (r *Registry) created(c *course.Course) journal.Event {
+	d := c.Definition()
+	return journal.Event{Kind: journal.CourseCreated, Course: c.ID(), Registry: r.Name, Definition: &d}
+}
 
+// check returns for each of the courses why it can't be added or nil, if all of them can.
+func (r *Registry) check(cs Courses) []error {
+	errs := make([]error, len(cs))
+	failed := false
+	for i, c := range cs {
+		if errs[i] = r.duplicate(c, cs[:i]); errs[i] != nil {
+			failed = true
+		}
+	}
+	if !failed {
+		return nil
+	}
+	return errs
+}
+
+// duplicate returns an error if the course duplicates a course of the registry or one of the others that are about to be added.
+// A course is considered a duplicate if the ID is the same or if there is another course with the same dates and the same name.
+func (r *Registry) duplicate(c *course.Course, others Courses) error {
 	// check if the ID exists already
-	if _, ok := byID[c.ID()]; ok {
+	if _, ok := r.byID[c.ID()]; ok {
 		return api.ErrBadRequest(fmt.Errorf(
 			"a course with the ID %d has already been added", c.ID()))
 	}
 
 	// it's okay to add a course with the same name, but not if it's on the same dates
-	if sameName, ok := byName[c.Name()]; ok {
-		for _, o := range sameName {
-			if c.Start() == o.Start() && c.End() == o.End() {
-				return api.ErrBadRequest(fmt.Errorf(
-					"a course '%s' with the same dates has already been added", c.Name()))
-			}
+	for _, o := range r.byName[c.Name()] {
+		if c.Start() == o.Start() && c.End() == o.End() {
+			return api.ErrBadRequest(fmt.Errorf(
+				"a course '%s' with the same dates has already been added", c.Name()))
+		}
+	}
+
+	for _, o := range others {
+		if o.ID() == c.ID() {
+			return api.ErrBadRequest(fmt.Errorf(
+				"a course with the ID %d is already about to be added", c.ID()))
+		}
+		if o.Name() == c.Name() && c.Start() == o.Start() && c.End() == o.End() {
+			return api.ErrBadRequest(fmt.Errorf(
+				"a course '%s' with the same dates is already about to be added", c.Name()))
 		}
-		// all other courses with that name have different dates, we can add the course to the list
-		byName[c.Name()] = append(sameName, c)
-	} else {
-		// it's the first course with that name
-		byName[c.Name()] = Courses{c}
 	}
 
+	return nil
+}
+
+// insert adds the course to the maps and sorted lists. It must not be a duplicate.
+func (r *Registry) insert(c *course.Course) {
+	r.byName[c.Name()] = append(r.byName[c.Name()], c)
+
 	// add to id map
-	byID[c.ID()] = c
+	r.byID[c.ID()] = c
 
 	// insert to sorted lists in the right place
-	byStart = byStart.add(c, func(i int) bool {
-		return c.Start().Before(byStart[i].Start())
+	r.byStart = r.byStart.add(c, func(i int) bool {
+		return c.Start().Before(r.byStart[i].Start())
 	})
-	byEnd = byEnd.add(c, func(i int) bool {
-		return c.End().Before(byEnd[i].End())
+	r.byEnd = r.byEnd.add(c, func(i int) bool {
+		return c.End().Before(r.byEnd[i].End())
 	})
+	r.byDates.add(c)
 
-	return nil
+	bus.Publish(bus.Event{Kind: bus.CourseAdded, Course: c.ID(), CourseFree: c.FreePlaces()})
 }
 
-// add adds the course to the list, given a search function that determines where.
+// add returns a new list with the course added, given a search function that determines where.
+// The list itself is not changed, since others may be reading it (see All).
 func (cs Courses) add(c *course.Course, f func(int) bool) Courses {
-	k := len(cs)
-	if k == 0 {
-		return Courses{c}
-	}
+	idx := sort.Search(len(cs), f)
 
-	switch idx := sort.Search(k, f); idx {
-	case k: // new value is the biggest
-		return append(cs, c)
-	default: // 0 to k-1
-		// most likely the most efficient way to insert
-		// see: https://github.com/golang/go/wiki/SliceTricks
-		cs = append(cs, nil)
-		copy(cs[idx+1:], cs[idx:])
-		cs[idx] = c
-		return cs
-	}
+	added := make(Courses, len(cs)+1)
+	copy(added, cs[:idx])
+	added[idx] = c
+	copy(added[idx+1:], cs[idx:])
+	return added
 }
 
 // for templates
 
 // All returns all courses, sorted by start date.
-func All() Courses {
-	return byStart
+func (r *Registry) All() Courses {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return snapshot(r.byStart)
+}
+
+// snapshot returns the (part of a) sorted list for others to read.
+// Since the list is never changed, it doesn't have to be copied. But its capacity is limited, so that appending to it can't change the list either.
+func snapshot(cs Courses) Courses {
+	return cs[:len(cs):len(cs)]
 }
 
 // Upcoming returns all upcoming courses, i.e. courses which start date is after today.
-func Upcoming() Courses {
-	mux.Lock()
-	defer mux.Unlock()
+func (r *Registry) Upcoming() Courses {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
 	today := civil.DateOf(time.Now())
 
-	idx := sort.Search(len(byStart), func(i int) bool {
-		return byStart[i].Start().After(today)
+	idx := sort.Search(len(r.byStart), func(i int) bool {
+		return r.byStart[i].Start().After(today)
 	})
 
-	return byStart[idx:]
+	return snapshot(r.byStart[idx:])
 }
 
 // Current returns all current courses, i.e. courses which start date is today or before,
 // and which end date is today or after.
-func Current() Courses {
-	mux.Lock()
-	defer mux.Unlock()
-
+func (r *Registry) Current() Courses {
 	today := civil.DateOf(time.Now())
+	return r.Between(today, today)
+}
 
-	idx := sort.Search(len(byEnd), func(i int) bool {
-		return !byEnd[i].End().Before(today)
-	})
-
-	// since we ignore all past courses, the list is relatively small
-	curr := make(Courses, 0)
-	for _, c := range byEnd[idx:] {
-		// filter upcoming courses
-		if !c.Start().After(today) {
-			curr = append(curr, c)
-		}
-	}
+// Between returns all courses that have classes in the time frame from 'from' to 'to', sorted by start date.
+// A zero date leaves that side of the time frame open.
+func (r *Registry) Between(from, to civil.Date) Courses {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
-	return curr
+	cs := make(Courses, 0)
+	r.byDates.overlapping(from, to, func(c *course.Course) {
+		cs = append(cs, c)
+	})
+	return cs
 }
 
 // Past returns all past courses, i.e. courses which end date is before today.
-func Past() Courses {
-	mux.Lock()
-	defer mux.Unlock()
+func (r *Registry) Past() Courses {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
 	today := civil.DateOf(time.Now())
 
-	idx := sort.Search(len(byEnd), func(i int) bool {
-		return !byEnd[i].End().Before(today)
+	idx := sort.Search(len(r.byEnd), func(i int) bool {
+		return !r.byEnd[i].End().Before(today)
 	})
 
-	return byEnd[:idx]
+	return snapshot(r.byEnd[:idx])
 }
@@ -3,12 +3,15 @@ package courses
 import (
 	"math/rand"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/journal"
 	"golang.org/x/sync/errgroup"
 
 	"cloud.google.com/go/civil"
@@ -18,7 +21,7 @@ const addedCourses = 1000
 
 var today civil.Date = civil.DateOf(time.Now())
 
-func addCourses(t *testing.T) {
+func addCourses(t *testing.T, r *Registry) {
 	createAndAdd := func(start, end civil.Date) error {
 		// create the course
 		c, err := course.NewHistoric(
@@ -28,7 +31,7 @@ func addCourses(t *testing.T) {
 			return err
 		}
 
-		return Add(c)
+		return r.Add(c)
 	}
 
 	var eg errgroup.Group
@@ -50,18 +53,20 @@ func addCourses(t *testing.T) {
 }
 
 func TestAdd(t *testing.T) {
+	r := NewRegistry()
+
 	c, err := course.NewHistoric("Test Course", today, today.AddDays(3), 10)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err = Add(c); err != nil {
+	if err = r.Add(c); err != nil {
 		t.Errorf("couldn't add course: %s", err)
 	}
 
 	// don't add same course twice
 
-	if err = Add(c); err == nil {
+	if err = r.Add(c); err == nil {
 		t.Errorf("could add same course twice")
 	}
 
@@ -73,7 +78,7 @@ func TestAdd(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err = Add(duplicate); err == nil {
+	if err = r.Add(duplicate); err == nil {
 		t.Errorf("could add course with same name and dates")
 	}
 
@@ -84,51 +89,159 @@ func TestAdd(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err = Add(previous); err != nil {
+	if err = r.Add(previous); err != nil {
 		t.Errorf("couldn't add previous course with same name: %s", err)
 	}
 
 	// create and add a bunch of courses
-	addCourses(t)
+	addCourses(t, r)
 
 	// all length values correct?
 
 	count := 0
-	for _, list := range byName {
+	for _, list := range r.byName {
 		count += len(list)
 	}
 
-	if count != len(byID) {
-		t.Errorf("byID doesn't have correct lenght, want: %d, have: %d", count, len(byID))
+	if count != len(r.byID) {
+		t.Errorf("byID doesn't have correct lenght, want: %d, have: %d", count, len(r.byID))
 	}
 
-	if count != len(byStart) {
-		t.Errorf("byStart doesn't have correct lenght, want: %d, have: %d", count, len(byStart))
+	if count != len(r.byStart) {
+		t.Errorf("byStart doesn't have correct lenght, want: %d, have: %d", count, len(r.byStart))
 	}
 
-	if count != len(byEnd) {
-		t.Errorf("byEnd doesn't have correct lenght, want: %d, have: %d", count, len(byEnd))
+	if count != len(r.byEnd) {
+		t.Errorf("byEnd doesn't have correct lenght, want: %d, have: %d", count, len(r.byEnd))
 	}
 
 	// all sorted?
 
-	sorted := sort.SliceIsSorted(byStart, func(i, j int) bool {
-		return byStart[i].Start().Before(byStart[j].Start())
+	sorted := sort.SliceIsSorted(r.byStart, func(i, j int) bool {
+		return r.byStart[i].Start().Before(r.byStart[j].Start())
 	})
 	if !sorted {
 		t.Errorf("byStart is not sorted")
 	}
 
-	sorted = sort.SliceIsSorted(byEnd, func(i, j int) bool {
-		return byEnd[i].End().Before(byEnd[j].End())
+	sorted = sort.SliceIsSorted(r.byEnd, func(i, j int) bool {
+		return r.byEnd[i].End().Before(r.byEnd[j].End())
 	})
 	if !sorted {
 		t.Errorf("byEnd is not sorted")
 	}
 }
 
+func TestAddAll(t *testing.T) {
+	r := NewRegistry()
+
+	existing, err := course.NewHistoric("Yoga", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Add(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	newCourse := func(name string, start, end civil.Date) *course.Course {
+		c, err := course.NewHistoric(name, start, end, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	pilates := newCourse("Pilates", today, today.AddDays(3))
+	tables := []struct {
+		cs     Courses
+		failed []bool // which courses can't be added, nil if all can
+	}{
+		{Courses{}, nil},
+		{Courses{pilates, newCourse("Yoga", today.AddDays(7), today.AddDays(10))}, nil},
+		{Courses{newCourse("Zumba", today, today), newCourse("Yoga", today, today.AddDays(3))}, []bool{false, true}},
+		{Courses{newCourse("Zumba", today, today), newCourse("Zumba", today, today)}, []bool{false, true}},
+		{Courses{pilates, pilates}, []bool{false, true}},
+		{Courses{existing}, []bool{true}},
+	}
+
+	for i, table := range tables {
+		errs := r.Check(table.cs)
+		if table.failed == nil {
+			if errs != nil {
+				t.Errorf("%d: courses can't be added: %v", i, errs)
+			}
+			continue
+		}
+		if len(errs) != len(table.cs) {
+			t.Errorf("%d: got %d errors, want: %d", i, len(errs), len(table.cs))
+			continue
+		}
+		for j, failed := range table.failed {
+			if failed != (errs[j] != nil) {
+				t.Errorf("%d: course %d failed: %v, want: %v", i, j, errs[j], failed)
+			}
+		}
+	}
+
+	// checking doesn't add anything
+	if n := len(r.All()); n != 1 {
+		t.Errorf("registry has %d courses after checking, want: 1", n)
+	}
+
+	// either all or nothing is added
+	if errs := r.AddAll(tables[2].cs); errs == nil {
+		t.Errorf("could add duplicates")
+	}
+	if n := len(r.All()); n != 1 {
+		t.Errorf("registry has %d courses after failed import, want: 1", n)
+	}
+	if errs := r.AddAll(tables[1].cs); errs != nil {
+		t.Errorf("couldn't add courses: %v", errs)
+	}
+	if n := len(r.All()); n != 3 {
+		t.Errorf("registry has %d courses, want: 3", n)
+	}
+	if _, err := r.Get(pilates.ID()); err != nil {
+		t.Errorf("added course not found: %s", err)
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	studioA, studioB := NewRegistry(), NewRegistry()
+
+	c, err := course.NewHistoric("Test Course", today, today.AddDays(3), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = studioA.Add(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = studioB.Get(c.ID()); err == nil || len(studioB.All()) != 0 {
+		t.Errorf("course of one registry is in another")
+	}
+	if _, err = Get(c.ID()); err == nil {
+		t.Errorf("course of a registry is in the default registry")
+	}
+
+	// the same course can be offered by two studios
+	if err = studioB.Add(c); err != nil {
+		t.Errorf("couldn't add course to a second registry: %s", err)
+	}
+
+	// the functions of the package use the default registry
+	if err = Add(c); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := Default.Get(c.ID()); err != nil || found != c {
+		t.Errorf("course added to the package isn't in the default registry")
+	}
+}
+
 func TestGet(t *testing.T) {
-	_, err := Get(0)
+	r := NewRegistry()
+
+	_, err := r.Get(0)
 	if err == nil {
 		t.Errorf("didn't get error message for invalid id")
 	}
@@ -141,32 +254,34 @@ func TestGet(t *testing.T) {
 	if err != nil {
 		t.Fatalf("couldn't create test course: %s", err)
 	}
-	Add(c)
+	r.Add(c)
 
 	var c2 *course.Course
-	c2, err = Get(c.ID())
+	c2, err = r.Get(c.ID())
 	if err != nil || c != c2 {
 		t.Errorf("couldn't retrieve test course")
 	}
 }
 
 func TestAll(t *testing.T) {
-	addCourses(t)
+	r := NewRegistry()
 
-	total := len(All())
-	lenP := len(Past())
-	lenC := len(Current())
-	lenU := len(Upcoming())
+	addCourses(t, r)
+
+	total := len(r.All())
+	lenP := len(r.Past())
+	lenC := len(r.Current())
+	lenU := len(r.Upcoming())
 	if total != lenP+lenC+lenU {
 		t.Errorf("sum of courses not correct (%d past + %d current + %d upcoming != %d total)", lenP, lenC, lenU, total)
 	}
 }
 
 func TestUpcoming(t *testing.T) {
+	r := NewRegistry()
+	addCourses(t, r)
 
-	addCourses(t)
-
-	up := Upcoming()
+	up := r.Upcoming()
 
 	if len(up) == 0 {
 		t.Errorf("very unlikely occurance of 0 upcoming courses after randomly generating %d courses", addedCourses)
@@ -180,10 +295,10 @@ func TestUpcoming(t *testing.T) {
 }
 
 func TestCurrent(t *testing.T) {
+	r := NewRegistry()
+	addCourses(t, r)
 
-	addCourses(t)
-
-	curr := Current()
+	curr := r.Current()
 
 	if len(curr) == 0 {
 		t.Errorf("very unlikely occurance of 0 current courses after randomly generating %d courses", addedCourses)
@@ -197,10 +312,10 @@ func TestCurrent(t *testing.T) {
 }
 
 func TestPast(t *testing.T) {
+	r := NewRegistry()
+	addCourses(t, r)
 
-	addCourses(t)
-
-	past := Past()
+	past := r.Past()
 
 	if len(past) == 0 {
 		t.Errorf("very unlikely occurance of 0 past courses after randomly generating %d courses", addedCourses)
@@ -212,3 +327,435 @@ func TestPast(t *testing.T) {
 		}
 	}
 }
+
+func TestFind(t *testing.T) {
+	r := NewRegistry()
+
+	add := func(name string, start, end civil.Date, capacity int, opts ...course.Option) *course.Course {
+		c, err := course.NewHistoric(name, start, end, capacity, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Add(c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	add("Yoga", today, today.AddDays(2), 10, course.TaughtBy("Ann"), course.HeldAt("Studio A"))
+	add("Pilates", today.AddDays(1), today.AddDays(5), 10, course.TaughtBy("Bob"), course.HeldAt("Studio B"))
+	add("Yin Yoga", today.AddDays(-10), today.AddDays(-5), 10, course.TaughtBy("ann"))
+	boxing := add("Boxing", today.AddDays(3), today.AddDays(3), 1, course.HeldAt("Studio A"))
+	if err := boxing.BookClass("Arnold", today.AddDays(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		q     Query
+		names string
+	}{
+		{Query{}, "Yin Yoga,Yoga,Pilates,Boxing"},
+		{Query{Desc: true}, "Boxing,Pilates,Yoga,Yin Yoga"},
+		{Query{Sort: ByName}, "Boxing,Pilates,Yin Yoga,Yoga"},
+		{Query{Sort: ByEnd, Desc: true}, "Pilates,Boxing,Yoga,Yin Yoga"},
+		{Query{Name: "YOGA"}, "Yin Yoga,Yoga"},
+		{Query{From: today}, "Yoga,Pilates,Boxing"},
+		{Query{From: today.AddDays(3), To: today.AddDays(4)}, "Pilates,Boxing"},
+		{Query{To: today}, "Yin Yoga,Yoga"},
+		{Query{Instructor: "ANN"}, "Yin Yoga,Yoga"},
+		{Query{Location: "studio a"}, "Yoga,Boxing"},
+		{Query{Free: 1}, "Yoga,Pilates"},
+		{Query{Limit: 2}, "Yin Yoga,Yoga"},
+	}
+
+	for _, table := range tables {
+		page, err := r.Find(table.q)
+		if err != nil {
+			t.Errorf("%+v failed: %s", table.q, err)
+			continue
+		}
+
+		names := make([]string, 0, len(page.Courses))
+		for _, c := range page.Courses {
+			names = append(names, c.Name())
+		}
+		if got := strings.Join(names, ","); got != table.names {
+			t.Errorf("%+v returned the wrong courses, got: %s, want: %s", table.q, got, table.names)
+		}
+	}
+
+	// page through the courses one by one
+	for _, sortBy := range []SortKey{ByStart, ByEnd, ByName} {
+		for _, desc := range []bool{false, true} {
+			all, err := r.Find(Query{Sort: sortBy, Desc: desc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q := Query{Sort: sortBy, Desc: desc, Limit: 1}
+			var paged Courses
+			for {
+				page, err := r.Find(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Courses) > q.Limit {
+					t.Fatalf("page has %d courses, more than the limit %d", len(page.Courses), q.Limit)
+				}
+				paged = append(paged, page.Courses...)
+				if page.Next == "" {
+					break
+				}
+				q.Cursor = page.Next
+			}
+
+			if len(paged) != len(all.Courses) {
+				t.Errorf("sorted by %s (desc: %t), paging returned %d courses instead of %d", sortBy, desc, len(paged), len(all.Courses))
+				continue
+			}
+			for i := range paged {
+				if paged[i] != all.Courses[i] {
+					t.Errorf("sorted by %s (desc: %t), course %d differs when paging", sortBy, desc, i)
+					break
+				}
+			}
+		}
+	}
+
+	// a course added while paging shows up if it comes after the cursor
+	q := Query{Sort: ByName, Limit: 2}
+	first, err := r.Find(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add("Aerobics", today, today, 10) // before the cursor
+	add("Zumba", today, today, 10)    // after the cursor
+	q.Cursor, q.Limit = first.Next, 0
+	rest, err := r.Find(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(rest.Courses))
+	for _, c := range rest.Courses {
+		names = append(names, c.Name())
+	}
+	if got := strings.Join(names, ","); got != "Yin Yoga,Yoga,Zumba" {
+		t.Errorf("the next page was wrong after adding courses, got: %s", got)
+	}
+
+	// errors
+	for _, q := range []Query{
+		{Sort: "price"},
+		{Limit: -1},
+		{Limit: MaxLimit + 1},
+		{From: today, To: today.AddDays(-1)},
+		{Cursor: "garbage"},
+		{Sort: ByStart, Cursor: first.Next},
+		{Sort: ByName, Desc: true, Cursor: first.Next},
+		{Sort: ByName, Name: "yoga", Cursor: first.Next},
+	} {
+		if _, err := r.Find(q); err == nil {
+			t.Errorf("%+v should have failed", q)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	r := NewRegistry()
+
+	addCourses(t, r)
+
+	all, past, upcoming := r.All(), r.Past(), r.Upcoming()
+	copies := []Courses{append(Courses(nil), all...), append(Courses(nil), past...), append(Courses(nil), upcoming...)}
+
+	// courses that are sorted first and last into all lists
+	for _, days := range []int{-1000, 1000} {
+		c, err := course.NewHistoric(uuid.New().String(), today.AddDays(days), today.AddDays(days), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, list := range []Courses{all, past, upcoming} {
+		for j := range list {
+			if list[j] != copies[i][j] {
+				t.Fatalf("list %d changed after adding courses", i)
+			}
+		}
+	}
+
+	if len(r.All()) != len(all)+2 {
+		t.Errorf("new list doesn't have the added courses")
+	}
+
+	// appending to a list must not change the courses either
+	_ = append(r.Past(), nil)
+	for _, c := range r.byEnd {
+		if c == nil {
+			t.Fatalf("appending to the list of past courses changed the courses")
+		}
+	}
+}
+
+// randomCourses returns n courses starting within about three years around today, each lasting up to two weeks.
+func randomCourses(tb testing.TB, n int) Courses {
+	cs := make(Courses, n)
+	for i := range cs {
+		start := today.AddDays(rand.Intn(1000) - 500)
+		c, err := course.NewHistoric("Random", start, start.AddDays(rand.Intn(14)), 10)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		cs[i] = c
+	}
+	return cs
+}
+
+// overlapsBruteForce returns the courses with classes in the time frame by looking at every course, sorted like the interval tree.
+func overlapsBruteForce(cs Courses, from, to civil.Date) Courses {
+	found := make(Courses, 0)
+	for _, c := range cs {
+		if (!from.IsValid() || !c.End().Before(from)) && (!to.IsValid() || !c.Start().After(to)) {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Start() != found[j].Start() {
+			return found[i].Start().Before(found[j].Start())
+		}
+		return found[i].ID() < found[j].ID()
+	})
+	return found
+}
+
+// check fails if the subtree isn't a treap with the right latest end dates.
+func (n *node) check(t *testing.T) {
+	if n == nil {
+		return
+	}
+
+	maxEnd := n.end
+	for _, child := range []*node{n.left, n.right} {
+		if child == nil {
+			continue
+		}
+		if child.priority > n.priority {
+			t.Fatalf("child of course %d has a higher priority", n.c.ID())
+		}
+		if child.maxEnd.After(maxEnd) {
+			maxEnd = child.maxEnd
+		}
+		child.check(t)
+	}
+	if n.left != nil && !n.left.before(n) || n.right != nil && n.right.before(n) {
+		t.Fatalf("children of course %d are in the wrong order", n.c.ID())
+	}
+	if n.maxEnd != maxEnd {
+		t.Fatalf("course %d has the latest end date %s in its subtree, not %s", n.c.ID(), maxEnd, n.maxEnd)
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	cs := randomCourses(t, 2000)
+
+	var tree intervals
+	for _, c := range cs {
+		tree.add(c)
+	}
+	tree.root.check(t)
+	if tree.size != len(cs) {
+		t.Errorf("tree has %d courses, want: %d", tree.size, len(cs))
+	}
+
+	var none civil.Date
+	for i := 0; i < 200; i++ {
+		from := today.AddDays(rand.Intn(1100) - 550)
+		to := from.AddDays(rand.Intn(30))
+
+		for _, frame := range [][2]civil.Date{{from, to}, {from, from}, {from, none}, {none, to}, {none, none}} {
+			var got Courses
+			tree.overlapping(frame[0], frame[1], func(c *course.Course) {
+				got = append(got, c)
+			})
+
+			want := overlapsBruteForce(cs, frame[0], frame[1])
+			if len(got) != len(want) {
+				t.Fatalf("from %s to %s: found %d courses, want: %d", frame[0], frame[1], len(got), len(want))
+			}
+			for j := range got {
+				if got[j] != want[j] {
+					t.Fatalf("from %s to %s: course %d is wrong", frame[0], frame[1], j)
+				}
+			}
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	r := NewRegistry()
+
+	addCourses(t, r)
+
+	from, to := today.AddDays(-3), today.AddDays(3)
+	between := r.Between(from, to)
+	if want := overlapsBruteForce(r.All(), from, to); len(between) != len(want) {
+		t.Errorf("found %d courses between %s and %s, want: %d", len(between), from, to, len(want))
+	}
+
+	if len(r.Between(civil.Date{}, civil.Date{})) != len(r.All()) {
+		t.Errorf("an open time frame should contain all courses")
+	}
+}
+
+// benchmarks with 100k courses
+
+var (
+	benchOnce  sync.Once
+	benchTree  intervals
+	benchByEnd Courses
+)
+
+// benchCourses sets up the interval tree and the sorted slice with the same 100k courses.
+func TestRestore(t *testing.T) {
+	if err := journal.Open(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	r := NewRegistry()
+	r.Name = "downtown"
+
+	c, err := course.NewHistoric("Yoga", today, today.AddDays(3), 10, course.StartingAt(civil.Time{Hour: 18}), course.TaughtBy("Berta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	// the courses of the default registry
+	if errs := NewRegistry().AddAll(randomCourses(t, 3)); errs != nil {
+		t.Fatal(errs)
+	}
+
+	tomorrow := today.AddDays(1)
+	for _, m := range []string{"Arnold", "Bruce", "Chuck"} {
+		if err := c.BookClass(m, tomorrow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.CancelBooking("Bruce", tomorrow); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BookClass("Arnold", today.AddDays(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CancelClass(today.AddDays(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCapacity(12); err != nil {
+		t.Fatal(err)
+	}
+
+	// as after a restart
+	restored, others := NewRegistry(), NewRegistry()
+	if err := Restore(func(name string) (*Registry, error) {
+		if name == "downtown" {
+			return restored, nil
+		}
+		return others, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := restored.Get(c.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.All()) != 1 || len(others.All()) != 3 || rc.Name() != "Yoga" || rc.Capacity() != 12 || rc.At() != c.At() || rc.Instructor() != "Berta" {
+		t.Errorf("wrong course restored: %+v", rc)
+	}
+	if as, _ := rc.Attendees(tomorrow); strings.Join(as, ",") != "Arnold,Chuck" {
+		t.Errorf("wrong attendees tomorrow: %v", as)
+	}
+	if as, _ := rc.Attendees(today.AddDays(2)); !rc.Cancelled(today.AddDays(2)) || strings.Join(as, ",") != "Arnold" {
+		t.Errorf("class wasn't cancelled for Arnold: %v", as)
+	}
+
+	// the restored course is used from now on
+	if err := rc.BookClass("Bruce", tomorrow); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range journal.Courses() {
+		if s.ID == c.ID() && strings.Join(s.Classes[tomorrow].Attendees, ",") != "Arnold,Chuck,Bruce" {
+			t.Errorf("booking of the restored course wasn't journaled: %v", s.Classes[tomorrow].Attendees)
+		}
+	}
+}
+
+func benchCourses(b *testing.B) {
+	benchOnce.Do(func() {
+		for _, c := range randomCourses(b, 100000) {
+			benchTree.add(c)
+			benchByEnd = benchByEnd.add(c, func(i int) bool {
+				return c.End().Before(benchByEnd[i].End())
+			})
+		}
+	})
+	b.ResetTimer()
+}
+
+// scanByEnd is how the courses in a time frame were found before the interval tree:
+// search the first course ending in the time frame, then filter all later ones by start date.
+func scanByEnd(byEnd Courses, from, to civil.Date) Courses {
+	idx := sort.Search(len(byEnd), func(i int) bool {
+		return !byEnd[i].End().Before(from)
+	})
+
+	found := make(Courses, 0)
+	for _, c := range byEnd[idx:] {
+		if !c.Start().After(to) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// treeBetween finds the courses in a time frame with the interval tree.
+func treeBetween(tree *intervals, from, to civil.Date) Courses {
+	found := make(Courses, 0)
+	tree.overlapping(from, to, func(c *course.Course) {
+		found = append(found, c)
+	})
+	return found
+}
+
+func BenchmarkCurrentScan(b *testing.B) {
+	benchCourses(b)
+	for i := 0; i < b.N; i++ {
+		scanByEnd(benchByEnd, today, today)
+	}
+}
+
+func BenchmarkCurrentIndex(b *testing.B) {
+	benchCourses(b)
+	for i := 0; i < b.N; i++ {
+		treeBetween(&benchTree, today, today)
+	}
+}
+
+func BenchmarkWeekScan(b *testing.B) {
+	benchCourses(b)
+	for i := 0; i < b.N; i++ {
+		scanByEnd(benchByEnd, today.AddDays(-300), today.AddDays(-294))
+	}
+}
+
+func BenchmarkWeekIndex(b *testing.B) {
+	benchCourses(b)
+	for i := 0; i < b.N; i++ {
+		treeBetween(&benchTree, today.AddDays(-300), today.AddDays(-294))
+	}
+}
@@ -0,0 +1,56 @@
+package courses
+
+import (
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+)
+
+// Default is the registry used by the functions of this package, e.g. Add and Get.
+// They are kept for code that only needs one registry, e.g. a single studio.
+var Default = NewRegistry()
+
+// Get returns the course with the given id from the default registry (see Registry.Get).
+func Get(id uint64) (*course.Course, error) {
+	return Default.Get(id)
+}
+
+// Add adds a course to the default registry (see Registry.Add).
+func Add(c *course.Course) error {
+	return Default.Add(c)
+}
+
+// AddAll adds all courses to the default registry or none of them (see Registry.AddAll).
+func AddAll(cs Courses) []error {
+	return Default.AddAll(cs)
+}
+
+// All returns all courses of the default registry (see Registry.All).
+func All() Courses {
+	return Default.All()
+}
+
+// Upcoming returns the upcoming courses of the default registry (see Registry.Upcoming).
+func Upcoming() Courses {
+	return Default.Upcoming()
+}
+
+// Current returns the current courses of the default registry (see Registry.Current).
+func Current() Courses {
+	return Default.Current()
+}
+
+// Between returns the courses of the default registry in a time frame (see Registry.Between).
+func Between(from, to civil.Date) Courses {
+	return Default.Between(from, to)
+}
+
+// Past returns the past courses of the default registry (see Registry.Past).
+func Past() Courses {
+	return Default.Past()
+}
+
+// Find returns a page of courses of the default registry (see Registry.Find).
+func Find(q Query) (Page, error) {
+	return Default.Find(q)
+}
@@ -0,0 +1,133 @@
+package courses
+
+import (
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+)
+
+// intervals is an interval tree of courses: a binary search tree ordered by start date (and ID, if the start dates are the same),
+// in which every node also knows the latest end date in its subtree. That way, courses that overlap a time frame are found without looking at those that can't.
+//
+// It is balanced as a treap, i.e. the nodes are also a heap ordered by random priorities, so that adding and finding take logarithmic time on average.
+// The zero value is an empty tree.
+type intervals struct {
+	root *node
+	size int
+}
+
+type node struct {
+	c          *course.Course
+	start, end civil.Date
+	maxEnd     civil.Date // the latest end date of this node and its children
+	priority   uint64
+	left       *node // starting earlier
+	right      *node // starting later
+}
+
+// add adds the course to the tree.
+func (t *intervals) add(c *course.Course) {
+	t.root = t.root.insert(&node{
+		c:        c,
+		start:    c.Start(),
+		end:      c.End(),
+		maxEnd:   c.End(),
+		priority: priority(c.ID()),
+	})
+	t.size++
+}
+
+// overlapping calls visit for every course with classes in the time frame from 'from' to 'to', in the order of their start date.
+// A zero date leaves that side of the time frame open, e.g. all courses ending today or later are visited with a zero 'to'.
+func (t *intervals) overlapping(from, to civil.Date, visit func(*course.Course)) {
+	t.root.overlapping(from, to, visit)
+}
+
+// before returns whether the node comes before the other one in the tree.
+func (n *node) before(o *node) bool {
+	if n.start != o.start {
+		return n.start.Before(o.start)
+	}
+	return n.c.ID() < o.c.ID()
+}
+
+// insert inserts the new node into the subtree and returns its new root.
+func (n *node) insert(added *node) *node {
+	if n == nil {
+		return added
+	}
+
+	if added.before(n) {
+		n.left = n.left.insert(added)
+		if n.left.priority > n.priority {
+			n = n.rotateRight()
+		}
+	} else {
+		n.right = n.right.insert(added)
+		if n.right.priority > n.priority {
+			n = n.rotateLeft()
+		}
+	}
+
+	n.update()
+	return n
+}
+
+// rotateRight makes the left child the root of the subtree and returns it.
+func (n *node) rotateRight() *node {
+	l := n.left
+	n.left, l.right = l.right, n
+	n.update()
+	l.update()
+	return l
+}
+
+// rotateLeft makes the right child the root of the subtree and returns it.
+func (n *node) rotateLeft() *node {
+	r := n.right
+	n.right, r.left = r.left, n
+	n.update()
+	r.update()
+	return r
+}
+
+// update sets the latest end date of the subtree after its children changed.
+func (n *node) update() {
+	n.maxEnd = n.end
+	for _, child := range []*node{n.left, n.right} {
+		if child != nil && child.maxEnd.After(n.maxEnd) {
+			n.maxEnd = child.maxEnd
+		}
+	}
+}
+
+// overlapping visits the courses of the subtree that overlap the time frame (see intervals.overlapping).
+func (n *node) overlapping(from, to civil.Date, visit func(*course.Course)) {
+	// nothing in the subtree ends late enough
+	if n == nil || from.IsValid() && n.maxEnd.Before(from) {
+		return
+	}
+
+	n.left.overlapping(from, to, visit)
+
+	// this course and all in the right subtree start too late
+	if to.IsValid() && n.start.After(to) {
+		return
+	}
+
+	if !from.IsValid() || !n.end.Before(from) {
+		visit(n.c)
+	}
+
+	n.right.overlapping(from, to, visit)
+}
+
+// priority returns the priority of the node of the course with the ID.
+// It is derived from the ID, which is as good as random for the shape of the tree, but makes the tree the same every time.
+func priority(id uint64) uint64 {
+	// see SplitMix64
+	z := id + 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
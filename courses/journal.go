@@ -0,0 +1,41 @@
+package courses
+
+import (
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+// Restore adds the courses of the journal, which has to be open, to their registries, as the events add up to (see package journal).
+// The registry function returns the registry with the given name, e.g. that of a studio.
+//
+// The courses are not journaled again. Courses created afterwards get higher IDs than any course of the journal.
+func Restore(registry func(name string) (*Registry, error)) error {
+	course.Reserve(journal.LastID())
+
+	for _, s := range journal.Courses() {
+		r, err := registry(s.Registry)
+		if err != nil {
+			return err
+		}
+		c, err := course.Restore(s)
+		if err != nil {
+			return err
+		}
+		if err := r.restore(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore adds the course without journaling it.
+func (r *Registry) restore(c *course.Course) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.duplicate(c, nil); err != nil {
+		return err
+	}
+	r.insert(c)
+	return nil
+}
@@ -0,0 +1,242 @@
+package courses
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/validation"
+)
+
+// A SortKey is what courses can be sorted by.
+type SortKey string
+
+// the sort keys
+const (
+	ByStart SortKey = "start"
+	ByEnd   SortKey = "end"
+	ByName  SortKey = "name"
+)
+
+// MaxLimit is the maximum number of courses per page.
+const MaxLimit = 500
+
+// A Query selects courses. The zero value selects all courses, sorted by start date.
+type Query struct {
+	// filters, ignored if zero
+	Name       string     // part of the name, not case-sensitive
+	From       civil.Date // courses that end on or after this date
+	To         civil.Date // courses that start on or before this date
+	Instructor string     // the instructor, not case-sensitive
+	Location   string     // the location, not case-sensitive
+	Free       int        // courses with a class that has at least that many free places (see course.Course.FreePlaces)
+
+	// the order, by start date if not set
+	Sort SortKey
+	Desc bool
+
+	// pagination
+	Cursor string // where the page starts, i.e. Page.Next of the previous page
+	Limit  int    // the number of courses per page, all if 0
+}
+
+// A Page is a part of the courses that a query selects.
+type Page struct {
+	Courses Courses
+	// the cursor of the next page, empty if this is the last page
+	Next string
+}
+
+// a cursor points to the last course of a page
+// since it contains the sort key of the course, the next page starts at the right place even if courses were added in the meantime
+// the order and the filters of the query are kept, so that the cursor isn't used to page through other courses
+type cursor struct {
+	Sort    SortKey `json:"s"`
+	Desc    bool    `json:"d,omitempty"`
+	Filters uint64  `json:"f"`
+	Key     string  `json:"k"`
+	ID      uint64  `json:"id"`
+}
+
+// Find returns the page of courses of the registry selected by the query.
+func (r *Registry) Find(q Query) (Page, error) {
+	var errs validation.Errors
+
+	switch q.Sort {
+	case "":
+		q.Sort = ByStart
+	case ByStart, ByEnd, ByName:
+	default:
+		errs.Add("sort", validation.Invalid, "sort value '%s' must be one of: %s, %s, %s", q.Sort, ByStart, ByEnd, ByName)
+	}
+
+	if q.Limit < 0 || q.Limit > MaxLimit {
+		errs.Add("limit", validation.Invalid, "limit value (%d) must be between 0 and %d", q.Limit, MaxLimit)
+	}
+
+	if q.From.IsValid() && q.To.IsValid() && q.From.After(q.To) {
+		errs.Add("to", validation.Invalid, "to value (%s) must not be before from value (%s)", q.To, q.From)
+	}
+
+	var after *cursor
+	if q.Cursor != "" && !errs.Has("sort") {
+		c, err := decodeCursor(q.Cursor)
+		switch {
+		case err != nil:
+			errs.Add("cursor", validation.Malformed, "cursor value is not a cursor")
+		case c.Sort != q.Sort:
+			errs.Add("cursor", validation.Invalid, "cursor value is for sorting by %s, not by %s", c.Sort, q.Sort)
+		case c.Desc != q.Desc:
+			errs.Add("cursor", validation.Invalid, "cursor value is for the %s order, not the %s order", order(c.Desc), order(q.Desc))
+		case c.Filters != q.filters():
+			errs.Add("cursor", validation.Invalid, "cursor value is for other filters")
+		default:
+			after = &c
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return Page{}, err
+	}
+
+	// select
+
+	type keyed struct {
+		c   *course.Course
+		key string
+	}
+
+	selected := make([]keyed, 0)
+	selectIf := func(c *course.Course) {
+		if q.matches(c) {
+			selected = append(selected, keyed{c, sortKey(c, q.Sort)})
+		}
+	}
+
+	r.mux.Lock()
+	if q.From.IsValid() || q.To.IsValid() {
+		// only look at the courses in the time frame
+		r.byDates.overlapping(q.From, q.To, selectIf)
+	} else {
+		for _, c := range r.byStart {
+			selectIf(c)
+		}
+	}
+	r.mux.Unlock()
+
+	// sort, the ID decides if the keys are the same
+
+	sort.Slice(selected, func(i, j int) bool {
+		a, b := selected[i], selected[j]
+		if q.Desc {
+			a, b = b, a
+		}
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.c.ID() < b.c.ID()
+	})
+
+	// paginate
+
+	if after != nil {
+		// the first course after the one of the cursor
+		idx := sort.Search(len(selected), func(i int) bool {
+			k, id := selected[i].key, selected[i].c.ID()
+			if q.Desc {
+				return k < after.Key || k == after.Key && id < after.ID
+			}
+			return k > after.Key || k == after.Key && id > after.ID
+		})
+		selected = selected[idx:]
+	}
+
+	n := len(selected)
+	if q.Limit > 0 && n > q.Limit {
+		n = q.Limit
+	}
+
+	page := Page{Courses: make(Courses, n)}
+	for i := range page.Courses {
+		page.Courses[i] = selected[i].c
+	}
+	if n < len(selected) {
+		last := selected[n-1]
+		page.Next = encodeCursor(cursor{q.Sort, q.Desc, q.filters(), last.key, last.c.ID()})
+	}
+	return page, nil
+}
+
+// matches returns whether the course passes the filters of the query.
+func (q Query) matches(c *course.Course) bool {
+	switch {
+	case q.Name != "" && !strings.Contains(strings.ToLower(c.Name()), strings.ToLower(q.Name)):
+		return false
+	case q.From.IsValid() && c.End().Before(q.From):
+		return false
+	case q.To.IsValid() && c.Start().After(q.To):
+		return false
+	case q.Instructor != "" && !strings.EqualFold(c.Instructor(), q.Instructor):
+		return false
+	case q.Location != "" && !strings.EqualFold(c.Location(), q.Location):
+		return false
+	case q.Free > 0 && c.FreePlaces() < q.Free:
+		return false
+	}
+	return true
+}
+
+// filters returns a hash of the filters of the query. Queries that select the same courses have the same hash.
+func (q Query) filters() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%q %s %s %q %q %d", strings.ToLower(q.Name), q.From, q.To, strings.ToLower(q.Instructor), strings.ToLower(q.Location), q.Free)
+	return h.Sum64()
+}
+
+// order returns the name of the order, descending if desc is set.
+func order(desc bool) string {
+	if desc {
+		return "descending"
+	}
+	return "ascending"
+}
+
+// sortKey returns the key by which the course is sorted, as a string that sorts the same way.
+func sortKey(c *course.Course, s SortKey) string {
+	switch s {
+	case ByEnd:
+		return c.End().String() // dates in ISO format sort like strings
+	case ByName:
+		return strings.ToLower(c.Name())
+	default:
+		return c.Start().String()
+	}
+}
+
+// encodeCursor returns the cursor as an opaque string.
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c) // can't fail
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the cursor encoded by encodeCursor.
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	if c.Sort == "" {
+		return c, fmt.Errorf("cursor without sort key")
+	}
+	return c, nil
+}
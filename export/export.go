@@ -0,0 +1,146 @@
+// Package export writes tables, e.g. lists of attendees, as files for spreadsheets: CSV, JSON Lines or XLSX.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// A Format is a file format of an export.
+type Format string
+
+// the formats
+const (
+	CSV       Format = "csv"   // comma-separated values with a header row
+	JSONLines Format = "jsonl" // one JSON object per row, keyed by the columns
+	XLSX      Format = "xlsx"  // an Excel workbook with a single sheet
+)
+
+// Formats are all formats, the first is the default.
+var Formats = []Format{CSV, JSONLines, XLSX}
+
+// ParseFormat returns the format with the name, e.g. 'xlsx'. If the name is empty, it returns the default format.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return Formats[0], nil
+	}
+	for _, f := range Formats {
+		if Format(strings.ToLower(s)) == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("format value '%s' must be one of: csv, jsonl, xlsx", s)
+}
+
+// ContentType returns the media type of files of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case JSONLines:
+		return "application/jsonl; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// A Table has named columns and rows with a value for each column.
+// The values are strings, integers, booleans, dates or nil for no value.
+type Table struct {
+	Name    string // e.g. of the sheet of a workbook, optional
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Add adds a row with the values of the columns.
+func (t *Table) Add(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// Marshal returns the table as a file of the format.
+func (t Table) Marshal(f Format) ([]byte, error) {
+	for i, r := range t.Rows {
+		if len(r) != len(t.Columns) {
+			return nil, fmt.Errorf("row %d has %d values, but there are %d columns", i+1, len(r), len(t.Columns))
+		}
+	}
+
+	switch f {
+	case CSV:
+		return t.csv()
+	case JSONLines:
+		return t.jsonLines()
+	case XLSX:
+		return t.xlsx()
+	}
+	return nil, fmt.Errorf("unknown format '%s'", f)
+}
+
+// csv returns the table as CSV with a header row.
+func (t Table) csv() ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	if err := w.Write(t.Columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(t.Columns))
+	for _, r := range t.Rows {
+		for i, v := range r {
+			record[i] = text(v)
+			// spreadsheets would run text like '=1+1' as a formula, e.g. a name a member chose
+			if _, ok := v.(string); ok && record[i] != "" && strings.IndexByte("=+-@", record[i][0]) >= 0 {
+				record[i] = "'" + record[i]
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// jsonLines returns the table as one JSON object per line, with the keys in the order of the columns.
+func (t Table) jsonLines() ([]byte, error) {
+	var b bytes.Buffer
+	for _, r := range t.Rows {
+		b.WriteByte('{')
+		for i, v := range r {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, err := json.Marshal(t.Columns[i])
+			if err != nil {
+				return nil, err
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %w", t.Columns[i], err)
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteString("}\n")
+	}
+	return b.Bytes(), nil
+}
+
+// text returns the value as text, e.g. for a cell of a CSV file.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case civil.Date:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
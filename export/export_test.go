@@ -0,0 +1,158 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+)
+
+// table returns a table with all kinds of values.
+func table() Table {
+	t := Table{Name: "Roster: Pilates/Yoga", Columns: []string{"date", "course", "member", "booked", "paid"}}
+	t.Add(civil.Date{Year: 2020, Month: 12, Day: 1}, "Pilates, Advanced", "Arnold", 3, true)
+	t.Add(civil.Date{Year: 2020, Month: 12, Day: 2}, "Yoga", "=HYPERLINK(\"x\")", 0, nil)
+	return t
+}
+
+func TestParseFormat(t *testing.T) {
+	tables := []struct {
+		s      string
+		format Format
+		ok     bool
+	}{
+		{"", CSV, true},
+		{"csv", CSV, true},
+		{"JSONL", JSONLines, true},
+		{"xlsx", XLSX, true},
+		{"pdf", "", false},
+	}
+
+	for _, table := range tables {
+		f, err := ParseFormat(table.s)
+		if (err == nil) != table.ok || f != table.format {
+			t.Errorf("%q: got %q, %v, want: %q", table.s, f, err, table.format)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	b, err := table().Marshal(CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "date,course,member,booked,paid\n" +
+		"2020-12-01,\"Pilates, Advanced\",Arnold,3,true\n" +
+		"2020-12-02,Yoga,\"'=HYPERLINK(\"\"x\"\")\",0,\n"
+	if string(b) != want {
+		t.Errorf("wrong CSV, got:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	b, err := table().Marshal(JSONLines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want: 2\n%s", len(lines), b)
+	}
+	if want := `{"date":"2020-12-01","course":"Pilates, Advanced","member":"Arnold","booked":3,"paid":true}`; lines[0] != want {
+		t.Errorf("wrong line, got: %s, want: %s", lines[0], want)
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil {
+		t.Fatalf("line is not a JSON object: %s", err)
+	}
+	if row["member"] != `=HYPERLINK("x")` || row["paid"] != nil {
+		t.Errorf("wrong values: %v", row)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	b, err := table().Marshal(XLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %s", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+
+		// every part is well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %s", f.Name, err)
+			}
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("workbook has no %s", name)
+		}
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Roster  Pilates Yoga"`) {
+		t.Errorf("sheet has the wrong name: %s", files["xl/workbook.xml"])
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" s="2" t="inlineStr"><is><t xml:space="preserve">date</t></is></c>`,
+		`<c r="A2" s="1"><v>44166</v></c>`, // 2020-12-01
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">Pilates, Advanced</t></is></c>`,
+		`<c r="D2"><v>3</v></c>`,
+		`<c r="E2" t="b"><v>1</v></c>`,
+		`<c r="C3" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;x&#34;)</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet doesn't contain %s\n%s", cell, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="E3"`) {
+		t.Errorf("sheet contains a cell without a value")
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column %d: got %s, want: %s", i, got, want)
+		}
+	}
+}
+
+func TestMarshalWrongRow(t *testing.T) {
+	tb := Table{Columns: []string{"a", "b"}}
+	tb.Add(1)
+	for _, f := range Formats {
+		if _, err := tb.Marshal(f); err == nil {
+			t.Errorf("%s: could marshal row with too few values", f)
+		}
+	}
+}
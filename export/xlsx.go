@@ -0,0 +1,172 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/civil"
+)
+
+// An XLSX file is a zip archive of XML files (Office Open XML, ECMA-376).
+// We write the smallest workbook that spreadsheet apps open: one sheet whose strings are stored in the cells.
+
+// the parts of a workbook, except for the sheet
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// the cell formats: 0 is the default, 1 a date, 2 bold for the header
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`</cellXfs>` +
+		`</styleSheet>`},
+}
+
+// the indices of the cell formats of xl/styles.xml
+const (
+	styleDate   = 1
+	styleHeader = 2
+)
+
+// the day spreadsheets count dates from
+var epoch = civil.Date{Year: 1899, Month: 12, Day: 30}
+
+// xlsx returns the table as a workbook with a single sheet.
+func (t Table) xlsx() ([]byte, error) {
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+
+	write := func(name, content string) error {
+		w, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(content))
+		return err
+	}
+
+	for _, p := range xlsxParts {
+		if err := write(p.name, p.content); err != nil {
+			return nil, err
+		}
+	}
+
+	var wb strings.Builder
+	wb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	xml.EscapeText(&wb, []byte(sheetName(t.Name)))
+	wb.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	if err := write("xl/workbook.xml", wb.String()); err != nil {
+		return nil, err
+	}
+
+	var sh strings.Builder
+	sh.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c
+	}
+	writeRow(&sh, 1, header, styleHeader)
+	for i, r := range t.Rows {
+		writeRow(&sh, i+2, r, 0)
+	}
+	sh.WriteString(`</sheetData></worksheet>`)
+	if err := write("xl/worksheets/sheet1.xml", sh.String()); err != nil {
+		return nil, err
+	}
+
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeRow writes the row with the number n (from 1) as XML. Cells without a style of their own get the given one.
+func writeRow(sh *strings.Builder, n int, values []interface{}, style int) {
+	fmt.Fprintf(sh, `<row r="%d">`, n)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+
+		ref := column(i) + strconv.Itoa(n)
+		s := ""
+		if style != 0 {
+			s = fmt.Sprintf(` s="%d"`, style)
+		}
+
+		switch v := v.(type) {
+		case int, int64, uint64:
+			fmt.Fprintf(sh, `<c r="%s"%s><v>%d</v></c>`, ref, s, v)
+		case bool:
+			b := 0
+			if v {
+				b = 1
+			}
+			fmt.Fprintf(sh, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, s, b)
+		case civil.Date:
+			fmt.Fprintf(sh, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleDate, v.DaysSince(epoch))
+		default:
+			fmt.Fprintf(sh, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, s)
+			xml.EscapeText(sh, []byte(text(v)))
+			sh.WriteString(`</t></is></c>`)
+		}
+	}
+	sh.WriteString(`</row>`)
+}
+
+// column returns the name of the column with the index (from 0), e.g. 'A' or 'AB'.
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName returns a name spreadsheet apps accept: at most 31 characters, none of them []:*?/\.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	for utf8.RuneCountInString(name) > 31 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
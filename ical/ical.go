@@ -0,0 +1,130 @@
+// Package ical writes calendars in the iCalendar format (RFC 5545), e.g. to subscribe to classes in a calendar app.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/civil"
+)
+
+// ContentType is the media type of a calendar.
+const ContentType = "text/calendar; charset=utf-8"
+
+// the product that created the calendars
+const prodID = "-//MarkRosemaker//Booking System//EN"
+
+// A Calendar is a list of events, e.g. the classes of a course.
+type Calendar struct {
+	Name   string // shown by calendar apps, optional
+	Events []Event
+}
+
+// An Event is a class. It either lasts the whole day or has a start and an end.
+type Event struct {
+	UID         string // unique and the same every time the calendar is written, so that apps update the event instead of adding it again
+	Summary     string
+	Description string
+	Location    string
+
+	Date       civil.Date // the day of an event that lasts the whole day
+	Start, End time.Time  // the start and end of other events, zero for an event of a whole day
+
+	Cancelled bool // whether the event has been cancelled
+}
+
+// Marshal returns the calendar in the iCalendar format. DTSTAMP, the time the calendar was created, is the given time.
+func (c Calendar) Marshal(now time.Time) []byte {
+	var w writer
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", prodID)
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", escape(e.UID))
+		w.line("DTSTAMP", utc(now))
+		if e.Start.IsZero() {
+			w.line("DTSTART;VALUE=DATE", date(e.Date))
+			w.line("DTEND;VALUE=DATE", date(e.Date.AddDays(1)))
+		} else {
+			w.line("DTSTART", utc(e.Start))
+			w.line("DTEND", utc(e.End))
+		}
+		w.line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			w.line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Location != "" {
+			w.line("LOCATION", escape(e.Location))
+		}
+		if e.Cancelled {
+			// a higher sequence number tells apps that the event changed
+			w.line("STATUS", "CANCELLED")
+			w.line("SEQUENCE", "1")
+		} else {
+			w.line("STATUS", "CONFIRMED")
+			w.line("SEQUENCE", "0")
+		}
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.Bytes()
+}
+
+// a writer writes content lines
+type writer struct {
+	bytes.Buffer
+}
+
+// maximum length of a line in octets, without the line break
+const maxLine = 75
+
+// line writes a content line, folded so that no line is longer than 75 octets.
+func (w *writer) line(name, value string) {
+	l := name + ":" + value
+
+	n := cut(l, maxLine)
+	w.WriteString(l[:n])
+	// the following lines start with a space, which counts, too
+	for l = l[n:]; l != ""; l = l[n:] {
+		n = cut(l, maxLine-1)
+		w.WriteString("\r\n " + l[:n])
+	}
+	w.WriteString("\r\n")
+}
+
+// cut returns where to cut the string so that the first part has at most n octets and no character is split.
+func cut(s string, n int) int {
+	if len(s) <= n {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// escape escapes a text value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// utc returns the time in UTC in the format of a DATE-TIME value.
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// date returns the date in the format of a DATE value.
+func date(d civil.Date) string {
+	return fmt.Sprintf("%04d%02d%02d", d.Year, d.Month, d.Day)
+}
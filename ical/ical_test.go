@@ -0,0 +1,94 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestMarshal(t *testing.T) {
+	now := time.Date(2020, 11, 30, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2020, 12, 1, 18, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	c := Calendar{
+		Name: "Pilates, Yoga",
+		Events: []Event{
+			{UID: "1-2020-12-01@test", Summary: "Pilates", Location: "Hall; 1st floor", Description: "Instructor: Ann",
+				Start: start, End: start.Add(time.Hour)},
+			{UID: "2-2020-12-02@test", Summary: "Yoga", Date: civil.Date{Year: 2020, Month: 12, Day: 2}, Cancelled: true},
+		},
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + prodID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		`X-WR-CALNAME:Pilates\, Yoga`,
+		"BEGIN:VEVENT",
+		"UID:1-2020-12-01@test",
+		"DTSTAMP:20201130T120000Z",
+		"DTSTART:20201201T173000Z",
+		"DTEND:20201201T183000Z",
+		"SUMMARY:Pilates",
+		"DESCRIPTION:Instructor: Ann",
+		`LOCATION:Hall\; 1st floor`,
+		"STATUS:CONFIRMED",
+		"SEQUENCE:0",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:2-2020-12-02@test",
+		"DTSTAMP:20201130T120000Z",
+		"DTSTART;VALUE=DATE:20201202",
+		"DTEND;VALUE=DATE:20201203",
+		"SUMMARY:Yoga",
+		"STATUS:CANCELLED",
+		"SEQUENCE:1",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	if got := string(c.Marshal(now)); got != want {
+		t.Errorf("wrong calendar, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	tables := []struct {
+		value string
+	}{
+		{"short"},
+		{strings.Repeat("a", 200)},
+		{strings.Repeat("ä", 100)},
+		{"multiple\nlines, with; special \\ characters"},
+	}
+
+	for _, table := range tables {
+		var w writer
+		w.line("DESCRIPTION", escape(table.value))
+		folded := w.String()
+
+		lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+		for i, l := range lines {
+			if len(l) > maxLine {
+				t.Errorf("line %d has %d octets: %q", i, len(l), l)
+			}
+			if i > 0 && !strings.HasPrefix(l, " ") {
+				t.Errorf("continued line %d doesn't start with a space: %q", i, l)
+			}
+		}
+
+		// unfolding gives back the line
+		unfolded := strings.ReplaceAll(folded, "\r\n ", "")
+		if unfolded != "DESCRIPTION:"+escape(table.value)+"\r\n" {
+			t.Errorf("unfolding didn't give back the line, got: %q", unfolded)
+		}
+		if strings.Contains(strings.TrimSuffix(unfolded, "\r\n"), "\n") {
+			t.Errorf("line break in value wasn't escaped: %q", unfolded)
+		}
+	}
+}
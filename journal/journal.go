@@ -0,0 +1,541 @@
+// Package journal keeps every change to the courses and their bookings as an ordered event in an append-only log, so that the courses can be rebuilt after a restart.
+// So are the changes to what was paid for them: the ledger, the passes and the promo codes, and the revocations of the feeds of members.
+//
+// The log is a directory of segments, files with one event per line in the order the changes were made.
+// The events add up to the state of all courses and records. From time to time, that state is written to a snapshot and the segments before it are moved to
+// the archive, so that opening the journal only replays the events since the last snapshot (see SnapshotEvery). The archive keeps the whole history.
+//
+// A change is appended before it is made: if it can't be written down, it isn't made.
+// Until a journal is opened, nothing is written down and Append does nothing.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+// A Kind describes what changed.
+type Kind string
+
+// the kinds of events
+const (
+	CourseCreated    Kind = "course-created"    // a course was added to a registry
+	CapacityChanged  Kind = "capacity-changed"  // the capacity of a course changed
+	ClassBooked      Kind = "class-booked"      // a member booked a class
+	BookingCancelled Kind = "booking-cancelled" // a member cancelled a booking
+	ClassCancelled   Kind = "class-cancelled"   // the studio cancelled a class
+	RulesConfigured  Kind = "rules-configured"  // the booking rules of a course were set (see package rules)
+
+	// the kinds of events that set a record, or remove it if the event has none (see Records)
+	EntryRecorded Kind = "entry-recorded" // an entry was added to the ledger (see package ledger)
+	PassChanged   Kind = "pass-changed"   // a pass was issued or its credits changed (see package plans)
+	PassUsed      Kind = "pass-used"      // a pass was used to book a class, removed when it was given back
+	CodeChanged   Kind = "code-changed"   // a promo code was created, deactivated or redeemed (see package promos)
+	CodeRedeemed  Kind = "code-redeemed"  // how often a member redeemed a promo code
+	FirstClass    Kind = "first-class"    // a member had their first class, removed if the booking didn't go through
+	FeedRevoked   Kind = "feed-revoked"   // how often the feed of a member was revoked (see package calendars)
+)
+
+// records returns whether events of the kind set a record.
+func (k Kind) records() bool {
+	switch k {
+	case EntryRecorded, PassChanged, PassUsed, CodeChanged, CodeRedeemed, FirstClass, FeedRevoked:
+		return true
+	}
+	return false
+}
+
+// An Event is a change to a course or one of its classes, or to a record.
+type Event struct {
+	Seq  uint64 // the position in the log, starting at 1
+	Time time.Time
+	Kind Kind
+
+	Course     uint64
+	Date       *civil.Date     `json:",omitempty"` // of the class, nil for events of the whole course
+	Member     string          `json:",omitempty"` // who booked or cancelled
+	Capacity   int             `json:",omitempty"` // the new capacity
+	Registry   string          `json:",omitempty"` // the name of the registry the course was added to, empty for the default registry
+	Definition *Course         `json:",omitempty"` // the course that was created
+	Rules      json.RawMessage `json:",omitempty"` // the new rules, empty if the course has none
+	Key        string          `json:",omitempty"` // of the record, unique among those of its kind
+	Record     json.RawMessage `json:",omitempty"` // the new record, empty if it is removed
+}
+
+// A Course is how a course was defined when it was created.
+type Course struct {
+	ID          uint64
+	Name        string
+	Start       civil.Date
+	End         civil.Date
+	Capacity    int
+	At          civil.Time
+	Duration    time.Duration `json:",omitempty"`
+	MembersOnly bool          `json:",omitempty"`
+	Price       money.Amount
+	ClassPrices map[civil.Date]money.Amount `json:",omitempty"` // of the classes that have their own price
+	Instructor  string                      `json:",omitempty"`
+	Location    string                      `json:",omitempty"`
+}
+
+// A Class is the state of a class that was booked or cancelled.
+type Class struct {
+	Attendees    []string `json:",omitempty"`
+	Cancelled    bool     `json:",omitempty"`
+	CancelledFor []string `json:",omitempty"` // the attendees when the studio cancelled the class
+	Withdrawn    []string `json:",omitempty"` // those that cancelled their booking and didn't book again
+}
+
+// A State is the state of a course that the events add up to.
+type State struct {
+	Course
+	Registry string                `json:",omitempty"`
+	Classes  map[civil.Date]*Class `json:",omitempty"` // only those that were booked or cancelled
+}
+
+// SnapshotEvery is after how many events a snapshot is taken.
+var SnapshotEvery = 1000
+
+// the names of the files in the directory
+const (
+	snapshotFile  = "snapshot.json"
+	segmentPrefix = "events-"
+	segmentSuffix = ".jsonl"
+	archiveDir    = "archive" // where the segments before the snapshot are kept
+)
+
+// a state is what the events add up to
+type state struct {
+	courses map[uint64]*State
+	rules   map[uint64]json.RawMessage          // by course ID
+	records map[Kind]map[string]json.RawMessage // by kind and key
+}
+
+// newState returns the state before any event.
+func newState() state {
+	return state{make(map[uint64]*State), make(map[uint64]json.RawMessage), make(map[Kind]map[string]json.RawMessage)}
+}
+
+// a snapshot is the state after all events up to its sequence number, as written to the file
+type snapshot struct {
+	Seq     uint64
+	Courses []*State
+	Rules   map[uint64]json.RawMessage          `json:",omitempty"`
+	Records map[Kind]map[string]json.RawMessage `json:",omitempty"`
+}
+
+var (
+	// where the journal is kept, empty if it isn't open
+	dir string
+	// the segment the events are appended to and its size, nil if the journal isn't open
+	out  *os.File
+	size int64
+
+	// the state the events add up to, only kept while the journal is open
+	current state = newState()
+
+	// the sequence number of the last event and the number of events since the last snapshot
+	seq   uint64
+	since int
+
+	// protect file and state with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Open replays the journal in the directory, which is created if needed, and appends every event from now on to it.
+// It then takes a snapshot, so that the next time only the events after it are replayed.
+//
+// If the last line of a segment is incomplete, e.g. because we stopped while writing it, it is ignored.
+func Open(path string) error {
+	if err := os.MkdirAll(filepath.Join(path, archiveDir), 0o755); err != nil {
+		return err
+	}
+
+	st, last, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	reset()
+	dir, current, seq = path, st, last
+	if err := takeSnapshot(); err != nil {
+		reset()
+		return err
+	}
+	return nil
+}
+
+// Close stops appending events and forgets the state.
+func Close() error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	return reset()
+}
+
+// reset closes the segment, if any, and forgets the state. The caller holds the lock.
+func reset() error {
+	var err error
+	if out != nil {
+		err = out.Close()
+	}
+	dir, out, size, seq, since = "", nil, 0, 0, 0
+	current = newState()
+	return err
+}
+
+// Recording returns whether a journal is open, i.e. whether the events are written down.
+func Recording() bool {
+	mux.Lock()
+	defer mux.Unlock()
+
+	return out != nil
+}
+
+// Append writes the events down, all or none of them, in the given order. Nothing happens if no journal is open.
+// Each event gets its sequence number and time.
+func Append(es ...Event) error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if out == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	now := time.Now()
+	for i := range es {
+		es[i].Seq, es[i].Time = seq+uint64(i)+1, now
+		if err := enc.Encode(es[i]); err != nil {
+			return fmt.Errorf("journal: %w", err)
+		}
+	}
+
+	n, err := out.WriteString(b.String())
+	if err == nil {
+		err = out.Sync()
+	}
+	if err != nil {
+		// don't leave part of the events behind, later ones would follow them
+		if tErr := out.Truncate(size); tErr != nil {
+			err = fmt.Errorf("%w (and %s)", err, tErr)
+		}
+		return fmt.Errorf("journal: %w", err)
+	}
+	size += int64(n)
+
+	for _, e := range es {
+		current.apply(e)
+	}
+	seq += uint64(len(es))
+	since += len(es)
+
+	if since >= SnapshotEvery {
+		// the events were written down, so they can't fail because the snapshot couldn't be
+		if err := takeSnapshot(); err != nil {
+			log.Printf("journal: snapshot: %s", err)
+		}
+	}
+	return nil
+}
+
+// Courses returns the state of the courses the events add up to, sorted by ID.
+// Like this, the courses are rebuilt after the journal was opened (see courses.Restore).
+func Courses() []State {
+	mux.Lock()
+	defer mux.Unlock()
+
+	ss := make([]State, 0, len(current.courses))
+	for _, c := range current.courses {
+		s := *c
+		s.Classes = make(map[civil.Date]*Class, len(c.Classes))
+		for d, cl := range c.Classes {
+			s.Classes[d] = &Class{
+				Attendees:    append([]string{}, cl.Attendees...),
+				Cancelled:    cl.Cancelled,
+				CancelledFor: append([]string{}, cl.CancelledFor...),
+				Withdrawn:    append([]string{}, cl.Withdrawn...)}
+		}
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].ID < ss[j].ID })
+	return ss
+}
+
+// Rules returns the rules of the courses the events add up to, by course ID (see rules.Restore).
+func Rules() map[uint64]json.RawMessage {
+	mux.Lock()
+	defer mux.Unlock()
+
+	rs := make(map[uint64]json.RawMessage, len(current.rules))
+	for id, r := range current.rules {
+		rs[id] = r
+	}
+	return rs
+}
+
+// Records returns the records of the kind the events add up to, by key (see e.g. plans.Restore).
+func Records(k Kind) map[string]json.RawMessage {
+	mux.Lock()
+	defer mux.Unlock()
+
+	rs := make(map[string]json.RawMessage, len(current.records[k]))
+	for key, r := range current.records[k] {
+		rs[key] = r
+	}
+	return rs
+}
+
+// LastID returns the highest ID of a course that was created or got rules, so that new courses don't reuse it.
+func LastID() uint64 {
+	mux.Lock()
+	defer mux.Unlock()
+
+	var last uint64
+	for id := range current.courses {
+		if id > last {
+			last = id
+		}
+	}
+	for id := range current.rules {
+		if id > last {
+			last = id
+		}
+	}
+	return last
+}
+
+// apply changes the state according to the event. Events of courses that weren't created are ignored.
+func (st state) apply(e Event) {
+	if e.Kind.records() {
+		rs := st.records[e.Kind]
+		if rs == nil {
+			rs = make(map[string]json.RawMessage)
+			st.records[e.Kind] = rs
+		}
+		if len(e.Record) == 0 || string(e.Record) == "null" {
+			delete(rs, e.Key)
+		} else {
+			rs[e.Key] = e.Record
+		}
+		return
+	}
+
+	switch e.Kind {
+	case RulesConfigured:
+		if len(e.Rules) == 0 || string(e.Rules) == "null" {
+			delete(st.rules, e.Course)
+		} else {
+			st.rules[e.Course] = e.Rules
+		}
+		return
+	case CourseCreated:
+		if e.Definition != nil {
+			st.courses[e.Course] = &State{Course: *e.Definition, Registry: e.Registry}
+		}
+		return
+	}
+
+	c, ok := st.courses[e.Course]
+	if !ok {
+		return
+	}
+	if e.Kind == CapacityChanged {
+		c.Capacity = e.Capacity
+		return
+	}
+	if e.Date == nil {
+		return
+	}
+
+	if c.Classes == nil {
+		c.Classes = make(map[civil.Date]*Class)
+	}
+	cl, ok := c.Classes[*e.Date]
+	if !ok {
+		cl = &Class{}
+		c.Classes[*e.Date] = cl
+	}
+
+	switch e.Kind {
+	case ClassBooked:
+		cl.Attendees = append(cl.Attendees, e.Member)
+		cl.Withdrawn = without(cl.Withdrawn, e.Member)
+	case BookingCancelled:
+		for i, a := range cl.Attendees {
+			if a == e.Member {
+				cl.Attendees = append(cl.Attendees[:i], cl.Attendees[i+1:]...)
+				cl.Withdrawn = append(cl.Withdrawn, e.Member)
+				break
+			}
+		}
+	case ClassCancelled:
+		cl.Cancelled, cl.CancelledFor, cl.Attendees = true, cl.Attendees, nil
+	}
+}
+
+// without returns the names without the name.
+func without(names []string, name string) []string {
+	for i, n := range names {
+		if n == name {
+			return append(names[:i], names[i+1:]...)
+		}
+	}
+	return names
+}
+
+// load returns the state of the journal in the directory, i.e. the snapshot, if any, with the events of the segments after it applied,
+// and the sequence number of the last event.
+func load(path string) (state, uint64, error) {
+	var s snapshot
+	switch b, err := os.ReadFile(filepath.Join(path, snapshotFile)); {
+	case os.IsNotExist(err):
+	case err != nil:
+		return state{}, 0, err
+	default:
+		if err := json.Unmarshal(b, &s); err != nil {
+			return state{}, 0, fmt.Errorf("%s: %w", snapshotFile, err)
+		}
+	}
+
+	st := newState()
+	for _, c := range s.Courses {
+		st.courses[c.ID] = c
+	}
+	for id, r := range s.Rules {
+		st.rules[id] = r
+	}
+	for k, rs := range s.Records {
+		st.records[k] = rs
+	}
+
+	segments, err := segmentsIn(path)
+	if err != nil {
+		return state{}, 0, err
+	}
+	for _, name := range segments {
+		if err := st.replay(name, &s.Seq); err != nil {
+			return state{}, 0, err
+		}
+	}
+	return st, s.Seq, nil
+}
+
+// replay applies the events of the segment after the sequence number, which is then that of the last event.
+func (st state) replay(name string, last *uint64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// an incomplete line is only an error if another one follows it
+	var incomplete error
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for n := 1; sc.Scan(); n++ {
+		if incomplete != nil {
+			return incomplete
+		}
+
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			incomplete = fmt.Errorf("%s, line %d: %w", filepath.Base(name), n, err)
+			continue
+		}
+		switch {
+		case e.Seq <= *last:
+			// already in the snapshot
+			continue
+		case e.Seq != *last+1:
+			return fmt.Errorf("%s, line %d: event %d follows event %d", filepath.Base(name), n, e.Seq, *last)
+		}
+		st.apply(e)
+		*last = e.Seq
+	}
+	return sc.Err()
+}
+
+// segmentsIn returns the paths of the segments in the directory, oldest first.
+func segmentsIn(path string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(path, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	// the names contain the zero-padded sequence number of their first event
+	sort.Strings(names)
+	return names, nil
+}
+
+// takeSnapshot writes the state to the snapshot and archives the segments, so that a new one gets the events after it.
+// If that fails, the events are still appended to the current segment. The caller holds the lock.
+func takeSnapshot() error {
+	s := snapshot{Seq: seq, Courses: make([]*State, 0, len(current.courses)), Rules: current.rules, Records: current.records}
+	for _, c := range current.courses {
+		s.Courses = append(s.Courses, c)
+	}
+	sort.Slice(s.Courses, func(i, j int) bool { return s.Courses[i].ID < s.Courses[j].ID })
+
+	// replace the snapshot at once, so that it is complete even if we stop while writing
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(tmp).Encode(s)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, snapshotFile))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// all events so far are in the snapshot, so the segments with them are no longer replayed
+	old, err := segmentsIn(dir)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, seq+1, segmentSuffix))
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		out.Close()
+	}
+	out, size, since = f, 0, 0
+
+	for _, o := range old {
+		if o == name {
+			continue
+		}
+		if err := os.Rename(o, filepath.Join(dir, archiveDir, filepath.Base(o))); err != nil {
+			// replaying it skips the events in the snapshot
+			return err
+		}
+	}
+	return nil
+}
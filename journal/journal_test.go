@@ -0,0 +1,241 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+var today = civil.DateOf(time.Now())
+
+// events returns the events of a course with two booked classes, one of them cancelled.
+func events(id uint64) []Event {
+	tomorrow, later := today.AddDays(1), today.AddDays(2)
+	return []Event{
+		{Kind: RulesConfigured, Course: id, Rules: json.RawMessage(`[{"Kind":"weekly-limit","Rule":{"Max":2}}]`)},
+		{Kind: CourseCreated, Course: id, Registry: "downtown", Definition: &Course{
+			ID: id, Name: "Yoga", Start: today, End: later, Capacity: 10, Duration: time.Hour,
+			Price:       money.New(1200, "EUR"),
+			ClassPrices: map[civil.Date]money.Amount{later: money.New(1500, "EUR")}}},
+		{Kind: ClassBooked, Course: id, Date: &tomorrow, Member: "Arnold"},
+		{Kind: ClassBooked, Course: id, Date: &tomorrow, Member: "Bruce"},
+		{Kind: ClassBooked, Course: id, Date: &later, Member: "Chuck"},
+		{Kind: BookingCancelled, Course: id, Date: &tomorrow, Member: "Arnold"},
+		{Kind: ClassCancelled, Course: id, Date: &later},
+		{Kind: CapacityChanged, Course: id, Capacity: 12},
+		// of a course that wasn't created, e.g. in a test
+		{Kind: ClassBooked, Course: id + 1, Date: &tomorrow, Member: "Arnold"},
+	}
+}
+
+// check checks that the state is that of the events of the course.
+func check(t *testing.T, id uint64) {
+	t.Helper()
+
+	cs := Courses()
+	if len(cs) != 1 {
+		t.Fatalf("got %d courses, want: 1", len(cs))
+	}
+	c := cs[0]
+	if c.ID != id || c.Registry != "downtown" || c.Capacity != 12 || c.Duration != time.Hour || c.ClassPrices[today.AddDays(2)].Minor != 1500 {
+		t.Errorf("wrong course: %+v", c)
+	}
+	if want := (Class{Attendees: []string{"Bruce"}, CancelledFor: []string{}, Withdrawn: []string{"Arnold"}}); !reflect.DeepEqual(*c.Classes[today.AddDays(1)], want) {
+		t.Errorf("wrong class tomorrow: %+v, want: %+v", *c.Classes[today.AddDays(1)], want)
+	}
+	if want := (Class{Attendees: []string{}, Cancelled: true, CancelledFor: []string{"Chuck"}, Withdrawn: []string{}}); !reflect.DeepEqual(*c.Classes[today.AddDays(2)], want) {
+		t.Errorf("wrong class after tomorrow: %+v, want: %+v", *c.Classes[today.AddDays(2)], want)
+	}
+
+	if rs := Rules(); len(rs) != 1 || string(rs[id]) != `[{"Kind":"weekly-limit","Rule":{"Max":2}}]` {
+		t.Errorf("wrong rules: %v", rs)
+	}
+	if LastID() != id {
+		t.Errorf("last ID is %d, want: %d", LastID(), id)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+
+	es := events(3)
+	if err := Append(es[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es[2:] {
+		if err := Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(t, 3)
+
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if Recording() || len(Courses()) != 0 {
+		t.Fatal("journal still open")
+	}
+	// nothing is written down
+	if err := Append(events(5)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	check(t, 3)
+
+	// events are numbered on
+	e := Event{Kind: CapacityChanged, Course: 3, Capacity: 12}
+	if err := Append(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	segments, _ := segmentsIn(dir)
+	b, _ := os.ReadFile(segments[len(segments)-1])
+	if err := json.Unmarshal(b, &e); err != nil || e.Seq != uint64(len(es)+1) {
+		t.Errorf("wrong sequence number %d (%v), want: %d", e.Seq, err, len(es)+1)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	defer func(n int) { SnapshotEvery = n }(SnapshotEvery)
+	SnapshotEvery = 4
+
+	dir := t.TempDir()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+
+	for _, e := range events(7) {
+		if err := Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a snapshot was taken after 4 and 8 events, so only the last event is in a segment
+	segments, err := segmentsIn(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || filepath.Base(segments[0]) != "events-00000000000000000009.jsonl" {
+		t.Fatalf("wrong segments: %v", segments)
+	}
+
+	// the segments before are archived with all events
+	archived, err := segmentsIn(filepath.Join(dir, archiveDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines int
+	for _, name := range archived {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines += strings.Count(string(b), "\n")
+	}
+	if lines != 8 {
+		t.Errorf("%d events are archived in %v, want: 8", lines, archived)
+	}
+
+	Close()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	check(t, 7)
+}
+
+func TestRecords(t *testing.T) {
+	defer func(n int) { SnapshotEvery = n }(SnapshotEvery)
+	SnapshotEvery = 3
+
+	dir := t.TempDir()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+
+	for _, e := range []Event{
+		{Kind: PassChanged, Key: "1", Record: json.RawMessage(`{"Credits":10}`)},
+		{Kind: PassUsed, Key: "3/Arnold", Record: json.RawMessage(`{"Pass":1}`)},
+		{Kind: PassChanged, Key: "1", Record: json.RawMessage(`{"Credits":9}`)},
+		{Kind: PassChanged, Key: "2", Record: json.RawMessage(`{"Credits":5}`)},
+		// removed
+		{Kind: PassUsed, Key: "3/Arnold"},
+	} {
+		if err := Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// as after a restart, from the snapshot and a segment
+	Close()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if rs := Records(PassChanged); len(rs) != 2 || string(rs["1"]) != `{"Credits":9}` || string(rs["2"]) != `{"Credits":5}` {
+		t.Errorf("wrong passes: %s", rs)
+	}
+	if rs := Records(PassUsed); len(rs) != 0 {
+		t.Errorf("removed record is still there: %s", rs)
+	}
+	if len(Courses()) != 0 || LastID() != 0 {
+		t.Errorf("records changed the courses")
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	dir := t.TempDir()
+	if err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+
+	for _, e := range events(9) {
+		if err := Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Close()
+
+	segments, _ := segmentsIn(dir)
+	f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// we stopped while writing
+	f.WriteString(`{"Seq":10,"Kind":"class-bo`)
+	f.Close()
+
+	if err := Open(dir); err != nil {
+		t.Fatalf("incomplete last line: %s", err)
+	}
+	check(t, 9)
+	Close()
+
+	// an incomplete line followed by another one is an error
+	line := `{"Seq":10,"Kind":"capacity-changed","Course":9,"Capacity":5}`
+	name := filepath.Join(dir, "events-00000000000000000010.jsonl")
+	if err := os.WriteFile(name, []byte(`{"Seq":10,"Kind":"class-bo`+"\n"+line+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(dir); err == nil {
+		t.Error("no error for an incomplete line in the middle of a segment")
+	}
+}
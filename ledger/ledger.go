@@ -0,0 +1,157 @@
+// Package ledger records what happened to the money and credits of each booking.
+//
+// The ledger is append-only: entries are never changed or removed. A refund, for example, is a new entry for the booking.
+// The entries are kept in the journal, if one is open (see Restore).
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+)
+
+// A Booking identifies the booking of a member for the class of a course on a given date.
+type Booking struct {
+	Course uint64
+	Member string
+	Date   civil.Date
+}
+
+// A Kind describes what an entry records.
+type Kind string
+
+// the kinds of entries
+const (
+	KindCharge          Kind = "charge"           // the customer paid for the class
+	KindRefund          Kind = "refund"           // (part of) the payment was given back
+	KindFee             Kind = "fee"              // (part of) the payment was kept as a cancellation fee
+	KindCreditUsed      Kind = "credit-used"      // a credit of a pass was used for the class
+	KindCreditReturned  Kind = "credit-returned"  // the credit was given back to the pass
+	KindCreditForfeited Kind = "credit-forfeited" // the credit was not given back because the cancellation was too late
+)
+
+// An Entry is a single record in the ledger.
+type Entry struct {
+	ID      uint64
+	Time    time.Time
+	Booking Booking
+	Kind    Kind
+	Amount  money.Amount      // for charges, refunds and fees
+	Receipt *payments.Receipt `json:",omitempty"` // for charges
+	Pass    uint64            `json:",omitempty"` // for credits
+	Note    string            `json:",omitempty"`
+}
+
+var (
+	// all entries, in the order they were recorded
+	entries []Entry = make([]Entry, 0)
+	// the indices of the entries of each booking
+	byBooking map[Booking][]int = make(map[Booking][]int)
+
+	// protect list and map with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Record adds an entry to the ledger and returns it with its ID and time.
+//
+// The entry records what already happened, e.g. a payment, so it is added even if it can't be written to the journal. That is logged.
+func Record(e Entry) Entry {
+	mux.Lock()
+	defer mux.Unlock()
+
+	e.ID = uint64(len(entries) + 1)
+	e.Time = time.Now()
+
+	if err := journalEntry(e); err != nil {
+		log.Printf("ledger: entry %d is not in the journal: %s", e.ID, err)
+	}
+	add(e)
+
+	return e
+}
+
+// journalEntry appends the entry to the journal, without the payment token of the customer.
+func journalEntry(e Entry) error {
+	if e.Receipt != nil {
+		r := *e.Receipt
+		r.Charge.Token = ""
+		e.Receipt = &r
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return journal.Append(journal.Event{Kind: journal.EntryRecorded, Key: strconv.FormatUint(e.ID, 10), Record: b})
+}
+
+// add adds the entry with its ID and time. The caller holds the lock.
+func add(e Entry) {
+	byBooking[e.Booking] = append(byBooking[e.Booking], len(entries))
+	entries = append(entries, e)
+}
+
+// Restore adds the entries of the journal, which has to be open, to the ledger. They are not journaled again.
+func Restore() error {
+	es := make([]Entry, 0)
+	for key, raw := range journal.Records(journal.EntryRecorded) {
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return fmt.Errorf("ledger entry %s: %w", key, err)
+		}
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].ID < es[j].ID })
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	for _, e := range es {
+		if e.ID != uint64(len(entries)+1) {
+			return fmt.Errorf("ledger entry %d follows entry %d", e.ID, len(entries))
+		}
+		add(e)
+	}
+	return nil
+}
+
+// Of returns all entries of a booking, in the order they were recorded.
+func Of(b Booking) []Entry {
+	mux.Lock()
+	defer mux.Unlock()
+
+	es := make([]Entry, len(byBooking[b]))
+	for i, idx := range byBooking[b] {
+		es[i] = entries[idx]
+	}
+	return es
+}
+
+// OpenCharge returns the last charge of a booking, unless it has been settled by a refund or fee since.
+func OpenCharge(b Booking) (Entry, bool) {
+	var (
+		charge Entry
+		open   bool
+	)
+
+	for _, e := range Of(b) {
+		switch e.Kind {
+		case KindCharge:
+			charge, open = e, true
+		case KindRefund, KindFee:
+			open = false
+		}
+	}
+
+	return charge, open
+}
@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+)
+
+func TestRecord(t *testing.T) {
+	b := Booking{1, "Arnold", civil.DateOf(time.Now())}
+	other := Booking{2, "Arnold", civil.DateOf(time.Now())}
+
+	if _, open := OpenCharge(b); open {
+		t.Errorf("booking without entries has open charge")
+	}
+
+	first := Record(Entry{Booking: b, Kind: KindCharge, Amount: money.New(1000, "EUR")})
+	Record(Entry{Booking: other, Kind: KindCreditUsed, Pass: 1})
+	second := Record(Entry{Booking: b, Kind: KindRefund, Amount: money.New(1000, "EUR")})
+
+	if second.ID <= first.ID {
+		t.Errorf("IDs not increasing: %d, %d", first.ID, second.ID)
+	}
+
+	if es := Of(b); len(es) != 2 || es[0].ID != first.ID || es[1].ID != second.ID {
+		t.Errorf("wrong entries of booking: %+v", es)
+	}
+
+	if _, open := OpenCharge(b); open {
+		t.Errorf("refunded charge is still open")
+	}
+
+	// booked again
+	third := Record(Entry{Booking: b, Kind: KindCharge, Amount: money.New(1200, "EUR")})
+	if e, open := OpenCharge(b); !open || e.ID != third.ID {
+		t.Errorf("new charge is not open")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	// the journal is open from the start
+	entries, byBooking = make([]Entry, 0), make(map[Booking][]int)
+	dir := t.TempDir()
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	b := Booking{3, "Bruce", civil.DateOf(time.Now())}
+	receipt := &payments.Receipt{ID: "r1", Charge: payments.Charge{Member: "Bruce", Amount: money.New(1500, "EUR"), Token: "secret"}}
+	charge := Record(Entry{Booking: b, Kind: KindCharge, Amount: money.New(1500, "EUR"), Receipt: receipt})
+	Record(Entry{Booking: Booking{3, "Chuck", b.Date}, Kind: KindCreditUsed, Pass: 1})
+
+	// as after a restart
+	journal.Close()
+	entries, byBooking = make([]Entry, 0), make(map[Booking][]int)
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, open := OpenCharge(b)
+	if !open || e.ID != charge.ID || e.Receipt == nil || e.Receipt.ID != "r1" {
+		t.Fatalf("charge wasn't restored: %+v", e)
+	}
+	if e.Receipt.Charge.Token != "" {
+		t.Errorf("the payment token was journaled")
+	}
+	if next := Record(Entry{Booking: b, Kind: KindRefund, Amount: money.New(1500, "EUR")}); next.ID != 3 {
+		t.Errorf("entry after restoring has ID %d, want: 3", next.ID)
+	}
+}
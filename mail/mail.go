@@ -0,0 +1,120 @@
+// Package mail defines how we send emails to customers.
+//
+// The actual way of sending is hidden behind the Mailer interface: package mail/smtp sends through an SMTP server, package mail/outbox keeps the emails, for tests and local development.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotConfigured is returned if no mailer is in use.
+var ErrNotConfigured = errors.New("emails are not available at the moment")
+
+// A Message is a plain text email to a customer.
+type Message struct {
+	To      string // the address, e.g. 'arnold@example.com'
+	Subject string
+	Body    string
+}
+
+// A Mailer sends emails. Sending may be slow, so it must respect the context.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+var (
+	// the mailer in use
+	mailer Mailer
+
+	// protect mailer with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Use sets the mailer through which all emails are sent.
+func Use(m Mailer) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	mailer = m
+}
+
+// current returns the mailer in use.
+func current() (Mailer, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if mailer == nil {
+		return nil, ErrNotConfigured
+	}
+	return mailer, nil
+}
+
+// Send sends the email through the mailer in use.
+func Send(ctx context.Context, m Message) error {
+	if _, err := ParseAddress(m.To); err != nil {
+		return err
+	}
+
+	ml, err := current()
+	if err != nil {
+		return err
+	}
+	return ml.Send(ctx, m)
+}
+
+// ParseAddress returns the bare address of e.g. 'Arnold <arnold@example.com>' or an error, if it isn't an email address.
+func ParseAddress(s string) (string, error) {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid email address", s)
+	}
+	return a.Address, nil
+}
+
+// Format returns the email from the sender as sent over the wire (RFC 5322), e.g. to an SMTP server or into a file.
+// The subject is encoded for non-ASCII characters and the body as quoted-printable UTF-8 text with CRLF line endings.
+func Format(from string, m Message, date time.Time) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimSuffix(d, ">")
+	}
+
+	var b bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	body := strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
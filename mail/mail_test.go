@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAddress(t *testing.T) {
+	for _, tc := range []struct {
+		s, want string
+	}{
+		{"arnold@example.com", "arnold@example.com"},
+		{"Arnold <arnold@example.com>", "arnold@example.com"},
+		{"arnold", ""},
+		{"", ""},
+	} {
+		got, err := ParseAddress(tc.s)
+		if got != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("%q: got %q (%v), want: %q", tc.s, got, err, tc.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	if err := Send(context.Background(), Message{To: "arnold@example.com"}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("expected emails not to be available, got: %v", err)
+	}
+	if err := Send(context.Background(), Message{To: "arnold"}); err == nil {
+		t.Errorf("expected an invalid address")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2026, 10, 19, 18, 30, 0, 0, time.UTC)
+	b, err := Format("Downtown Yoga <bookings@example.com>", Message{
+		To:      "arnold@example.com",
+		Subject: "Your booking for Yoga für alle",
+		Body:    "Hi Arnold,\nsee you there!\n",
+	}, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+
+	for _, want := range []string{
+		"From: Downtown Yoga <bookings@example.com>\r\n",
+		"To: arnold@example.com\r\n",
+		"Subject: =?utf-8?q?Your_booking_for_Yoga_f=C3=BCr_alle?=\r\n",
+		"Date: Mon, 19 Oct 2026 18:30:00 +0000\r\n",
+		"@example.com>\r\n",
+		"\r\n\r\nHi Arnold,\r\nsee you there!\r\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %q in:\n%s", want, s)
+		}
+	}
+}
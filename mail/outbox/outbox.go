@@ -0,0 +1,78 @@
+// Package outbox implements a mailer that keeps the emails instead of sending them, for tests and local development.
+//
+// If it has a directory, it also writes every email there as a file (e.g. '0001.eml'), which mail apps open.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/mail"
+)
+
+// From is the sender of the emails written to files.
+const From = "Booking System <bookings@localhost>"
+
+// Outbox is a mailer that keeps the emails it is given.
+type Outbox struct {
+	// Dir is where the emails are written as files, none are written if empty.
+	Dir string
+
+	messages []mail.Message
+
+	// protect messages with mutex
+	mux sync.Mutex
+}
+
+// New returns an outbox that writes the emails to the directory, if it isn't empty.
+func New(dir string) *Outbox {
+	return &Outbox{Dir: dir, messages: make([]mail.Message, 0)}
+}
+
+// Send implements the mail.Mailer interface.
+func (o *Outbox) Send(ctx context.Context, m mail.Message) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if o.Dir != "" {
+		b, err := mail.Format(From, m, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+			return err
+		}
+		name := filepath.Join(o.Dir, fmt.Sprintf("%04d.eml", len(o.messages)+1))
+		if err := os.WriteFile(name, b, 0o644); err != nil {
+			return err
+		}
+	}
+
+	o.messages = append(o.messages, m)
+	return nil
+}
+
+// Messages returns the emails in the order they were sent.
+func (o *Outbox) Messages() []mail.Message {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return append([]mail.Message{}, o.messages...)
+}
+
+// Wait returns the emails as soon as there are at least n, or an error if that takes longer than the timeout, e.g. because they are sent in the background.
+func (o *Outbox) Wait(n int, timeout time.Duration) ([]mail.Message, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ms := o.Messages(); len(ms) >= n {
+			return ms, nil
+		} else if time.Now().After(deadline) {
+			return ms, fmt.Errorf("got %d emails in %s, want: %d", len(ms), timeout, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/mail"
+)
+
+func TestSend(t *testing.T) {
+	o := New(t.TempDir())
+
+	go func() {
+		for _, to := range []string{"arnold@example.com", "maria@example.com"} {
+			if err := o.Send(context.Background(), mail.Message{To: to, Subject: "Hi", Body: "Hello!\n"}); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	ms, err := o.Wait(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms[0].To != "arnold@example.com" || ms[1].To != "maria@example.com" {
+		t.Errorf("wrong emails: %v", ms)
+	}
+
+	b, err := os.ReadFile(filepath.Join(o.Dir, "0002.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "To: maria@example.com\r\n") {
+		t.Errorf("wrong file:\n%s", b)
+	}
+
+	if _, err := o.Wait(3, 20*time.Millisecond); err == nil {
+		t.Errorf("expected to wait in vain for a third email")
+	}
+}
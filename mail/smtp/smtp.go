@@ -0,0 +1,59 @@
+// Package smtp implements a mailer that sends emails through an SMTP server, e.g. that of an email provider.
+package smtp
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/mail"
+)
+
+// Mailer sends emails through an SMTP server.
+type Mailer struct {
+	Addr string    // of the server, e.g. 'smtp.example.com:587'
+	Auth smtp.Auth // nil if the server doesn't need it
+	From string    // the sender, e.g. 'Downtown Yoga <bookings@example.com>'
+}
+
+// New returns a mailer that sends emails from the sender through the server at the address.
+// If a user is given, it logs in with the password, which the server only gets over TLS (or on localhost).
+func New(addr, user, password, from string) *Mailer {
+	m := &Mailer{Addr: addr, From: from}
+	if user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		m.Auth = smtp.PlainAuth("", user, password, host)
+	}
+	return m
+}
+
+// Send implements the mail.Mailer interface.
+// The server may still be sending when the context is done, but then we don't wait for it.
+func (m *Mailer) Send(ctx context.Context, msg mail.Message) error {
+	b, err := mail.Format(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+
+	// buffered, so that the goroutine can finish even if we stopped waiting
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- smtp.SendMail(m.Addr, m.Auth, from, []string{msg.To}, b)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
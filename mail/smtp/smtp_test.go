@@ -0,0 +1,128 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/mail"
+)
+
+// server is a minimal SMTP server on localhost that receives a single email.
+type server struct {
+	ln       net.Listener
+	from, to string
+	data     string
+	done     chan struct{}
+}
+
+func newServer(t *testing.T) *server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *server) serve() {
+	defer close(s.done)
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	reply := func(format string, a ...interface{}) {
+		_ = tp.PrintfLine(format, a...)
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 authenticated")
+		case "MAIL":
+			s.from = line
+			reply("250 OK")
+		case "RCPT":
+			s.to = line
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			b, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(b)
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	s := newServer(t)
+	m := New(s.ln.Addr().String(), "bookings", "secret", "Downtown Yoga <bookings@example.com>")
+
+	if err := m.Send(context.Background(), mail.Message{To: "arnold@example.com", Subject: "Hi", Body: "Hello!\n"}); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	if !strings.HasPrefix(s.from, "MAIL FROM:<bookings@example.com>") {
+		t.Errorf("wrong sender: %s", s.from)
+	}
+	if s.to != "RCPT TO:<arnold@example.com>" {
+		t.Errorf("wrong recipient: %s", s.to)
+	}
+	if !strings.Contains(s.data, "Subject: Hi\n") || !strings.Contains(s.data, "Hello!") {
+		t.Errorf("wrong email:\n%s", s.data)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	// a server that never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	m := New(ln.Addr().String(), "", "", "bookings@example.com")
+	if err := m.Send(ctx, mail.Message{To: "arnold@example.com"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context to be done, got: %v", err)
+	}
+}
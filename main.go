@@ -1,28 +1,495 @@
 package main
 
 import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	apiaudit "github.com/MarkRosemaker/booking-system/api/audit"
 	"github.com/MarkRosemaker/booking-system/api/bookings"
+	"github.com/MarkRosemaker/booking-system/api/calendars"
 	"github.com/MarkRosemaker/booking-system/api/classes"
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/events"
+	"github.com/MarkRosemaker/booking-system/api/exports"
+	"github.com/MarkRosemaker/booking-system/api/input"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/api/passes"
+	apipromos "github.com/MarkRosemaker/booking-system/api/promos"
+	"github.com/MarkRosemaker/booking-system/api/sessions"
+	"github.com/MarkRosemaker/booking-system/api/users"
+	apiwebhooks "github.com/MarkRosemaker/booking-system/api/webhooks"
+	"github.com/MarkRosemaker/booking-system/audit"
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/mail/outbox"
+	"github.com/MarkRosemaker/booking-system/mail/smtp"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/payments/fake"
+	"github.com/MarkRosemaker/booking-system/plans"
+	"github.com/MarkRosemaker/booking-system/promos"
+	"github.com/MarkRosemaker/booking-system/reminders"
+	"github.com/MarkRosemaker/booking-system/rules"
+	"github.com/MarkRosemaker/booking-system/tenants"
 	"github.com/MarkRosemaker/booking-system/tpl"
+	"github.com/MarkRosemaker/booking-system/webhooks"
 	"github.com/MarkRosemaker/go-server/server/api"
 
 	"github.com/MarkRosemaker/go-server/server"
 )
 
 func main() {
+	setupPayments()
+	setupAuth()
+	setupStudios()
+	setupJournal()
+	setupMail()
+	setupAudit()
+
+	// notify the subscribed systems, e.g. a CRM, of bookings and courses
+	webhooks.Start()
+
+	// remind members of the classes they booked
+	// which reminders were sent is kept in BOOKING_REMINDERS, so that none is sent twice after a restart
+	reminders.Start(os.Getenv("BOOKING_REMINDERS"))
+
+	// before we distinguished HTTP methods, '/classes' and '/bookings' accepted any method
+	// old clients can be supported by setting BOOKING_LEGACY_METHODS
+	legacy := os.Getenv("BOOKING_LEGACY_METHODS") != ""
+	if legacy {
+		log.Printf("BOOKING_LEGACY_METHODS set, '/classes' and '/bookings' ignore the HTTP method")
+	}
+
 	o := server.Options{
 		ContentSource:    "site",
 		TemplateDataFunc: tpl.DataFunc,
-		Endpoints: api.Endpoints{
-			api.BaseEndpoint{
-				URL:          "/classes",
-				ResponseFunc: classes.Respond},
-			api.BaseEndpoint{
-				URL:          "/bookings",
-				ResponseFunc: bookings.Respond},
-		},
-		Verbose: true,
+		Endpoints:        endpoints(legacy),
+		Verbose:          true,
 	}
 
 	server.Run(o)
 }
+
+// endpoints returns the endpoints of our API.
+// Those that act on the courses, passes and promo codes of a studio are also served at the paths of the studios, e.g. '/studios/downtown/v1/classes', and so are the pages in the folder 'site'.
+// If they change, their documentation in spec has to change, too.
+func endpoints(legacy bool) api.Endpoints {
+	admins := auth.Roles(auth.RoleAdmin)
+
+	// '/classes' and '/bookings' were served before versioning and behave like v1
+	// when v2 arrives, it gets its own paths, e.g. '/v2/bookings', while v1 stays as it is
+	classesV1, bookingsV1, classesImport := v1(legacy)
+	courseFeed, scheduleFeed, memberFeed, feedToken := calendarsV1()
+	courseExport, rosterExport, bookingExport := exportsV1()
+	live := eventsV1()
+	hooks, deadLetters := webhooksV1()
+	auditLog := auditV1()
+	passesOf, promosOf := salesV1()
+
+	// the endpoints that act on the courses, passes and promo codes of the studio the request is for
+	scoped := []scopedEndpoint{
+		{classesV1, classes.Doc},
+		{classesImport, classes.ImportDoc},
+		{bookingsV1, bookings.Doc},
+		{courseFeed, calendars.CourseDoc},
+		{scheduleFeed, calendars.ScheduleDoc},
+		{memberFeed, calendars.MemberDoc},
+		{feedToken, calendars.TokenDoc},
+		{courseExport, exports.CoursesDoc},
+		{rosterExport, exports.RostersDoc},
+		{bookingExport, exports.BookingsDoc},
+		{live, events.Doc},
+		{hooks, apiwebhooks.Doc},
+		{deadLetters, apiwebhooks.DeadLettersDoc},
+		{auditLog, apiaudit.Doc},
+		{passesOf, passes.Doc},
+		{promosOf, apipromos.Doc},
+	}
+
+	es := make(api.Endpoints, 0, len(scoped))
+	for _, e := range scoped {
+		es = append(es, e.Endpoint)
+	}
+
+	es = append(es,
+		endpoint.Deprecated{
+			BaseEndpoint: api.BaseEndpoint{URL: "/classes"},
+			Handler:      classesV1,
+			Successor:    classesV1.URL},
+		endpoint.Deprecated{
+			BaseEndpoint: api.BaseEndpoint{URL: "/bookings"},
+			Handler:      bookingsV1,
+			Successor:    bookingsV1.URL},
+		api.BaseEndpoint{
+			URL:          "/users",
+			ResponseFunc: auth.Protect(users.Respond, admins)},
+		endpoint.WithWriter{
+			BaseEndpoint: api.BaseEndpoint{URL: "/login"},
+			RespondFunc:  sessions.Login},
+		endpoint.WithWriter{
+			BaseEndpoint: api.BaseEndpoint{URL: "/logout"},
+			RespondFunc:  sessions.Logout},
+		endpoint.Document{
+			BaseEndpoint: api.BaseEndpoint{URL: "/openapi.json"},
+			Object:       spec(scoped)},
+	)
+
+	// the pages are served below the paths of the studios, too, e.g. '/studios/downtown/courses'
+	pages := tpl.Pages("site")
+	for _, s := range tenants.All() {
+		for _, e := range scoped {
+			es = append(es, endpoint.ForStudio{
+				BaseEndpoint: api.BaseEndpoint{URL: s.Prefix() + e.doc.URL},
+				Handler:      e.Endpoint,
+				Studio:       s})
+		}
+		es = append(es, endpoint.ForStudio{
+			BaseEndpoint: api.BaseEndpoint{URL: s.Prefix() + "/"},
+			Handler:      http.StripPrefix(s.Prefix(), pages),
+			Studio:       s})
+	}
+
+	return es
+}
+
+// v1 returns the endpoints of version 1 of '/classes' and '/bookings' and of the import of courses.
+// They act on the courses of the studio the request is for (see package tenants).
+// Their behavior is frozen: changes that break clients belong into a new version.
+func v1(legacy bool) (classesV1, bookingsV1, classesImport endpoint.Methods) {
+	var (
+		cs = func(respond func(classes.API, *http.Request) interface{}) func(*http.Request) interface{} {
+			return tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+				return respond(classes.API{Courses: s.Courses, Rooms: s.Rooms}, req)
+			})
+		}
+		bs = func(respond func(bookings.API, *http.Request) interface{}) func(*http.Request) interface{} {
+			return tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+				return respond(bookings.API{Studio: s.ID, Courses: s.Courses}, req)
+			})
+		}
+
+		admins  = auth.Roles(auth.RoleAdmin)
+		staff   = auth.Roles(auth.RoleAdmin, auth.RoleInstructor)
+		anybody = auth.Roles(auth.RoleAdmin, auth.RoleInstructor, auth.RoleMember)
+		self    = auth.Self("name")
+	)
+
+	// a JSON body is read before authorization, since policies like 'self' check its values
+	// the legacy response functions make changes even for GET requests, so those can't be authenticated by the session cookie
+	classesV1 = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: "/v1/classes"},
+		Handlers: endpoint.Handlers{
+			http.MethodGet:    auth.Protect(cs(classes.API.List), anybody),
+			http.MethodPost:   input.JSON(classes.CreateSchema, auth.Protect(cs(classes.API.Create), admins)),
+			http.MethodPatch:  input.JSON(classes.UpdateSchema, auth.Protect(cs(classes.API.Update), admins)),
+			http.MethodDelete: input.JSON(classes.CancelSchema, auth.Protect(cs(classes.API.Cancel), staff))},
+		Legacy:    auth.Protect(cs(classes.API.Respond), auth.Changes(classes.Authorize)),
+		UseLegacy: legacy}
+
+	bookingsV1 = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: "/v1/bookings"},
+		Handlers: endpoint.Handlers{
+			http.MethodGet:    auth.Protect(bs(bookings.API.List), self),
+			http.MethodPost:   input.JSON(bookings.CreateSchema, auth.Protect(bs(bookings.API.Create), self)),
+			http.MethodDelete: input.JSON(bookings.CancelSchema, auth.Protect(bs(bookings.API.Cancel), self))},
+		Legacy:    auth.Protect(bs(bookings.API.Respond), auth.Changes(self)),
+		UseLegacy: legacy}
+
+	classesImport = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: classes.ImportDoc.URL},
+		Handlers: endpoint.Handlers{
+			http.MethodPost: auth.Protect(cs(classes.API.Import), admins)}}
+
+	return classesV1, bookingsV1, classesImport
+}
+
+// calendarsV1 returns the endpoints of the iCalendar feeds, which contain the courses of the studio the request is for.
+// The feeds are public, so that calendar apps can subscribe to them, except for the feeds of members, which need a secret token.
+func calendarsV1() (courseFeed, scheduleFeed, memberFeed endpoint.File, feedToken endpoint.Methods) {
+	var (
+		cals = func(respond func(calendars.API, *http.Request) interface{}) func(*http.Request) interface{} {
+			return tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+				return respond(calendars.API{Courses: s.Courses, Studio: s.Name, StudioID: s.ID}, req)
+			})
+		}
+
+		self = auth.Self("name")
+	)
+
+	courseFeed = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          calendars.CourseDoc.URL,
+		ResponseFunc: cals(calendars.API.Course)}}
+
+	scheduleFeed = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          calendars.ScheduleDoc.URL,
+		ResponseFunc: cals(calendars.API.Schedule)}}
+
+	memberFeed = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          calendars.MemberDoc.URL,
+		ResponseFunc: cals(calendars.API.Member)}}
+
+	feedToken = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: calendars.TokenDoc.URL},
+		Handlers: endpoint.Handlers{
+			http.MethodGet:    auth.Protect(cals(calendars.API.Token), self),
+			http.MethodDelete: input.JSON(calendars.TokenSchema, auth.Protect(cals(calendars.API.Revoke), self))}}
+
+	return courseFeed, scheduleFeed, memberFeed, feedToken
+}
+
+// exportsV1 returns the endpoints of the exports, which contain the courses of the studio the request is for.
+func exportsV1() (courseExport, rosterExport, bookingExport endpoint.File) {
+	var (
+		es = func(respond func(exports.API, *http.Request) interface{}) func(*http.Request) interface{} {
+			return tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+				return respond(exports.API{Courses: s.Courses, Studio: s.Name}, req)
+			})
+		}
+
+		admins = auth.Roles(auth.RoleAdmin)
+		staff  = auth.Roles(auth.RoleAdmin, auth.RoleInstructor)
+	)
+
+	courseExport = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          exports.CoursesDoc.URL,
+		ResponseFunc: auth.Protect(es(exports.API.CourseList), staff)}}
+
+	rosterExport = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          exports.RostersDoc.URL,
+		ResponseFunc: auth.Protect(es(exports.API.Rosters), staff)}}
+
+	bookingExport = endpoint.File{BaseEndpoint: api.BaseEndpoint{
+		URL:          exports.BookingsDoc.URL,
+		ResponseFunc: auth.Protect(es(exports.API.Bookings), admins)}}
+
+	return courseExport, rosterExport, bookingExport
+}
+
+// eventsV1 returns the endpoint of the live events about the courses of the studio the request is for.
+// It is public, so that kiosk displays can show the free places without logging in.
+func eventsV1() endpoint.Stream {
+	return endpoint.Stream{BaseEndpoint: api.BaseEndpoint{
+		URL: events.Doc.URL,
+		ResponseFunc: tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+			return events.New(s.Courses).Stream(req)
+		})}}
+}
+
+// webhooksV1 returns the endpoints where admins subscribe other systems to the events of the studio the request is for, and see the deliveries that failed.
+func webhooksV1() (hooks, deadLetters endpoint.Methods) {
+	var (
+		ws = func(respond func(apiwebhooks.API, *http.Request) interface{}) func(*http.Request) interface{} {
+			return tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+				return respond(apiwebhooks.API{Studio: s.ID}, req)
+			})
+		}
+
+		admins = auth.Roles(auth.RoleAdmin)
+	)
+
+	hooks = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: apiwebhooks.Doc.URL},
+		Handlers: endpoint.Handlers{
+			http.MethodGet:    auth.Protect(ws(apiwebhooks.API.List), admins),
+			http.MethodPost:   input.JSON(apiwebhooks.SubscribeSchema, auth.Protect(ws(apiwebhooks.API.Subscribe), admins)),
+			http.MethodDelete: input.JSON(apiwebhooks.UnsubscribeSchema, auth.Protect(ws(apiwebhooks.API.Unsubscribe), admins))}}
+
+	deadLetters = endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: apiwebhooks.DeadLettersDoc.URL},
+		Handlers: endpoint.Handlers{
+			http.MethodGet: auth.Protect(ws(apiwebhooks.API.DeadLetters), admins)}}
+
+	return hooks, deadLetters
+}
+
+// auditV1 returns the endpoint where admins look into the changes to the courses and bookings of the studio the request is for.
+func auditV1() endpoint.Methods {
+	list := tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+		return apiaudit.API{Studio: s.ID}.List(req)
+	})
+
+	return endpoint.Methods{
+		BaseEndpoint: api.BaseEndpoint{URL: apiaudit.Doc.URL},
+		Handlers: endpoint.Handlers{
+			http.MethodGet: auth.Protect(list, auth.Roles(auth.RoleAdmin))}}
+}
+
+// salesV1 returns the endpoints where admins issue passes and manage the promo codes of the studio the request is for.
+func salesV1() (passesOf, promosOf api.BaseEndpoint) {
+	admins := auth.Roles(auth.RoleAdmin)
+
+	passesOf = api.BaseEndpoint{
+		URL: passes.Doc.URL,
+		ResponseFunc: auth.Protect(tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+			return passes.API{Studio: s.ID}.Respond(req)
+		}), admins)}
+
+	promosOf = api.BaseEndpoint{
+		URL: apipromos.Doc.URL,
+		ResponseFunc: auth.Protect(tenants.Scope(func(s tenants.Studio, req *http.Request) interface{} {
+			return apipromos.API{Studio: s.ID}.Respond(req)
+		}), admins)}
+
+	return passesOf, promosOf
+}
+
+// scopedEndpoint is an endpoint that acts on the courses, passes or promo codes of the studio the request is for, together with its documentation.
+// It is served at the path of its documentation and at the same path below each studio.
+type scopedEndpoint struct {
+	api.Endpoint
+	doc openapi.Path
+}
+
+// spec returns the OpenAPI specification of our API, served at '/openapi.json'.
+// The endpoints in 'scoped' are documented once for each studio, too.
+func spec(scoped []scopedEndpoint) openapi.Spec {
+	paths := make([]openapi.Path, 0, len(scoped))
+	for _, e := range scoped {
+		paths = append(paths, e.doc)
+	}
+
+	paths = append(paths,
+		classes.Doc.DeprecatedAt("/classes"),
+		bookings.Doc.DeprecatedAt("/bookings"),
+		users.Doc,
+		sessions.LoginDoc,
+		sessions.LogoutDoc,
+	)
+
+	for _, s := range tenants.All() {
+		for _, e := range scoped {
+			paths = append(paths, e.doc.At(s.Prefix()+e.doc.URL))
+		}
+	}
+
+	return openapi.Build("Booking System", "1.0.0", paths...)
+}
+
+// setupStudios adds the studios given in BOOKING_STUDIOS as JSON, e.g. '[{"id": "downtown", "name": "Downtown", "rooms": ["Hall", "Pool"]}]'.
+// Without it, a single studio is hosted.
+func setupStudios() {
+	studios := os.Getenv("BOOKING_STUDIOS")
+	if studios == "" {
+		return
+	}
+
+	var ss []tenants.Studio
+	if err := json.Unmarshal([]byte(studios), &ss); err != nil {
+		log.Fatalf("BOOKING_STUDIOS: %s", err)
+	}
+
+	for _, s := range ss {
+		if err := tenants.Add(s); err != nil {
+			log.Fatalf("BOOKING_STUDIOS: %s", err)
+		}
+	}
+}
+
+// setupJournal rebuilds the courses, their bookings and rules, the ledger, the passes, the promo codes and the revocations of feeds
+// from the journal in the directory BOOKING_JOURNAL, if set, and writes every change down there from now on, so that they survive restarts.
+// The studios of the courses have to exist, so it runs after setupStudios.
+func setupJournal() {
+	dir := os.Getenv("BOOKING_JOURNAL")
+	if dir == "" {
+		log.Printf("BOOKING_JOURNAL not set, courses, bookings and payments are lost when the server stops")
+		return
+	}
+
+	if err := journal.Open(dir); err != nil {
+		log.Fatalf("BOOKING_JOURNAL: %s", err)
+	}
+	if err := courses.Restore(registry); err != nil {
+		log.Fatalf("BOOKING_JOURNAL: %s", err)
+	}
+	for _, restore := range []func() error{rules.Restore, ledger.Restore, plans.Restore, promos.Restore, calendars.Restore} {
+		if err := restore(); err != nil {
+			log.Fatalf("BOOKING_JOURNAL: %s", err)
+		}
+	}
+}
+
+// registry returns the courses of the studio with the ID, those of the default studio if it is empty.
+func registry(id string) (*courses.Registry, error) {
+	if id == "" {
+		return tenants.Default.Courses, nil
+	}
+	s, err := tenants.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Courses, nil
+}
+
+// setupMail sets how emails to customers are sent from the environment.
+// Without an SMTP server, they can be written to the directory BOOKING_OUTBOX, e.g. for local development.
+func setupMail() {
+	addr := os.Getenv("BOOKING_SMTP_ADDR")
+	if addr == "" {
+		if dir := os.Getenv("BOOKING_OUTBOX"); dir != "" {
+			mail.Use(outbox.New(dir))
+		} else {
+			log.Printf("neither BOOKING_SMTP_ADDR nor BOOKING_OUTBOX set, no emails are sent to customers")
+		}
+		return
+	}
+
+	from := os.Getenv("BOOKING_SMTP_FROM")
+	if _, err := mail.ParseAddress(from); err != nil {
+		log.Fatalf("BOOKING_SMTP_FROM: %s", err)
+	}
+	mail.Use(smtp.New(addr, os.Getenv("BOOKING_SMTP_USER"), os.Getenv("BOOKING_SMTP_PASSWORD"), from))
+}
+
+// setupPayments sets the gateway through which paid classes are paid from BOOKING_PAYMENTS.
+// For now, the only gateway is 'fake', for local development, which lets every token but a few pass.
+// Without it, no gateway is used and paid classes can't be booked.
+func setupPayments() {
+	switch g := os.Getenv("BOOKING_PAYMENTS"); g {
+	case "":
+		log.Printf("BOOKING_PAYMENTS not set, paid classes can't be booked")
+	case "fake":
+		log.Printf("BOOKING_PAYMENTS set to 'fake', payments are not real, do not use this in production")
+		payments.Use(fake.New(0))
+	default:
+		log.Fatalf("BOOKING_PAYMENTS: unknown gateway '%s'", g)
+	}
+}
+
+// setupAudit appends the audit log to the file BOOKING_AUDIT, if set, so that it survives restarts.
+func setupAudit() {
+	if path := os.Getenv("BOOKING_AUDIT"); path != "" {
+		if err := audit.Open(path); err != nil {
+			log.Fatalf("BOOKING_AUDIT: %s", err)
+		}
+	}
+}
+
+// setupAuth creates the first admin and sets the JWT key from the environment.
+// Everybody else can then be added via '/users'.
+func setupAuth() {
+	if key := os.Getenv("BOOKING_ADMIN_KEY"); key != "" {
+		if err := auth.AddKey(key, auth.Principal{Name: "admin", Role: auth.RoleAdmin}); err != nil {
+			log.Fatalf("BOOKING_ADMIN_KEY: %s", err)
+		}
+	}
+
+	if pw := os.Getenv("BOOKING_ADMIN_PASSWORD"); pw != "" {
+		if err := auth.AddUser("admin", pw, auth.RoleAdmin); err != nil {
+			log.Fatalf("BOOKING_ADMIN_PASSWORD: %s", err)
+		}
+	}
+
+	if key := os.Getenv("BOOKING_JWT_KEY"); key != "" {
+		if err := auth.SetJWTKey([]byte(key)); err != nil {
+			log.Fatalf("BOOKING_JWT_KEY: %s", err)
+		}
+	}
+
+	if os.Getenv("BOOKING_ADMIN_KEY") == "" && os.Getenv("BOOKING_ADMIN_PASSWORD") == "" {
+		log.Printf("neither BOOKING_ADMIN_KEY nor BOOKING_ADMIN_PASSWORD set, nobody can create courses")
+	}
+}
@@ -1,3 +1,384 @@
 package main
 
-// empty, included just so we see 'ok' everywhere on the left side when running go test.
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	apiaudit "github.com/MarkRosemaker/booking-system/api/audit"
+	"github.com/MarkRosemaker/booking-system/api/bookings"
+	"github.com/MarkRosemaker/booking-system/api/calendars"
+	"github.com/MarkRosemaker/booking-system/api/classes"
+	"github.com/MarkRosemaker/booking-system/api/endpoint"
+	"github.com/MarkRosemaker/booking-system/api/exports"
+	"github.com/MarkRosemaker/booking-system/api/openapi"
+	"github.com/MarkRosemaker/booking-system/api/passes"
+	apipromos "github.com/MarkRosemaker/booking-system/api/promos"
+	"github.com/MarkRosemaker/booking-system/api/sessions"
+	"github.com/MarkRosemaker/booking-system/api/users"
+	apiwebhooks "github.com/MarkRosemaker/booking-system/api/webhooks"
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/plans"
+	"github.com/MarkRosemaker/booking-system/promos"
+	"github.com/MarkRosemaker/booking-system/tenants"
+	"github.com/MarkRosemaker/go-server/server/api"
+)
+
+// TestSpec fails if the endpoints and their OpenAPI specification drift apart.
+func TestSpec(t *testing.T) {
+	addStudios(t)
+
+	es := endpoints(false)
+	paths := servedSpec(t, es).Paths()
+	served := map[string]bool{}
+
+	for _, e := range es {
+		var (
+			url     string
+			methods []string // nil if any method is accepted
+		)
+
+		switch e := e.(type) {
+		case endpoint.Methods:
+			url = e.URL
+			for m := range e.Handlers {
+				methods = append(methods, strings.ToLower(m))
+			}
+		case endpoint.Deprecated:
+			url = e.URL
+			if m, ok := e.Handler.(endpoint.Methods); ok {
+				for m := range m.Handlers {
+					methods = append(methods, strings.ToLower(m))
+				}
+			}
+			for m, op := range paths[url] {
+				if op.(map[string]interface{})["deprecated"] != true {
+					t.Errorf("%s %s is deprecated, but not documented as deprecated", strings.ToUpper(m), url)
+				}
+			}
+		case endpoint.ForStudio:
+			if strings.HasSuffix(e.URL, "/") {
+				// the pages of the studio, which are not part of the API
+				continue
+			}
+			url = e.URL
+			if m, ok := e.Handler.(endpoint.Methods); ok {
+				for m := range m.Handlers {
+					methods = append(methods, strings.ToLower(m))
+				}
+			}
+		case endpoint.File:
+			url = e.URL
+		case endpoint.Stream:
+			url = e.URL
+		case api.BaseEndpoint:
+			url = e.URL
+		case endpoint.WithWriter:
+			url = e.URL
+		case endpoint.Document:
+			// the specification itself
+			continue
+		default:
+			t.Errorf("unknown type of endpoint: %T", e)
+			continue
+		}
+		served[url] = true
+
+		ops, ok := paths[url]
+		if !ok {
+			t.Errorf("endpoint %s is not documented", url)
+			continue
+		}
+
+		documented := make([]string, 0, len(ops))
+		for m := range ops {
+			documented = append(documented, m)
+		}
+		sort.Strings(documented)
+		sort.Strings(methods)
+
+		if methods == nil {
+			if len(documented) == 0 {
+				t.Errorf("endpoint %s has no documented methods", url)
+			}
+		} else if strings.Join(methods, ",") != strings.Join(documented, ",") {
+			t.Errorf("endpoint %s has the methods %v, but %v are documented", url, methods, documented)
+		}
+	}
+
+	for url := range paths {
+		if !served[url] {
+			t.Errorf("%s is documented, but not served", url)
+		}
+	}
+
+	for _, p := range []openapi.Path{classes.Doc, classes.ImportDoc, bookings.Doc, calendars.CourseDoc, calendars.ScheduleDoc, calendars.MemberDoc, calendars.TokenDoc, exports.CoursesDoc, exports.RostersDoc, exports.BookingsDoc, apiwebhooks.Doc, apiwebhooks.DeadLettersDoc, apiaudit.Doc, passes.Doc, apipromos.Doc, users.Doc, sessions.LoginDoc, sessions.LogoutDoc} {
+		if err := openapi.Check(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// servedSpec returns the OpenAPI specification served by one of the endpoints.
+func servedSpec(t *testing.T, es api.Endpoints) openapi.Spec {
+	t.Helper()
+
+	for _, e := range es {
+		if d, ok := e.(endpoint.Document); ok {
+			if s, ok := d.Object.(openapi.Spec); ok {
+				return s
+			}
+		}
+	}
+
+	t.Fatal("no OpenAPI specification is served")
+	return nil
+}
+
+func TestServeSpec(t *testing.T) {
+	var e endpoint.Document
+	for _, ep := range endpoints(false) {
+		if d, ok := ep.(endpoint.Document); ok {
+			e = d
+		}
+	}
+	if e.URL != "/openapi.json" {
+		t.Fatalf("the specification is not served at /openapi.json")
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("specification is not valid JSON: %s", err)
+	}
+	if doc.OpenAPI != openapi.Version || len(doc.Paths) == 0 {
+		t.Errorf("specification is incomplete: %s", w.Body.String())
+	}
+}
+
+func TestVersions(t *testing.T) {
+	served := map[string]api.Endpoint{}
+	for _, e := range endpoints(false) {
+		switch e := e.(type) {
+		case endpoint.Methods:
+			served[e.URL] = e
+		case endpoint.Deprecated:
+			served[e.URL] = e
+		}
+	}
+
+	tables := []struct {
+		url        string
+		deprecated bool
+	}{
+		{"/v1/classes", false},
+		{"/v1/bookings", false},
+		{"/classes", true},
+		{"/bookings", true},
+	}
+
+	for _, table := range tables {
+		e, ok := served[table.url]
+		if !ok {
+			t.Errorf("%s is not served", table.url)
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, table.url, nil))
+
+		if got := w.Header().Get("Deprecation") != ""; got != table.deprecated {
+			t.Errorf("%s: deprecated was incorrect, got: %t, want: %t", table.url, got, table.deprecated)
+		}
+		if table.deprecated && !strings.Contains(w.Header().Get("Link"), "</v1"+table.url+">") {
+			t.Errorf("%s: the successor is missing, got: %q", table.url, w.Header().Get("Link"))
+		}
+		if w.Header().Get("Allow") == "" {
+			t.Errorf("%s: the request wasn't served by the versioned endpoint", table.url)
+		}
+	}
+}
+
+var studiosOnce sync.Once
+
+// addStudios adds the studios of the tests once.
+func addStudios(t *testing.T) {
+	studiosOnce.Do(func() {
+		for _, s := range []tenants.Studio{
+			{ID: "north", Name: "North", Rooms: []string{"Hall"}},
+			{ID: "south", Name: "South"},
+		} {
+			if err := tenants.Add(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestStudios(t *testing.T) {
+	addStudios(t)
+	north, _ := tenants.Get("north")
+	south, _ := tenants.Get("south")
+
+	classesV1, _, _ := v1(false)
+	create := classesV1.Handlers[http.MethodPost]
+
+	keys := map[string]string{}
+	for _, p := range []auth.Principal{
+		{Name: "Boss", Role: auth.RoleAdmin},
+		{Name: "North Admin", Role: auth.RoleAdmin, Studio: "north"},
+	} {
+		key, err := auth.NewKey(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[p.Name] = key
+	}
+
+	today := civil.DateOf(time.Now())
+	tables := []struct {
+		user   string
+		studio *tenants.Studio // selected by the path, nil if not
+		host   string
+		params string
+		status int
+		added  *tenants.Studio // nil if the course isn't added
+	}{
+		// the path selects the studio
+		{"North Admin", &north, "example.com", "location=Hall", http.StatusCreated, &north},
+		// only the rooms of the studio
+		{"North Admin", &north, "example.com", "location=Pool", http.StatusBadRequest, nil},
+		// not in another studio, no matter how it is selected
+		{"North Admin", &south, "example.com", "", http.StatusForbidden, nil},
+		{"North Admin", nil, "south.example.com", "", http.StatusForbidden, nil},
+		{"North Admin", nil, "example.com", "", http.StatusForbidden, nil},
+		// users of all studios act in any of them, selected by the subdomain
+		{"Boss", nil, "south.example.com:8080", "location=Pool", http.StatusCreated, &south},
+		{"Boss", nil, "example.com", "", http.StatusCreated, &tenants.Default},
+	}
+
+	for i, table := range tables {
+		name := fmt.Sprintf("Studio Test %d", i)
+		url := fmt.Sprintf("/v1/classes?name=%s&start=%s&end=%s&capacity=10&%s",
+			strings.ReplaceAll(name, " ", "+"), today, today, table.params)
+
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		req.Host = table.host
+		req.Header.Set("X-API-Key", keys[table.user])
+		if table.studio != nil {
+			req = req.WithContext(tenants.NewContext(req.Context(), *table.studio))
+		}
+
+		status := 0
+		switch v := create(req).(type) {
+		case api.Error:
+			status = v.Status
+		case api.Success:
+			status = v.Status
+		}
+		if status != table.status {
+			t.Errorf("%d: wrong status, got: %d, want: %d", i, status, table.status)
+		}
+
+		for _, s := range []tenants.Studio{tenants.Default, north, south} {
+			page, err := s.Courses.Find(courses.Query{Name: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			if table.added != nil && table.added.ID == s.ID {
+				want = 1
+			}
+			if len(page.Courses) != want {
+				t.Errorf("%d: course found %d times in studio '%s', want: %d", i, len(page.Courses), s.ID, want)
+			}
+		}
+	}
+}
+
+func TestStudioSales(t *testing.T) {
+	addStudios(t)
+	north, _ := tenants.Get("north")
+	south, _ := tenants.Get("south")
+
+	passesOf, promosOf := salesV1()
+
+	key, err := auth.NewKey(auth.Principal{Name: "North Admin", Role: auth.RoleAdmin, Studio: "north"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		respond func(*http.Request) interface{}
+		params  string
+		studio  tenants.Studio
+		status  int
+	}{
+		{passesOf.ResponseFunc, "name=Sales+Test&plan=10-class", north, http.StatusCreated},
+		{passesOf.ResponseFunc, "name=Sales+Test&plan=10-class", south, http.StatusForbidden},
+		{promosOf.ResponseFunc, "code=NORTH10&kind=percent&percent=10", north, http.StatusCreated},
+		{promosOf.ResponseFunc, "code=NORTH10&kind=percent&percent=10", south, http.StatusForbidden},
+	}
+
+	for i, table := range tables {
+		req := httptest.NewRequest(http.MethodPost, "/?"+table.params, nil)
+		req.Header.Set("X-API-Key", key)
+		req = req.WithContext(tenants.NewContext(req.Context(), table.studio))
+
+		status := 0
+		switch v := table.respond(req).(type) {
+		case api.Error:
+			status = v.Status
+		case api.Success:
+			status = v.Status
+		}
+		if status != table.status {
+			t.Errorf("%d: wrong status, got: %d, want: %d", i, status, table.status)
+		}
+	}
+
+	// only in the studio they were made for
+	if ps := plans.Passes("north", "Sales Test"); len(ps) != 1 {
+		t.Errorf("pass wasn't issued in the studio, got: %+v", ps)
+	}
+	if ps := plans.Passes("", "Sales Test"); len(ps) != 0 {
+		t.Errorf("pass was issued in the default studio, got: %+v", ps)
+	}
+	if _, err := promos.Get("north", "NORTH10"); err != nil {
+		t.Errorf("code wasn't created in the studio: %s", err)
+	}
+	if _, err := promos.Get("", "NORTH10"); err == nil {
+		t.Errorf("code was created in the default studio")
+	}
+}
+
+func TestStudioPaths(t *testing.T) {
+	addStudios(t)
+
+	served := map[string]bool{}
+	for _, e := range endpoints(false) {
+		if e, ok := e.(endpoint.ForStudio); ok {
+			served[e.URL] = true
+		}
+	}
+
+	for _, url := range []string{"/studios/north/v1/classes", "/studios/north/v1/classes/import", "/studios/north/v1/bookings", "/studios/south/v1/classes", "/studios/south/v1/calendars/schedule", "/studios/north/passes", "/studios/south/promos", "/studios/north/"} {
+		if !served[url] {
+			t.Errorf("%s is not served", url)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package money implements amounts of money in integer minor units (e.g. cents) to avoid rounding errors.
+package money
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used if no currency is given.
+const DefaultCurrency = "EUR"
+
+// An Amount is an amount of money in the minor unit of its currency, e.g. 1250 EUR is 12.50 €.
+// For now, we assume that every currency has two decimal places.
+type Amount struct {
+	Minor    int64
+	Currency string // ISO 4217 code, e.g. "EUR"
+}
+
+// New returns an amount in the given currency.
+func New(minor int64, currency string) Amount {
+	return Amount{minor, strings.ToUpper(currency)}
+}
+
+// Parse parses an amount such as "12.50" or "12" in the given currency.
+func Parse(s, currency string) (Amount, error) {
+	if len(currency) != 3 {
+		return Amount{}, fmt.Errorf("currency '%s' is not a three-letter code", currency)
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if hasFrac && len(frac) != 2 {
+		return Amount{}, fmt.Errorf("amount '%s' must have two decimal places", s)
+	}
+	if !hasFrac {
+		frac = "00"
+	}
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || strings.HasPrefix(s, "-") {
+		return Amount{}, fmt.Errorf("amount '%s' could not be parsed", s)
+	}
+
+	return New(minor, currency), nil
+}
+
+// IsZero returns whether the amount is nothing.
+func (a Amount) IsZero() bool {
+	return a.Minor == 0
+}
+
+// String returns the amount in a readable format, e.g. "12.50 EUR" or "-0.05 EUR".
+func (a Amount) String() string {
+	sign, minor := "", a.Minor
+	if minor < 0 {
+		// the remainder of a negative amount is negative, too
+		sign, minor = "-", -minor
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, minor/100, minor%100, a.Currency)
+}
@@ -0,0 +1,51 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tables := []struct {
+		s, currency string
+		minor       int64
+		ok          bool
+	}{
+		{"12.50", "EUR", 1250, true},
+		{"12", "usd", 1200, true},
+		{"0.05", "EUR", 5, true},
+		{"12.5", "EUR", 0, false},
+		{"-1.00", "EUR", 0, false},
+		{"abc", "EUR", 0, false},
+		{"12.00", "EURO", 0, false},
+	}
+
+	for _, table := range tables {
+		a, err := Parse(table.s, table.currency)
+		if table.ok != (err == nil) {
+			t.Errorf("parsing %q returned unexpected error: %v", table.s, err)
+			continue
+		}
+		if table.ok && a.Minor != table.minor {
+			t.Errorf("parsing %q, got: %d, want: %d", table.s, a.Minor, table.minor)
+		}
+	}
+
+	if a, _ := Parse("12", "usd"); a.String() != "12.00 USD" {
+		t.Errorf("wrong format: %s", a)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, table := range []struct {
+		minor int64
+		s     string
+	}{
+		{1250, "12.50 EUR"},
+		{5, "0.05 EUR"},
+		{0, "0.00 EUR"},
+		{-5, "-0.05 EUR"},
+		{-1250, "-12.50 EUR"},
+	} {
+		if s := New(table.minor, "EUR").String(); s != table.s {
+			t.Errorf("formatting %d, got: %s, want: %s", table.minor, s, table.s)
+		}
+	}
+}
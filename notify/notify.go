@@ -0,0 +1,152 @@
+// Package notify sends customers emails about their bookings, e.g. a confirmation, through the mailer in use (see package mail).
+//
+// The emails are rendered from the templates in site/emails, one per email, e.g. 'booking-confirmed.txt'.
+// A template starts with the header 'Subject: ...', followed by an empty line and the body.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+// the emails, named after their templates
+const (
+	BookingConfirmed = "booking-confirmed" // the member booked a class
+	BookingCancelled = "booking-cancelled" // the member cancelled a booking
+	ClassCancelled   = "class-cancelled"   // the studio cancelled a class the member booked
+	ClassReminder    = "class-reminder"    // the class the member booked starts soon
+)
+
+// Dir is the directory of the templates.
+var Dir = "site/emails"
+
+// Timeout is how long sending an email in the background may take (see Notify).
+const Timeout = 30 * time.Second
+
+// Data is what the templates get about a class and what the member paid or got back.
+type Data struct {
+	Member     string
+	Course     string
+	Date       civil.Date
+	Time       string // when the class starts, e.g. '18:30', empty if not known
+	Instructor string
+	Location   string
+
+	Paid           money.Amount
+	Refunded       money.Amount
+	Fee            money.Amount
+	CreditReturned bool
+}
+
+// DataOf returns the data about the class of the course on the date, for the member.
+func DataOf(c *course.Course, member string, date civil.Date) Data {
+	d := Data{Member: member, Course: c.Name(), Date: date, Instructor: c.Instructor(), Location: c.Location()}
+	if c.At() != (civil.Time{}) {
+		d.Time = fmt.Sprintf("%02d:%02d", c.At().Hour, c.At().Minute)
+	}
+	return d
+}
+
+var (
+	// the email addresses of the members, by name
+	addresses map[string]string = make(map[string]string)
+
+	// protect map with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// SetAddress sets the email address the member gets emails at.
+func SetAddress(member, address string) error {
+	a, err := mail.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	addresses[member] = a
+	return nil
+}
+
+// AddressOf returns the email address of the member, if it is known.
+func AddressOf(member string) (string, bool) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	a, ok := addresses[member]
+	return a, ok
+}
+
+// Send renders the email with the name, e.g. BookingConfirmed, and sends it to the member of the data.
+// Members whose address isn't known get no email, which is not an error.
+func Send(ctx context.Context, name string, d Data) error {
+	to, ok := AddressOf(d.Member)
+	if !ok {
+		return nil
+	}
+
+	m, err := render(name, d)
+	if err != nil {
+		return err
+	}
+	m.To = to
+	return mail.Send(ctx, m)
+}
+
+// Notify sends the email in the background, so that the booking doesn't wait for it, and logs if that fails.
+func Notify(name string, d Data) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+
+		if err := Send(ctx, name, d); err != nil {
+			log.Printf("email %s to %s: %s", name, d.Member, err)
+		}
+	}()
+}
+
+// funcs are the functions the templates can use, like those of the pages
+var funcs = template.FuncMap{
+	"dateFormat": func(layout string, d civil.Date) string {
+		return d.In(time.UTC).Format(layout)
+	},
+}
+
+// render returns the email with the name, without the recipient.
+func render(name string, d Data) (mail.Message, error) {
+	t, err := template.New(name + ".txt").Funcs(funcs).ParseFiles(filepath.Join(Dir, name+".txt"))
+	if err != nil {
+		return mail.Message{}, err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, d); err != nil {
+		return mail.Message{}, err
+	}
+
+	header, body, _ := strings.Cut(strings.ReplaceAll(b.String(), "\r\n", "\n"), "\n\n")
+	if !strings.HasPrefix(header, "Subject:") || strings.Contains(header, "\n") {
+		return mail.Message{}, fmt.Errorf("template %s must start with the only header 'Subject: ...' and an empty line", name)
+	}
+
+	// optional lines that were left out leave empty lines behind
+	for strings.Contains(body, "\n\n\n") {
+		body = strings.ReplaceAll(body, "\n\n\n", "\n\n")
+	}
+
+	return mail.Message{Subject: strings.TrimSpace(strings.TrimPrefix(header, "Subject:")), Body: strings.TrimSpace(body) + "\n"}, nil
+}
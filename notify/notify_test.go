@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/mail/outbox"
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+func init() {
+	Dir = "../site/emails"
+}
+
+// TestTemplates renders the templates in folder 'site/emails'.
+func TestTemplates(t *testing.T) {
+	d := Data{
+		Member:     "Arnold",
+		Course:     "Pilates",
+		Date:       civil.Date{Year: 2026, Month: time.October, Day: 19},
+		Time:       "18:30",
+		Instructor: "Maria",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		data    func(Data) Data
+		subject string
+		want    []string
+		notWant []string
+	}{
+		{BookingConfirmed, func(d Data) Data { return d }, "You are booked: Pilates on October 19, 2026",
+			[]string{"Hi Arnold,", "Pilates class on Monday, October 19, 2026 at 18:30.", "Maria"}, []string{"paid", "takes place"}},
+		{BookingConfirmed, func(d Data) Data { d.Paid = money.New(1250, "EUR"); return d }, "You are booked: Pilates on October 19, 2026",
+			[]string{"You paid 12.50 EUR."}, nil},
+		{BookingCancelled, func(d Data) Data { d.Refunded, d.Fee = money.New(1000, "EUR"), money.New(250, "EUR"); return d }, "Booking cancelled: Pilates on October 19, 2026",
+			[]string{"You will get 10.00 EUR back.", "A cancellation fee of 2.50 EUR applies."}, []string{"credit"}},
+		{ClassCancelled, func(d Data) Data { d.CreditReturned = true; return d }, "Class cancelled: Pilates on October 19, 2026",
+			[]string{"we had to cancel", "The credit has been returned to your pass."}, []string{"back."}},
+	} {
+		m, err := render(tc.name, tc.data(d))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if m.Subject != tc.subject {
+			t.Errorf("%s: got subject %q, want: %q", tc.name, m.Subject, tc.subject)
+		}
+		if strings.Contains(m.Body, "\n\n\n") {
+			t.Errorf("%s: too many empty lines in:\n%s", tc.name, m.Body)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(m.Body, want) {
+				t.Errorf("%s: expected %q in:\n%s", tc.name, want, m.Body)
+			}
+		}
+		for _, notWant := range tc.notWant {
+			if strings.Contains(m.Body, notWant) {
+				t.Errorf("%s: didn't expect %q in:\n%s", tc.name, notWant, m.Body)
+			}
+		}
+	}
+
+	if _, err := render("waitlist-promoted", d); err == nil {
+		t.Errorf("expected no template for an unknown email")
+	}
+}
+
+func TestSend(t *testing.T) {
+	o := outbox.New("")
+	mail.Use(o)
+	defer mail.Use(nil)
+
+	d := Data{Member: "Notify Tester", Course: "Yoga", Date: civil.DateOf(time.Now())}
+
+	// members without an address get no email
+	if err := Send(context.Background(), BookingConfirmed, d); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetAddress(d.Member, "tester"); err == nil {
+		t.Errorf("expected an invalid address")
+	}
+	if err := SetAddress(d.Member, "Notify Tester <tester@example.com>"); err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := AddressOf(d.Member); !ok || a != "tester@example.com" {
+		t.Errorf("got address %q, want: tester@example.com", a)
+	}
+
+	Notify(BookingConfirmed, d)
+	ms, err := o.Wait(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].To != "tester@example.com" || !strings.HasPrefix(ms[0].Subject, "You are booked: Yoga") {
+		t.Errorf("wrong emails: %v", ms)
+	}
+}
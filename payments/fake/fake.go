@@ -0,0 +1,113 @@
+// Package fake implements a payment gateway that doesn't talk to a payment provider, for tests and local development.
+//
+// The outcome of a charge is chosen by the payment token:
+//
+//   - "decline": the payment is declined.
+//   - "timeout": the gateway doesn't answer until the context is done.
+//   - anything else: the payment succeeds.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+)
+
+// the payment tokens that simulate a failure
+const (
+	TokenDecline = "decline"
+	TokenTimeout = "timeout"
+)
+
+// Gateway is a fake payment gateway. It keeps track of the charges and refunds it has made.
+type Gateway struct {
+	// Delay is how long every call takes, to simulate a slow provider.
+	Delay time.Duration
+
+	count    int
+	charged  map[string]money.Amount // by receipt ID
+	refunded map[string]money.Amount // by receipt ID
+
+	// protect maps with mutex
+	mux sync.Mutex
+}
+
+// New returns a fake gateway where every call takes the given time.
+func New(delay time.Duration) *Gateway {
+	return &Gateway{
+		Delay:    delay,
+		charged:  make(map[string]money.Amount),
+		refunded: make(map[string]money.Amount)}
+}
+
+// wait waits for the delay or until the context is done.
+func (g *Gateway) wait(ctx context.Context) error {
+	select {
+	case <-time.After(g.Delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Charge implements the payments.Gateway interface.
+func (g *Gateway) Charge(ctx context.Context, c payments.Charge) (payments.Receipt, error) {
+	switch c.Token {
+	case TokenTimeout:
+		<-ctx.Done()
+		return payments.Receipt{}, ctx.Err()
+	case TokenDecline:
+		if err := g.wait(ctx); err != nil {
+			return payments.Receipt{}, err
+		}
+		return payments.Receipt{}, payments.ErrDeclined
+	}
+
+	if err := g.wait(ctx); err != nil {
+		return payments.Receipt{}, err
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.count++
+	r := payments.Receipt{ID: fmt.Sprintf("fake_%d", g.count), Charge: c}
+	g.charged[r.ID] = c.Amount
+	return r, nil
+}
+
+// Refund implements the payments.Gateway interface.
+func (g *Gateway) Refund(ctx context.Context, r payments.Receipt, a money.Amount) error {
+	if err := g.wait(ctx); err != nil {
+		return err
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	charged, ok := g.charged[r.ID]
+	if !ok {
+		return fmt.Errorf("charge %s does not exist", r.ID)
+	}
+	if a.Currency != charged.Currency || g.refunded[r.ID].Minor+a.Minor > charged.Minor {
+		return fmt.Errorf("can't refund %s of charge %s over %s", a, r.ID, charged)
+	}
+
+	a.Minor += g.refunded[r.ID].Minor
+	g.refunded[r.ID] = a
+	return nil
+}
+
+// Charged returns the amount charged with the given receipt ID, minus refunds.
+func (g *Gateway) Charged(id string) money.Amount {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	a := g.charged[id]
+	a.Minor -= g.refunded[id].Minor
+	return a
+}
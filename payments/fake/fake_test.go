@@ -0,0 +1,72 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+)
+
+func TestCharge(t *testing.T) {
+	g := New(0)
+	ctx := context.Background()
+	price := money.New(1250, "EUR")
+
+	r, err := g.Charge(ctx, payments.Charge{Member: "Arnold", Amount: price, Token: "tok"})
+	if err != nil {
+		t.Fatalf("charge failed: %s", err)
+	}
+	if g.Charged(r.ID) != price {
+		t.Errorf("charged %s, want: %s", g.Charged(r.ID), price)
+	}
+
+	if _, err = g.Charge(ctx, payments.Charge{Amount: price, Token: TokenDecline}); !errors.Is(err, payments.ErrDeclined) {
+		t.Errorf("payment wasn't declined, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = g.Charge(ctx, payments.Charge{Amount: price, Token: TokenTimeout}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("payment didn't time out, got: %v", err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	g := New(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Charge(ctx, payments.Charge{Amount: money.New(100, "EUR"), Token: "tok"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow gateway didn't respect context, got: %v", err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	g := New(0)
+	ctx := context.Background()
+
+	r, err := g.Charge(ctx, payments.Charge{Amount: money.New(1000, "EUR"), Token: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = g.Refund(ctx, r, money.New(400, "EUR")); err != nil {
+		t.Errorf("couldn't refund part of charge: %s", err)
+	}
+	if err = g.Refund(ctx, r, money.New(700, "EUR")); err == nil {
+		t.Errorf("could refund more than was charged")
+	}
+	if err = g.Refund(ctx, r, money.New(600, "EUR")); err != nil {
+		t.Errorf("couldn't refund rest of charge: %s", err)
+	}
+	if a := g.Charged(r.ID); !a.IsZero() {
+		t.Errorf("%s still charged after full refund", a)
+	}
+
+	if err = g.Refund(ctx, payments.Receipt{ID: "unknown"}, money.New(1, "EUR")); err == nil {
+		t.Errorf("could refund unknown charge")
+	}
+}
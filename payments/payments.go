@@ -0,0 +1,84 @@
+// Package payments defines how we take payments for bookings.
+//
+// The actual payment provider is hidden behind the Gateway interface. For tests and local development, see the package payments/fake.
+package payments
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+var (
+	// ErrDeclined is returned by a gateway if the payment was declined.
+	ErrDeclined = errors.New("your payment was declined")
+	// ErrNotConfigured is returned if no gateway is in use.
+	ErrNotConfigured = errors.New("payments are not available at the moment")
+)
+
+// A Charge is a request to take money from a customer.
+type Charge struct {
+	Member      string
+	Amount      money.Amount
+	Token       string // identifies the payment method of the customer, as given by the payment provider
+	Description string
+}
+
+// A Receipt confirms a successful charge.
+type Receipt struct {
+	ID     string
+	Charge Charge
+}
+
+// A Gateway talks to a payment provider. Calls may be slow, so they must respect the context.
+type Gateway interface {
+	Charge(ctx context.Context, c Charge) (Receipt, error)
+	Refund(ctx context.Context, r Receipt, a money.Amount) error
+}
+
+var (
+	// the gateway in use
+	gateway Gateway
+
+	// protect gateway with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Use sets the gateway through which all payments are made.
+func Use(g Gateway) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	gateway = g
+}
+
+// current returns the gateway in use.
+func current() (Gateway, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if gateway == nil {
+		return nil, ErrNotConfigured
+	}
+	return gateway, nil
+}
+
+// Pay charges the customer through the gateway in use.
+func Pay(ctx context.Context, c Charge) (Receipt, error) {
+	g, err := current()
+	if err != nil {
+		return Receipt{}, err
+	}
+	return g.Charge(ctx, c)
+}
+
+// Refund gives back (part of) a charge through the gateway in use.
+func Refund(ctx context.Context, r Receipt, a money.Amount) error {
+	g, err := current()
+	if err != nil {
+		return err
+	}
+	return g.Refund(ctx, r, a)
+}
@@ -0,0 +1,326 @@
+// Package plans implements membership plans and class passes.
+//
+// A member holds passes, each issued from a plan. Passes are only valid in the studio that issued them, members of different studios may have the same name. A pass is valid for a period of time and either has a number of credits (e.g. a 10-class pass) or is unlimited (e.g. a monthly membership).
+// Booking a class consumes a credit, cancelling in time gives it back.
+// The passes and what they were used for are kept in the journal, if one is open (see Restore).
+package plans
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+// ErrNoEntitlement is returned if a member has no valid pass for a class.
+var ErrNoEntitlement = errors.New("you don't have a valid pass or membership for this class")
+
+// A Plan is a product we sell, e.g. a 10-class pass.
+type Plan struct {
+	Name string
+	// the number of classes that can be booked with the pass, zero means unlimited
+	Credits int
+	// the number of days the pass is valid
+	Days int
+	// a credit is only given back if a class is cancelled at least this long before it starts
+	RefundBefore time.Duration
+}
+
+// Unlimited returns whether passes of the plan can be used for an unlimited number of classes.
+func (p Plan) Unlimited() bool {
+	return p.Credits == 0
+}
+
+// Catalogue contains all plans that can be issued, by name.
+var Catalogue = map[string]Plan{
+	"10-class": {
+		Name:         "10-class",
+		Credits:      10,
+		Days:         365,
+		RefundBefore: 12 * time.Hour},
+	"monthly-unlimited": {
+		Name:         "monthly-unlimited",
+		Days:         30,
+		RefundBefore: 12 * time.Hour},
+}
+
+// A Pass is a plan issued to a member.
+type Pass struct {
+	ID      uint64
+	Studio  string // the ID of the studio the pass is valid in, empty for the default studio
+	Member  string
+	Plan    Plan
+	Credits int // the credits left, not used for unlimited plans
+	From    civil.Date
+	Until   civil.Date
+}
+
+// ValidOn returns whether the pass can be used for a class on the given date.
+func (p Pass) ValidOn(date civil.Date) bool {
+	if date.Before(p.From) || date.After(p.Until) {
+		return false
+	}
+	return p.Plan.Unlimited() || p.Credits > 0
+}
+
+// a holder is a member of a studio
+type holder struct {
+	studio, member string
+}
+
+// a usage is a class that was booked with a pass
+type usage struct {
+	member string
+	course uint64
+	date   civil.Date
+}
+
+// key identifies the usage in the journal.
+func (u usage) key() string {
+	return fmt.Sprintf("%d/%s/%s", u.course, u.date, u.member)
+}
+
+// a use is how the journal keeps which pass was used for a class
+type use struct {
+	Member string
+	Course uint64
+	Date   civil.Date
+	Pass   uint64
+}
+
+var (
+	// the current pass id, is incremented before issuing a pass
+	currID uint64
+
+	// all passes of each member of each studio
+	byMember map[holder][]*Pass = make(map[holder][]*Pass)
+	// the pass used for each booking
+	used map[usage]*Pass = make(map[usage]*Pass)
+
+	// protect maps with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Issue gives a member of the studio a new pass from the plan, valid from the given date.
+func Issue(studio, member string, p Plan, from civil.Date) (Pass, error) {
+	if member == "" {
+		return Pass{}, fmt.Errorf("please provide a member name")
+	}
+
+	if p.Days < 1 {
+		return Pass{}, fmt.Errorf("invalid plan: validity (%d days) must be positive", p.Days)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	pass := &Pass{
+		ID:      atomic.AddUint64(&currID, 1),
+		Studio:  studio,
+		Member:  member,
+		Plan:    p,
+		Credits: p.Credits,
+		From:    from,
+		Until:   from.AddDays(p.Days - 1)}
+
+	ev, err := changed(*pass)
+	if err != nil {
+		return Pass{}, err
+	}
+	if err := journal.Append(ev); err != nil {
+		return Pass{}, err
+	}
+
+	h := holder{studio, member}
+	byMember[h] = append(byMember[h], pass)
+	return *pass, nil
+}
+
+// Passes returns copies of all passes of a member of the studio.
+func Passes(studio, member string) []Pass {
+	mux.Lock()
+	defer mux.Unlock()
+
+	h := holder{studio, member}
+	ps := make([]Pass, len(byMember[h]))
+	for i, p := range byMember[h] {
+		ps[i] = *p
+	}
+	return ps
+}
+
+// Consume uses the pass of a member of the studio for the class of a course of the studio on the given date.
+//
+// Unlimited passes are preferred, otherwise a credit of the pass that expires first is used.
+// If the member has no valid pass in the studio, ErrNoEntitlement is returned.
+func Consume(studio, member string, course uint64, date civil.Date) (Pass, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	u := usage{member, course, date}
+	if _, ok := used[u]; ok {
+		return Pass{}, fmt.Errorf("a pass was already used for this class")
+	}
+
+	valid := make([]*Pass, 0)
+	for _, p := range byMember[holder{studio, member}] {
+		if p.ValidOn(date) {
+			valid = append(valid, p)
+		}
+	}
+	if len(valid) == 0 {
+		return Pass{}, ErrNoEntitlement
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		if valid[i].Plan.Unlimited() != valid[j].Plan.Unlimited() {
+			return valid[i].Plan.Unlimited()
+		}
+		return valid[i].Until.Before(valid[j].Until)
+	})
+
+	p := valid[0]
+	after := *p
+	if !p.Plan.Unlimited() {
+		after.Credits--
+	}
+
+	ev, err := changed(after)
+	if err != nil {
+		return Pass{}, err
+	}
+	b, err := json.Marshal(use{member, course, date, p.ID})
+	if err != nil {
+		return Pass{}, err
+	}
+	if err := journal.Append(ev, journal.Event{Kind: journal.PassUsed, Key: u.key(), Record: b}); err != nil {
+		return Pass{}, err
+	}
+
+	*p = after
+	used[u] = p
+
+	return *p, nil
+}
+
+// UsedFor returns the pass that was used to book the class of a course on the given date, if any.
+func UsedFor(member string, course uint64, date civil.Date) (Pass, bool) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if p, ok := used[usage{member, course, date}]; ok {
+		return *p, true
+	}
+	return Pass{}, false
+}
+
+// Refund gives back the credit that was used to book the class of a course on the given date, which starts at the given time.
+// The credit is only given back if the class is cancelled at least the refund period of the plan before it starts.
+//
+// It returns whether a credit was given back. If no pass was used for the class, nothing happens.
+func Refund(member string, course uint64, date civil.Date, start time.Time) (bool, error) {
+	return giveBack(usage{member, course, date}, func(p *Pass) bool {
+		return time.Until(start) >= p.Plan.RefundBefore
+	})
+}
+
+// Release gives back the credit unconditionally, e.g. because the booking did not go through or the class was cancelled by the studio.
+// It returns whether a credit was given back.
+func Release(member string, course uint64, date civil.Date) (bool, error) {
+	return giveBack(usage{member, course, date}, func(*Pass) bool { return true })
+}
+
+// giveBack removes the usage and gives back the credit if the pass is not unlimited and the condition is met.
+func giveBack(u usage, cond func(*Pass) bool) (bool, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	p, ok := used[u]
+	if !ok {
+		return false, nil
+	}
+
+	es := []journal.Event{{Kind: journal.PassUsed, Key: u.key()}}
+	returned := !p.Plan.Unlimited() && cond(p)
+	if returned {
+		after := *p
+		after.Credits++
+		ev, err := changed(after)
+		if err != nil {
+			return false, err
+		}
+		es = append(es, ev)
+	}
+	if err := journal.Append(es...); err != nil {
+		return false, err
+	}
+
+	delete(used, u)
+	if returned {
+		p.Credits++
+	}
+	return returned, nil
+}
+
+// changed returns the event that the pass changed, for the journal.
+func changed(p Pass) (journal.Event, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return journal.Event{}, err
+	}
+	return journal.Event{Kind: journal.PassChanged, Key: strconv.FormatUint(p.ID, 10), Record: b}, nil
+}
+
+// Restore adds the passes of the journal, which has to be open, and what they were used for. They are not journaled again.
+// Passes issued afterwards get higher IDs than any pass of the journal.
+func Restore() error {
+	passes := make(map[uint64]*Pass)
+	for key, raw := range journal.Records(journal.PassChanged) {
+		p := &Pass{}
+		if err := json.Unmarshal(raw, p); err != nil {
+			return fmt.Errorf("pass %s: %w", key, err)
+		}
+		passes[p.ID] = p
+	}
+
+	uses := make([]use, 0)
+	for key, raw := range journal.Records(journal.PassUsed) {
+		var u use
+		if err := json.Unmarshal(raw, &u); err != nil {
+			return fmt.Errorf("use of a pass %s: %w", key, err)
+		}
+		if _, ok := passes[u.Pass]; !ok {
+			return fmt.Errorf("use of a pass %s: pass %d does not exist", key, u.Pass)
+		}
+		uses = append(uses, u)
+	}
+
+	ps := make([]*Pass, 0, len(passes))
+	for _, p := range passes {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	for _, p := range ps {
+		h := holder{p.Studio, p.Member}
+		byMember[h] = append(byMember[h], p)
+		if p.ID > atomic.LoadUint64(&currID) {
+			atomic.StoreUint64(&currID, p.ID)
+		}
+	}
+	for _, u := range uses {
+		used[usage{u.Member, u.Course, u.Date}] = passes[u.Pass]
+	}
+	return nil
+}
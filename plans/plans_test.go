@@ -0,0 +1,173 @@
+package plans
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+var today civil.Date = civil.DateOf(time.Now())
+
+func TestIssue(t *testing.T) {
+	if _, err := Issue("", "", Catalogue["10-class"], today); err == nil {
+		t.Errorf("issued pass without member name")
+	}
+
+	if _, err := Issue("", "Arnold", Plan{Name: "broken", Credits: 1}, today); err == nil {
+		t.Errorf("issued pass that is never valid")
+	}
+
+	p, err := Issue("", "Arnold", Catalogue["10-class"], today)
+	if err != nil {
+		t.Fatalf("couldn't issue pass: %s", err)
+	}
+	if p.Credits != 10 || p.Until != today.AddDays(364) {
+		t.Errorf("pass not initialized correctly: %+v", p)
+	}
+
+	if ps := Passes("", "Arnold"); len(ps) != 1 || ps[0].ID != p.ID {
+		t.Errorf("couldn't retrieve issued pass")
+	}
+
+	// another studio has its own Arnold
+	if ps := Passes("downtown", "Arnold"); len(ps) != 0 {
+		t.Errorf("pass of the default studio is visible in another studio: %+v", ps)
+	}
+	if _, err := Consume("downtown", "Arnold", 1, today); err != ErrNoEntitlement {
+		t.Errorf("pass of the default studio could be used in another studio, got: %v", err)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	if _, err := Consume("", "Nobody", 1, today); err != ErrNoEntitlement {
+		t.Errorf("member without pass could book, got: %v", err)
+	}
+
+	one := Plan{Name: "single", Credits: 1, Days: 10, RefundBefore: time.Hour}
+	if _, err := Issue("", "Bruce", one, today); err != nil {
+		t.Fatal(err)
+	}
+
+	// not valid before or after the validity period
+	if _, err := Consume("", "Bruce", 1, today.AddDays(-1)); err != ErrNoEntitlement {
+		t.Errorf("could use pass before it is valid, got: %v", err)
+	}
+	if _, err := Consume("", "Bruce", 1, today.AddDays(10)); err != ErrNoEntitlement {
+		t.Errorf("could use pass after it expired, got: %v", err)
+	}
+
+	p, err := Consume("", "Bruce", 1, today.AddDays(1))
+	if err != nil {
+		t.Fatalf("couldn't use pass: %s", err)
+	}
+	if p.Credits != 0 {
+		t.Errorf("credit wasn't consumed, %d left", p.Credits)
+	}
+
+	if _, err = Consume("", "Bruce", 2, today.AddDays(1)); err != ErrNoEntitlement {
+		t.Errorf("could use pass without credits, got: %v", err)
+	}
+
+	// releasing gives back the credit
+	if _, err := Release("Bruce", 1, today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Consume("", "Bruce", 2, today.AddDays(1)); err != nil {
+		t.Errorf("credit wasn't released: %s", err)
+	}
+
+	// unlimited passes are preferred and never run out
+	if _, err = Issue("", "Bruce", Catalogue["monthly-unlimited"], today); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if p, err = Consume("", "Bruce", 3, today.AddDays(i)); err != nil {
+			t.Fatalf("couldn't use unlimited pass: %s", err)
+		}
+		if !p.Plan.Unlimited() {
+			t.Errorf("unlimited pass wasn't preferred")
+		}
+	}
+}
+
+func TestRefund(t *testing.T) {
+	plan := Plan{Name: "double", Credits: 2, Days: 10, RefundBefore: 12 * time.Hour}
+	if _, err := Issue("", "Chuck", plan, today); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []civil.Date{today.AddDays(1), today.AddDays(3)} {
+		if _, err := Consume("", "Chuck", 1, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// too late
+	if ok, err := Refund("Chuck", 1, today.AddDays(1), time.Now().Add(time.Hour)); err != nil || ok {
+		t.Errorf("credit refunded even though the cancellation was late")
+	}
+
+	// in time
+	if ok, err := Refund("Chuck", 1, today.AddDays(3), time.Now().Add(48*time.Hour)); err != nil || !ok {
+		t.Errorf("credit not refunded even though the cancellation was in time")
+	}
+
+	// only once
+	if ok, err := Refund("Chuck", 1, today.AddDays(3), time.Now().Add(48*time.Hour)); err != nil || ok {
+		t.Errorf("credit refunded twice")
+	}
+
+	if ps := Passes("", "Chuck"); ps[0].Credits != 1 {
+		t.Errorf("pass should have 1 credit left, has %d", ps[0].Credits)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	p, err := Issue("", "Diana", Catalogue["10-class"], today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []civil.Date{today.AddDays(1), today.AddDays(2)} {
+		if _, err := Consume("", "Diana", 4, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Release("Diana", 4, today.AddDays(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// as after a restart
+	journal.Close()
+	byMember, used = make(map[holder][]*Pass), make(map[usage]*Pass)
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ps := Passes("", "Diana"); len(ps) != 1 || ps[0].ID != p.ID || ps[0].Credits != 9 {
+		t.Fatalf("pass wasn't restored: %+v", ps)
+	}
+	if u, ok := UsedFor("Diana", 4, today.AddDays(1)); !ok || u.ID != p.ID {
+		t.Errorf("use of the pass wasn't restored")
+	}
+	if _, ok := UsedFor("Diana", 4, today.AddDays(2)); ok {
+		t.Errorf("released use of the pass was restored")
+	}
+	if ok, err := Release("Diana", 4, today.AddDays(1)); err != nil || !ok {
+		t.Errorf("credit of the restored use wasn't given back: %v", err)
+	}
+	if next, err := Issue("", "Diana", Catalogue["10-class"], today); err != nil || next.ID <= p.ID {
+		t.Errorf("pass after restoring has ID %d, want more than %d (%v)", next.ID, p.ID, err)
+	}
+}
@@ -0,0 +1,365 @@
+// Package promos implements promo codes that give a discount on paid classes.
+//
+// A code either takes a percentage off the price (e.g. "SPRING20") or makes the first class of a member free (e.g. "FIRSTCLASS").
+// Codes can be restricted to a course, have a usage limit and expire.
+// Each studio has its own codes and members, so the same code can exist in several studios.
+// The codes, their redemptions and who had their first class are kept in the journal, if one is open (see Restore).
+package promos
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+// A Kind determines what discount a code gives.
+type Kind string
+
+// the kinds of codes
+const (
+	KindPercent    Kind = "percent"     // a percentage off the price
+	KindFirstClass Kind = "first-class" // the first class of a member is free
+)
+
+// A Code is a promo code.
+type Code struct {
+	Studio  string // the ID of the studio the code is valid in, empty for the default studio
+	Code    string
+	Kind    Kind
+	Percent int        // only used for KindPercent
+	Course  uint64     // the ID of the course the code is restricted to, zero means all courses
+	Limit   int        // how often the code can be redeemed in total, zero means no limit
+	Expires civil.Date // the last day the code can be redeemed, zero means never
+
+	Active      bool
+	Redemptions int
+}
+
+// a studioCode is a code of a studio
+type studioCode struct {
+	studio, code string
+}
+
+// a customer is a member of a studio
+type customer struct {
+	studio, member string
+}
+
+var (
+	// all codes, by studio and code
+	byCode map[studioCode]*Code = make(map[studioCode]*Code)
+	// the members that redeemed each code and how often
+	redeemedBy map[studioCode]map[string]int = make(map[studioCode]map[string]int)
+	// the members that had their first class in their studio, i.e. booked a class or redeemed a first-class code (see Booked)
+	hadFirstClass map[customer]bool = make(map[customer]bool)
+
+	// protect maps with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// normalize makes codes case-insensitive.
+func normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Create adds a new, active promo code to the studio of the code.
+func Create(c Code) (Code, error) {
+	c.Code = normalize(c.Code)
+	if c.Code == "" {
+		return Code{}, fmt.Errorf("please provide a code")
+	}
+
+	switch c.Kind {
+	case KindPercent:
+		if c.Percent < 1 || c.Percent > 100 {
+			return Code{}, fmt.Errorf("invalid code parameters: percent (%d) must be between 1 and 100", c.Percent)
+		}
+	case KindFirstClass:
+	default:
+		return Code{}, fmt.Errorf("invalid code parameters: kind '%s' does not exist", c.Kind)
+	}
+
+	if c.Limit < 0 {
+		return Code{}, fmt.Errorf("invalid code parameters: limit (%d) must not be negative", c.Limit)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	k := studioCode{c.Studio, c.Code}
+	if _, ok := byCode[k]; ok {
+		return Code{}, fmt.Errorf("the code %s already exists", c.Code)
+	}
+
+	c.Active = true
+	c.Redemptions = 0
+	if err := journalRecords(codeRecord(c)); err != nil {
+		return Code{}, err
+	}
+	byCode[k] = &c
+	redeemedBy[k] = make(map[string]int)
+
+	return c, nil
+}
+
+// Deactivate makes sure a code of the studio can't be redeemed anymore.
+func Deactivate(studio, code string) (Code, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	c, ok := byCode[studioCode{studio, normalize(code)}]
+	if !ok {
+		return Code{}, fmt.Errorf("the code %s does not exist", normalize(code))
+	}
+
+	after := *c
+	after.Active = false
+	if err := journalRecords(codeRecord(after)); err != nil {
+		return Code{}, err
+	}
+
+	*c = after
+	return *c, nil
+}
+
+// Get returns the code of the studio or an error, if it doesn't exist.
+func Get(studio, code string) (Code, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if c, ok := byCode[studioCode{studio, normalize(code)}]; ok {
+		return *c, nil
+	}
+	return Code{}, fmt.Errorf("the code %s does not exist", normalize(code))
+}
+
+// Redeem uses a code of the studio for a member of the studio for the class of a course on a given day and returns the discounted price.
+// If the code can't be used, an error is returned.
+func Redeem(studio, code, member string, course uint64, today civil.Date, price money.Amount) (money.Amount, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	k := studioCode{studio, normalize(code)}
+	c, ok := byCode[k]
+	if !ok || !c.Active {
+		return price, fmt.Errorf("the code %s is not valid", normalize(code))
+	}
+
+	if !c.Expires.IsZero() && today.After(c.Expires) {
+		return price, fmt.Errorf("the code %s expired on %s", c.Code, c.Expires)
+	}
+
+	if c.Course != 0 && c.Course != course {
+		return price, fmt.Errorf("the code %s is not valid for this course", c.Code)
+	}
+
+	if c.Limit > 0 && c.Redemptions >= c.Limit {
+		return price, fmt.Errorf("the code %s has been used up", c.Code)
+	}
+
+	after := *c
+	after.Redemptions++
+	rs := []record{codeRecord(after), redeemedRecord(studio, c.Code, member, redeemedBy[k][member]+1)}
+	discounted := price
+
+	switch c.Kind {
+	case KindPercent:
+		discounted.Minor = price.Minor * int64(100-c.Percent) / 100
+	case KindFirstClass:
+		if hadFirstClass[customer{studio, member}] {
+			return price, fmt.Errorf("the code %s is only valid for your first class", c.Code)
+		}
+		rs = append(rs, firstClassRecord(studio, member, true))
+		discounted.Minor = 0
+	}
+
+	if err := journalRecords(rs...); err != nil {
+		return price, err
+	}
+
+	if c.Kind == KindFirstClass {
+		hadFirstClass[customer{studio, member}] = true
+	}
+	*c = after
+	redeemedBy[k][member]++
+
+	return discounted, nil
+}
+
+// Unredeem undoes the redemption of a code of the studio, e.g. because the booking didn't go through.
+func Unredeem(studio, code, member string) error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	k := studioCode{studio, normalize(code)}
+	c, ok := byCode[k]
+	if !ok || redeemedBy[k][member] == 0 {
+		return nil
+	}
+
+	after := *c
+	after.Redemptions--
+	rs := []record{codeRecord(after), redeemedRecord(studio, c.Code, member, redeemedBy[k][member]-1)}
+	if c.Kind == KindFirstClass {
+		// the member couldn't have redeemed it after another class
+		rs = append(rs, firstClassRecord(studio, member, false))
+	}
+	if err := journalRecords(rs...); err != nil {
+		return err
+	}
+
+	*c = after
+	redeemedBy[k][member]--
+	if c.Kind == KindFirstClass {
+		delete(hadFirstClass, customer{studio, member})
+	}
+	return nil
+}
+
+// Booked records that the member booked a class of the studio, so that first-class codes of the studio are no longer valid for them, even if they cancel it.
+//
+// The class was booked already, so the member had their first class even if it can't be written to the journal. That is logged.
+func Booked(studio, member string) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if hadFirstClass[customer{studio, member}] {
+		return
+	}
+	if err := journalRecords(firstClassRecord(studio, member, true)); err != nil {
+		log.Printf("promos: the first class of %s is not in the journal: %s", member, err)
+	}
+	hadFirstClass[customer{studio, member}] = true
+}
+
+// a redemption is how the journal keeps how often a member redeemed a code
+type redemption struct {
+	Studio string `json:",omitempty"`
+	Code   string
+	Member string
+	Count  int
+}
+
+// a firstClass is how the journal keeps that a member had their first class
+type firstClass struct {
+	Studio string `json:",omitempty"`
+	Member string
+}
+
+// a record is what the journal keeps of a change, removed if the value is nil
+type record struct {
+	kind  journal.Kind
+	key   string
+	value interface{}
+}
+
+// a journaledCode is how the journal keeps a code: the expiry is left out if the code never expires,
+// since a zero date can't be read back
+type journaledCode struct {
+	Code
+	Expires *civil.Date `json:",omitempty"`
+}
+
+// codeRecord returns the record of the code.
+func codeRecord(c Code) record {
+	jc := journaledCode{Code: c}
+	if !c.Expires.IsZero() {
+		jc.Expires = &c.Expires
+	}
+	return record{journal.CodeChanged, c.Studio + "/" + c.Code, jc}
+}
+
+// redeemedRecord returns the record of how often the member of the studio redeemed the code.
+func redeemedRecord(studio, code, member string, count int) record {
+	r := record{kind: journal.CodeRedeemed, key: studio + "/" + code + "/" + member}
+	if count > 0 {
+		r.value = redemption{studio, code, member, count}
+	}
+	return r
+}
+
+// firstClassRecord returns the record of whether the member of the studio had their first class.
+func firstClassRecord(studio, member string, had bool) record {
+	r := record{kind: journal.FirstClass, key: studio + "/" + member}
+	if had {
+		r.value = firstClass{studio, member}
+	}
+	return r
+}
+
+// journalRecords appends the records to the journal, all or none of them.
+func journalRecords(rs ...record) error {
+	es := make([]journal.Event, len(rs))
+	for i, r := range rs {
+		es[i] = journal.Event{Kind: r.kind, Key: r.key}
+		if r.value == nil {
+			continue
+		}
+		b, err := json.Marshal(r.value)
+		if err != nil {
+			return err
+		}
+		es[i].Record = b
+	}
+	return journal.Append(es...)
+}
+
+// Restore adds the codes of the journal, which has to be open, with their redemptions and who had their first class.
+// They are not journaled again.
+func Restore() error {
+	cs := make(map[studioCode]*Code)
+	for key, raw := range journal.Records(journal.CodeChanged) {
+		var jc journaledCode
+		if err := json.Unmarshal(raw, &jc); err != nil {
+			return fmt.Errorf("promo code %s: %w", key, err)
+		}
+		c := jc.Code
+		if jc.Expires != nil {
+			c.Expires = *jc.Expires
+		}
+		cs[studioCode{c.Studio, c.Code}] = &c
+	}
+
+	rs := make([]redemption, 0)
+	for key, raw := range journal.Records(journal.CodeRedeemed) {
+		var r redemption
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return fmt.Errorf("redemption %s: %w", key, err)
+		}
+		if _, ok := cs[studioCode{r.Studio, r.Code}]; !ok {
+			return fmt.Errorf("redemption %s: the code %s does not exist", key, r.Code)
+		}
+		rs = append(rs, r)
+	}
+
+	fs := make([]firstClass, 0)
+	for key, raw := range journal.Records(journal.FirstClass) {
+		var f firstClass
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("first class %s: %w", key, err)
+		}
+		fs = append(fs, f)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	for k, c := range cs {
+		byCode[k] = c
+		redeemedBy[k] = make(map[string]int)
+	}
+	for _, r := range rs {
+		redeemedBy[studioCode{r.Studio, r.Code}][r.Member] = r.Count
+	}
+	for _, f := range fs {
+		hadFirstClass[customer{f.Studio, f.Member}] = true
+	}
+	return nil
+}
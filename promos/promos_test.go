@@ -0,0 +1,220 @@
+package promos
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/journal"
+	"github.com/MarkRosemaker/booking-system/money"
+)
+
+var (
+	today civil.Date   = civil.DateOf(time.Now())
+	price money.Amount = money.New(2000, "EUR")
+)
+
+func TestCreate(t *testing.T) {
+	tables := []struct {
+		c  Code
+		ok bool
+	}{
+		{Code{Code: "", Kind: KindFirstClass}, false},
+		{Code{Code: "BOGUS", Kind: "bogus"}, false},
+		{Code{Code: "ZERO", Kind: KindPercent}, false},
+		{Code{Code: "TOOMUCH", Kind: KindPercent, Percent: 101}, false},
+		{Code{Code: "NEGATIVE", Kind: KindFirstClass, Limit: -1}, false},
+		{Code{Code: "summer10", Kind: KindPercent, Percent: 10}, true},
+		{Code{Code: "SUMMER10", Kind: KindPercent, Percent: 20}, false}, // duplicate
+	}
+
+	for _, table := range tables {
+		_, err := Create(table.c)
+		if table.ok != (err == nil) {
+			t.Errorf("creating %+v returned unexpected error: %v", table.c, err)
+		}
+	}
+
+	if c, err := Get("", "Summer10"); err != nil || !c.Active || c.Percent != 10 {
+		t.Errorf("couldn't get code case-insensitively: %+v, %v", c, err)
+	}
+}
+
+func TestRedeemPercent(t *testing.T) {
+	if _, err := Create(Code{Code: "SPRING20", Kind: KindPercent, Percent: 20, Limit: 2, Expires: today}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Redeem("", "spring20", "Arnold", 1, today, price)
+	if err != nil {
+		t.Fatalf("couldn't redeem code: %s", err)
+	}
+	if p.Minor != 1600 {
+		t.Errorf("wrong discounted price, got: %s, want 16.00 EUR", p)
+	}
+
+	if _, err = Redeem("", "SPRING20", "Bruce", 1, today.AddDays(1), price); err == nil {
+		t.Errorf("could redeem expired code")
+	}
+
+	if _, err = Redeem("", "SPRING20", "Bruce", 1, today, price); err != nil {
+		t.Errorf("couldn't redeem code a second time: %s", err)
+	}
+	if _, err = Redeem("", "SPRING20", "Chuck", 1, today, price); err == nil {
+		t.Errorf("could redeem code more often than the limit")
+	}
+
+	// undoing a redemption makes room again
+	Unredeem("", "SPRING20", "Bruce")
+	if _, err = Redeem("", "SPRING20", "Chuck", 1, today, price); err != nil {
+		t.Errorf("couldn't redeem code after a redemption was undone: %s", err)
+	}
+
+	if _, err = Deactivate("", "SPRING20"); err != nil {
+		t.Fatal(err)
+	}
+	Unredeem("", "SPRING20", "Chuck")
+	if _, err = Redeem("", "SPRING20", "Chuck", 1, today, price); err == nil {
+		t.Errorf("could redeem deactivated code")
+	}
+}
+
+func TestRedeemCourse(t *testing.T) {
+	if _, err := Create(Code{Code: "KARATE50", Kind: KindPercent, Percent: 50, Course: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Redeem("", "KARATE50", "Arnold", 41, today, price); err == nil {
+		t.Errorf("could redeem code for another course")
+	}
+	if p, err := Redeem("", "KARATE50", "Arnold", 42, today, price); err != nil || p.Minor != 1000 {
+		t.Errorf("couldn't redeem code for its course: %s, %v", p, err)
+	}
+}
+
+func TestRedeemFirstClass(t *testing.T) {
+	if _, err := Create(Code{Code: "FIRSTCLASS", Kind: KindFirstClass}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := Redeem("", "FIRSTCLASS", "Diana", 1, today, price); err != nil || !p.IsZero() {
+		t.Errorf("first class isn't free: %s, %v", p, err)
+	}
+	if _, err := Redeem("", "FIRSTCLASS", "Diana", 1, today, price); err == nil {
+		t.Errorf("could redeem code twice")
+	}
+
+	// members who booked before don't get a free class, even if they cancelled
+	Booked("", "Ethan")
+	if _, err := Redeem("", "FIRSTCLASS", "Ethan", 1, today, price); err == nil {
+		t.Errorf("could redeem code even though it's not the first class")
+	}
+
+	// unless the booking with the code didn't go through
+	if _, err := Redeem("", "FIRSTCLASS", "Fiona", 1, today, price); err != nil {
+		t.Fatal(err)
+	}
+	Unredeem("", "FIRSTCLASS", "Fiona")
+	if _, err := Redeem("", "FIRSTCLASS", "Fiona", 1, today, price); err != nil {
+		t.Errorf("couldn't redeem code again after the booking didn't go through: %s", err)
+	}
+}
+
+func TestStudios(t *testing.T) {
+	if _, err := Create(Code{Code: "AUTUMN30", Kind: KindPercent, Percent: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Redeem("downtown", "AUTUMN30", "Gwen", 1, today, price); err == nil {
+		t.Errorf("could redeem code of the default studio in another studio")
+	}
+
+	// the same code in another studio
+	if _, err := Create(Code{Studio: "downtown", Code: "AUTUMN30", Kind: KindFirstClass}); err != nil {
+		t.Fatalf("couldn't create the code in another studio: %s", err)
+	}
+	if c, err := Get("downtown", "AUTUMN30"); err != nil || c.Kind != KindFirstClass {
+		t.Errorf("got the wrong code: %+v, %v", c, err)
+	}
+	if _, err := Deactivate("", "AUTUMN30"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := Get("downtown", "AUTUMN30"); !c.Active {
+		t.Errorf("deactivated the code of another studio")
+	}
+
+	// booking in one studio doesn't use up the first class in another
+	Booked("", "Gwen")
+	if p, err := Redeem("downtown", "AUTUMN30", "Gwen", 1, today, price); err != nil || !p.IsZero() {
+		t.Errorf("first class in another studio isn't free: %s, %v", p, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	if _, err := Create(Code{Code: "WELCOME", Kind: KindFirstClass, Limit: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(Code{Code: "SUMMER", Kind: KindPercent, Percent: 15, Expires: today.AddDays(30)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(Code{Code: "GONE", Kind: KindPercent, Percent: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Deactivate("", "GONE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Redeem("", "WELCOME", "Gina", 1, today, price); err != nil {
+		t.Fatal(err)
+	}
+	Booked("", "Hank")
+	if _, err := Create(Code{Studio: "downtown", Code: "WELCOME", Kind: KindPercent, Percent: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Redeem("downtown", "WELCOME", "Gina", 1, today, price); err != nil {
+		t.Fatal(err)
+	}
+
+	// as after a restart
+	journal.Close()
+	byCode, redeemedBy, hadFirstClass = make(map[studioCode]*Code), make(map[studioCode]map[string]int), make(map[customer]bool)
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	// WELCOME never expires
+	if c, err := Get("", "WELCOME"); err != nil || c.Redemptions != 1 || !c.Active || !c.Expires.IsZero() {
+		t.Errorf("code wasn't restored: %+v, %v", c, err)
+	}
+	if c, err := Get("", "SUMMER"); err != nil || c.Expires != today.AddDays(30) {
+		t.Errorf("code with expiry wasn't restored: %+v, %v", c, err)
+	}
+	if c, err := Get("", "GONE"); err != nil || c.Active {
+		t.Errorf("deactivated code wasn't restored: %+v, %v", c, err)
+	}
+	for _, member := range []string{"Gina", "Hank"} {
+		if _, err := Redeem("", "WELCOME", member, 1, today, price); err == nil {
+			t.Errorf("%s could redeem the first-class code again after restoring", member)
+		}
+	}
+
+	if c, err := Get("downtown", "WELCOME"); err != nil || c.Kind != KindPercent || c.Redemptions != 1 {
+		t.Errorf("code of another studio wasn't restored: %+v, %v", c, err)
+	}
+
+	// undoing a redemption after restoring
+	if err := Unredeem("", "WELCOME", "Gina"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := Get("", "WELCOME"); c.Redemptions != 0 {
+		t.Errorf("redemption wasn't undone: %+v", c)
+	}
+}
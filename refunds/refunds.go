@@ -0,0 +1,127 @@
+// Package refunds implements what happens to the money and credits of a booking when it is cancelled.
+//
+// If the studio cancels a class, customers get everything back. If a member cancels, the refund depends on how close to the class that happens (see Policy).
+// Pass holders get their credit back instead of money. All results are recorded in the ledger.
+package refunds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/plans"
+)
+
+// A Tier gives back a percentage of the payment if a booking is cancelled at least some time before the class starts.
+type Tier struct {
+	Before  time.Duration
+	Percent int
+}
+
+// A Policy is a list of tiers. The tier with the longest duration that is met applies.
+// If no tier applies, nothing is refunded.
+type Policy []Tier
+
+// Default is the refund policy of the studio.
+var Default = Policy{
+	{Before: 48 * time.Hour, Percent: 100},
+	{Before: 24 * time.Hour, Percent: 50},
+}
+
+// Percent returns the percentage that is refunded if a booking is cancelled the given time before the class starts.
+func (p Policy) Percent(left time.Duration) int {
+	tiers := make(Policy, len(p))
+	copy(tiers, p)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Before > tiers[j].Before
+	})
+
+	for _, t := range tiers {
+		if left >= t.Before {
+			return t.Percent
+		}
+	}
+	return 0
+}
+
+// A Result is what the customer got back after a cancellation.
+type Result struct {
+	Refunded       money.Amount
+	Fee            money.Amount
+	CreditReturned bool
+}
+
+// Process settles a cancelled booking of a class that starts at the given time.
+// If byStudio is set, the class was cancelled by the studio and the customer gets everything back. Otherwise, the refund policy applies.
+func Process(ctx context.Context, b ledger.Booking, start time.Time, byStudio bool) (Result, error) {
+	var res Result
+
+	// pass holders get their credit back instead of money
+	if pass, ok := plans.UsedFor(b.Member, b.Course, b.Date); ok {
+		var err error
+		if byStudio {
+			res.CreditReturned, err = plans.Release(b.Member, b.Course, b.Date)
+		} else {
+			res.CreditReturned, err = plans.Refund(b.Member, b.Course, b.Date, start)
+		}
+		if err != nil {
+			return res, fmt.Errorf("the credit could not be given back: %w", err)
+		}
+
+		switch {
+		case pass.Plan.Unlimited():
+		case res.CreditReturned:
+			ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindCreditReturned, Pass: pass.ID})
+		default:
+			ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindCreditForfeited, Pass: pass.ID,
+				Note: fmt.Sprintf("cancelled less than %s before the class", pass.Plan.RefundBefore)})
+		}
+	}
+
+	charge, ok := ledger.OpenCharge(b)
+	if !ok {
+		return res, nil
+	}
+
+	percent := 100
+	if !byStudio {
+		percent = Default.Percent(time.Until(start))
+	}
+
+	res.Refunded = charge.Amount
+	res.Refunded.Minor = charge.Amount.Minor * int64(percent) / 100
+	res.Fee = charge.Amount
+	res.Fee.Minor -= res.Refunded.Minor
+
+	if !res.Refunded.IsZero() {
+		if err := payments.Refund(ctx, *charge.Receipt, res.Refunded); err != nil {
+			return res, fmt.Errorf("the payment %s could not be refunded: %w", charge.Receipt.ID, err)
+		}
+
+		note := fmt.Sprintf("%d%% refund", percent)
+		if byStudio {
+			note = "class cancelled by the studio"
+		}
+		ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindRefund, Amount: res.Refunded, Note: note})
+	}
+
+	if !res.Fee.IsZero() {
+		ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindFee, Amount: res.Fee,
+			Note: fmt.Sprintf("cancelled %s before the class", time.Until(start).Round(time.Minute))})
+	}
+
+	return res, nil
+}
+
+// Outstanding returns whether Process still has something to give back for the booking, i.e. a credit or an open charge.
+func Outstanding(b ledger.Booking) bool {
+	if _, ok := plans.UsedFor(b.Member, b.Course, b.Date); ok {
+		return true
+	}
+	_, ok := ledger.OpenCharge(b)
+	return ok
+}
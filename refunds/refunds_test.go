@@ -0,0 +1,138 @@
+package refunds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/ledger"
+	"github.com/MarkRosemaker/booking-system/money"
+	"github.com/MarkRosemaker/booking-system/payments"
+	"github.com/MarkRosemaker/booking-system/payments/fake"
+	"github.com/MarkRosemaker/booking-system/plans"
+)
+
+var today civil.Date = civil.DateOf(time.Now())
+
+func TestPercent(t *testing.T) {
+	tables := []struct {
+		left    time.Duration
+		percent int
+	}{
+		{72 * time.Hour, 100},
+		{48 * time.Hour, 100},
+		{30 * time.Hour, 50},
+		{24 * time.Hour, 50},
+		{time.Hour, 0},
+		{-time.Hour, 0},
+	}
+
+	for _, table := range tables {
+		if p := Default.Percent(table.left); p != table.percent {
+			t.Errorf("refund %s before class was incorrect, got: %d%%, want: %d%%", table.left, p, table.percent)
+		}
+	}
+}
+
+// charge pays for a booking and records it in the ledger.
+func charge(t *testing.T, g *fake.Gateway, b ledger.Booking, a money.Amount) payments.Receipt {
+	r, err := g.Charge(context.Background(), payments.Charge{Member: b.Member, Amount: a, Token: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Record(ledger.Entry{Booking: b, Kind: ledger.KindCharge, Amount: a, Receipt: &r})
+	return r
+}
+
+func TestProcessPayment(t *testing.T) {
+	g := fake.New(0)
+	payments.Use(g)
+	ctx := context.Background()
+	price := money.New(2000, "EUR")
+
+	tables := []struct {
+		member   string
+		left     time.Duration
+		byStudio bool
+		refunded int64
+		fee      int64
+	}{
+		{"Arnold", 72 * time.Hour, false, 2000, 0},
+		{"Bruce", 30 * time.Hour, false, 1000, 1000},
+		{"Chuck", time.Hour, false, 0, 2000},
+		{"Diana", time.Hour, true, 2000, 0},
+	}
+
+	for _, table := range tables {
+		b := ledger.Booking{Course: 1, Member: table.member, Date: today}
+		r := charge(t, g, b, price)
+		if !Outstanding(b) {
+			t.Errorf("charge of %s not outstanding before cancellation", table.member)
+		}
+
+		res, err := Process(ctx, b, time.Now().Add(table.left), table.byStudio)
+		if err != nil {
+			t.Fatalf("couldn't process cancellation of %s: %s", table.member, err)
+		}
+		if res.Refunded.Minor != table.refunded || res.Fee.Minor != table.fee {
+			t.Errorf("wrong result for %s, got: %+v, want: %d refunded and %d fee", table.member, res, table.refunded, table.fee)
+		}
+		if kept := g.Charged(r.ID); kept.Minor != table.fee {
+			t.Errorf("gateway kept %s of %s, want: %d", kept, table.member, table.fee)
+		}
+		if _, open := ledger.OpenCharge(b); open || Outstanding(b) {
+			t.Errorf("charge of %s still open after cancellation", table.member)
+		}
+
+		// nothing happens a second time
+		if res, _ = Process(ctx, b, time.Now().Add(table.left), table.byStudio); res != (Result{}) {
+			t.Errorf("cancellation of %s processed twice: %+v", table.member, res)
+		}
+	}
+}
+
+func TestProcessCredit(t *testing.T) {
+	plan := plans.Plan{Name: "test", Credits: 5, Days: 10, RefundBefore: 12 * time.Hour}
+	if _, err := plans.Issue("", "Ethan", plan, today); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tables := []struct {
+		course   uint64
+		left     time.Duration
+		byStudio bool
+		returned bool
+		kind     ledger.Kind
+	}{
+		{1, 24 * time.Hour, false, true, ledger.KindCreditReturned},
+		{2, time.Hour, false, false, ledger.KindCreditForfeited},
+		{3, time.Hour, true, true, ledger.KindCreditReturned},
+	}
+
+	for _, table := range tables {
+		b := ledger.Booking{Course: table.course, Member: "Ethan", Date: today.AddDays(1)}
+		if _, err := plans.Consume("", b.Member, b.Course, b.Date); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := Process(ctx, b, time.Now().Add(table.left), table.byStudio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.CreditReturned != table.returned || !res.Refunded.IsZero() {
+			t.Errorf("wrong result for course %d: %+v", table.course, res)
+		}
+
+		es := ledger.Of(b)
+		if len(es) == 0 || es[len(es)-1].Kind != table.kind {
+			t.Errorf("wrong ledger entries for course %d: %+v", table.course, es)
+		}
+	}
+
+	if ps := plans.Passes("", "Ethan"); ps[0].Credits != 4 {
+		t.Errorf("pass should have 4 credits left, has %d", ps[0].Credits)
+	}
+}
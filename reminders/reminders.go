@@ -0,0 +1,294 @@
+// Package reminders reminds members of the classes they booked, a day and an hour before they start.
+//
+// The reminders are not stored, but worked out from the bookings whenever the scheduler looks for due ones, so a cancelled booking or class gets none.
+// Only which reminders were sent is kept, optionally in a file, so that none is sent twice, not even after a restart.
+package reminders
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/notify"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+// Before are how long before a class starts its attendees are reminded of it.
+var Before = []time.Duration{24 * time.Hour, time.Hour}
+
+// Grace is how late a reminder is still sent, e.g. after a restart.
+// Reminders that were due longer ago are skipped, e.g. the one a day before a class that was booked an hour before it.
+const Grace = 15 * time.Minute
+
+// Interval is how often the scheduler looks for due reminders at least.
+const Interval = time.Minute
+
+// A Reminder reminds a member of a class they booked.
+type Reminder struct {
+	Studio string // the ID, empty for the default studio
+	Course uint64
+	Date   civil.Date
+	Member string
+	Start  time.Time     // of the class
+	Before time.Duration // how long before the start the reminder is due
+}
+
+// Due returns when the reminder is due.
+func (r Reminder) Due() time.Time {
+	return r.Start.Add(-r.Before)
+}
+
+// key identifies the reminder among those sent.
+// It contains the start of the class, so that members get reminded again if their class moves.
+func (r Reminder) key() string {
+	return fmt.Sprintf("%s/%d/%s/%s/%d/%s", r.Studio, r.Course, r.Date, r.Member, r.Start.Unix(), r.Before)
+}
+
+// now returns the current time, tests can change it.
+var now = time.Now
+
+var (
+	// the reminders that were sent, by key, for classes that haven't started
+	sent map[string]Reminder = make(map[string]Reminder)
+	// where they are kept across restarts, empty if not
+	file string
+
+	// protect map and file with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Upcoming returns the reminders of the booked classes of all studios that haven't started, sorted by when they are due.
+// Reminders that are due or were sent are included, too.
+func Upcoming(t time.Time) []Reminder {
+	var longest time.Duration
+	for _, b := range Before {
+		if b > longest {
+			longest = b
+		}
+	}
+	first, last := civil.DateOf(t), civil.DateOf(t.Add(longest))
+
+	rs := make([]Reminder, 0)
+	for _, s := range append([]tenants.Studio{tenants.Default}, tenants.All()...) {
+		// only the courses with classes in the time frame, found in the index of the registry
+		for _, c := range s.Courses.Between(first, last) {
+			for date := first; !date.After(last); date = date.AddDays(1) {
+				if date.Before(c.Start()) || date.After(c.End()) || c.Cancelled(date) {
+					continue
+				}
+				start := c.ClassStart(date)
+				if !start.After(t) {
+					continue
+				}
+				attendees, err := c.Attendees(date)
+				if err != nil {
+					continue
+				}
+				for _, m := range attendees {
+					for _, b := range Before {
+						rs = append(rs, Reminder{s.ID, c.ID(), date, m, start, b})
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].Due().Before(rs[j].Due()) })
+	return rs
+}
+
+// Start loads which reminders were sent from the file, if given, and starts sending the reminders that are due.
+// Those sent from now on are added to the file. The returned function stops sending.
+func Start(path string) (stop func()) {
+	if err := load(path); err != nil {
+		log.Printf("reminders: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			wait := Interval
+			if next := remind(now()); !next.IsZero() && next.Sub(now()) < wait {
+				wait = next.Sub(now())
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// remind sends the reminders that are due at the time and haven't been sent. It returns when the next one is due, zero if none is.
+func remind(t time.Time) (next time.Time) {
+	mux.Lock()
+	for k, r := range sent {
+		if !r.Start.After(t) {
+			delete(sent, k)
+		}
+	}
+	mux.Unlock()
+
+	for _, r := range Upcoming(t) {
+		switch due := r.Due(); {
+		case due.After(t):
+			if next.IsZero() {
+				next = due
+			}
+		case t.Sub(due) <= Grace:
+			send(r)
+		}
+	}
+	return next
+}
+
+// send sends the reminder, unless it was sent before, through the channels to the member: an email (see package notify) and the webhooks (see package webhooks).
+// It is recorded as sent first, so that it is sent at most once.
+func send(r Reminder) {
+	s := tenants.Default
+	if r.Studio != "" {
+		var err error
+		if s, err = tenants.Get(r.Studio); err != nil {
+			return
+		}
+	}
+	c, err := s.Courses.Get(r.Course)
+	if err != nil {
+		return
+	}
+
+	if !attends(c, r.Member, r.Date) {
+		// cancelled since we looked
+		return
+	}
+
+	ok, err := record(r)
+	if err != nil {
+		log.Printf("reminders: %s", err)
+	}
+	if !ok {
+		return
+	}
+
+	notify.Notify(notify.ClassReminder, notify.DataOf(c, r.Member, r.Date))
+
+	e := bus.Event{Kind: bus.ClassReminder, Course: c.ID(), Date: r.Date, Member: r.Member, CourseFree: c.FreePlaces()}
+	if attendees, err := c.Attendees(r.Date); err == nil && c.Capacity() > len(attendees) {
+		e.ClassFree = c.Capacity() - len(attendees)
+	}
+	bus.Publish(e)
+}
+
+// attends returns whether the member attends the class of the course on the date.
+func attends(c *course.Course, member string, date civil.Date) bool {
+	if c.Cancelled(date) {
+		return false
+	}
+	attendees, _ := c.Attendees(date)
+	for _, a := range attendees {
+		if a == member {
+			return true
+		}
+	}
+	return false
+}
+
+// record records the reminder as sent and adds it to the file, if any.
+// It returns false if it had been sent before and an error if it couldn't be added to the file.
+func record(r Reminder) (bool, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	k := r.key()
+	if _, ok := sent[k]; ok {
+		return false, nil
+	}
+	sent[k] = r
+
+	if file == "" {
+		return true, nil
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+	return true, json.NewEncoder(f).Encode(r)
+}
+
+// load forgets which reminders were sent and loads them from the file, if given.
+// The file is then rewritten without the reminders of classes that have started.
+func load(path string) error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	sent, file = make(map[string]Reminder), path
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	t := now()
+	rs := make([]Reminder, 0)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Reminder
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// e.g. the last line, if we stopped while writing it
+			continue
+		}
+		if r.Start.After(t) {
+			sent[r.key()] = r
+			rs = append(rs, r)
+		}
+	}
+	f.Close()
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	// replace the file at once, so that it is complete even if we stop while writing
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".reminders-*")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(tmp)
+	for _, r := range rs {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,146 @@
+package reminders
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/mail"
+	"github.com/MarkRosemaker/booking-system/mail/outbox"
+	"github.com/MarkRosemaker/booking-system/notify"
+)
+
+// reminded returns the members that were reminded according to the events on the channel.
+func reminded(events <-chan bus.Event) []string {
+	ms := make([]string, 0)
+	for {
+		select {
+		case e := <-events:
+			if e.Kind == bus.ClassReminder {
+				ms = append(ms, e.Member)
+			}
+		default:
+			return ms
+		}
+	}
+}
+
+func TestRemind(t *testing.T) {
+	notify.Dir = filepath.Join("..", "site", "emails")
+	o := outbox.New("")
+	mail.Use(o)
+	defer mail.Use(nil)
+
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	// a file that is kept across restarts
+	path := filepath.Join(t.TempDir(), "reminders.jsonl")
+	if err := load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	today := civil.DateOf(time.Now())
+	tomorrow, later := today.AddDays(1), today.AddDays(2)
+	c, err := course.New("Reminder Yoga", today, today.AddDays(3), 10, course.StartingAt(civil.Time{Hour: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := courses.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"Arnold", "Berta"} {
+		if err := c.BookClass(m, tomorrow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.BookClass("Dora", later); err != nil {
+		t.Fatal(err)
+	}
+	if err := notify.SetAddress("Arnold", "arnold@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	start := c.ClassStart(tomorrow)
+
+	for _, tc := range []struct {
+		name   string
+		before func()
+		at     time.Time
+		want   []string
+	}{
+		{"too early", nil, start.Add(-25 * time.Hour), []string{}},
+		{"a day before", nil, start.Add(-24*time.Hour + time.Minute), []string{"Arnold", "Berta"}},
+		{"sent", nil, start.Add(-24*time.Hour + 2*time.Minute), []string{}},
+		{"restarted", func() {
+			if err := load(path); err != nil {
+				t.Fatal(err)
+			}
+		}, start.Add(-24*time.Hour + 3*time.Minute), []string{}},
+		{"an hour before, after a cancellation", func() {
+			if err := c.CancelBooking("Berta", tomorrow); err != nil {
+				t.Fatal(err)
+			}
+		}, start.Add(-time.Hour), []string{"Arnold"}},
+		{"too late", nil, c.ClassStart(later).Add(-time.Hour - Grace - time.Minute), []string{}},
+		{"cancelled class", func() {
+			if _, err := c.CancelClass(later); err != nil {
+				t.Fatal(err)
+			}
+		}, c.ClassStart(later).Add(-time.Hour), []string{}},
+	} {
+		if tc.before != nil {
+			tc.before()
+		}
+		remind(tc.at)
+
+		got := reminded(events)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: reminded %v, want: %v", tc.name, got, tc.want)
+		}
+	}
+
+	// only Arnold has an address
+	ms, err := o.Wait(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ms {
+		if m.To != "arnold@example.com" || !strings.HasPrefix(m.Subject, "Reminder: Reminder Yoga on ") || !strings.HasSuffix(m.Subject, " at 10:00") {
+			t.Errorf("wrong email: %v", m)
+		}
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	today := civil.DateOf(time.Now())
+	c, err := course.New("Upcoming Pilates", today, today.AddDays(9), 10, course.StartingAt(civil.Time{Hour: 18}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := courses.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, date := range []civil.Date{today.AddDays(1), today.AddDays(5)} {
+		if err := c.BookClass("Emil", date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rs []Reminder
+	for _, r := range Upcoming(c.ClassStart(today)) {
+		if r.Member == "Emil" {
+			rs = append(rs, r)
+		}
+	}
+
+	// only the class of tomorrow is within a day
+	if len(rs) != 2 || rs[0].Before != 24*time.Hour || rs[1].Before != time.Hour || rs[0].Date != today.AddDays(1) {
+		t.Errorf("wrong reminders: %v", rs)
+	}
+}
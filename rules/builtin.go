@@ -0,0 +1,169 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// AdvanceWindow opens bookings for a class a number of days before the class takes place.
+type AdvanceWindow struct {
+	Days int
+}
+
+// Check implements the Rule interface.
+func (r AdvanceWindow) Check(b Booking) error {
+	opens := b.Date.AddDays(-r.Days)
+	if civil.DateOf(b.Now).Before(opens) {
+		return Violation{CodeNotYetOpen, fmt.Sprintf(
+			"bookings for this class open %d days in advance, on %s", r.Days, opens)}
+	}
+	return nil
+}
+
+// Cutoff closes bookings for a class some time before the class starts.
+type Cutoff struct {
+	Before time.Duration
+}
+
+// Check implements the Rule interface.
+func (r Cutoff) Check(b Booking) error {
+	closes := b.Course.ClassStart(b.Date).Add(-r.Before)
+	if !b.Now.Before(closes) {
+		return Violation{CodeClosed, fmt.Sprintf(
+			"bookings for this class closed %s before the class starts", r.Before)}
+	}
+	return nil
+}
+
+// WeeklyLimit limits the number of active bookings a member can have in a week (Monday to Sunday).
+// All courses are taken into account, not just the one that is booked.
+type WeeklyLimit struct {
+	Max int
+}
+
+// Check implements the Rule interface.
+func (r WeeklyLimit) Check(b Booking) error {
+	today := civil.DateOf(b.Now)
+	monday := b.Date.AddDays(-(int(b.Date.In(time.UTC).Weekday()) + 6) % 7)
+	sunday := monday.AddDays(6)
+
+	count := 0
+	for _, c := range b.registry().All() {
+		if c.End().Before(monday) || c.Start().After(sunday) {
+			continue
+		}
+		for _, d := range c.BookedDates(b.Member) {
+			// only count active bookings, i.e. not those in the past
+			if !d.Before(monday) && !d.After(sunday) && !d.Before(today) {
+				count++
+			}
+		}
+	}
+
+	if count >= r.Max {
+		return Violation{CodeWeeklyLimit, fmt.Sprintf(
+			"you can only have %d active bookings in the week of %s", r.Max, monday)}
+	}
+	return nil
+}
+
+// Blocked prevents the given members from booking.
+// Spaces around the names are ignored, e.g. " Bruce" blocks "Bruce".
+type Blocked struct {
+	Members []string
+}
+
+// Check implements the Rule interface.
+func (r Blocked) Check(b Booking) error {
+	for _, m := range r.Members {
+		if strings.TrimSpace(m) == b.Member {
+			return Violation{CodeBlocked, "you are not allowed to book this course"}
+		}
+	}
+	return nil
+}
+
+// the names of the builtin rules in the journal
+const (
+	kindAdvanceWindow = "advance-window"
+	kindCutoff        = "cutoff"
+	kindWeeklyLimit   = "weekly-limit"
+	kindBlocked       = "blocked"
+)
+
+// an encoded rule is how a rule is kept in the journal
+type encoded struct {
+	Kind string
+	Rule json.RawMessage
+}
+
+// encode returns the rules as they are kept in the journal, nil if there are none.
+// Only the builtin rules can be encoded.
+func encode(l List) (json.RawMessage, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+
+	es := make([]encoded, len(l))
+	for i, r := range l {
+		switch r.(type) {
+		case AdvanceWindow:
+			es[i].Kind = kindAdvanceWindow
+		case Cutoff:
+			es[i].Kind = kindCutoff
+		case WeeklyLimit:
+			es[i].Kind = kindWeeklyLimit
+		case Blocked:
+			es[i].Kind = kindBlocked
+		default:
+			return nil, fmt.Errorf("the rule %T can't be kept in the journal", r)
+		}
+
+		var err error
+		if es[i].Rule, err = json.Marshal(r); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(es)
+}
+
+// decode returns the rules as they were kept in the journal.
+func decode(raw json.RawMessage) (List, error) {
+	var es []encoded
+	if err := json.Unmarshal(raw, &es); err != nil {
+		return nil, err
+	}
+
+	l := make(List, len(es))
+	for i, e := range es {
+		var err error
+		switch e.Kind {
+		case kindAdvanceWindow:
+			var r AdvanceWindow
+			err = json.Unmarshal(e.Rule, &r)
+			l[i] = r
+		case kindCutoff:
+			var r Cutoff
+			err = json.Unmarshal(e.Rule, &r)
+			l[i] = r
+		case kindWeeklyLimit:
+			var r WeeklyLimit
+			err = json.Unmarshal(e.Rule, &r)
+			l[i] = r
+		case kindBlocked:
+			var r Blocked
+			err = json.Unmarshal(e.Rule, &r)
+			l[i] = r
+		default:
+			err = fmt.Errorf("unknown rule '%s'", e.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,177 @@
+// Package rules implements configurable booking rules for courses.
+//
+// Every course can have its own list of rules. When a customer books a class, the rules of the course are evaluated in order and the first violation is returned.
+package rules
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+// A Code is a machine-readable identifier of a rule violation.
+type Code string
+
+// the codes of the rule violations
+const (
+	CodeNotYetOpen  Code = "booking_not_yet_open"
+	CodeClosed      Code = "booking_closed"
+	CodeWeeklyLimit Code = "weekly_limit_reached"
+	CodeBlocked     Code = "member_blocked"
+)
+
+// A Violation is returned if a booking breaks a rule.
+type Violation struct {
+	Code    Code
+	Message string
+}
+
+// Error implements the error interface.
+// The code is included so that clients don't need to parse the message.
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Code, v.Message)
+}
+
+// A Booking holds everything a rule needs to know about a booking attempt.
+type Booking struct {
+	Course *course.Course
+	Member string
+	Date   civil.Date
+	Now    time.Time
+
+	// the courses the course belongs to, e.g. to count the member's other bookings; the default registry if nil
+	Courses *courses.Registry
+}
+
+// registry returns the courses the course of the booking belongs to.
+func (b Booking) registry() *courses.Registry {
+	if b.Courses == nil {
+		return courses.Default
+	}
+	return b.Courses
+}
+
+// A Rule checks whether a booking is allowed.
+type Rule interface {
+	Check(b Booking) error
+}
+
+// A List is a list of rules that are evaluated in order.
+type List []Rule
+
+// Check evaluates all rules in order and returns the first violation, if any.
+func (l List) Check(b Booking) error {
+	for _, r := range l {
+		if err := r.Check(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	// the rules of each course, by course ID
+	byCourse map[uint64]List = make(map[uint64]List)
+	// the locks of the members who are booking, by name (see Lock)
+	locks map[string]*memberLock = make(map[string]*memberLock)
+
+	// protect maps with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// a memberLock is held while a member books
+type memberLock struct {
+	sync.Mutex
+	waiting int // how many hold or wait for the lock, so that it can be removed when nobody does
+}
+
+// Lock locks the bookings of the member until the returned function is called.
+// Rules that look at the member's other bookings, e.g. WeeklyLimit, only hold if the lock is held from the check until the class is booked,
+// since otherwise two bookings at the same time could both pass the check.
+func Lock(member string) (unlock func()) {
+	mux.Lock()
+	l, ok := locks[member]
+	if !ok {
+		l = &memberLock{}
+		locks[member] = l
+	}
+	l.waiting++
+	mux.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		mux.Lock()
+		defer mux.Unlock()
+		if l.waiting--; l.waiting == 0 {
+			delete(locks, member)
+		}
+	}
+}
+
+// Configure sets the rules for the course with the given ID, replacing any previous rules.
+// The rules are kept in the journal, if one is open, so only the builtin rules can be set then (see Restore).
+func Configure(id uint64, l List) error {
+	raw, err := encode(l)
+	if err != nil && journal.Recording() {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if err := journal.Append(journal.Event{Kind: journal.RulesConfigured, Course: id, Rules: raw}); err != nil {
+		return err
+	}
+	configure(id, l)
+	return nil
+}
+
+// Restore sets the rules of the courses as the journal, which has to be open, has them. They are not journaled again.
+func Restore() error {
+	ls := make(map[uint64]List)
+	for id, raw := range journal.Rules() {
+		l, err := decode(raw)
+		if err != nil {
+			return fmt.Errorf("rules of course %d: %w", id, err)
+		}
+		ls[id] = l
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	for id, l := range ls {
+		configure(id, l)
+	}
+	return nil
+}
+
+// configure sets the rules for the course. The caller holds the lock.
+func configure(id uint64, l List) {
+	if len(l) == 0 {
+		delete(byCourse, id)
+		return
+	}
+	byCourse[id] = l
+}
+
+// Of returns the rules of the course with the given ID.
+func Of(id uint64) List {
+	mux.Lock()
+	defer mux.Unlock()
+
+	return byCourse[id]
+}
+
+// Check evaluates the rules of the course that is booked.
+func Check(b Booking) error {
+	return Of(b.Course.ID()).Check(b)
+}
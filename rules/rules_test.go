@@ -0,0 +1,232 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/journal"
+)
+
+var today civil.Date = civil.DateOf(time.Now())
+
+func getTestCourse(t *testing.T, name string) *course.Course {
+	c, err := course.New(name, today, today.AddDays(30), 20,
+		course.StartingAt(civil.Time{Hour: 18}))
+	if err != nil {
+		t.Fatalf("couldn't create course: %s", err)
+	}
+	if err = courses.Add(c); err != nil {
+		t.Fatalf("couldn't add course: %s", err)
+	}
+	return c
+}
+
+// code returns the code of the violation or an empty string.
+func code(err error) Code {
+	var v Violation
+	if errors.As(err, &v) {
+		return v.Code
+	}
+	return ""
+}
+
+func TestAdvanceWindow(t *testing.T) {
+	c := getTestCourse(t, "Karate")
+	r := AdvanceWindow{Days: 7}
+
+	if err := r.Check(Booking{c, "Arnold", today.AddDays(7), time.Now(), nil}); err != nil {
+		t.Errorf("couldn't book class 7 days in advance: %s", err)
+	}
+
+	if err := r.Check(Booking{c, "Arnold", today.AddDays(8), time.Now(), nil}); code(err) != CodeNotYetOpen {
+		t.Errorf("wrong violation when booking 8 days in advance, got: %v", err)
+	}
+}
+
+func TestCutoff(t *testing.T) {
+	c := getTestCourse(t, "Judo")
+	r := Cutoff{Before: 2 * time.Hour}
+	date := today.AddDays(1)
+	start := c.ClassStart(date)
+
+	if err := r.Check(Booking{c, "Arnold", date, start.Add(-3 * time.Hour), nil}); err != nil {
+		t.Errorf("couldn't book class 3 hours before it starts: %s", err)
+	}
+
+	if err := r.Check(Booking{c, "Arnold", date, start.Add(-time.Hour), nil}); code(err) != CodeClosed {
+		t.Errorf("wrong violation when booking 1 hour before class starts, got: %v", err)
+	}
+}
+
+func TestWeeklyLimit(t *testing.T) {
+	c := getTestCourse(t, "Yoga")
+	r := WeeklyLimit{Max: 2}
+
+	// find the next monday so that the whole week lies within the course
+	monday := today.AddDays(7 - (int(today.In(time.UTC).Weekday())+6)%7)
+
+	for i := 0; i < 2; i++ {
+		b := Booking{c, "Arnold", monday.AddDays(i), time.Now(), nil}
+		if err := r.Check(b); err != nil {
+			t.Fatalf("couldn't book class %d of the week: %s", i+1, err)
+		}
+		if err := c.BookClass(b.Member, b.Date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Check(Booking{c, "Arnold", monday.AddDays(6), time.Now(), nil}); code(err) != CodeWeeklyLimit {
+		t.Errorf("wrong violation when booking a third class in a week, got: %v", err)
+	}
+
+	// the next week is fine
+	if err := r.Check(Booking{c, "Arnold", monday.AddDays(7), time.Now(), nil}); err != nil {
+		t.Errorf("couldn't book class in the following week: %s", err)
+	}
+
+	// other members are not affected
+	if err := r.Check(Booking{c, "Bruce", monday.AddDays(6), time.Now(), nil}); err != nil {
+		t.Errorf("other member couldn't book class: %s", err)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	c := getTestCourse(t, "Boxing")
+	r := Blocked{Members: []string{"Chuck", " Bruce "}}
+
+	for _, name := range []string{"Chuck", "Bruce"} {
+		if err := r.Check(Booking{c, name, today.AddDays(1), time.Now(), nil}); code(err) != CodeBlocked {
+			t.Errorf("wrong violation for blocked member %s, got: %v", name, err)
+		}
+	}
+
+	if err := r.Check(Booking{c, "Arnold", today.AddDays(1), time.Now(), nil}); err != nil {
+		t.Errorf("member who isn't blocked couldn't book: %s", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	c := getTestCourse(t, "Pilates")
+	Configure(c.ID(), List{
+		Blocked{Members: []string{"Chuck"}},
+		AdvanceWindow{Days: 3},
+	})
+
+	// rules are evaluated in order
+	if err := Check(Booking{c, "Chuck", today.AddDays(10), time.Now(), nil}); code(err) != CodeBlocked {
+		t.Errorf("wrong violation for blocked member, got: %v", err)
+	}
+
+	if err := Check(Booking{c, "Arnold", today.AddDays(10), time.Now(), nil}); code(err) != CodeNotYetOpen {
+		t.Errorf("wrong violation for early booking, got: %v", err)
+	}
+
+	if err := Check(Booking{c, "Arnold", today.AddDays(1), time.Now(), nil}); err != nil {
+		t.Errorf("couldn't book class: %s", err)
+	}
+
+	// removing the rules allows everything
+	Configure(c.ID(), nil)
+	if err := Check(Booking{c, "Chuck", today.AddDays(10), time.Now(), nil}); err != nil {
+		t.Errorf("rules weren't removed: %s", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	unlock := Lock("Arnold")
+
+	// other members can book in the meantime
+	Lock("Emma")()
+
+	locked := make(chan struct{})
+	go func() {
+		defer Lock("Arnold")()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("Arnold's bookings were locked twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatalf("Arnold's bookings weren't unlocked")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	l := List{
+		AdvanceWindow{Days: 3},
+		Cutoff{Before: 2 * time.Hour},
+		WeeklyLimit{Max: 2},
+		Blocked{Members: []string{"Chuck"}},
+	}
+
+	raw, err := encode(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, l) {
+		t.Errorf("got %v, want: %v", decoded, l)
+	}
+
+	if raw, err := encode(nil); err != nil || raw != nil {
+		t.Errorf("no rules encoded as %s (%v)", raw, err)
+	}
+
+	if _, err := encode(List{custom{}}); err == nil {
+		t.Error("no error for a custom rule")
+	}
+	if _, err := decode([]byte(`[{"Kind":"custom","Rule":{}}]`)); err == nil {
+		t.Error("no error for an unknown rule")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	c := getTestCourse(t, "Journaled")
+	l := List{Blocked{Members: []string{"Chuck"}}, WeeklyLimit{Max: 1}}
+	if err := Configure(c.ID(), l); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(c.ID(), List{custom{}}); err == nil {
+		t.Error("no error for a custom rule that can't be journaled")
+	}
+
+	// as after a restart
+	journal.Close()
+	Configure(c.ID(), nil)
+	if err := journal.Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(Of(c.ID()), l) {
+		t.Errorf("got %v, want: %v", Of(c.ID()), l)
+	}
+}
+
+// a custom rule allows everything
+type custom struct{}
+
+func (custom) Check(Booking) error { return nil }
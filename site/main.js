@@ -4,3 +4,40 @@ function jumpToResult() {
 	location.href = '#result-header';
 	history.replaceState(null, null, url);
 }
+
+// live keeps the free places on the courses page up to date as classes are booked and cancelled (see '/v1/events' of the studio in the attribute 'data-live').
+// When courses are added, it reloads the page, unless somebody is filling in a booking form; then it offers to reload.
+function live() {
+	var notice = document.querySelector('[data-live]');
+	if (!notice || !window.EventSource) {
+		return;
+	}
+
+	var source = new EventSource(notice.dataset.live || '/v1/events');
+	var update = function (e) {
+		var d = JSON.parse(e.data);
+		document.querySelectorAll('.free[data-course="' + d.Course + '"]').forEach(function (el) {
+			el.textContent = d.CourseFree;
+		});
+		document.querySelectorAll('.capacity[data-course="' + d.Course + '"]').forEach(function (el) {
+			el.textContent = d.Capacity;
+		});
+	};
+	var reload = function () {
+		if (document.querySelector('input.toggle:checked')) {
+			notice.hidden = false;
+		} else {
+			location.reload();
+		}
+	};
+
+	['class-booked', 'booking-cancelled', 'class-cancelled', 'capacity-changed'].forEach(function (kind) {
+		source.addEventListener(kind, update);
+	});
+	// after a reset, the page may have missed anything
+	['course-added', 'reset'].forEach(function (kind) {
+		source.addEventListener(kind, reload);
+	});
+}
+
+live();
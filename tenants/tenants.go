@@ -0,0 +1,147 @@
+// Package tenants implements studios, which are hosted by the same server but don't share their courses, rooms and members.
+//
+// A request is for the studio selected by, in this order:
+//
+//   - the path prefix, e.g. '/studios/downtown/v1/classes' (see NewContext).
+//   - the subdomain, e.g. 'downtown.example.com/v1/classes'.
+//
+// Requests that select no studio are for the default studio, which is all there is if a single studio is hosted.
+package tenants
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+// PathPrefix is the prefix of the paths of a studio, followed by its ID.
+const PathPrefix = "/studios/"
+
+// A Studio is a tenant of the server.
+type Studio struct {
+	ID    string   `json:"id"` // the subdomain and the path after PathPrefix, e.g. 'downtown'
+	Name  string   `json:"name"`
+	Rooms []string `json:"rooms"` // where courses can be held, anywhere if empty
+
+	// the courses of the studio
+	Courses *courses.Registry `json:"-"`
+}
+
+// Default is the studio of requests that don't select one. Its courses are those of the default registry.
+var Default = Studio{Courses: courses.Default}
+
+// Allows returns whether the principal may act in the studio.
+// Users of a studio may only act in their studio, users that don't belong to a studio in all of them.
+func (s Studio) Allows(p auth.Principal) bool {
+	return p.Studio == "" || p.Studio == s.ID
+}
+
+// Prefix returns the path prefix of the studio, e.g. '/studios/downtown'.
+func (s Studio) Prefix() string {
+	return PathPrefix + s.ID
+}
+
+// an ID can be used as subdomain
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+var (
+	// studios by ID
+	studios map[string]Studio = make(map[string]Studio)
+
+	// protect map with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Add adds a studio. If it has no courses yet, it gets a new registry, named after the studio (see courses.Restore).
+func Add(s Studio) error {
+	if !validID.MatchString(s.ID) {
+		return fmt.Errorf("studio ID '%s' must consist of lower case letters, digits and hyphens", s.ID)
+	}
+	if s.Courses == nil {
+		s.Courses = courses.NewRegistry()
+		s.Courses.Name = s.ID
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if _, ok := studios[s.ID]; ok {
+		return fmt.Errorf("studio '%s' already exists", s.ID)
+	}
+	studios[s.ID] = s
+	return nil
+}
+
+// Get returns the studio with the ID.
+func Get(id string) (Studio, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	s, ok := studios[id]
+	if !ok {
+		return Studio{}, fmt.Errorf("studio '%s' does not exist", id)
+	}
+	return s, nil
+}
+
+// All returns all studios, sorted by ID. The default studio is not one of them.
+func All() []Studio {
+	mux.Lock()
+	defer mux.Unlock()
+
+	ss := make([]Studio, 0, len(studios))
+	for _, s := range studios {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].ID < ss[j].ID })
+	return ss
+}
+
+// the key to store the studio in the context of a request
+type contextKey struct{}
+
+// NewContext returns a copy of the context that carries the studio, e.g. because the request was made to a path of the studio.
+func NewContext(ctx context.Context, s Studio) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// Of returns the studio the request is for: the one stored in its context, otherwise the one whose ID is the subdomain, otherwise the default studio.
+func Of(req *http.Request) Studio {
+	if s, ok := req.Context().Value(contextKey{}).(Studio); ok {
+		return s
+	}
+
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if sub, _, ok := strings.Cut(host, "."); ok {
+		if s, err := Get(strings.ToLower(sub)); err == nil {
+			return s
+		}
+	}
+
+	return Default
+}
+
+// Scope wraps a response function so that it is called with the studio of the request (see Of).
+// Principals that were stored in the context by auth.Protect but don't belong to the studio are forbidden.
+func Scope(respond func(s Studio, req *http.Request) interface{}) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		s := Of(req)
+		if p, ok := auth.FromRequest(req); ok && !s.Allows(p) {
+			return api.NewError(http.StatusForbidden, fmt.Errorf("you don't belong to this studio"))
+		}
+		return respond(s, req)
+	}
+}
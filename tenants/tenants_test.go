@@ -0,0 +1,123 @@
+package tenants
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarkRosemaker/go-server/server/api"
+
+	"github.com/MarkRosemaker/booking-system/auth"
+	"github.com/MarkRosemaker/booking-system/courses"
+)
+
+func TestAdd(t *testing.T) {
+	tables := []struct {
+		id  string
+		err bool
+	}{
+		{"", true},
+		{"Downtown", true},
+		{"down town", true},
+		{"-downtown", true},
+		{"downtown", false},
+		{"downtown", true}, // twice
+		{"east-2", false},
+	}
+
+	for _, table := range tables {
+		if err := Add(Studio{ID: table.id}); (err != nil) != table.err {
+			t.Errorf("wrong result of adding studio %q, got: %v", table.id, err)
+		}
+	}
+
+	s, err := Get("downtown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Courses == nil || s.Courses == courses.Default {
+		t.Errorf("studio didn't get its own registry")
+	}
+	if _, err := Get("nowhere"); err == nil {
+		t.Errorf("got studio that doesn't exist")
+	}
+
+	ids := []string{}
+	for _, s := range All() {
+		ids = append(ids, s.ID)
+	}
+	if len(ids) < 2 || ids[0] > ids[1] {
+		t.Errorf("studios are not sorted by ID: %v", ids)
+	}
+}
+
+func TestOf(t *testing.T) {
+	if err := Add(Studio{ID: "uptown"}); err != nil {
+		t.Fatal(err)
+	}
+	uptown, _ := Get("uptown")
+
+	tables := []struct {
+		host    string
+		context *Studio
+		studio  string
+	}{
+		{"example.com", nil, ""},
+		{"localhost:8080", nil, ""},
+		{"uptown.example.com", nil, "uptown"},
+		{"Uptown.example.com:8080", nil, "uptown"},
+		{"unknown.example.com", nil, ""},
+		// the path prefix wins
+		{"unknown.example.com", &uptown, "uptown"},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = table.host
+		if table.context != nil {
+			req = req.WithContext(NewContext(req.Context(), *table.context))
+		}
+		if s := Of(req); s.ID != table.studio {
+			t.Errorf("wrong studio for %s, got: %q, want: %q", table.host, s.ID, table.studio)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	if err := Add(Studio{ID: "midtown"}); err != nil {
+		t.Fatal(err)
+	}
+
+	respond := Scope(func(s Studio, req *http.Request) interface{} {
+		return api.NewSuccessNow(http.StatusOK, nil, "%s", s.ID)
+	})
+
+	tables := []struct {
+		p      *auth.Principal // nil if not authenticated
+		status int
+	}{
+		{nil, http.StatusOK},
+		{&auth.Principal{Name: "Boss", Role: auth.RoleAdmin}, http.StatusOK},
+		{&auth.Principal{Name: "Arnold", Role: auth.RoleMember, Studio: "midtown"}, http.StatusOK},
+		{&auth.Principal{Name: "Bruce", Role: auth.RoleAdmin, Studio: "uptown"}, http.StatusForbidden},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "midtown.example.com"
+		if table.p != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), *table.p))
+		}
+
+		switch v := respond(req).(type) {
+		case api.Success:
+			if v.Status != table.status || v.Message != "midtown" {
+				t.Errorf("%+v: wrong response, got: %d %s", table.p, v.Status, v.Message)
+			}
+		case api.Error:
+			if v.Status != table.status {
+				t.Errorf("%+v: wrong status, got: %d, want: %d", table.p, v.Status, table.status)
+			}
+		}
+	}
+}
@@ -3,22 +3,35 @@ package tpl
 
 import (
 	"net/http"
+	"net/url"
 	"time"
 
 	"cloud.google.com/go/civil"
 
+	"github.com/MarkRosemaker/booking-system/api/classes"
+	"github.com/MarkRosemaker/booking-system/auth"
 	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/tenants"
 )
 
-// DataFunc returns data to be given to a template, given the request.
+// DataFunc returns data to be given to a template, given the request. The templates list the courses of the studio the request is for (see tenants.Of).
 func DataFunc(req *http.Request) interface{} {
-	return Data{Request: req}
+	s := tenants.Of(req)
+	return Data{Request: req, Courses: Courses{s.Courses}, Studio: s}
+}
+
+// DataFuncFor returns a function that returns data to be given to a template, given the request. The templates list the courses of the registry.
+func DataFuncFor(r *courses.Registry) func(*http.Request) interface{} {
+	return func(req *http.Request) interface{} {
+		return Data{Request: req, Courses: Courses{r}}
+	}
 }
 
 // Data is a struct holding the data we want to pass to a template.
 type Data struct {
 	Request *http.Request
 	Courses Courses
+	Studio  tenants.Studio // e.g. to show its name and rooms, the zero value if the data is not for a studio
 }
 
 // Today returns the current date.
@@ -27,27 +40,81 @@ func (d Data) Today() civil.Date {
 	return civil.DateOf(time.Now())
 }
 
-// Courses is a dummy type to attach methods to.
+// User returns the logged in user. If nobody is logged in, the name is empty.
+func (d Data) User() auth.Principal {
+	p, _ := auth.Authenticate(d.Request)
+	return p
+}
+
+// Prefix returns the path prefix of the studio, e.g. to post a form to {{ .Prefix }}/v1/classes. It is empty for the default studio.
+func (d Data) Prefix() string {
+	if d.Studio.ID == "" {
+		return ""
+	}
+	return d.Studio.Prefix()
+}
+
+// WithParam returns the query of the request with the parameter set to the value, e.g. to link to the next page with {{ .WithParam "cursor" .Next }}.
+func (d Data) WithParam(key, value string) string {
+	var q url.Values
+	if d.Request != nil {
+		q = d.Request.URL.Query()
+	} else {
+		q = url.Values{}
+	}
+	q.Set(key, value)
+	return "?" + q.Encode()
+}
+
+// Courses gives the templates access to the courses of a registry, the default registry if it is nil.
 //
-// This implemenation was chosen so that we can use the intuitive notation {{ .Courses.All }}, {{ .Courses.Past }}, {{ .Courses.Current }}, and {{ .Courses.Upcoming }} in our templates.
-type Courses int
+// This implemenation was chosen so that we can use the intuitive notation {{ .Courses.All }}, {{ .Courses.Past }}, {{ .Courses.Current }}, {{ .Courses.Upcoming }}, and {{ .Courses.Find .Request }} in our templates.
+type Courses struct {
+	registry *courses.Registry
+}
+
+// reg returns the registry of the courses.
+func (c Courses) reg() *courses.Registry {
+	if c.registry == nil {
+		return courses.Default
+	}
+	return c.registry
+}
 
 // All returns all courses to be accessed by the template.
 func (c Courses) All() courses.Courses {
-	return courses.All()
+	return c.reg().All()
 }
 
 // Past returns all past courses to be accessed by the template.
 func (c Courses) Past() courses.Courses {
-	return courses.Past()
+	return c.reg().Past()
 }
 
 // Current returns all current courses to be accessed by the template.
 func (c Courses) Current() courses.Courses {
-	return courses.Current()
+	return c.reg().Current()
 }
 
 // Upcoming returns all upcoming courses to be accessed by the template.
 func (c Courses) Upcoming() courses.Courses {
-	return courses.Upcoming()
+	return c.reg().Upcoming()
+}
+
+// pageSize is the number of courses Find returns if the request doesn't give a 'limit'
+const pageSize = 20
+
+// Find returns the page of courses selected by the parameters of the request (see classes.ListQuery), to be accessed by the template with {{ .Courses.Find .Request }}.
+// Invalid parameters are ignored. If the page can't be found at all, e.g. because the cursor is for another order, the first page of all courses is returned.
+func (c Courses) Find(req *http.Request) courses.Page {
+	q, _ := classes.ListQuery(req)
+	if q.Limit <= 0 || q.Limit > courses.MaxLimit {
+		q.Limit = pageSize
+	}
+
+	page, err := c.reg().Find(q)
+	if err != nil {
+		page, _ = c.reg().Find(courses.Query{Limit: pageSize})
+	}
+	return page
 }
@@ -1,5 +1,182 @@
 package tpl
 
-// empty, included just so we see 'ok' everywhere on the left side when running go test.
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 
-// this package is implicitly tested by running the templates in folder 'site'
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+// Otherwise, this package is implicitly tested by running the templates in folder 'site'.
+
+// TestRenderWhileAdding renders the pages that list courses while courses are being added.
+// Run it with -race to see if the lists of courses change while the templates read them.
+func TestRenderWhileAdding(t *testing.T) {
+	var pages []*template.Template
+	for _, page := range []string{"courses", "create-courses"} {
+		tpl, err := template.New("index.html").Funcs(funcs).ParseFiles(filepath.Join("..", "site", page, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, tpl)
+	}
+
+	today := civil.DateOf(time.Now())
+
+	// render the pages and check the lists of courses a couple of times
+	var readers sync.WaitGroup
+
+	for _, tpl := range pages {
+		readers.Add(1)
+		go func(tpl *template.Template) {
+			defer readers.Done()
+			for i := 0; i < 10; i++ {
+				data := DataFunc(httptest.NewRequest("GET", "/?sort=name&limit=50", nil))
+				if err := tpl.Execute(io.Discard, data); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(tpl)
+	}
+
+	// the lists must stay the same while they are being read
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		var c Courses
+		for i := 0; i < 100; i++ {
+			for name, list := range map[string]func() courses.Courses{
+				"all": c.All, "past": c.Past, "current": c.Current, "upcoming": c.Upcoming,
+			} {
+				cs := list()
+				before := append(courses.Courses(nil), cs...)
+				time.Sleep(time.Microsecond)
+				for i := range cs {
+					if cs[i] != before[i] {
+						t.Errorf("the list of %s courses changed while it was being read", name)
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		readers.Wait()
+		close(done)
+	}()
+
+	// meanwhile, add courses in the past, present and future
+	for i := 0; i < 1000; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		start := today.AddDays(i%30 - 15)
+		c, err := course.NewHistoric(uuid.New().String(), start, start.AddDays(i%5), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := courses.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+func TestDataFuncOfStudio(t *testing.T) {
+	if err := tenants.Add(tenants.Studio{ID: "tpl-test", Name: "Template Test"}); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := tenants.Get("tpl-test")
+
+	today := civil.DateOf(time.Now())
+	c, err := course.New("Studio Only", today, today, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Courses.Add(c); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		host   string
+		studio string
+		prefix string
+		found  bool
+	}{
+		{"tpl-test.example.com", "Template Test", "/studios/tpl-test", true},
+		{"example.com", "", "", false},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = table.host
+		data := DataFunc(req).(Data)
+
+		if data.Studio.Name != table.studio {
+			t.Errorf("%s: wrong studio, got: %q, want: %q", table.host, data.Studio.Name, table.studio)
+		}
+		if data.Prefix() != table.prefix {
+			t.Errorf("%s: wrong prefix, got: %q, want: %q", table.host, data.Prefix(), table.prefix)
+		}
+		found := false
+		for _, listed := range data.Courses.All() {
+			found = found || listed == c
+		}
+		if found != table.found {
+			t.Errorf("%s: course of the studio listed: %t, want: %t", table.host, found, table.found)
+		}
+	}
+}
+
+func TestPages(t *testing.T) {
+	if err := tenants.Add(tenants.Studio{ID: "tpl-pages", Name: "Pages Test"}); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := tenants.Get("tpl-pages")
+	pages := Pages(filepath.Join("..", "site"))
+
+	tables := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/create-courses", http.StatusOK, `action="/studios/tpl-pages/v1/classes"`},
+		{"/courses", http.StatusOK, `data-live="/studios/tpl-pages/v1/events"`},
+		{"/main.css", http.StatusOK, ""},
+		{"/no-such-page", http.StatusNotFound, ""},
+		{"/../main.go", http.StatusNotFound, ""},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = table.path
+		req = req.WithContext(tenants.NewContext(req.Context(), s))
+		w := httptest.NewRecorder()
+		pages.ServeHTTP(w, req)
+
+		if w.Code != table.status {
+			t.Errorf("%s: wrong status, got: %d, want: %d", table.path, w.Code, table.status)
+		}
+		if !strings.Contains(w.Body.String(), table.want) {
+			t.Errorf("%s: %q not found in:\n%s", table.path, table.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tpl
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// funcs are the template functions of go-server that the pages use.
+var funcs = template.FuncMap{
+	"dateFormat": func(layout string, d civil.Date) string {
+		return d.In(time.UTC).Format(layout)
+	},
+}
+
+// Pages returns a handler that serves the pages in the folder like go-server serves its content source, e.g. below the path prefix of a studio, where go-server doesn't serve them.
+// A path like '/courses' is served by the template 'courses/index.html', executed with the data of DataFunc; other files are served as they are.
+// Paths without a page are served by the template '404.html'.
+func Pages(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+req.URL.Path)))
+		info, err := os.Stat(name)
+		switch {
+		case err == nil && !info.IsDir():
+			files.ServeHTTP(w, req)
+			return
+		case err == nil:
+			name = filepath.Join(name, "index.html")
+			if _, err = os.Stat(name); err == nil {
+				render(w, req, name, http.StatusOK)
+				return
+			}
+		}
+		render(w, req, filepath.Join(dir, "404.html"), http.StatusNotFound)
+	})
+}
+
+// render executes the template in the file with the data of DataFunc and writes it with the status.
+// If that fails, nothing but the error status is written.
+func render(w http.ResponseWriter, req *http.Request, file string, status int) {
+	t, err := template.New(filepath.Base(file)).Funcs(funcs).ParseFiles(file)
+	if err != nil {
+		log.Printf("page %s: %s", file, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, DataFunc(req)); err != nil {
+		log.Printf("page %s: %s", file, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = b.WriteTo(w)
+}
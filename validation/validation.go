@@ -0,0 +1,83 @@
+// Package validation describes problems with input field by field, so that all of them can be reported at once.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Code tells clients what kind of problem a field has, without them having to parse the message.
+type Code string
+
+// the codes
+const (
+	Required  Code = "required"  // the field is missing
+	Malformed Code = "malformed" // the value can't be parsed, e.g. a date that isn't a date
+	Invalid   Code = "invalid"   // the value was parsed but doesn't make sense, e.g. a negative capacity
+	Unknown   Code = "unknown"   // the field is not known
+)
+
+// An Error is a problem with a single field of the input.
+type Error struct {
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"` // for humans
+}
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Errors lists all problems with the input, in the order they were found.
+type Errors []Error
+
+// Error implements the error interface.
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add adds a problem with the field.
+func (es *Errors) Add(field string, code Code, format string, a ...interface{}) {
+	*es = append(*es, Error{field, code, fmt.Sprintf(format, a...)})
+}
+
+// Append adds the problems described by the error.
+// If it is neither an Error nor Errors, it is added as an invalid value of the field.
+func (es *Errors) Append(field string, err error) {
+	var (
+		e   Error
+		all Errors
+	)
+	switch {
+	case errors.As(err, &all):
+		*es = append(*es, all...)
+	case errors.As(err, &e):
+		*es = append(*es, e)
+	default:
+		*es = append(*es, Error{field, Invalid, err.Error()})
+	}
+}
+
+// Has returns whether the field has a problem.
+func (es Errors) Has(field string) bool {
+	for _, e := range es {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the problems as an error or nil, if there are none.
+func (es Errors) Err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
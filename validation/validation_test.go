@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	if errs.Err() != nil {
+		t.Fatalf("no problems should be no error")
+	}
+
+	errs.Add("name", Required, "name value not provided")
+	errs.Append("capacity", Error{"capacity", Invalid, "capacity (0) must be positive"})
+	errs.Append("start", Errors{{"start", Malformed, "start value 'now' could not be parsed to date"}})
+	errs.Append("end", fmt.Errorf("end is too late"))
+
+	if s := errs.Err().Error(); s != "name value not provided; capacity (0) must be positive; start value 'now' could not be parsed to date; end is too late" {
+		t.Errorf("wrong message: %s", s)
+	}
+
+	if !errs.Has("start") || errs.Has("price") {
+		t.Errorf("wrong fields with problems: %v", errs)
+	}
+
+	var found Errors
+	if !errors.As(fmt.Errorf("wrapped: %w", errs.Err()), &found) || len(found) != 4 {
+		t.Errorf("problems can't be found in wrapped error")
+	}
+
+	b, err := json.Marshal(errs[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"field":"end","code":"invalid","message":"end is too late"}` {
+		t.Errorf("wrong JSON: %s", s)
+	}
+}
@@ -0,0 +1,268 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/tenants"
+)
+
+// A Payload is what a subscription gets about an event, as JSON.
+type Payload struct {
+	ID         string // of the event, the same in every attempt, so that receivers can skip duplicates
+	Type       bus.Kind
+	Time       time.Time
+	Studio     string // the ID of the studio, empty for the default studio
+	Course     Course
+	Date       string `json:",omitempty"` // of the class, empty for events of the whole course
+	Member     string `json:",omitempty"` // who booked or cancelled
+	ClassFree  int    // the free places of the class, zero for events of the whole course
+	CourseFree int    // see course.Course.FreePlaces
+}
+
+// Course is what a payload tells about the course of the event.
+type Course struct {
+	ID       uint64
+	Name     string
+	Start    civil.Date
+	End      civil.Date
+	Capacity int
+}
+
+// A DeadLetter is a delivery that failed every attempt.
+type DeadLetter struct {
+	Subscription uint64
+	URL          string
+	Payload      Payload
+	Attempts     int
+	Error        string    // why the last attempt failed
+	Time         time.Time // of the last attempt
+}
+
+// the headers of a delivery, besides the signature
+const (
+	EventHeader   = "X-Webhook-Event"   // the type of the event, e.g. 'class-booked'
+	IDHeader      = "X-Webhook-ID"      // the ID of the event
+	AttemptHeader = "X-Webhook-Attempt" // 1 for the first attempt
+)
+
+// Client sends the deliveries. An attempt fails if the receiver takes longer than its timeout.
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+// Attempts is how often a delivery is tried before it becomes a dead letter.
+var Attempts = 6
+
+// Backoff is how long to wait before the second attempt. The wait doubles with every attempt, i.e. 1s, 2s, 4s, 8s and 16s.
+var Backoff = time.Second
+
+// MaxDeadLetters is how many dead letters are kept. When there are more, the oldest are dropped.
+const MaxDeadLetters = 1000
+
+var (
+	// the dead letters, oldest first
+	deadLetters []DeadLetter = make([]DeadLetter, 0)
+
+	// protect list with mutex
+	deadMux *sync.Mutex = &sync.Mutex{}
+)
+
+// DeadLetters returns the dead letters of the studio, oldest first.
+func DeadLetters(studio string) []DeadLetter {
+	deadMux.Lock()
+	defer deadMux.Unlock()
+
+	ds := make([]DeadLetter, 0)
+	for _, d := range deadLetters {
+		if d.Payload.Studio == studio {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// bury adds a dead letter.
+func bury(d DeadLetter) {
+	deadMux.Lock()
+	defer deadMux.Unlock()
+
+	if len(deadLetters) == MaxDeadLetters {
+		deadLetters = append(deadLetters[:0], deadLetters[1:]...)
+	}
+	deadLetters = append(deadLetters, d)
+}
+
+// Start delivers the events of the bus to the subscriptions until the returned function is called.
+// Stopping cancels the deliveries under way, which become dead letters, and waits for them.
+func Start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	// subscribe right away, so that no event after starting is missed
+	events, unsubscribe := bus.Subscribe()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dispatch(ctx, wg, events, unsubscribe)
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// dispatch notifies the subscriptions of each event of the bus until the context is done.
+func dispatch(ctx context.Context, wg *sync.WaitGroup, events <-chan bus.Event, unsubscribe func()) {
+	var last uint64 // the ID of the last event, to catch up after falling behind
+
+	for {
+		if last != 0 {
+			missed, _ := bus.Since(last)
+			for _, e := range missed {
+				last = e.ID
+				notify(ctx, wg, e)
+			}
+		}
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			case e, ok := <-events:
+				if !ok {
+					// fell behind, subscribe again and catch up
+					events, unsubscribe = bus.Subscribe()
+					break receive
+				}
+				if e.ID <= last {
+					continue
+				}
+				last = e.ID
+				notify(ctx, wg, e)
+			}
+		}
+	}
+}
+
+// notify starts the deliveries of the event to the subscriptions of the studio of its course that get it.
+func notify(ctx context.Context, wg *sync.WaitGroup, e bus.Event) {
+	s, c, ok := studioOf(e.Course)
+	if !ok {
+		return
+	}
+
+	p := Payload{
+		ID:         strconv.FormatUint(e.ID, 10),
+		Type:       e.Kind,
+		Time:       e.Time,
+		Studio:     s.ID,
+		Course:     Course{c.ID(), c.Name(), c.Start(), c.End(), c.Capacity()},
+		Member:     e.Member,
+		ClassFree:  e.ClassFree,
+		CourseFree: e.CourseFree,
+	}
+	if e.Date.IsValid() {
+		p.Date = e.Date.String()
+	}
+
+	for _, sub := range Of(s.ID) {
+		if !sub.gets(e.Kind) {
+			continue
+		}
+		wg.Add(1)
+		go func(sub Subscription) {
+			defer wg.Done()
+			deliver(ctx, sub, p)
+		}(sub)
+	}
+}
+
+// studioOf returns the studio the course with the ID belongs to, and the course.
+func studioOf(id uint64) (tenants.Studio, *course.Course, bool) {
+	for _, s := range append([]tenants.Studio{tenants.Default}, tenants.All()...) {
+		if c, err := s.Courses.Get(id); err == nil {
+			return s, c, true
+		}
+	}
+	return tenants.Studio{}, nil, false
+}
+
+// deliver sends the payload to the subscription, trying again with exponential backoff until it succeeds or becomes a dead letter.
+func deliver(ctx context.Context, sub Subscription, p Payload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("webhook %d: %s", sub.ID, err)
+		return
+	}
+
+	wait, attempts := Backoff, 0
+	for {
+		attempts++
+		if err = send(ctx, sub, body, p.Type, p.ID, attempts); err == nil {
+			return
+		}
+		if attempts == Attempts {
+			break
+		}
+		if !sleep(ctx, wait) {
+			err = fmt.Errorf("stopped, the last attempt failed: %s", err)
+			break
+		}
+		wait *= 2
+	}
+
+	log.Printf("webhook %d: giving up on event %s after %d attempts: %s", sub.ID, p.ID, attempts, err)
+	bury(DeadLetter{Subscription: sub.ID, URL: sub.URL, Payload: p, Attempts: attempts, Error: err.Error(), Time: time.Now()})
+}
+
+// sleep waits for the duration and returns true, or returns false as soon as the context is done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// send makes one attempt to deliver the payload. Any response but 2xx is a failure.
+func send(ctx context.Context, sub Subscription, body []byte, kind bus.Kind, id string, attempt int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(kind))
+	req.Header.Set(IDHeader, id)
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), body))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16)) // so that the connection can be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the receiver responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package receiver implements a receiver of webhooks that records the deliveries it gets, for integration tests and local development.
+//
+// It checks the signature of every delivery (see webhooks.Verify) and can be told to fail, to test retries and dead letters:
+//
+//	r := receiver.New(sub.Secret)
+//	srv := httptest.NewServer(r)
+//	defer srv.Close()
+//	r.FailNext(2) // answered with 503 Service Unavailable, so the third attempt succeeds
+package receiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/webhooks"
+)
+
+// Tolerance is how old the signature of a delivery may be.
+const Tolerance = 5 * time.Minute
+
+// A Delivery is a payload the receiver got.
+type Delivery struct {
+	Payload webhooks.Payload
+	Attempt int // see webhooks.AttemptHeader
+	Header  http.Header
+}
+
+// Receiver is an http.Handler that receives webhooks. Deliveries that aren't signed with its secret are answered with 401 Unauthorized.
+type Receiver struct {
+	secret     string
+	failures   int // how many of the next deliveries fail
+	deliveries []Delivery
+
+	// protect failures and deliveries with mutex
+	mux sync.Mutex
+}
+
+// New returns a receiver of webhooks signed with the secret.
+func New(secret string) *Receiver {
+	return &Receiver{secret: secret, deliveries: make([]Delivery, 0)}
+}
+
+// FailNext makes the receiver answer the next n deliveries with 503 Service Unavailable, without recording them.
+func (r *Receiver) FailNext(n int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.failures = n
+}
+
+// Deliveries returns the deliveries the receiver got, in the order it got them.
+func (r *Receiver) Deliveries() []Delivery {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return append([]Delivery{}, r.deliveries...)
+}
+
+// Wait returns the deliveries as soon as there are at least n, or an error if that takes longer than the timeout.
+func (r *Receiver) Wait(n int, timeout time.Duration) ([]Delivery, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ds := r.Deliveries(); len(ds) >= n {
+			return ds, nil
+		} else if time.Now().After(deadline) {
+			return ds, fmt.Errorf("got %d deliveries in %s, want: %d", len(ds), timeout, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := webhooks.Verify(r.secret, req.Header.Get(webhooks.SignatureHeader), body, Tolerance); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var d Delivery
+	if err := json.Unmarshal(body, &d.Payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.Attempt, _ = strconv.Atoi(req.Header.Get(webhooks.AttemptHeader))
+	d.Header = req.Header.Clone()
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "failing as told", http.StatusServiceUnavailable)
+		return
+	}
+	r.deliveries = append(r.deliveries, d)
+	w.WriteHeader(http.StatusNoContent)
+}
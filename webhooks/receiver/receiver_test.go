@@ -0,0 +1,100 @@
+package receiver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+	"github.com/MarkRosemaker/booking-system/course"
+	"github.com/MarkRosemaker/booking-system/courses"
+	"github.com/MarkRosemaker/booking-system/webhooks"
+)
+
+// TestReceive sends webhooks of the default studio from the bus to a receiver.
+func TestReceive(t *testing.T) {
+	defer func(b time.Duration) { webhooks.Backoff = b }(webhooks.Backoff)
+	webhooks.Backoff = time.Millisecond
+
+	stop := webhooks.Start()
+	defer stop()
+
+	r := New("secret")
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if _, err := webhooks.Subscribe(webhooks.Subscription{URL: srv.URL, Kinds: []bus.Kind{bus.CourseAdded, bus.ClassBooked}, Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	today := civil.DateOf(time.Now())
+	c, err := course.New("Webhook Yoga", today, today.AddDays(2), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := courses.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := r.Wait(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := ds[0].Payload; p.Type != bus.CourseAdded || p.Course.ID != c.ID() || p.Course.Name != "Webhook Yoga" || p.Studio != "" {
+		t.Errorf("wrong payload: %v", p)
+	}
+
+	// the third attempt succeeds
+	r.FailNext(2)
+	if err := c.BookClass("Arnold", today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	// not subscribed to
+	if err := c.CancelBooking("Arnold", today.AddDays(1)); err != nil {
+		t.Fatal(err)
+	}
+	ds, err = r.Wait(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := ds[1]; d.Payload.Type != bus.ClassBooked || d.Payload.Member != "Arnold" || d.Payload.Date != today.AddDays(1).String() || d.Attempt != 3 ||
+		d.Header.Get(webhooks.EventHeader) != string(bus.ClassBooked) || d.Header.Get(webhooks.IDHeader) != d.Payload.ID {
+		t.Errorf("wrong delivery: %v", d)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if ds := r.Deliveries(); len(ds) != 2 {
+		t.Errorf("got %d deliveries, want: 2", len(ds))
+	}
+}
+
+func TestUnsigned(t *testing.T) {
+	r := New("secret")
+	body := []byte(`{"ID":"1"}`)
+
+	tables := []struct {
+		signature string
+		status    int
+	}{
+		{webhooks.Sign("secret", time.Now(), body), http.StatusNoContent},
+		{webhooks.Sign("other", time.Now(), body), http.StatusUnauthorized},
+		{webhooks.Sign("secret", time.Now().Add(-time.Hour), body), http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+
+	for _, table := range tables {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(webhooks.SignatureHeader, table.signature)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != table.status {
+			t.Errorf("%q: got status %d, want: %d", table.signature, w.Code, table.status)
+		}
+	}
+	if ds := r.Deliveries(); len(ds) != 1 {
+		t.Errorf("got %d deliveries, want: 1", len(ds))
+	}
+}
@@ -0,0 +1,182 @@
+// Package webhooks notifies other systems, e.g. a CRM, of what happens to bookings and courses by sending HTTP requests to the URLs they subscribed.
+//
+// The events come from the bus (see package bus). Each is sent as a JSON Payload, signed with the secret of the subscription (see Sign).
+// Failed deliveries are retried with exponential backoff; those that fail every time end up in the dead letters (see DeadLetters).
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+)
+
+// Kinds are the kinds of events that can be subscribed to.
+var Kinds = []bus.Kind{bus.CourseAdded, bus.ClassBooked, bus.BookingCancelled, bus.ClassCancelled, bus.CapacityChanged, bus.ClassReminder}
+
+// A Subscription is a URL that gets events of a studio.
+type Subscription struct {
+	ID     uint64
+	URL    string
+	Kinds  []bus.Kind // the kinds of events it gets, all if empty
+	Studio string     // the ID of the studio whose events it gets, empty for the default studio
+	Secret string     `json:"-"` // signs the payloads, shown only when subscribing
+}
+
+// gets returns whether the subscription gets events of the kind.
+func (s Subscription) gets(k bus.Kind) bool {
+	if len(s.Kinds) == 0 {
+		return true
+	}
+	for _, kind := range s.Kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// all subscriptions, by ID
+	subscriptions map[uint64]Subscription = make(map[uint64]Subscription)
+	// the ID of the last subscription
+	lastID uint64
+
+	// protect map and ID with mutex
+	mux *sync.Mutex = &sync.Mutex{}
+)
+
+// Subscribe adds a subscription and returns it with its ID and, if it had none, a new secret.
+func Subscribe(s Subscription) (Subscription, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, fmt.Errorf("invalid subscription parameters: url '%s' must be an absolute HTTP or HTTPS URL", s.URL)
+	}
+	for _, k := range s.Kinds {
+		if !known(k) {
+			return Subscription{}, fmt.Errorf("invalid subscription parameters: event '%s' does not exist", k)
+		}
+	}
+
+	if s.Secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return Subscription{}, err
+		}
+		s.Secret = "whsec_" + hex.EncodeToString(b)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	lastID++
+	s.ID = lastID
+	subscriptions[s.ID] = s
+	return s, nil
+}
+
+// Unsubscribe removes the subscription with the ID from the studio.
+func Unsubscribe(studio string, id uint64) error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if s, ok := subscriptions[id]; !ok || s.Studio != studio {
+		return fmt.Errorf("subscription %d does not exist", id)
+	}
+	delete(subscriptions, id)
+	return nil
+}
+
+// Of returns the subscriptions of the studio, sorted by ID.
+func Of(studio string) []Subscription {
+	mux.Lock()
+	defer mux.Unlock()
+
+	ss := make([]Subscription, 0)
+	for _, s := range subscriptions {
+		if s.Studio == studio {
+			ss = append(ss, s)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].ID < ss[j].ID })
+	return ss
+}
+
+// ParseKinds returns the kinds of events in the comma-separated list, e.g. 'class-booked,booking-cancelled'.
+func ParseKinds(list string) ([]bus.Kind, error) {
+	kinds := make([]bus.Kind, 0)
+	for _, k := range strings.Split(list, ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		if !known(bus.Kind(k)) {
+			return nil, fmt.Errorf("event '%s' does not exist", k)
+		}
+		kinds = append(kinds, bus.Kind(k))
+	}
+	return kinds, nil
+}
+
+// known returns whether the kind can be subscribed to.
+func known(k bus.Kind) bool {
+	for _, kind := range Kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// SignatureHeader is the header of a delivery that holds its signature (see Sign).
+const SignatureHeader = "X-Webhook-Signature"
+
+// Sign returns the signature of the payload sent at the time, e.g. 't=1609459200,v1=5257a869...'.
+// It is the HMAC-SHA256 of the time in Unix seconds, a dot and the payload, with the secret as key, in hex.
+// Signing the time, too, lets receivers reject deliveries that are replayed later.
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, mac(secret, ts, payload))
+}
+
+// Verify returns an error if the signature (see Sign) doesn't belong to the payload or is older than the tolerance, if it isn't zero.
+func Verify(secret, signature string, payload []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("the signature '%s' is malformed", signature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, payload))) {
+		return fmt.Errorf("the signature does not match the payload")
+	}
+	if tolerance != 0 && time.Since(time.Unix(sec, 0)) > tolerance {
+		return fmt.Errorf("the signature is too old")
+	}
+	return nil
+}
+
+// mac returns the HMAC of the time stamp and payload in hex.
+func mac(secret, ts string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts + "."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
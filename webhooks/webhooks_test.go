@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MarkRosemaker/booking-system/bus"
+)
+
+func TestSubscribe(t *testing.T) {
+	tables := []struct {
+		s  Subscription
+		ok bool
+	}{
+		{Subscription{URL: "https://crm.example.com/hooks"}, true},
+		{Subscription{URL: "http://localhost:9000", Kinds: []bus.Kind{bus.ClassBooked}, Studio: "downtown"}, true},
+		{Subscription{URL: "/hooks"}, false},
+		{Subscription{URL: "ftp://example.com"}, false},
+		{Subscription{URL: "https://crm.example.com", Kinds: []bus.Kind{"class-sold"}}, false},
+	}
+
+	for _, table := range tables {
+		s, err := Subscribe(table.s)
+		if (err == nil) != table.ok {
+			t.Errorf("%v: got error %v, want ok: %v", table.s, err, table.ok)
+			continue
+		}
+		if table.ok && (s.ID == 0 || !strings.HasPrefix(s.Secret, "whsec_")) {
+			t.Errorf("%v: no ID or secret: %v", table.s, s)
+		}
+	}
+
+	s, err := Subscribe(Subscription{URL: "https://crm.example.com/hooks", Studio: "uptown", Secret: "mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss := Of("uptown"); len(ss) != 1 || ss[0].ID != s.ID || ss[0].Secret != "mine" {
+		t.Errorf("wrong subscriptions of the studio: %v", ss)
+	}
+	if err := Unsubscribe("downtown", s.ID); err == nil {
+		t.Errorf("could unsubscribe from another studio")
+	}
+	if err := Unsubscribe("uptown", s.ID); err != nil {
+		t.Error(err)
+	}
+	if ss := Of("uptown"); len(ss) != 0 {
+		t.Errorf("subscription wasn't removed: %v", ss)
+	}
+}
+
+func TestParseKinds(t *testing.T) {
+	kinds, err := ParseKinds(" class-booked, booking-cancelled,")
+	if err != nil || len(kinds) != 2 || kinds[0] != bus.ClassBooked || kinds[1] != bus.BookingCancelled {
+		t.Errorf("wrong kinds: %v, %v", kinds, err)
+	}
+	if _, err := ParseKinds("class-booked,class-sold"); err == nil {
+		t.Errorf("could parse an unknown kind")
+	}
+}
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"ID":"1"}`)
+	now := time.Now()
+	sig := Sign("secret", now, payload)
+
+	if err := Verify("secret", sig, payload, time.Minute); err != nil {
+		t.Errorf("valid signature: %s", err)
+	}
+	if err := Verify("other", sig, payload, 0); err == nil {
+		t.Errorf("signature is valid with another secret")
+	}
+	if err := Verify("secret", sig, []byte(`{"ID":"2"}`), 0); err == nil {
+		t.Errorf("signature is valid for another payload")
+	}
+	if err := Verify("secret", Sign("secret", now.Add(-time.Hour), payload), payload, time.Minute); err == nil {
+		t.Errorf("an old signature is valid")
+	}
+	if err := Verify("secret", "v1=abc", payload, 0); err == nil {
+		t.Errorf("a malformed signature is valid")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	defer func(b time.Duration) { Backoff = b }(Backoff)
+	Backoff = time.Millisecond
+
+	var (
+		mux      sync.Mutex
+		failures = 2
+		attempts []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if err := Verify("secret", req.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("delivery isn't signed: %s", err)
+		}
+
+		mux.Lock()
+		defer mux.Unlock()
+		attempts = append(attempts, req.Header.Get(AttemptHeader))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	sub := Subscription{ID: 1, URL: srv.URL, Secret: "secret", Studio: "deliver-test"}
+	p := Payload{ID: "7", Type: bus.ClassBooked, Studio: "deliver-test", Member: "Arnold"}
+
+	// succeeds in the third attempt
+	deliver(context.Background(), sub, p)
+	if strings.Join(attempts, ",") != "1,2,3" {
+		t.Errorf("wrong attempts: %v", attempts)
+	}
+	if ds := DeadLetters("deliver-test"); len(ds) != 0 {
+		t.Errorf("delivery became a dead letter: %v", ds)
+	}
+
+	// fails every attempt
+	attempts, failures = nil, Attempts
+	deliver(context.Background(), sub, p)
+	if len(attempts) != Attempts {
+		t.Errorf("got %d attempts, want: %d", len(attempts), Attempts)
+	}
+	ds := DeadLetters("deliver-test")
+	if len(ds) != 1 || ds[0].Payload.ID != "7" || ds[0].Attempts != Attempts || !strings.Contains(ds[0].Error, "500") {
+		t.Errorf("wrong dead letters: %v", ds)
+	}
+	if len(DeadLetters("")) != 0 {
+		t.Errorf("dead letter of another studio")
+	}
+
+	// stopped while waiting for the next attempt
+	Backoff = time.Hour
+	failures = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	deliver(ctx, sub, p)
+	if ds := DeadLetters("deliver-test"); len(ds) != 2 || !strings.Contains(ds[1].Error, "stopped") {
+		t.Errorf("wrong dead letters after stopping: %v", ds)
+	}
+}